@@ -1,13 +1,98 @@
 use crate::args::{ArgumentResolver, ALIAS_ARGS_NAME};
+use crate::cli::find_command_by_path;
 use crate::config::RawCommandConfigVariant::Shorthand;
-use crate::config::{ActionConfig, AliasActionConfig, ExecutionConfigVariant};
-use crate::exec::{CommandExecutor, ExecutionError, ExitStatus};
-use crate::variables::{substitute_variables, VariableMap};
+use crate::config::{
+    ActionConfig, AliasActionConfig, CallsActionConfig, CommandConfigMap, ExecutionConfigVariant,
+    HookConfigVariant, HooksConfig, StepConfig, StepsActionConfig,
+};
+use crate::exec::{
+    strip_ansi_escapes, CommandExecutor, ExecutionError, ExecutionResult, ExitStatus, Output,
+};
+use crate::plan;
+use crate::plan::PlanError;
+use crate::redact::Redactor;
+use crate::runner::{CommandRunner, RunError};
+use crate::spinner::Spinner;
+use crate::umask::{UmaskError, UmaskGuard};
+use crate::variables::{find_variable_references, substitute_variables, VariableMap};
+use colored::Colorize;
+use std::io::Write;
+use std::time::{Duration, Instant, SystemTime, UNIX_EPOCH};
+use std::{io, thread};
 use thiserror::Error;
 
+/// The name of the variable containing the outcome of the action (`success` or `failure`),
+/// available to notification commands.
+const NOTIFY_STATUS_VARIABLE_NAME: &str = "status";
+
+/// The name of the variable containing the exit code of the action, available to notification
+/// commands.
+const NOTIFY_EXIT_CODE_VARIABLE_NAME: &str = "exit_code";
+
 pub struct ActionExecutor {
     pub command_executor: Box<dyn CommandExecutor>,
     pub arg_resolver: Box<dyn ArgumentResolver>,
+
+    /// The [`CommandRunner`] used to run the dependencies of the action, and the commands called
+    /// by a [`CallsActionConfig`].
+    pub command_runner: Box<dyn CommandRunner>,
+
+    /// The top-level commands, used to find the dependencies of the action.
+    pub commands: CommandConfigMap,
+
+    /// The paths of the commands which led to this action, ending with the command being
+    /// executed, with subcommands separated by spaces.
+    /// Calls back to any of these commands are reported as a cycle rather than executing them again.
+    pub call_stack: Vec<String>,
+
+    /// Whether a breakdown of how long each step took should be printed to stderr.
+    pub print_timings: bool,
+
+    /// An optional message to show alongside a spinner while each step is executed.
+    /// When specified, the output of each step is hidden unless the step fails.
+    pub spinner: Option<String>,
+
+    /// An optional [`Redactor`] for text that should be redacted from the JSON log, and from the
+    /// output of failed steps when their output has been hidden by the spinner.
+    pub redactor: Option<Redactor>,
+
+    /// An optional octal umask to apply while the action is executed.
+    pub umask: Option<String>,
+
+    /// The hooks to execute before the action. If any of them fail, the action isn't executed.
+    pub before: Vec<HookConfigVariant>,
+
+    /// The hooks to execute once the action has succeeded, or regardless of whether it succeeded
+    /// for hooks with `always` set.
+    pub after: Vec<HookConfigVariant>,
+
+    /// The paths of the commands to run before the action, along with their own dependencies.
+    /// Each command is run in full, with its own variables and settings.
+    pub depends_on: Vec<String>,
+
+    /// The [`LogFormat`] to use when logging each step.
+    pub log_format: LogFormat,
+}
+
+/// The format to log the steps of an action in.
+#[derive(PartialEq, Debug, Clone)]
+pub enum LogFormat {
+    /// Nothing is logged, other than the output of the commands themselves.
+    Text,
+
+    /// A JSON object is written to stderr for each step once it has finished.
+    Json,
+}
+
+/// How long a single step of an action took to execute.
+pub struct StepTiming {
+    /// The command text for the step.
+    pub step: String,
+
+    pub duration: Duration,
+
+    /// The [`ExitStatus`] of the step, or `None` if the step could not be executed.
+    pub status: Option<ExitStatus>,
 }
 
 impl ActionExecutor {
@@ -16,6 +101,129 @@ impl ActionExecutor {
         &self,
         action_config: &ActionConfig,
         variables: &VariableMap,
+    ) -> Result<(), ActionError> {
+        // The umask is inherited by the commands, and restored once the guard is dropped
+        let _umask_guard = match &self.umask {
+            Some(umask) => Some(UmaskGuard::set(umask).map_err(|err| ActionError::Umask(err))?),
+            None => None,
+        };
+
+        let result = self
+            .execute_dependencies()
+            .and_then(|_| self.execute_before_hooks(variables))
+            .and_then(|_| self.execute_action(action_config, variables));
+
+        return self.execute_after_hooks(variables, result);
+    }
+
+    /// Runs the commands that the action depends on, with the dependencies of each command being
+    /// run before it.
+    fn execute_dependencies(&self) -> Result<(), ActionError> {
+        for command_path in dependency_order(&self.depends_on, &self.commands) {
+            let mut call_stack = self.call_stack.clone();
+            call_stack.push(command_path.clone());
+
+            // The dependencies of each command are already part of the order
+            self.command_runner
+                .run(&command_path, &call_stack, false)
+                .map_err(|err| ActionError::Dependency {
+                    name: command_path,
+                    source: Box::new(err),
+                })?;
+        }
+
+        return Ok(());
+    }
+
+    fn execute_before_hooks(&self, variables: &VariableMap) -> Result<(), ActionError> {
+        for hook in &self.before {
+            self.execute_hook(hook, variables)?;
+        }
+
+        return Ok(());
+    }
+
+    /// Executes the `after` hooks, following the provided result of the action.
+    /// Once the action or a hook has failed, only the hooks with `always` set are executed, and
+    /// the first failure is returned.
+    fn execute_after_hooks(
+        &self,
+        variables: &VariableMap,
+        mut result: Result<(), ActionError>,
+    ) -> Result<(), ActionError> {
+        for hook in &self.after {
+            if result.is_err() && !hook.always() {
+                continue;
+            }
+
+            let hook_result = self.execute_hook(hook, variables);
+            if result.is_ok() {
+                result = hook_result;
+            }
+        }
+
+        return result;
+    }
+
+    fn execute_hook(
+        &self,
+        hook: &HookConfigVariant,
+        variables: &VariableMap,
+    ) -> Result<(), ActionError> {
+        let execution_config = hook.execution();
+        let status = self
+            .command_executor
+            .execute(&execution_config, variables)
+            .map_err(|err| ActionError::Hook {
+                hook: execution_config.command_template(),
+                source: err,
+            })?;
+
+        return match status {
+            ExitStatus::Success => Ok(()),
+            _ => Err(ActionError::HookStatusCode {
+                hook: execution_config.command_template(),
+                status,
+            }),
+        };
+    }
+
+    /// Runs the provided notification command once an action has finished.
+    /// The outcome of the action is provided to the command via the `status` and `exit_code`
+    /// variables.
+    /// Notifications are best-effort, so any failures are printed rather than returned.
+    pub fn notify(
+        &self,
+        notify_config: &ExecutionConfigVariant,
+        variables: &VariableMap,
+        result: &Result<(), ActionError>,
+    ) {
+        let (status, exit_code) = match result {
+            Ok(()) => ("success", 0),
+            Err(err) => ("failure", err.exit_code()),
+        };
+
+        let mut notify_variables = variables.clone();
+        notify_variables.insert(NOTIFY_STATUS_VARIABLE_NAME.to_string(), status.to_string());
+        notify_variables.insert(
+            NOTIFY_EXIT_CODE_VARIABLE_NAME.to_string(),
+            exit_code.to_string(),
+        );
+
+        match self
+            .command_executor
+            .execute(notify_config, &notify_variables)
+        {
+            Ok(ExitStatus::Success) => {}
+            Ok(status) => eprintln!("notification command failed: {status}"),
+            Err(err) => eprintln!("failed to run notification command: {err}"),
+        }
+    }
+
+    fn execute_action(
+        &self,
+        action_config: &ActionConfig,
+        variables: &VariableMap,
     ) -> Result<(), ActionError> {
         match action_config {
             ActionConfig::SingleStep(single_command_action) => {
@@ -27,35 +235,257 @@ impl ActionExecutor {
             }
 
             ActionConfig::Alias(alias_action) => self.execute_alias(alias_action, variables),
+
+            ActionConfig::Calls(calls_action) => self.execute_calls(calls_action),
+
+            ActionConfig::Steps(steps_action) => {
+                let stages =
+                    plan::plan_steps(&steps_action.steps).map_err(|err| ActionError::Plan(err))?;
+
+                let mut variables = variables.clone();
+                let mut timings = Vec::new();
+                let result =
+                    self.execute_stages(&stages, steps_action, &mut variables, &mut timings);
+
+                if self.print_timings {
+                    eprint!("{}", format_timings(&timings));
+                }
+
+                result
+            }
+        }
+    }
+
+    fn execute_calls(&self, calls_action_config: &CallsActionConfig) -> Result<(), ActionError> {
+        let mut call_stack = self.call_stack.clone();
+        let mut first_error = None;
+        for command_path in &calls_action_config.calls {
+            // Bail out if we've already called this command, otherwise we'd never stop
+            if call_stack.contains(command_path) {
+                let mut cycle = call_stack.clone();
+                cycle.push(command_path.clone());
+                return Err(ActionError::CallCycle { cycle });
+            }
+
+            // Each called command is run in full, with its own variables and settings
+            call_stack.push(command_path.clone());
+            let result = self
+                .command_runner
+                .run(command_path, &call_stack, true)
+                .map_err(|err| ActionError::Call {
+                    name: command_path.clone(),
+                    source: Box::new(err),
+                });
+            call_stack.pop();
+
+            if let Err(err) = result {
+                if !calls_action_config.continue_on_error {
+                    return Err(err);
+                }
+
+                first_error.get_or_insert(err);
+            }
         }
+
+        return match first_error {
+            Some(err) => Err(err),
+            None => Ok(()),
+        };
     }
 
     fn execute_actions(
         &self,
         exec_configs: Vec<ExecutionConfigVariant>,
         variables: &VariableMap,
+    ) -> Result<(), ActionError> {
+        let mut timings = Vec::new();
+        let result = self.execute_steps(&exec_configs, variables, &mut timings);
+
+        // Print the timings regardless of whether a step failed, they're most useful then
+        if self.print_timings {
+            eprint!("{}", format_timings(&timings));
+        }
+
+        return result;
+    }
+
+    fn execute_steps(
+        &self,
+        exec_configs: &Vec<ExecutionConfigVariant>,
+        variables: &VariableMap,
+        timings: &mut Vec<StepTiming>,
     ) -> Result<(), ActionError> {
         for (idx, execution_config) in exec_configs.iter().enumerate() {
-            let result = self.command_executor.execute(&execution_config, &variables);
+            let start = Instant::now();
+            let result = self.execute_step(&execution_config, &variables);
+            self.finish_step(idx, execution_config, start.elapsed(), result, timings)?;
+        }
+
+        return Ok(());
+    }
+
+    /// Executes the provided stages of a [`StepsActionConfig`] one after another.
+    /// The steps within each stage don't depend on each other, so they're executed in parallel.
+    /// Any step outputs are added to the provided [`VariableMap`] once their stage has finished.
+    fn execute_stages(
+        &self,
+        stages: &Vec<Vec<String>>,
+        steps_action_config: &StepsActionConfig,
+        variables: &mut VariableMap,
+        timings: &mut Vec<StepTiming>,
+    ) -> Result<(), ActionError> {
+        let command_executor = self.command_executor.as_ref();
+        let mut idx = 0;
+        for stage in stages {
+            // Safe to unwrap: the stages only contain names from the steps
+            let step_configs: Vec<&StepConfig> = stage
+                .iter()
+                .map(|name| steps_action_config.steps.get(name).unwrap())
+                .collect();
 
-            match result {
-                Ok(status) => {
-                    match status {
-                        ExitStatus::Success => continue,
+            let stage_variables = &*variables;
+            let results: Vec<(Duration, Result<Output, ExecutionError>)> = thread::scope(|scope| {
+                let handles: Vec<_> = step_configs
+                    .iter()
+                    .map(|step_config| {
+                        scope.spawn(move || {
+                            let start = Instant::now();
+                            let result =
+                                execute_step_config(command_executor, step_config, stage_variables);
+                            (start.elapsed(), result)
+                        })
+                    })
+                    .collect();
+
+                handles
+                    .into_iter()
+                    .map(|handle| handle.join().unwrap())
+                    .collect()
+            });
 
-                        // Re-map non-zero exit codes to errors
-                        _ => return Err(ActionError::StatusCode { index: idx, status }),
+            // Every step in the stage has finished by now, so report the first failure in the
+            // order the steps were declared
+            let mut stage_result = Ok(());
+            for (step_config, (duration, result)) in step_configs.iter().zip(results) {
+                let result = result.map(|output| {
+                    if let Some(variable_name) = &step_config.output {
+                        let stdout = String::from_utf8_lossy(&output.stdout).to_string();
+                        let value = strip_ansi_escapes(&stdout).trim_end().to_string();
+                        variables.insert(variable_name.clone(), value);
                     }
+
+                    output.status
+                });
+
+                let step_result =
+                    self.finish_step(idx, &step_config.action, duration, result, timings);
+                if stage_result.is_ok() {
+                    stage_result = step_result;
                 }
-                Err(err) => {
-                    return Err(ActionError::Execution {
-                        index: idx,
-                        source: err,
-                    })
+
+                idx += 1;
+            }
+
+            stage_result?;
+        }
+
+        return Ok(());
+    }
+
+    /// Records the [`StepTiming`] for a step that has finished executing, and converts its result
+    /// into an [`ActionError`] if it failed.
+    fn finish_step(
+        &self,
+        idx: usize,
+        execution_config: &ExecutionConfigVariant,
+        duration: Duration,
+        result: ExecutionResult,
+        timings: &mut Vec<StepTiming>,
+    ) -> Result<(), ActionError> {
+        let timing = StepTiming {
+            step: execution_config.command_template(),
+            duration,
+            status: result.as_ref().ok().cloned(),
+        };
+
+        if self.log_format == LogFormat::Json {
+            eprintln!("{}", self.format_step_log(&timing, SystemTime::now()));
+        }
+
+        timings.push(timing);
+
+        match result {
+            Ok(status) => {
+                match status {
+                    ExitStatus::Success => return Ok(()),
+
+                    // Re-map non-zero exit codes to errors
+                    _ => return Err(ActionError::StatusCode { index: idx, status }),
                 }
             }
+            Err(err) => {
+                return Err(ActionError::Execution {
+                    index: idx,
+                    source: err,
+                })
+            }
+        }
+    }
+
+    /// Executes a single step, hiding its output behind a spinner if one has been configured.
+    fn execute_step(
+        &self,
+        execution_config: &ExecutionConfigVariant,
+        variables: &VariableMap,
+    ) -> ExecutionResult {
+        let Some(message) = &self.spinner else {
+            return self.command_executor.execute(execution_config, variables);
+        };
+
+        let spinner = Spinner::start_with_elapsed(message);
+        let result = self
+            .command_executor
+            .get_output(execution_config, variables);
+        spinner.stop();
+
+        let output = result?;
+
+        // The output would otherwise be lost, and it's usually needed to figure out what went wrong
+        if output.status != ExitStatus::Success {
+            self.write_hidden_output(&output)?;
         }
 
+        return Ok(output.status);
+    }
+
+    /// Formats the provided [`StepTiming`] as a JSON log entry, redacting the command text.
+    fn format_step_log(&self, timing: &StepTiming, timestamp: SystemTime) -> String {
+        let step = match &self.redactor {
+            Some(redactor) => {
+                String::from_utf8_lossy(&redactor.redact(timing.step.as_bytes())).to_string()
+            }
+            None => timing.step.clone(),
+        };
+
+        return format_step_log(&step, timing, timestamp);
+    }
+
+    fn write_hidden_output(&self, output: &Output) -> Result<(), ExecutionError> {
+        let (stdout, stderr) = match &self.redactor {
+            Some(redactor) => (
+                redactor.redact(&output.stdout),
+                redactor.redact(&output.stderr),
+            ),
+            None => (output.stdout.clone(), output.stderr.clone()),
+        };
+
+        io::stdout()
+            .write_all(&stdout)
+            .map_err(|io_err| ExecutionError::IO(io_err))?;
+        io::stderr()
+            .write_all(&stderr)
+            .map_err(|io_err| ExecutionError::IO(io_err))?;
+
         return Ok(());
     }
 
@@ -68,9 +498,15 @@ impl ActionExecutor {
         let alias_text = substitute_variables(alias_action_config.alias.as_str(), variables);
 
         // Get the args and append them to the alias
+        // The args are passed through as is, so any variables in them are escaped to stop them
+        // from being substituted, or reported as missing
         let command_text =
             if let Some(args) = self.arg_resolver.get_many(&ALIAS_ARGS_NAME.to_string()) {
-                let joined_args: String = args.join(" ");
+                let joined_args: String = args
+                    .iter()
+                    .map(|arg| arg.replace('$', "\\$"))
+                    .collect::<Vec<String>>()
+                    .join(" ");
                 format!("{} {}", alias_text, joined_args)
             } else {
                 alias_text
@@ -78,15 +514,201 @@ impl ActionExecutor {
 
         // Execute it!
         let exec = ExecutionConfigVariant::RawCommand(Shorthand(command_text));
-        self.command_executor
+        let status = self
+            .command_executor
             .execute(&exec, variables)
             .map_err(|err| ActionError::Execution {
                 index: 0,
                 source: err,
             })?;
 
-        return Ok(());
+        return match status {
+            ExitStatus::Success => Ok(()),
+
+            // Re-map non-zero exit codes to errors
+            _ => Err(ActionError::StatusCode { index: 0, status }),
+        };
+    }
+}
+
+/// Formats the provided [`StepTiming`] as a single line JSON object, using the provided command
+/// text for the step.
+fn format_step_log(step: &str, timing: &StepTiming, timestamp: SystemTime) -> String {
+    let (level, exit_code) = match &timing.status {
+        Some(ExitStatus::Success) => ("info", "0".to_string()),
+        Some(ExitStatus::Fail(code)) => ("error", code.to_string()),
+        Some(ExitStatus::Unknown) | None => ("error", "null".to_string()),
+    };
+
+    let timestamp = timestamp
+        .duration_since(UNIX_EPOCH)
+        .unwrap_or_default()
+        .as_millis();
+
+    return format!(
+        "{{\"timestamp\":{},\"level\":\"{}\",\"event\":\"step_finished\",\"command\":\"{}\",\"exit_code\":{},\"duration_ms\":{}}}",
+        timestamp,
+        level,
+        escape_json(step),
+        exit_code,
+        timing.duration.as_millis()
+    );
+}
+
+/// Escapes the provided text so that it can be used within a JSON string.
+fn escape_json(text: &str) -> String {
+    let mut escaped = String::new();
+    for c in text.chars() {
+        match c {
+            '"' => escaped.push_str("\\\""),
+            '\\' => escaped.push_str("\\\\"),
+            '\n' => escaped.push_str("\\n"),
+            '\r' => escaped.push_str("\\r"),
+            '\t' => escaped.push_str("\\t"),
+            c if c.is_control() => escaped.push_str(&format!("\\u{:04x}", c as u32)),
+            c => escaped.push(c),
+        }
+    }
+
+    return escaped;
+}
+
+/// Formats the provided [`StepTiming`]s into a table with a row for each step.
+pub fn format_timings(timings: &Vec<StepTiming>) -> String {
+    let width = timings
+        .iter()
+        .map(|timing| timing.step.len())
+        .max()
+        .unwrap_or(0);
+
+    let mut table = String::new();
+    for timing in timings {
+        let status = match &timing.status {
+            Some(ExitStatus::Success) => "ok".green(),
+            Some(ExitStatus::Fail(code)) => format!("exit code {code}").red(),
+            Some(ExitStatus::Unknown) => "unknown exit code".red(),
+            None => "error".red(),
+        };
+
+        let duration = format!("{:.2?}", timing.duration);
+        table.push_str(&format!(
+            "{:<width$}  {:>10}  {}\n",
+            timing.step, duration, status
+        ));
     }
+
+    return table;
+}
+
+/// Ensures that none of the variables referenced by the provided [`ActionConfig`] have empty values.
+/// Variables which are referenced but not defined in the [`VariableMap`] are ignored.
+pub fn ensure_variables_not_empty(
+    action_config: &ActionConfig,
+    variables: &VariableMap,
+) -> Result<(), ActionError> {
+    // Called commands are not checked
+    let templates = command_templates(action_config).unwrap_or_default();
+
+    let mut empty_variable_names: Vec<String> = vec![];
+    for template in templates {
+        for name in find_variable_references(&template) {
+            let is_empty = variables.get(&name).is_some_and(|value| value.is_empty());
+            if is_empty && !empty_variable_names.contains(&name) {
+                empty_variable_names.push(name);
+            }
+        }
+    }
+
+    if !empty_variable_names.is_empty() {
+        return Err(ActionError::EmptyVariables {
+            names: empty_variable_names,
+        });
+    }
+
+    return Ok(());
+}
+
+/// Executes the provided [`StepConfig`].
+/// The output is only captured when it's stored in a variable, otherwise it's printed as usual.
+fn execute_step_config(
+    command_executor: &dyn CommandExecutor,
+    step_config: &StepConfig,
+    variables: &VariableMap,
+) -> Result<Output, ExecutionError> {
+    if step_config.output.is_some() {
+        return command_executor.get_output(&step_config.action, variables);
+    }
+
+    let status = command_executor.execute(&step_config.action, variables)?;
+    return Ok(Output {
+        status,
+        stdout: vec![],
+        stderr: vec![],
+    });
+}
+
+/// Returns the paths of the provided dependencies in the order they should be executed, with the
+/// dependencies of each command coming before it.
+/// Commands which are depended on more than once are only included once.
+pub fn dependency_order(depends_on: &Vec<String>, commands: &CommandConfigMap) -> Vec<String> {
+    let mut order = Vec::new();
+    collect_dependencies(depends_on, commands, &mut order, &mut Vec::new());
+    return order;
+}
+
+fn collect_dependencies(
+    depends_on: &Vec<String>,
+    commands: &CommandConfigMap,
+    order: &mut Vec<String>,
+    visiting: &mut Vec<String>,
+) {
+    for command_path in depends_on {
+        // Cycles are reported when the config is loaded
+        if order.contains(command_path) || visiting.contains(command_path) {
+            continue;
+        }
+
+        visiting.push(command_path.clone());
+        if let Some(command_config) = find_command_by_path(command_path, commands) {
+            collect_dependencies(&command_config.depends_on, commands, order, visiting);
+        }
+
+        visiting.pop();
+        order.push(command_path.clone());
+    }
+}
+
+/// Returns the hooks from the provided [`HooksConfig`], if there are any.
+pub fn hooks(hooks_config: &Option<HooksConfig>) -> Vec<HookConfigVariant> {
+    return match hooks_config {
+        Some(hooks_config) => hooks_config.hooks(),
+        None => vec![],
+    };
+}
+
+/// Returns the command templates for the provided [`ActionConfig`].
+/// Returns `None` for actions which call other commands, since their templates depend on the
+/// commands being called.
+pub fn command_templates(action_config: &ActionConfig) -> Option<Vec<String>> {
+    let templates = match action_config {
+        ActionConfig::SingleStep(single_action_config) => {
+            vec![single_action_config.action.command_template()]
+        }
+        ActionConfig::MultiStep(multi_action_config) => multi_action_config
+            .actions
+            .iter()
+            .map(|execution_config| execution_config.command_template())
+            .collect(),
+        ActionConfig::Alias(alias_action_config) => vec![alias_action_config.alias.clone()],
+        ActionConfig::Calls(_) => return None,
+        ActionConfig::Steps(steps_action_config) => steps_action_config
+            .steps
+            .values()
+            .map(|step_config| step_config.action.command_template())
+            .collect(),
+    };
+
+    return Some(templates);
 }
 
 #[derive(Error, Debug)]
@@ -100,6 +722,64 @@ pub enum ActionError {
     // TODO: Reconsider whether a non-zero exit codes should be treated as errors
     #[error("failed to execute action {index}: {status}")]
     StatusCode { index: usize, status: ExitStatus },
+
+    #[error("the following variables are empty: {}", names.join(", "))]
+    EmptyVariables { names: Vec<String> },
+
+    #[error("cyclic command calls detected: {}", cycle.join(" -> "))]
+    CallCycle { cycle: Vec<String> },
+
+    #[error("failed to set umask")]
+    Umask(#[source] UmaskError),
+
+    #[error("failed to plan steps")]
+    Plan(#[source] PlanError),
+
+    #[error("failed to execute hook \"{hook}\"")]
+    Hook {
+        hook: String,
+        #[source]
+        source: ExecutionError,
+    },
+
+    #[error("hook \"{hook}\" failed: {status}")]
+    HookStatusCode { hook: String, status: ExitStatus },
+
+    #[error("dependency \"{name}\" failed")]
+    Dependency {
+        name: String,
+        #[source]
+        source: Box<RunError>,
+    },
+
+    #[error("called command \"{name}\" failed")]
+    Call {
+        name: String,
+        #[source]
+        source: Box<RunError>,
+    },
+}
+
+impl ActionError {
+    /// Returns the exit code that Dingus should exit with because of this error.
+    /// When a step exited with a non-zero exit code, the same exit code is used so that Dingus can
+    /// be composed with other tools. Anything else results in `1`.
+    pub fn exit_code(&self) -> i32 {
+        return match self {
+            ActionError::StatusCode {
+                status: ExitStatus::Fail(code),
+                ..
+            }
+            | ActionError::HookStatusCode {
+                status: ExitStatus::Fail(code),
+                ..
+            } => *code,
+            ActionError::Dependency { source, .. } | ActionError::Call { source, .. } => {
+                source.exit_code()
+            }
+            _ => 1,
+        };
+    }
 }
 
 #[cfg(test)]
@@ -107,10 +787,18 @@ mod tests {
     use super::*;
     use crate::{
         args::MockArgumentResolver,
-        config::{MultiActionConfig, RawCommandConfigVariant, SingleActionConfig},
+        config::{
+            parse_config, BashCommandConfig, CommandConfig, MultiActionConfig, Platform,
+            RawCommandConfigVariant, ShellCommandConfigVariant, SingleActionConfig,
+        },
         exec::MockCommandExecutor,
+        runner::MockCommandRunner,
+        variables::find_missing_variables,
     };
-    use mockall::{predicate::eq, Sequence};
+    use mockall::predicate::{always, eq};
+    use mockall::Sequence;
+    use std::collections::HashMap;
+    use std::sync::{Arc, Mutex};
 
     #[test]
     fn execute_single_step() {
@@ -145,6 +833,17 @@ mod tests {
         let action_executor = ActionExecutor {
             command_executor: Box::new(command_executor),
             arg_resolver: Box::new(arg_resolver),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
         };
 
         let result = action_executor.execute(&action, &variables.clone());
@@ -203,6 +902,17 @@ mod tests {
         let action_executor = ActionExecutor {
             command_executor: Box::new(command_executor),
             arg_resolver: Box::new(arg_resolver),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
         };
 
         let result = action_executor.execute(&action, &variables.clone());
@@ -211,47 +921,1206 @@ mod tests {
         assert!(result.is_ok())
     }
 
+    fn create_steps_action_executor(
+        command_executor: MockCommandExecutor,
+        commands: CommandConfigMap,
+    ) -> ActionExecutor {
+        return ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands,
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+    }
+
     #[test]
-    fn execute_alias() {
+    fn execute_steps_in_dependency_order() {
         // Arrange
-        let mut variables = VariableMap::new();
-        variables.insert("name".to_string(), "Dingus".to_string());
+        let config = parse_config(
+            &"commands:
+    release:
+        steps:
+            package:
+                action: ./package.sh
+                depends_on: [frontend, backend]
+            frontend:
+                action: npm run build
+                depends_on: [install]
+            backend:
+                action: cargo build
+                depends_on: [install]
+            install:
+                action: ./install.sh"
+                .to_string(),
+            Platform::Linux,
+        )
+        .unwrap();
+        let action = config.commands["release"].action.clone().unwrap();
 
-        let command_text = "docker compose";
+        let executed_commands = Arc::new(Mutex::new(Vec::new()));
+        let recorded_commands = executed_commands.clone();
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_execute()
+            .times(4)
+            .returning(move |execution_config, _| {
+                recorded_commands
+                    .lock()
+                    .unwrap()
+                    .push(execution_config.command_template());
+                Ok(ExitStatus::Success)
+            });
+
+        let action_executor = create_steps_action_executor(command_executor, config.commands);
+
+        // Act
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        assert!(result.is_ok());
+
+        // The frontend and backend steps are executed in parallel, so they can finish in any order
+        let executed_commands = executed_commands.lock().unwrap().clone();
+        assert_eq!(executed_commands.len(), 4);
+        assert_eq!(executed_commands[0], "./install.sh");
+        assert!(executed_commands[1..3].contains(&"npm run build".to_string()));
+        assert!(executed_commands[1..3].contains(&"cargo build".to_string()));
+        assert_eq!(executed_commands[3], "./package.sh");
+    }
+
+    #[test]
+    fn execute_steps_provides_outputs_to_later_steps() {
+        // Arrange
+        let config = parse_config(
+            &"commands:
+    release:
+        steps:
+            version:
+                action: git describe --tags
+                output: version
+            tag:
+                action: docker tag app app:$version
+                depends_on: [version]"
+                .to_string(),
+            Platform::Linux,
+        )
+        .unwrap();
+        let action = config.commands["release"].action.clone().unwrap();
 
         let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .once()
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Success,
+                    stdout: "v1.2.3\n".as_bytes().to_vec(),
+                    stderr: vec![],
+                })
+            });
+
+        let mut expected_variables = VariableMap::new();
+        expected_variables.insert("version".to_string(), "v1.2.3".to_string());
         command_executor
             .expect_execute()
-            .times(1)
+            .once()
             .with(
                 eq(ExecutionConfigVariant::RawCommand(
-                    RawCommandConfigVariant::Shorthand("docker compose up -d".to_string()),
+                    RawCommandConfigVariant::Shorthand("docker tag app app:$version".to_string()),
                 )),
-                eq(variables.clone()),
+                eq(expected_variables),
             )
             .returning(|_, _| Ok(ExitStatus::Success));
 
-        let alias_text = "up -d";
-        let mut arg_resolver = MockArgumentResolver::new();
-        arg_resolver
-            .expect_get_many()
-            .with(eq(ALIAS_ARGS_NAME.to_string()))
-            .once()
-            .returning(|_| Some(vec![alias_text.to_string()]));
+        let action_executor = create_steps_action_executor(command_executor, config.commands);
 
         // Act
-        let action = ActionConfig::Alias(AliasActionConfig {
-            alias: command_text.to_string(),
-        });
-
-        let action_executor = ActionExecutor {
-            command_executor: Box::new(command_executor),
-            arg_resolver: Box::new(arg_resolver),
-        };
-
-        let result = action_executor.execute(&action, &variables.clone());
+        let result = action_executor.execute(&action, &VariableMap::new());
 
         // Assert
         assert!(result.is_ok())
     }
+
+    #[test]
+    fn execute_steps_stops_after_failed_stage() {
+        // Arrange
+        let config = parse_config(
+            &"commands:
+    release:
+        steps:
+            build:
+                action: cargo build
+            package:
+                action: ./package.sh
+                depends_on: [build]"
+                .to_string(),
+            Platform::Linux,
+        )
+        .unwrap();
+        let action = config.commands["release"].action.clone().unwrap();
+
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_execute()
+            .once()
+            .returning(|_, _| Ok(ExitStatus::Fail(101)));
+
+        let action_executor = create_steps_action_executor(command_executor, config.commands);
+
+        // Act
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(ActionError::StatusCode {
+                index: 0,
+                status: ExitStatus::Fail(101)
+            })
+        ));
+    }
+
+    #[test]
+    fn execute_alias() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        let command_text = "docker compose";
+
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_execute()
+            .times(1)
+            .with(
+                eq(ExecutionConfigVariant::RawCommand(
+                    RawCommandConfigVariant::Shorthand("docker compose up -d".to_string()),
+                )),
+                eq(variables.clone()),
+            )
+            .returning(|_, _| Ok(ExitStatus::Success));
+
+        let alias_text = "up -d";
+        let mut arg_resolver = MockArgumentResolver::new();
+        arg_resolver
+            .expect_get_many()
+            .with(eq(ALIAS_ARGS_NAME.to_string()))
+            .once()
+            .returning(|_| Some(vec![alias_text.to_string()]));
+
+        // Act
+        let action = ActionConfig::Alias(AliasActionConfig {
+            alias: command_text.to_string(),
+            requires: Vec::new(),
+        });
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(arg_resolver),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        let result = action_executor.execute(&action, &variables.clone());
+
+        // Assert
+        assert!(result.is_ok())
+    }
+
+    #[test]
+    fn execute_alias_passes_variables_in_args_through() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_execute()
+            .once()
+            .withf(|execution_config, variables| {
+                let template = execution_config.command_template();
+                find_missing_variables(&template, variables).is_empty()
+                    && substitute_variables(&template, variables)
+                        == "kubectl exec pod -- sh -c echo $FOO"
+            })
+            .returning(|_, _| Ok(ExitStatus::Success));
+
+        let mut arg_resolver = MockArgumentResolver::new();
+        arg_resolver
+            .expect_get_many()
+            .with(eq(ALIAS_ARGS_NAME.to_string()))
+            .once()
+            .returning(|_| {
+                Some(vec![
+                    "exec".to_string(),
+                    "pod".to_string(),
+                    "--".to_string(),
+                    "sh".to_string(),
+                    "-c".to_string(),
+                    "echo $FOO".to_string(),
+                ])
+            });
+
+        let action = ActionConfig::Alias(AliasActionConfig {
+            alias: "kubectl".to_string(),
+            requires: Vec::new(),
+        });
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(arg_resolver),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        // Act
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        assert!(result.is_ok())
+    }
+
+    #[test]
+    fn execute_alias_fails_with_exit_code_of_command() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_execute()
+            .once()
+            .returning(|_, _| Ok(ExitStatus::Fail(42)));
+
+        let mut arg_resolver = MockArgumentResolver::new();
+        arg_resolver
+            .expect_get_many()
+            .with(eq(ALIAS_ARGS_NAME.to_string()))
+            .once()
+            .returning(|_| Some(vec!["logs".to_string()]));
+
+        let action = ActionConfig::Alias(AliasActionConfig {
+            alias: "docker compose".to_string(),
+            requires: Vec::new(),
+        });
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(arg_resolver),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        // Act
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        let err = result.unwrap_err();
+        assert!(matches!(
+            err,
+            ActionError::StatusCode {
+                index: 0,
+                status: ExitStatus::Fail(42)
+            }
+        ));
+        assert_eq!(err.exit_code(), 42);
+    }
+
+    #[test]
+    fn ensure_variables_not_empty_fails_for_empty_variables() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+        variables.insert("environment".to_string(), "".to_string());
+        variables.insert("region".to_string(), "".to_string());
+
+        let action = ActionConfig::MultiStep(MultiActionConfig {
+            actions: vec![
+                ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                    "echo Hello, $name!".to_string(),
+                )),
+                ExecutionConfigVariant::ShellCommand(ShellCommandConfigVariant::Bash(
+                    BashCommandConfig {
+                        working_directory: None,
+                        command: "./deploy.sh ${environment} $HOME".to_string(),
+                        script_file: false,
+                    },
+                )),
+            ],
+        });
+
+        // Act
+        let result = ensure_variables_not_empty(&action, &variables);
+
+        // Assert
+        match result {
+            Err(ActionError::EmptyVariables { names }) => {
+                assert_eq!(names, vec!["environment".to_string()])
+            }
+            _ => panic!("expected an empty variables error"),
+        }
+    }
+
+    #[test]
+    fn ensure_variables_not_empty_succeeds_for_non_empty_variables() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        let action = ActionConfig::SingleStep(SingleActionConfig {
+            action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                "echo Hello, $name!".to_string(),
+            )),
+        });
+
+        // Act
+        let result = ensure_variables_not_empty(&action, &variables);
+
+        // Assert
+        assert!(result.is_ok())
+    }
+
+    /// Creates an [`ActionExecutor`] for the provided command, which expects the provided called
+    /// commands to be run in order with the provided exit statuses.
+    fn call_action_executor(
+        commands: CommandConfigMap,
+        command_name: &'static str,
+        call_statuses: Vec<(&'static str, ExitStatus)>,
+    ) -> ActionExecutor {
+        let mut seq = Sequence::new();
+        let mut command_runner = MockCommandRunner::new();
+        for (path, status) in call_statuses {
+            command_runner
+                .expect_run()
+                .once()
+                .in_sequence(&mut seq)
+                .withf(move |command_path, call_stack, run_dependencies| {
+                    command_path == path
+                        && call_stack == &vec![command_name.to_string(), path.to_string()]
+                        && *run_dependencies
+                })
+                .returning(move |_, _, _| match &status {
+                    ExitStatus::Success => Ok(()),
+                    status => Err(RunError::Action(ActionError::StatusCode {
+                        index: 0,
+                        status: status.clone(),
+                    })),
+                });
+        }
+
+        return ActionExecutor {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(command_runner),
+            commands,
+            call_stack: vec![command_name.to_string()],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+    }
+
+    #[test]
+    fn execute_calls_runs_called_commands() {
+        // Arrange
+        let yaml = "commands:
+    release:
+        calls:
+            - build
+            - deploy app
+    build:
+        action: ./build.sh
+    deploy:
+        commands:
+            app:
+                action: ./deploy.sh";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let action_executor = call_action_executor(
+            config.commands.clone(),
+            "release",
+            vec![
+                ("build", ExitStatus::Success),
+                ("deploy app", ExitStatus::Success),
+            ],
+        );
+
+        // Act
+        let action = config.commands["release"].action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        assert!(result.is_ok())
+    }
+
+    #[test]
+    fn execute_calls_stops_when_called_command_fails() {
+        // Arrange
+        let yaml = "commands:
+    release:
+        calls:
+            - build
+            - deploy
+    build:
+        action: ./build.sh
+    deploy:
+        action: ./deploy.sh";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        // Deploy isn't expected to run
+        let action_executor = call_action_executor(
+            config.commands.clone(),
+            "release",
+            vec![("build", ExitStatus::Fail(2))],
+        );
+
+        // Act
+        let action = config.commands["release"].action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        let err = result.unwrap_err();
+        assert_eq!(err.exit_code(), 2);
+        assert!(matches!(err, ActionError::Call { name, .. } if name == "build"));
+    }
+
+    #[test]
+    fn execute_calls_continues_on_error() {
+        // Arrange
+        let yaml = "commands:
+    full-deploy:
+        runs:
+            - build
+            - migrate
+            - deploy
+        continue_on_error: true
+    build:
+        action: ./build.sh
+    migrate:
+        action: ./migrate.sh
+    deploy:
+        action: ./deploy.sh";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let action_executor = call_action_executor(
+            config.commands.clone(),
+            "full-deploy",
+            vec![
+                ("build", ExitStatus::Success),
+                ("migrate", ExitStatus::Fail(3)),
+                ("deploy", ExitStatus::Success),
+            ],
+        );
+
+        // Act
+        let action = config.commands["full-deploy"].action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        assert_eq!(result.unwrap_err().exit_code(), 3);
+    }
+
+    /// Creates an [`ActionExecutor`] for the provided command, which expects the provided
+    /// commands to be executed in order with the provided exit statuses.
+    fn hook_action_executor(
+        command_config: &CommandConfig,
+        executions: Vec<(&'static str, ExitStatus)>,
+    ) -> ActionExecutor {
+        let mut seq = Sequence::new();
+        let mut command_executor = MockCommandExecutor::new();
+        for (command_text, status) in executions {
+            command_executor
+                .expect_execute()
+                .once()
+                .in_sequence(&mut seq)
+                .withf(move |execution_config, _| {
+                    execution_config.command_template() == command_text
+                })
+                .returning(move |_, _| Ok(status.clone()));
+        }
+
+        return ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: hooks(&command_config.before),
+            after: hooks(&command_config.after),
+            depends_on: command_config.depends_on.clone(),
+            log_format: LogFormat::Text,
+        };
+    }
+
+    fn hook_command_config() -> CommandConfig {
+        let yaml = "commands:
+    test:
+        before:
+            - npm ci
+            - npm run build
+        after:
+            - ./report.sh
+            - command: ./cleanup.sh
+              always: true
+        action: npm test";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+        return config.commands["test"].clone();
+    }
+
+    #[test]
+    fn execute_runs_hooks_around_action() {
+        // Arrange
+        let command_config = hook_command_config();
+        let action_executor = hook_action_executor(
+            &command_config,
+            vec![
+                ("npm ci", ExitStatus::Success),
+                ("npm run build", ExitStatus::Success),
+                ("npm test", ExitStatus::Success),
+                ("./report.sh", ExitStatus::Success),
+                ("./cleanup.sh", ExitStatus::Success),
+            ],
+        );
+
+        // Act
+        let action = command_config.action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        assert!(result.is_ok());
+    }
+
+    #[test]
+    fn execute_does_not_run_action_when_before_hook_fails() {
+        // Arrange
+        let command_config = hook_command_config();
+        let action_executor = hook_action_executor(
+            &command_config,
+            vec![
+                ("npm ci", ExitStatus::Fail(2)),
+                ("./cleanup.sh", ExitStatus::Success),
+            ],
+        );
+
+        // Act
+        let action = command_config.action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        let err = result.unwrap_err();
+        assert!(matches!(&err, ActionError::HookStatusCode { hook, .. } if hook == "npm ci"));
+        assert_eq!(err.exit_code(), 2);
+    }
+
+    #[test]
+    fn execute_only_runs_always_after_hooks_when_action_fails() {
+        // Arrange
+        let command_config = hook_command_config();
+        let action_executor = hook_action_executor(
+            &command_config,
+            vec![
+                ("npm ci", ExitStatus::Success),
+                ("npm run build", ExitStatus::Success),
+                ("npm test", ExitStatus::Fail(1)),
+                ("./cleanup.sh", ExitStatus::Fail(4)),
+            ],
+        );
+
+        // Act
+        let action = command_config.action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        // The action failing takes precedence over the hooks that were run afterwards
+        assert!(matches!(result, Err(ActionError::StatusCode { .. })));
+    }
+
+    #[test]
+    fn dependency_order_includes_dependencies_of_dependencies_once() {
+        // Arrange
+        let yaml = "commands:
+    generate:
+        action: ./generate.sh
+    build:
+        depends_on:
+            - generate
+        action: ./build.sh
+    lint:
+        depends_on:
+            - generate
+        action: ./lint.sh
+    release:
+        depends_on:
+            - build
+            - lint
+        action: ./release.sh";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        // Act
+        let order = dependency_order(&config.commands["release"].depends_on, &config.commands);
+
+        // Assert
+        assert_eq!(order, vec!["generate", "build", "lint"]);
+    }
+
+    fn dependency_action_executor(
+        commands: &CommandConfigMap,
+        command_name: &str,
+        dependency_statuses: Vec<(&'static str, ExitStatus)>,
+        executions: Vec<&'static str>,
+    ) -> ActionExecutor {
+        let mut seq = Sequence::new();
+        let mut command_runner = MockCommandRunner::new();
+        for (command_path, status) in dependency_statuses {
+            let expected_call_stack = vec![command_name.to_string(), command_path.to_string()];
+            command_runner
+                .expect_run()
+                .once()
+                .in_sequence(&mut seq)
+                .withf(move |path, call_stack, run_dependencies| {
+                    path == command_path && *call_stack == expected_call_stack && !run_dependencies
+                })
+                .returning(move |_, _, _| match &status {
+                    ExitStatus::Success => Ok(()),
+                    status => Err(RunError::Action(ActionError::StatusCode {
+                        index: 0,
+                        status: status.clone(),
+                    })),
+                });
+        }
+
+        let mut command_executor = MockCommandExecutor::new();
+        for command_text in executions {
+            command_executor
+                .expect_execute()
+                .once()
+                .in_sequence(&mut seq)
+                .withf(move |execution_config, _| {
+                    execution_config.command_template() == command_text
+                })
+                .returning(|_, _| Ok(ExitStatus::Success));
+        }
+
+        return ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(command_runner),
+            commands: commands.clone(),
+            call_stack: vec![command_name.to_string()],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: commands[command_name].depends_on.clone(),
+            log_format: LogFormat::Text,
+        };
+    }
+
+    fn dependency_commands() -> CommandConfigMap {
+        let yaml = "commands:
+    build:
+        depends_on:
+            - generate
+        action: ./build.sh
+    generate:
+        action: ./generate.sh
+    lint:
+        action: ./lint.sh
+    release:
+        depends_on:
+            - build
+            - lint
+        action: ./release.sh";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+        return config.commands;
+    }
+
+    #[test]
+    fn execute_runs_dependencies_first() {
+        // Arrange
+        let commands = dependency_commands();
+        let action_executor = dependency_action_executor(
+            &commands,
+            "release",
+            vec![
+                ("generate", ExitStatus::Success),
+                ("build", ExitStatus::Success),
+                ("lint", ExitStatus::Success),
+            ],
+            vec!["./release.sh"],
+        );
+
+        // Act
+        let action = commands["release"].action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        assert!(result.is_ok());
+    }
+
+    #[test]
+    fn execute_stops_when_dependency_fails() {
+        // Arrange
+        let commands = dependency_commands();
+        let action_executor = dependency_action_executor(
+            &commands,
+            "release",
+            vec![
+                ("generate", ExitStatus::Success),
+                ("build", ExitStatus::Fail(2)),
+            ],
+            vec![],
+        );
+
+        // Act
+        let action = commands["release"].action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        let err = result.unwrap_err();
+        assert!(matches!(&err, ActionError::Dependency { name, .. } if name == "build"));
+        assert_eq!(err.exit_code(), 2);
+    }
+
+    #[test]
+    fn execute_calls_fails_for_cycles() {
+        // Arrange
+        let yaml = "commands:
+    ping:
+        calls:
+            - pong
+    pong:
+        calls:
+            - ping";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor.expect_execute().times(0);
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: config.commands.clone(),
+            call_stack: vec!["ping".to_string()],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        // Act
+        let action = config.commands["ping"].action.clone().unwrap();
+        let result = action_executor.execute(&action, &VariableMap::new());
+
+        // Assert
+        match result {
+            Err(ActionError::CallCycle { cycle }) => {
+                assert_eq!(cycle, vec!["ping", "pong", "ping"])
+            }
+            _ => panic!("expected a call cycle error"),
+        }
+    }
+
+    #[test]
+    fn execute_steps_records_timing_for_each_step() {
+        // Arrange
+        let mut seq = Sequence::new();
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_execute()
+            .once()
+            .in_sequence(&mut seq)
+            .returning(|_, _| Ok(ExitStatus::Success));
+        command_executor
+            .expect_execute()
+            .once()
+            .in_sequence(&mut seq)
+            .returning(|_, _| Ok(ExitStatus::Fail(2)));
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: true,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        let exec_configs = vec![
+            ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                "./build.sh".to_string(),
+            )),
+            ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                "./test.sh".to_string(),
+            )),
+            ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                "./deploy.sh".to_string(),
+            )),
+        ];
+
+        // Act
+        let mut timings = Vec::new();
+        let result =
+            action_executor.execute_steps(&exec_configs, &VariableMap::new(), &mut timings);
+
+        // Assert
+        assert!(result.is_err());
+        assert_eq!(timings.len(), 2);
+        assert_eq!(timings[0].step, "./build.sh");
+        assert_eq!(timings[0].status, Some(ExitStatus::Success));
+        assert_eq!(timings[1].step, "./test.sh");
+        assert_eq!(timings[1].status, Some(ExitStatus::Fail(2)));
+    }
+
+    #[test]
+    fn execute_step_hides_output_when_spinner_is_configured() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor.expect_execute().never();
+        command_executor
+            .expect_get_output()
+            .once()
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Success,
+                    stdout: b"Building...".to_vec(),
+                    stderr: vec![],
+                })
+            });
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: Some("Building...".to_string()),
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        let exec_config = ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "./build.sh".to_string(),
+        ));
+
+        // Act
+        let result = action_executor.execute_step(&exec_config, &VariableMap::new());
+
+        // Assert
+        assert_eq!(result.unwrap(), ExitStatus::Success);
+    }
+
+    #[test]
+    fn execute_step_returns_failure_when_spinner_is_configured() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .once()
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Fail(2),
+                    stdout: vec![],
+                    stderr: vec![],
+                })
+            });
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: Some("Building...".to_string()),
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        let exec_config = ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "./build.sh".to_string(),
+        ));
+
+        // Act
+        let result = action_executor.execute_step(&exec_config, &VariableMap::new());
+
+        // Assert
+        assert_eq!(result.unwrap(), ExitStatus::Fail(2));
+    }
+
+    #[test]
+    fn format_step_log_formats_json() {
+        // Arrange
+        let timing = StepTiming {
+            step: "echo \"Hello, $name!\"".to_string(),
+            duration: Duration::from_millis(1500),
+            status: Some(ExitStatus::Fail(2)),
+        };
+        let timestamp = UNIX_EPOCH + Duration::from_millis(1700000000000);
+
+        // Act
+        let log = format_step_log(&timing.step, &timing, timestamp);
+
+        // Assert
+        assert_eq!(
+            log,
+            "{\"timestamp\":1700000000000,\"level\":\"error\",\"event\":\"step_finished\",\"command\":\"echo \\\"Hello, $name!\\\"\",\"exit_code\":2,\"duration_ms\":1500}"
+        );
+
+        // JSON is also valid YAML, so make sure it parses
+        let entry: HashMap<String, serde_yaml::Value> = serde_yaml::from_str(&log).unwrap();
+        assert_eq!(
+            entry.get("command").unwrap().as_str(),
+            Some("echo \"Hello, $name!\"")
+        );
+        assert_eq!(entry.get("exit_code").unwrap().as_i64(), Some(2));
+    }
+
+    #[test]
+    fn format_step_log_redacts_command() {
+        // Arrange
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: Some(Redactor::new(&vec!["ghp_[A-Za-z0-9]+".to_string()]).unwrap()),
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Json,
+        };
+
+        let timing = StepTiming {
+            step: "./login.sh ghp_abc123".to_string(),
+            duration: Duration::from_millis(20),
+            status: Some(ExitStatus::Success),
+        };
+
+        // Act
+        let log = action_executor.format_step_log(&timing, SystemTime::now());
+
+        // Assert
+        assert!(log.contains("\"command\":\"./login.sh ***\""));
+        assert!(log.contains("\"level\":\"info\""));
+        assert!(log.contains("\"exit_code\":0"));
+    }
+
+    #[test]
+    fn escape_json_escapes_special_characters() {
+        // Act
+        let escaped = escape_json("a\\b\"c\nd\u{1b}");
+
+        // Assert
+        assert_eq!(escaped, "a\\\\b\\\"c\\nd\\u001b");
+    }
+
+    #[test]
+    fn format_timings_lists_each_step_with_duration() {
+        // Arrange
+        let timings = vec![
+            StepTiming {
+                step: "./build.sh".to_string(),
+                duration: Duration::from_millis(1500),
+                status: Some(ExitStatus::Success),
+            },
+            StepTiming {
+                step: "./deploy.sh --environment Production".to_string(),
+                duration: Duration::from_millis(250),
+                status: Some(ExitStatus::Fail(1)),
+            },
+        ];
+
+        // Act
+        let table = format_timings(&timings);
+
+        // Assert
+        let lines: Vec<&str> = table.lines().collect();
+        assert_eq!(lines.len(), 2);
+
+        assert!(lines[0].starts_with("./build.sh "));
+        assert!(lines[0].contains("1.50s"));
+        assert!(lines[0].contains("ok"));
+
+        assert!(lines[1].starts_with("./deploy.sh --environment Production "));
+        assert!(lines[1].contains("250.00ms"));
+        assert!(lines[1].contains("exit code 1"));
+    }
+
+    #[test]
+    fn notify_provides_status_of_successful_action() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        let notify_config = ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "notify-send $status".to_string(),
+        ));
+
+        let mut expected_variables = variables.clone();
+        expected_variables.insert("status".to_string(), "success".to_string());
+        expected_variables.insert("exit_code".to_string(), "0".to_string());
+
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_execute()
+            .times(1)
+            .with(eq(notify_config.clone()), eq(expected_variables))
+            .returning(|_, _| Ok(ExitStatus::Success));
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        // Act
+        action_executor.notify(&notify_config, &variables, &Ok(()));
+    }
+
+    #[test]
+    fn notify_provides_exit_code_of_failed_action() {
+        // Arrange
+        let notify_config = ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "notify-send $status $exit_code".to_string(),
+        ));
+
+        let mut expected_variables = VariableMap::new();
+        expected_variables.insert("status".to_string(), "failure".to_string());
+        expected_variables.insert("exit_code".to_string(), "75".to_string());
+
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_execute()
+            .times(1)
+            .with(eq(notify_config.clone()), eq(expected_variables))
+            .returning(|_, _| Ok(ExitStatus::Fail(1)));
+
+        let action_executor = ActionExecutor {
+            command_executor: Box::new(command_executor),
+            arg_resolver: Box::new(MockArgumentResolver::new()),
+            command_runner: Box::new(MockCommandRunner::new()),
+            commands: CommandConfigMap::new(),
+            call_stack: vec![],
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: vec![],
+            after: vec![],
+            depends_on: vec![],
+            log_format: LogFormat::Text,
+        };
+
+        let result = Err(ActionError::StatusCode {
+            index: 0,
+            status: ExitStatus::Fail(75),
+        });
+
+        // Act
+        // A failing notification command shouldn't cause a panic
+        action_executor.notify(&notify_config, &VariableMap::new(), &result);
+    }
+
+    #[test]
+    fn exit_code_uses_exit_code_of_failed_step() {
+        // Arrange
+        let status_code_err = ActionError::StatusCode {
+            index: 0,
+            status: ExitStatus::Fail(127),
+        };
+        let unknown_status_err = ActionError::StatusCode {
+            index: 0,
+            status: ExitStatus::Unknown,
+        };
+        let other_err = ActionError::CallCycle {
+            cycle: vec!["deploy".to_string(), "deploy".to_string()],
+        };
+
+        // Act
+        let status_code_exit_code = status_code_err.exit_code();
+        let unknown_status_exit_code = unknown_status_err.exit_code();
+        let other_exit_code = other_err.exit_code();
+
+        // Assert
+        assert_eq!(status_code_exit_code, 127);
+        assert_eq!(unknown_status_exit_code, 1);
+        assert_eq!(other_exit_code, 1);
+    }
 }
@@ -1,5 +1,6 @@
 use clap::ArgMatches;
 use mockall::automock;
+use std::collections::HashMap;
 
 pub const ALIAS_ARGS_NAME: &str = "ARGS";
 
@@ -17,34 +18,78 @@ pub trait ArgumentResolver {
 
 pub struct ClapArgumentResolver {
     arg_matches: ArgMatches,
+    root_arg_matches: Option<ArgMatches>,
+
+    /// Values which take precedence over any arguments, keyed by the argument's name.
+    overrides: HashMap<String, String>,
 }
 
 impl ClapArgumentResolver {
     pub fn from_arg_matches(arg_matches: &ArgMatches) -> ClapArgumentResolver {
         return ClapArgumentResolver {
             arg_matches: arg_matches.clone(),
+            root_arg_matches: None,
+            overrides: HashMap::new(),
+        };
+    }
+
+    /// Falls back to the provided root-level [`ArgMatches`] for any arguments that weren't
+    /// defined on the subcommand.
+    pub fn with_root_arg_matches(self, root_arg_matches: &ArgMatches) -> ClapArgumentResolver {
+        return ClapArgumentResolver {
+            arg_matches: self.arg_matches,
+            root_arg_matches: Some(root_arg_matches.clone()),
+            overrides: self.overrides,
         };
     }
+
+    /// Uses the provided values instead of any arguments with the same name, regardless of
+    /// whether those arguments were specified.
+    pub fn with_overrides(self, overrides: &HashMap<String, String>) -> ClapArgumentResolver {
+        return ClapArgumentResolver {
+            arg_matches: self.arg_matches,
+            root_arg_matches: self.root_arg_matches,
+            overrides: overrides.clone(),
+        };
+    }
+
+    fn all_arg_matches(&self) -> Vec<&ArgMatches> {
+        let mut all_arg_matches = vec![&self.arg_matches];
+        if let Some(root_arg_matches) = &self.root_arg_matches {
+            all_arg_matches.push(root_arg_matches);
+        }
+
+        return all_arg_matches;
+    }
 }
 
 impl ArgumentResolver for ClapArgumentResolver {
     fn get(&self, key: &String) -> Option<String> {
-        if let Some(found_value) = self.arg_matches.get_one::<String>(key) {
-            return Some(found_value.clone());
+        if let Some(override_value) = self.overrides.get(key) {
+            return Some(override_value.clone());
+        }
+
+        for arg_matches in self.all_arg_matches() {
+            // Arguments for root-level variables may not be defined on the subcommand
+            if let Ok(Some(found_value)) = arg_matches.try_get_one::<String>(key) {
+                return Some(found_value.clone());
+            }
         }
 
         return None;
     }
 
     fn get_many(&self, key: &String) -> Option<Vec<String>> {
-        if let Some(found_values) = self.arg_matches.get_many::<String>(key) {
-            let mut values: Vec<String> = Vec::new();
+        for arg_matches in self.all_arg_matches() {
+            if let Ok(Some(found_values)) = arg_matches.try_get_many::<String>(key) {
+                let mut values: Vec<String> = Vec::new();
 
-            for found_value in found_values {
-                values.push(found_value.clone());
-            }
+                for found_value in found_values {
+                    values.push(found_value.clone());
+                }
 
-            return Some(values);
+                return Some(values);
+            }
         }
 
         return None;
@@ -125,6 +170,59 @@ mod tests {
         );
     }
 
+    #[test]
+    fn argresolver_falls_back_to_root_args() {
+        // Arrange
+        let name_arg = single_arg(&"name".to_string());
+        let age_arg = single_arg(&"age".to_string());
+        let greet_command = Command::new("greet").arg(age_arg);
+
+        let root_command = Command::new("dingus")
+            .arg(name_arg)
+            .subcommand(greet_command);
+
+        // Act
+        let root_matches = root_command
+            .get_matches_from(vec!["dingus", "--name", "Dingus", "greet", "--age", "42"]);
+        let (_, subcommand_matches) = root_matches.subcommand().unwrap();
+
+        let arg_resolver = ClapArgumentResolver::from_arg_matches(&subcommand_matches)
+            .with_root_arg_matches(&root_matches);
+
+        // Assert
+        assert_eq!(
+            arg_resolver.get(&"name".to_string()),
+            Some("Dingus".to_string())
+        );
+        assert_eq!(arg_resolver.get(&"age".to_string()), Some("42".to_string()));
+        assert_eq!(arg_resolver.get(&"missing".to_string()), None);
+    }
+
+    #[test]
+    fn argresolver_prefers_overrides() {
+        // Arrange
+        let name_arg = single_arg(&"name".to_string());
+        let matches = Command::new("dingus")
+            .arg(name_arg)
+            .get_matches_from(vec!["dingus", "--name", "Dingus"]);
+
+        let mut overrides = HashMap::new();
+        overrides.insert("name".to_string(), "Godzilla".to_string());
+        overrides.insert("age".to_string(), "70".to_string());
+
+        // Act
+        let arg_resolver = ClapArgumentResolver::from_arg_matches(&matches)
+            .with_root_arg_matches(&matches)
+            .with_overrides(&overrides);
+
+        // Assert
+        assert_eq!(
+            arg_resolver.get(&"name".to_string()),
+            Some("Godzilla".to_string())
+        );
+        assert_eq!(arg_resolver.get(&"age".to_string()), Some("70".to_string()));
+    }
+
     fn single_arg(name: &String) -> Arg {
         return Arg::new(name.clone())
             .long(name.clone())
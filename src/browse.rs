@@ -0,0 +1,315 @@
+use crate::cli::find_command_by_name;
+use crate::config::{
+    CommandConfigMap, Platform, PromptConfig, PromptOptionsVariant, SelectOptionsConfig,
+    SelectPromptOptions,
+};
+use crate::platform::is_current_platform;
+use crate::prompt::{PromptError, PromptExecutor};
+use std::fmt;
+
+/// An entry which can be selected in the [`CommandBrowser`].
+#[derive(Debug, PartialEq, Clone)]
+pub enum BrowserEntry {
+    /// Returns to the parent command.
+    Back,
+
+    /// Runs the current command.
+    Run,
+
+    /// A subcommand of the current command.
+    Command {
+        name: String,
+        description: Option<String>,
+        has_subcommands: bool,
+    },
+}
+
+impl fmt::Display for BrowserEntry {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            BrowserEntry::Back => write!(f, ".."),
+            BrowserEntry::Run => write!(f, "(run)"),
+            BrowserEntry::Command {
+                name,
+                description,
+                has_subcommands,
+            } => {
+                let suffix = if *has_subcommands { " ›" } else { "" };
+                match description {
+                    Some(description) => write!(f, "{}{} - {}", name, suffix, description),
+                    None => write!(f, "{}{}", name, suffix),
+                }
+            }
+        }
+    }
+}
+
+/// The result of selecting a [`BrowserEntry`].
+#[derive(Debug, PartialEq)]
+pub enum Navigation {
+    /// The browser moved to another command, so there are new entries to show.
+    Moved,
+
+    /// The command with the provided names should be run.
+    Run(Vec<String>),
+}
+
+/// Keeps track of where the user is while browsing the command tree.
+pub struct CommandBrowser {
+    commands: CommandConfigMap,
+    platform: Platform,
+
+    /// The names of the commands leading to the current command.
+    path: Vec<String>,
+}
+
+impl CommandBrowser {
+    pub fn new(commands: CommandConfigMap, platform: Platform) -> CommandBrowser {
+        return CommandBrowser {
+            commands,
+            platform,
+            path: Vec::new(),
+        };
+    }
+
+    /// The names of the commands leading to the current command.
+    pub fn path(&self) -> &Vec<String> {
+        return &self.path;
+    }
+
+    /// Returns the entries which can be selected from the current command.
+    /// Hidden commands, and commands for other platforms, are excluded.
+    pub fn entries(&self) -> Vec<BrowserEntry> {
+        let mut entries = Vec::new();
+        let mut commands = self.commands.clone();
+        for name in &self.path {
+            // Safe to unwrap: the path only contains commands that have been selected
+            let command_config = find_command_by_name(name, &commands).unwrap();
+            if name == self.path.last().unwrap() {
+                entries.push(BrowserEntry::Back);
+                if command_config.action.is_some() {
+                    entries.push(BrowserEntry::Run);
+                }
+            }
+
+            commands = command_config.commands;
+        }
+
+        let mut subcommands: Vec<BrowserEntry> = commands
+            .iter()
+            .filter(|(_, command_config)| {
+                if command_config.hidden {
+                    return false;
+                }
+
+                return match &command_config.platform {
+                    Some(platforms) => is_current_platform(self.platform.clone(), platforms),
+                    None => true,
+                };
+            })
+            .map(|(key, command_config)| BrowserEntry::Command {
+                name: command_config.name.clone().unwrap_or(key.clone()),
+                description: command_config.description.clone(),
+                has_subcommands: !command_config.commands.is_empty(),
+            })
+            .collect();
+        subcommands.sort_by_key(|entry| entry.to_string());
+
+        entries.extend(subcommands);
+        return entries;
+    }
+
+    /// Selects the provided [`BrowserEntry`].
+    /// Commands with subcommands are moved into, otherwise they're run.
+    pub fn select(&mut self, entry: &BrowserEntry) -> Navigation {
+        return match entry {
+            BrowserEntry::Back => {
+                self.path.pop();
+                Navigation::Moved
+            }
+
+            BrowserEntry::Run => Navigation::Run(self.path.clone()),
+
+            BrowserEntry::Command {
+                name,
+                has_subcommands,
+                ..
+            } => {
+                let mut path = self.path.clone();
+                path.push(name.clone());
+                if !has_subcommands {
+                    return Navigation::Run(path);
+                }
+
+                self.path = path;
+                Navigation::Moved
+            }
+        };
+    }
+}
+
+/// Lets the user browse the command tree until they pick a command to run.
+/// Returns the names of the command to run, as they would be provided on the command-line.
+pub fn browse(
+    prompt_executor: &dyn PromptExecutor,
+    browser: &mut CommandBrowser,
+) -> Result<Vec<String>, PromptError> {
+    loop {
+        let entries = browser.entries();
+        let labels: Vec<String> = entries.iter().map(|entry| entry.to_string()).collect();
+
+        let message = match browser.path().is_empty() {
+            true => "Which command do you want to run?".to_string(),
+            false => format!("{}:", browser.path().join(" ")),
+        };
+
+        let prompt_config = PromptConfig {
+            message,
+            options: PromptOptionsVariant::Select(SelectPromptOptions {
+                options: SelectOptionsConfig::Literal(labels.clone()),
+                allow_other: false,
+            }),
+            ..Default::default()
+        };
+
+        let choice = prompt_executor.execute(&prompt_config)?;
+        let Some(index) = labels.iter().position(|label| *label == choice) else {
+            continue;
+        };
+
+        if let Navigation::Run(names) = browser.select(&entries[index]) {
+            return Ok(names);
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::parse_config;
+    use crate::prompt::MockPromptExecutor;
+
+    const CONFIG: &str = "commands:
+    build:
+        description: Builds the project
+        action: cargo build
+    db:
+        description: Database commands
+        action: ./db.sh
+        commands:
+            migrate:
+                action: ./migrate.sh
+            seed:
+                name: populate
+                action: ./seed.sh
+    secret:
+        hidden: true
+        action: echo shh
+    brew:
+        platform: MacOS
+        action: brew bundle";
+
+    fn create_browser() -> CommandBrowser {
+        let config = parse_config(&CONFIG.to_string(), Platform::Linux).unwrap();
+        return CommandBrowser::new(config.commands, Platform::Linux);
+    }
+
+    fn command_entry(name: &str, description: Option<&str>, has_subcommands: bool) -> BrowserEntry {
+        return BrowserEntry::Command {
+            name: name.to_string(),
+            description: description.map(|description| description.to_string()),
+            has_subcommands,
+        };
+    }
+
+    #[test]
+    fn entries_lists_visible_commands() {
+        // Arrange
+        let browser = create_browser();
+
+        // Act
+        let entries = browser.entries();
+
+        // Assert
+        assert_eq!(
+            entries,
+            vec![
+                command_entry("build", Some("Builds the project"), false),
+                command_entry("db", Some("Database commands"), true),
+            ]
+        );
+    }
+
+    #[test]
+    fn select_moves_into_commands_with_subcommands() {
+        // Arrange
+        let mut browser = create_browser();
+
+        // Act
+        let navigation = browser.select(&command_entry("db", None, true));
+
+        // Assert
+        assert_eq!(navigation, Navigation::Moved);
+        assert_eq!(browser.path(), &vec!["db".to_string()]);
+        assert_eq!(
+            browser.entries(),
+            vec![
+                BrowserEntry::Back,
+                BrowserEntry::Run,
+                command_entry("migrate", None, false),
+                command_entry("populate", None, false),
+            ]
+        );
+    }
+
+    #[test]
+    fn select_runs_commands() {
+        // Arrange
+        let mut browser = create_browser();
+        browser.select(&command_entry("db", None, true));
+
+        // Act
+        let run_subcommand = browser.select(&command_entry("populate", None, false));
+        let run_current = browser.select(&BrowserEntry::Run);
+
+        // Assert
+        assert_eq!(
+            run_subcommand,
+            Navigation::Run(vec!["db".to_string(), "populate".to_string()])
+        );
+        assert_eq!(run_current, Navigation::Run(vec!["db".to_string()]));
+    }
+
+    #[test]
+    fn select_back_returns_to_parent() {
+        // Arrange
+        let mut browser = create_browser();
+        browser.select(&command_entry("db", None, true));
+
+        // Act
+        let navigation = browser.select(&BrowserEntry::Back);
+
+        // Assert
+        assert_eq!(navigation, Navigation::Moved);
+        assert!(browser.path().is_empty());
+        assert_eq!(browser.entries().len(), 2);
+    }
+
+    #[test]
+    fn browse_returns_selected_command() {
+        // Arrange
+        let mut browser = create_browser();
+        let mut prompt_executor = MockPromptExecutor::new();
+        let mut choices = vec!["db › - Database commands", "migrate"].into_iter();
+        prompt_executor
+            .expect_execute()
+            .times(2)
+            .returning(move |_| Ok(choices.next().unwrap().to_string()));
+
+        // Act
+        let names = browse(&prompt_executor, &mut browser).unwrap();
+
+        // Assert
+        assert_eq!(names, vec!["db".to_string(), "migrate".to_string()]);
+    }
+}
@@ -1,11 +1,405 @@
+use crate::actions::LogFormat;
 use crate::args::ALIAS_ARGS_NAME;
 use crate::config::{
     ActionConfig, ArgumentConfigVariant, CommandConfig, CommandConfigMap, Config, DingusOptions,
     ExecutionConfigVariant, NamedArgumentConfig, RawCommandConfigVariant, VariableConfig,
     VariableConfigMap,
 };
+use crate::exec::CommandExecutor;
 use crate::platform::{is_current_platform, PlatformProvider};
-use clap::{Arg, ArgMatches, Command, ValueHint};
+use crate::preconditions;
+use crate::variables::ExportFormat;
+use clap::{value_parser, Arg, ArgAction, ArgMatches, Command, ValueHint};
+use std::collections::HashMap;
+use std::env;
+use std::ffi::OsString;
+use std::path::{Path, PathBuf};
+
+/// The name of the program to use when it can't be determined from the executable.
+const DEFAULT_PROGRAM_NAME: &str = "dingus";
+
+/// The prefix for the IDs of the arguments used by Dingus itself.
+/// The arguments for variables use the name of the variable as their ID, so variable names can't
+/// start with this prefix.
+pub const RESERVED_ARG_ID_PREFIX: &str = "__dingus_";
+
+const SHOW_CONFIG_PATH_ARG_NAME: &str = "show-config-path";
+const SHOW_CONFIG_PATH_ARG_ID: &str = "__dingus_show_config_path";
+const LOG_ANSWERS_ARG_NAME: &str = "log-answers";
+const LOG_ANSWERS_ARG_ID: &str = "__dingus_log_answers";
+const TEST_CONFIG_ARG_NAME: &str = "test-config";
+const TEST_CONFIG_ARG_ID: &str = "__dingus_test_config";
+const FORMAT_CONFIG_ARG_NAME: &str = "format-config";
+const FORMAT_CONFIG_ARG_ID: &str = "__dingus_format_config";
+const WRITE_ARG_NAME: &str = "write";
+const WRITE_ARG_ID: &str = "__dingus_write";
+const CONFIG_ARG_NAME: &str = "config";
+const CONFIG_ARG_ID: &str = "__dingus_config";
+const ALLOW_INSECURE_CONFIG_ARG_NAME: &str = "allow-insecure-config";
+const ALLOW_INSECURE_CONFIG_ARG_ID: &str = "__dingus_allow_insecure_config";
+const CONFIG_SHA256_ARG_NAME: &str = "config-sha256";
+const CONFIG_SHA256_ARG_ID: &str = "__dingus_config_sha256";
+const WORKING_DIRECTORY_ARG_NAME: &str = "working-dir";
+const WORKING_DIRECTORY_ARG_ID: &str = "__dingus_working_dir";
+const TIMINGS_ARG_NAME: &str = "timings";
+const TIMINGS_ARG_ID: &str = "__dingus_timings";
+const TAG_ARG_NAME: &str = "tag";
+const TAG_ARG_ID: &str = "__dingus_tag";
+const PICK_CONFIG_ARG_NAME: &str = "pick-config";
+const PICK_CONFIG_ARG_ID: &str = "__dingus_pick_config";
+const EXPORT_VARS_ARG_NAME: &str = "export-vars";
+const EXPORT_VARS_ARG_ID: &str = "__dingus_export_vars";
+const NO_SECRETS_ARG_NAME: &str = "no-secrets";
+const NO_SECRETS_ARG_ID: &str = "__dingus_no_secrets";
+const DESCRIBE_ARG_NAME: &str = "describe";
+const DESCRIBE_ARG_ID: &str = "__dingus_describe";
+const LOG_FORMAT_ARG_NAME: &str = "log-format";
+const LOG_FORMAT_ARG_ID: &str = "__dingus_log_format";
+const LIST_FLAGS_ARG_NAME: &str = "list-flags";
+const LIST_FLAGS_ARG_ID: &str = "__dingus_list_flags";
+const BROWSE_ARG_NAME: &str = "browse";
+const BROWSE_ARG_ID: &str = "__dingus_browse";
+const PLAN_ARG_NAME: &str = "plan";
+const PLAN_ARG_ID: &str = "__dingus_plan";
+const DRY_RUN_ARG_NAME: &str = "dry-run";
+const DRY_RUN_ARG_ID: &str = "__dingus_dry_run";
+const VAR_ARG_NAME: &str = "var";
+const VAR_ARG_ID: &str = "__dingus_var";
+const ALLOW_MISSING_ARG_NAME: &str = "allow-missing";
+const ALLOW_MISSING_ARG_ID: &str = "__dingus_allow_missing";
+
+/// Arguments that are available regardless of the [`Config`], and need to be known before the
+/// [`Config`] is loaded.
+pub struct GlobalArgs {
+    /// Whether the path to the config file should be printed instead of executing a command.
+    pub show_config_path: bool,
+
+    /// Whether the value entered for each prompt should be logged.
+    pub log_answers: bool,
+
+    /// Whether the tests defined in the config should be run instead of executing a command.
+    pub test_config: bool,
+
+    /// Whether the config file should be formatted instead of executing a command.
+    pub format_config: bool,
+
+    /// Whether the formatted config should be written back to the config file, rather than
+    /// printed.
+    pub write: bool,
+
+    /// An optional path or URL to load the config from, rather than searching for a config file.
+    pub config: Option<String>,
+
+    /// Whether remote configs can be downloaded over plain HTTP.
+    pub allow_insecure_config: bool,
+
+    /// An optional SHA-256 checksum that remote configs must match.
+    pub config_checksum: Option<String>,
+
+    /// An optional directory to change to before doing anything else.
+    pub working_directory: Option<PathBuf>,
+
+    /// Whether a breakdown of how long each step took should be printed.
+    pub timings: bool,
+
+    /// Tags used to filter the commands shown in the --help output.
+    pub tags: Vec<String>,
+
+    /// Whether the user should be asked which config file to use when several are found.
+    pub pick_config: bool,
+
+    /// An optional [`ExportFormat`] to print the resolved variables in, instead of executing the
+    /// command.
+    pub export_format: Option<ExportFormat>,
+
+    /// Whether sensitive variables should be excluded when exporting variables.
+    pub no_secrets: bool,
+
+    /// Whether Markdown documentation for the commands should be printed instead of executing a
+    /// command.
+    pub describe: bool,
+
+    /// The [`LogFormat`] to log the steps of each action in.
+    pub log_format: LogFormat,
+
+    /// Whether the user should browse the commands and choose one to run, instead of providing
+    /// one on the command-line.
+    pub browse: bool,
+
+    /// Whether the commands should be printed instead of executed, once the variables have been
+    /// resolved.
+    pub dry_run: bool,
+
+    /// Values to use for variables instead of resolving them, keyed by the name of the variable.
+    pub variable_overrides: HashMap<String, String>,
+
+    /// Whether raw commands referencing variables that aren't set should still be executed.
+    pub allow_missing: bool,
+}
+
+/// Parses the [`GlobalArgs`] from the provided command-line arguments.
+/// Any other arguments or subcommands are ignored, since they depend on the [`Config`].
+pub fn parse_global_args<I, T>(args: I) -> GlobalArgs
+where
+    I: IntoIterator<Item = T>,
+    T: Into<std::ffi::OsString> + Clone,
+{
+    let arg_matches = Command::new("dingus")
+        .args(create_global_args())
+        // Invalid values would stop the other global args from being parsed, they're reported once
+        // the config has been loaded instead
+        .mut_arg(VAR_ARG_ID, |arg| arg.value_parser(value_parser!(String)))
+        .disable_help_flag(true)
+        .disable_version_flag(true)
+        .allow_external_subcommands(true)
+        .ignore_errors(true)
+        .get_matches_from(args);
+
+    return GlobalArgs {
+        show_config_path: arg_matches.get_flag(SHOW_CONFIG_PATH_ARG_ID),
+        log_answers: arg_matches.get_flag(LOG_ANSWERS_ARG_ID),
+        test_config: arg_matches.get_flag(TEST_CONFIG_ARG_ID),
+        format_config: arg_matches.get_flag(FORMAT_CONFIG_ARG_ID),
+        write: arg_matches.get_flag(WRITE_ARG_ID),
+        config: arg_matches.get_one::<String>(CONFIG_ARG_ID).cloned(),
+        allow_insecure_config: arg_matches.get_flag(ALLOW_INSECURE_CONFIG_ARG_ID),
+        config_checksum: arg_matches.get_one::<String>(CONFIG_SHA256_ARG_ID).cloned(),
+        working_directory: arg_matches
+            .get_one::<PathBuf>(WORKING_DIRECTORY_ARG_ID)
+            .cloned(),
+        timings: arg_matches.get_flag(TIMINGS_ARG_ID),
+        tags: arg_matches
+            .get_many::<String>(TAG_ARG_ID)
+            .unwrap_or_default()
+            .cloned()
+            .collect(),
+        pick_config: arg_matches.get_flag(PICK_CONFIG_ARG_ID),
+        export_format: arg_matches
+            .get_one::<String>(EXPORT_VARS_ARG_ID)
+            .map(|format| match format.as_str() {
+                "fish" => ExportFormat::Fish,
+                _ => ExportFormat::Bash,
+            }),
+        no_secrets: arg_matches.get_flag(NO_SECRETS_ARG_ID),
+        describe: arg_matches.get_flag(DESCRIBE_ARG_ID),
+        log_format: match arg_matches
+            .get_one::<String>(LOG_FORMAT_ARG_ID)
+            .map(|format| format.as_str())
+        {
+            Some("json") => LogFormat::Json,
+            _ => LogFormat::Text,
+        },
+        browse: arg_matches.get_flag(BROWSE_ARG_ID),
+        dry_run: arg_matches.get_flag(DRY_RUN_ARG_ID),
+        variable_overrides: arg_matches
+            .get_many::<String>(VAR_ARG_ID)
+            .unwrap_or_default()
+            .filter_map(|variable_override| parse_variable_override(variable_override).ok())
+            .collect(),
+        allow_missing: arg_matches.get_flag(ALLOW_MISSING_ARG_ID),
+    };
+}
+
+fn create_global_args() -> Vec<Arg> {
+    vec![
+        Arg::new(SHOW_CONFIG_PATH_ARG_ID)
+            .long(SHOW_CONFIG_PATH_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Print the path to the config file in use and exit."),
+        Arg::new(LOG_ANSWERS_ARG_ID)
+            .long(LOG_ANSWERS_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Print the value entered for each prompt to stderr."),
+        Arg::new(TEST_CONFIG_ARG_ID)
+            .long(TEST_CONFIG_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Run the tests defined in the config file and exit."),
+        Arg::new(FORMAT_CONFIG_ARG_ID)
+            .long(FORMAT_CONFIG_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Print the config file in a canonical form and exit."),
+        Arg::new(WRITE_ARG_ID)
+            .long(WRITE_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .requires(FORMAT_CONFIG_ARG_ID)
+            .help("Write the formatted config back to the config file when using --format-config."),
+        Arg::new(CONFIG_ARG_ID)
+            .long(CONFIG_ARG_NAME)
+            .short('c')
+            .value_name("PATH|URL")
+            .value_hint(ValueHint::AnyPath)
+            .help("Load the config from the provided path or URL instead of searching for one."),
+        Arg::new(ALLOW_INSECURE_CONFIG_ARG_ID)
+            .long(ALLOW_INSECURE_CONFIG_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Allow remote configs to be downloaded over plain HTTP."),
+        Arg::new(CONFIG_SHA256_ARG_ID)
+            .long(CONFIG_SHA256_ARG_NAME)
+            .value_name("SHA256")
+            .help("Only use the remote config if its SHA-256 checksum matches the provided one."),
+        Arg::new(WORKING_DIRECTORY_ARG_ID)
+            .long(WORKING_DIRECTORY_ARG_NAME)
+            .short('C')
+            .value_name("DIR")
+            .value_hint(ValueHint::DirPath)
+            .value_parser(value_parser!(PathBuf))
+            .help("Run as if dingus was started in the provided directory."),
+        Arg::new(TIMINGS_ARG_ID)
+            .long(TIMINGS_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Print how long each step took once the command has finished."),
+        Arg::new(TAG_ARG_ID)
+            .long(TAG_ARG_NAME)
+            .value_name("TAG")
+            .action(ArgAction::Append)
+            .help("Only show commands with the provided tag in the help output. Can be used multiple times."),
+        Arg::new(PICK_CONFIG_ARG_ID)
+            .long(PICK_CONFIG_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Choose which config file to use when there are several in this directory and its parents."),
+        Arg::new(EXPORT_VARS_ARG_ID)
+            .long(EXPORT_VARS_ARG_NAME)
+            .value_name("FORMAT")
+            .value_parser(["bash", "fish"])
+            .num_args(0..=1)
+            .require_equals(true)
+            .default_missing_value("bash")
+            .help("Print the resolved variables as shell exports instead of executing the command."),
+        Arg::new(NO_SECRETS_ARG_ID)
+            .long(NO_SECRETS_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Exclude sensitive variables when using --export-vars."),
+        Arg::new(DESCRIBE_ARG_ID)
+            .long(DESCRIBE_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Print Markdown documentation for all of the commands and exit."),
+        Arg::new(LOG_FORMAT_ARG_ID)
+            .long(LOG_FORMAT_ARG_NAME)
+            .value_name("FORMAT")
+            .value_parser(["text", "json"])
+            .help("Log each step to stderr in the provided format. Secrets are redacted."),
+        Arg::new(LIST_FLAGS_ARG_ID)
+            .long(LIST_FLAGS_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .global(true)
+            .help("Print the inputs accepted by the command instead of executing it."),
+        Arg::new(BROWSE_ARG_ID)
+            .long(BROWSE_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Browse the commands interactively and choose one to run."),
+        Arg::new(PLAN_ARG_ID)
+            .long(PLAN_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .global(true)
+            .help("Print the order that the steps of the command will run in instead of executing it."),
+        Arg::new(DRY_RUN_ARG_ID)
+            .long(DRY_RUN_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Resolve the variables, then print the commands instead of executing them."),
+        Arg::new(VAR_ARG_ID)
+            .long(VAR_ARG_NAME)
+            .value_name("NAME=VALUE")
+            .value_parser(parse_variable_override)
+            .action(ArgAction::Append)
+            .help("Use the provided value for a variable, instead of resolving it. Can be used multiple times."),
+        Arg::new(ALLOW_MISSING_ARG_ID)
+            .long(ALLOW_MISSING_ARG_NAME)
+            .action(ArgAction::SetTrue)
+            .help("Execute raw commands even if they reference variables that aren't set."),
+    ]
+}
+
+/// Returns `true` if the --list-flags flag was specified anywhere on the command-line.
+pub fn is_list_flags_set(arg_matches: &ArgMatches) -> bool {
+    return arg_matches.get_flag(LIST_FLAGS_ARG_ID);
+}
+
+/// Returns `true` if the --plan flag was specified anywhere on the command-line.
+pub fn is_plan_set(arg_matches: &ArgMatches) -> bool {
+    return arg_matches.get_flag(PLAN_ARG_ID);
+}
+
+/// Returns the names of the subcommands that were matched, from the outermost to the innermost.
+pub fn subcommand_names(arg_matches: &ArgMatches) -> Vec<String> {
+    let mut names = Vec::new();
+    let mut current_matches = arg_matches;
+    while let Some((subcommand_name, subcommand_matches)) = current_matches.subcommand() {
+        names.push(subcommand_name.to_string());
+        current_matches = subcommand_matches;
+    }
+
+    return names;
+}
+
+/// Splits a variable override in the `NAME=VALUE` format into its name and value.
+fn parse_variable_override(variable_override: &str) -> Result<(String, String), String> {
+    return match variable_override.split_once('=') {
+        Some((name, value)) if !name.is_empty() => Ok((name.to_string(), value.to_string())),
+        _ => Err(format!(
+            "expected NAME=VALUE, found \"{}\"",
+            variable_override
+        )),
+    };
+}
+
+/// Hides any commands that don't have any of the provided tags, so that they're excluded from
+/// the --help output.
+/// Commands without a matching tag remain visible if any of their subcommands have one, and all
+/// subcommands of a command with a matching tag remain visible.
+pub fn hide_commands_without_tags(
+    commands: &CommandConfigMap,
+    tags: &Vec<String>,
+) -> CommandConfigMap {
+    return commands
+        .iter()
+        .map(|(key, command_config)| {
+            let mut command_config = command_config.clone();
+            if !has_any_tag(&command_config, tags) {
+                command_config.hidden =
+                    command_config.hidden || !has_any_tag_recursive(&command_config, tags);
+                command_config.commands =
+                    hide_commands_without_tags(&command_config.commands, tags);
+            }
+
+            (key.clone(), command_config)
+        })
+        .collect();
+}
+
+/// Removes any alias commands whose requirements aren't met, so that they aren't available at all.
+/// Requirements which can't be checked (e.g. the command doesn't exist) are treated as unmet.
+pub fn remove_unavailable_aliases(
+    commands: &CommandConfigMap,
+    command_executor: &dyn CommandExecutor,
+) -> CommandConfigMap {
+    return commands
+        .iter()
+        .filter(|(_, command_config)| match &command_config.action {
+            Some(ActionConfig::Alias(alias_config)) => {
+                preconditions::check_preconditions(&alias_config.requires, command_executor).is_ok()
+            }
+            _ => true,
+        })
+        .map(|(key, command_config)| {
+            let mut command_config = command_config.clone();
+            command_config.commands =
+                remove_unavailable_aliases(&command_config.commands, command_executor);
+            (key.clone(), command_config)
+        })
+        .collect();
+}
+
+fn has_any_tag(command_config: &CommandConfig, tags: &Vec<String>) -> bool {
+    return command_config.tags.iter().any(|tag| tags.contains(tag));
+}
+
+fn has_any_tag_recursive(command_config: &CommandConfig, tags: &Vec<String>) -> bool {
+    return has_any_tag(command_config, tags)
+        || command_config
+            .commands
+            .values()
+            .any(|subcommand_config| has_any_tag_recursive(subcommand_config, tags));
+}
 
 /// Creates a root-level [`Command`] for the provided [`Config`].
 pub fn create_root_command(
@@ -13,18 +407,30 @@ pub fn create_root_command(
     platform_provider: &Box<dyn PlatformProvider>,
 ) -> Command {
     let root_args = create_args(&config.options, &config.variables);
+
+    // Root-level variables are still resolved for subcommands when they aren't inherited, their
+    // arguments just need to be provided to the root command instead.
+    let inherited_variables = if config.options.inherit_root_args {
+        config.variables.clone()
+    } else {
+        VariableConfigMap::new()
+    };
+
     let subcommands = create_commands(
         &config.options,
         &config.commands,
-        &config.variables,
+        &inherited_variables,
         &platform_provider,
     );
 
-    let mut root_command = Command::new("dingus")
+    let name = program_name(config.name.as_ref(), env::args_os().next());
+    let mut root_command = Command::new(name.clone())
+        .bin_name(name)
         .version(env!("CARGO_PKG_VERSION"))
         .subcommands(subcommands)
         .subcommand_required(true)
         .arg_required_else_help(true)
+        .args(create_global_args())
         .args(root_args);
 
     if let Some(description) = &config.description {
@@ -34,6 +440,30 @@ pub fn create_root_command(
     return root_command;
 }
 
+/// Determines the name of the program to show in the help output.
+/// The name from the config is preferred, otherwise the name of the executable is used.
+fn program_name(config_name: Option<&String>, executable_path: Option<OsString>) -> String {
+    if let Some(config_name) = config_name {
+        return config_name.clone();
+    }
+
+    let executable_name = executable_path.and_then(|executable_path| {
+        let file_name = Path::new(&executable_path)
+            .file_name()?
+            .to_str()?
+            .to_string();
+        return Some(file_name);
+    });
+
+    return match executable_name {
+        Some(executable_name) => match executable_name.strip_suffix(".exe") {
+            Some(stripped_name) => stripped_name.to_string(),
+            None => executable_name,
+        },
+        None => DEFAULT_PROGRAM_NAME.to_string(),
+    };
+}
+
 fn create_commands(
     dingus_options: &DingusOptions,
     commands: &CommandConfigMap,
@@ -116,6 +546,10 @@ fn create_args(
                 VariableConfig::ShorthandLiteral(_) => None,
                 VariableConfig::Literal(literal) => literal.clone().argument,
                 VariableConfig::Execution(exec) => exec.clone().argument,
+                VariableConfig::ExitCode(exit_code) => exit_code.clone().argument,
+                VariableConfig::Http(http) => http.clone().argument,
+                VariableConfig::File(file) => file.clone().argument,
+                VariableConfig::Keyring(keyring) => keyring.clone().argument,
                 VariableConfig::Prompt(prompt) => prompt.clone().argument,
                 VariableConfig::Argument(argument) => Some(argument.clone().argument),
             };
@@ -159,10 +593,21 @@ fn create_args(
                     }
                 };
 
+                // Fall back to the variable's description if the argument doesn't have one
+                if arg.get_help().is_none() {
+                    if let Some(description) = var_config.description() {
+                        arg = arg.help(description)
+                    }
+                }
+
                 // Set the default value if applicable
+                // Values sourced from the environment take precedence over the literal value, so it
+                // can't be used as the argument's default.
                 match var_config {
                     VariableConfig::ShorthandLiteral(literal) => arg = arg.default_value(literal),
-                    VariableConfig::Literal(literal) => arg = arg.default_value(&literal.value),
+                    VariableConfig::Literal(literal) if literal.from_env.is_none() => {
+                        arg = arg.default_value(&literal.value)
+                    }
                     _ => {}
                 }
 
@@ -218,7 +663,8 @@ pub fn find_subcommand(
     return None;
 }
 
-fn find_command_by_name(
+/// Finds the [`CommandConfig`] with the provided name, taking any custom names into account.
+pub fn find_command_by_name(
     command_name: &String,
     available_commands: &CommandConfigMap,
 ) -> Option<CommandConfig> {
@@ -243,6 +689,23 @@ fn find_command_by_name(
     return None;
 }
 
+/// Finds the command at the provided path.
+/// Subcommands are separated by spaces, the same way they would be on the command-line.
+pub fn find_command_by_path(
+    command_path: &str,
+    available_commands: &CommandConfigMap,
+) -> Option<CommandConfig> {
+    let mut commands = available_commands.clone();
+    let mut found_command = None;
+    for name in command_path.split_whitespace() {
+        let command_config = find_command_by_name(&name.to_string(), &commands)?;
+        commands = command_config.commands.clone();
+        found_command = Some(command_config);
+    }
+
+    return found_command;
+}
+
 type SubcommandSearchResult = (CommandConfig, VariableConfigMap, ArgMatches);
 
 #[cfg(test)]
@@ -251,12 +714,16 @@ mod tests {
     use crate::config::ArgumentConfigVariant::Named;
     use crate::config::OneOrManyPlatforms::{Many, One};
     use crate::config::RawCommandConfigVariant::Shorthand;
+    use crate::config::{parse_config, Platform::Linux};
     use crate::config::{
         ActionConfig, AliasActionConfig, CommandConfig, DingusOptions, ExecutionVariableConfig,
         LiteralVariableConfig, ManyPlatforms, OnePlatform, Platform, PositionalArgumentConfig,
-        PromptConfig, PromptVariableConfig, SingleActionConfig, VariableConfig,
+        PromptConfig, PromptConfigVariant, PromptVariableConfig, SingleActionConfig,
+        VariableConfig,
     };
+    use crate::exec::{ExitStatus, MockCommandExecutor, Output};
     use crate::platform::MockPlatformProvider;
+    use linked_hash_map::LinkedHashMap;
 
     fn mock_platform_provider() -> Box<dyn PlatformProvider> {
         let mut platform_provider = MockPlatformProvider::new();
@@ -274,17 +741,13 @@ mod tests {
         subcommands.insert(
             "sub-1".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Sub 1 description".to_string()),
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -297,17 +760,14 @@ mod tests {
         subcommands.insert(
             "sub-2".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Sub 2 description".to_string()),
-                hidden: false,
                 variables: subcommand_variables,
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -359,6 +819,11 @@ mod tests {
                 )),
                 argument: None,
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
             }),
         );
         subcommand_variables.insert(
@@ -370,10 +835,16 @@ mod tests {
                     short: None,
                 })),
                 environment_variable_name: None,
-                prompt: PromptConfig {
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
                     message: "What's your name?".to_string(),
                     options: Default::default(),
-                },
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -381,17 +852,13 @@ mod tests {
         subcommands.insert(
             "sub".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
-                description: None,
-                hidden: false,
                 variables: subcommand_variables,
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -406,6 +873,10 @@ mod tests {
                 value: "bar".to_string(),
                 argument: Some(ArgumentConfigVariant::Shorthand("parent-arg-2".to_string())),
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -460,10 +931,16 @@ mod tests {
                     short: None,
                 })),
                 environment_variable_name: None,
-                prompt: PromptConfig {
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
                     message: "What's your name?".to_string(),
                     options: Default::default(),
-                },
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -471,17 +948,13 @@ mod tests {
         subsubcommands.insert(
             "sub-again".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
-                description: None,
-                hidden: false,
                 variables: subsubcommand_variables,
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -494,6 +967,11 @@ mod tests {
                 )),
                 argument: Some(ArgumentConfigVariant::Shorthand("sub-arg-1".to_string())),
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -501,10 +979,6 @@ mod tests {
         subcommands.insert(
             "sub".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
-                description: None,
-                hidden: false,
                 variables: subcommand_variables,
                 commands: subsubcommands,
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
@@ -512,6 +986,7 @@ mod tests {
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -554,17 +1029,12 @@ mod tests {
         subsubcommands.insert(
             "sub-again".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
-                description: None,
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -572,13 +1042,8 @@ mod tests {
         subcommands.insert(
             "sub".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
-                description: None,
-                hidden: false,
-                variables: Default::default(),
                 commands: subsubcommands,
-                action: None,
+                ..Default::default()
             },
         );
 
@@ -608,15 +1073,11 @@ mod tests {
         subcommands.insert(
             "alias".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
-                description: None,
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::Alias(AliasActionConfig {
                     alias: "docker compose".to_string(),
+                    requires: Vec::new(),
                 })),
+                ..Default::default()
             },
         );
 
@@ -655,16 +1116,12 @@ mod tests {
             "demo".to_string(),
             CommandConfig {
                 name: Some("demonstration".to_string()),
-                platform: None,
-                description: None,
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -695,14 +1152,12 @@ mod tests {
                     platform: Platform::Linux,
                 })),
                 description: Some("Demo command on Linux.".to_string()),
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -714,14 +1169,12 @@ mod tests {
                     platform: Platform::MacOS,
                 })),
                 description: Some("Demo command on macOS.".to_string()),
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -733,14 +1186,12 @@ mod tests {
                     platforms: vec![Platform::Linux, Platform::MacOS],
                 })),
                 description: Some("Demo command on Unix.".to_string()),
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -752,14 +1203,12 @@ mod tests {
                     platform: Platform::Windows,
                 })),
                 description: Some("Demo command on Windows.".to_string()),
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "Write-Host \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -812,6 +1261,10 @@ mod tests {
                 value: "bar".to_string(),
                 argument: None,
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
         variables.insert(
@@ -822,6 +1275,11 @@ mod tests {
                 )),
                 argument: Some(ArgumentConfigVariant::Shorthand("var-3".to_string())),
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
             }),
         );
         variables.insert(
@@ -833,10 +1291,16 @@ mod tests {
                     short: Some('v'),
                 })),
                 environment_variable_name: None,
-                prompt: PromptConfig {
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
                     message: "What's your name?".to_string(),
                     options: Default::default(),
-                },
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
         variables.insert(
@@ -849,10 +1313,16 @@ mod tests {
                     },
                 )),
                 environment_variable_name: None,
-                prompt: PromptConfig {
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
                     message: "What's your age?".to_string(),
                     options: Default::default(),
-                },
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -879,6 +1349,54 @@ mod tests {
         assert_eq!(var5.get_help().unwrap().to_string(), "Fifth variable");
     }
 
+    #[test]
+    fn create_args_uses_variable_description_as_help() {
+        // Arrange
+        let options = DingusOptions::default();
+
+        let mut variables = VariableConfigMap::new();
+        variables.insert(
+            "name".to_string(),
+            VariableConfig::Prompt(PromptVariableConfig {
+                description: Some("Your name".to_string()),
+                argument: Some(ArgumentConfigVariant::Shorthand("name".to_string())),
+                environment_variable_name: None,
+                prompt: PromptConfigVariant::Shorthand("What's your name?".to_string()),
+                options: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+        variables.insert(
+            "age".to_string(),
+            VariableConfig::Literal(LiteralVariableConfig {
+                description: Some("Your age".to_string()),
+                argument: Some(ArgumentConfigVariant::Named(NamedArgumentConfig {
+                    description: Some("How old you are".to_string()),
+                    long: "age".to_string(),
+                    short: None,
+                })),
+                environment_variable_name: None,
+                value: "100".to_string(),
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        // Act
+        let args = create_args(&options, &variables);
+
+        // Assert
+        let name = args.iter().find(|v| v.get_id() == "name").unwrap();
+        assert_eq!(name.get_help().unwrap().to_string(), "Your name");
+
+        // The argument's own description takes precedence
+        let age = args.iter().find(|v| v.get_id() == "age").unwrap();
+        assert_eq!(age.get_help().unwrap().to_string(), "How old you are");
+    }
+
     #[test]
     fn auto_args_creates_correct_args() {
         // Arrange
@@ -886,6 +1404,20 @@ mod tests {
             print_commands: false,
             print_variables: false,
             auto_args: true,
+            log_answers: false,
+            redact: Vec::new(),
+            print_timings: false,
+            bash_args: Vec::new(),
+            shell: "bash".to_string(),
+            lazy_prompts: false,
+            inherit_root_args: true,
+            allow_stdin: false,
+            capture_stdin: false,
+            prompt_symbol: "?".to_string(),
+            allow_missing_variables: false,
+            review_answers: false,
+            notify: None,
+            nice: None,
         };
 
         let mut variables = VariableConfigMap::new();
@@ -895,6 +1427,10 @@ mod tests {
                 value: "foo".to_string(),
                 argument: None,
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -904,6 +1440,10 @@ mod tests {
                 value: "bar".to_string(),
                 argument: Some(ArgumentConfigVariant::Shorthand("existing".to_string())),
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -940,26 +1480,27 @@ mod tests {
         commands.insert(
             "cmd".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Top-level command".to_string()),
-                hidden: false,
                 variables: subcommand_variables,
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
         let config = Config {
             imports: Default::default(),
+            include: None,
             description: None,
+            name: None,
             variables: root_variables,
             commands: commands,
             options: DingusOptions::default(),
+            working_directory: None,
+            env: LinkedHashMap::new(),
         };
 
         let platform_provider = mock_platform_provider();
@@ -1011,10 +1552,7 @@ mod tests {
         subcommands.insert(
             "sub".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Subcommand".to_string()),
-                hidden: false,
                 variables: subcommand_variables,
                 commands: CommandConfigMap::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
@@ -1022,6 +1560,7 @@ mod tests {
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -1029,10 +1568,7 @@ mod tests {
         target_commands.insert(
             "target".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Mid-level command".to_string()),
-                hidden: false,
                 variables: command_variables,
                 commands: subcommands,
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
@@ -1040,6 +1576,7 @@ mod tests {
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -1047,10 +1584,7 @@ mod tests {
         parent_commands.insert(
             "parent".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Top-level command".to_string()),
-                hidden: false,
                 variables: parent_command_variables,
                 commands: target_commands,
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
@@ -1058,15 +1592,20 @@ mod tests {
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
         let config = Config {
             imports: Default::default(),
+            include: None,
             description: None,
+            name: None,
             variables: root_variables,
             commands: parent_commands,
             options: DingusOptions::default(),
+            working_directory: None,
+            env: LinkedHashMap::new(),
         };
 
         let platform_provider = mock_platform_provider();
@@ -1116,17 +1655,14 @@ mod tests {
         target_commands.insert(
             "subcommand".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Bottom-level command".to_string()),
-                hidden: false,
                 variables: command_variables,
-                commands: CommandConfigMap::new(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
@@ -1134,10 +1670,7 @@ mod tests {
         parent_commands.insert(
             "parent".to_string(),
             CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Top-level command".to_string()),
-                hidden: false,
                 variables: parent_command_variables,
                 commands: target_commands,
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
@@ -1145,15 +1678,20 @@ mod tests {
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
         let config = Config {
             imports: Default::default(),
+            include: None,
             description: None,
+            name: None,
             variables: root_variables,
             commands: parent_commands,
             options: DingusOptions::default(),
+            working_directory: None,
+            env: LinkedHashMap::new(),
         };
 
         let platform_provider = mock_platform_provider();
@@ -1184,25 +1722,26 @@ mod tests {
             "cmd".to_string(),
             CommandConfig {
                 name: Some("command".to_string()),
-                platform: None,
                 description: Some("Command with custom name".to_string()),
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
         let config = Config {
             imports: Default::default(),
+            include: None,
             description: None,
+            name: None,
             variables: Default::default(),
             commands: commands,
             options: DingusOptions::default(),
+            working_directory: None,
+            env: LinkedHashMap::new(),
         };
 
         let platform_provider = mock_platform_provider();
@@ -1231,24 +1770,26 @@ mod tests {
             CommandConfig {
                 name: Some("command".to_string()),
                 hidden: true,
-                platform: None,
                 description: Some("Command with custom name".to_string()),
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(Shorthand(
                         "echo \"Hello, World!\"".to_string(),
                     )),
                 })),
+                ..Default::default()
             },
         );
 
         let config = Config {
             imports: Default::default(),
+            include: None,
             description: None,
+            name: None,
             variables: Default::default(),
             commands: commands,
             options: DingusOptions::default(),
+            working_directory: None,
+            env: LinkedHashMap::new(),
         };
 
         let platform_provider = mock_platform_provider();
@@ -1268,4 +1809,598 @@ mod tests {
             Some("Command with custom name".to_string())
         );
     }
+
+    #[test]
+    fn hide_commands_without_tags_hides_commands_without_matching_tags() {
+        // Arrange
+        let yaml = "commands:
+    build:
+        tags: [ci]
+        action: ./build.sh
+    deploy:
+        tags: [danger]
+        action: ./deploy.sh
+    db:
+        commands:
+            migrate:
+                tags: [ci, danger]
+                action: ./migrate.sh
+            seed:
+                action: ./seed.sh
+    clean:
+        action: rm -rf ./build";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+
+        // Act
+        let commands = hide_commands_without_tags(&config.commands, &vec!["ci".to_string()]);
+
+        // Assert
+        let visible_names = |commands: &CommandConfigMap| -> Vec<String> {
+            let mut names: Vec<String> = commands
+                .iter()
+                .filter(|(_, command_config)| !command_config.hidden)
+                .map(|(key, _)| key.clone())
+                .collect();
+            names.sort();
+            names
+        };
+
+        assert_eq!(visible_names(&commands), vec!["build", "db"]);
+        assert_eq!(
+            visible_names(&commands.get("db").unwrap().commands),
+            vec!["migrate"]
+        );
+    }
+
+    #[test]
+    fn remove_unavailable_aliases_removes_aliases_with_unmet_requirements() {
+        // Arrange
+        let yaml = "commands:
+    k:
+        alias: kubectl
+        requires:
+            - command_succeeds: kubectl version --client
+    tf:
+        alias: terraform
+        requires:
+            - command_succeeds: terraform version
+    tools:
+        commands:
+            h:
+                alias: helm
+                requires:
+                    - command_succeeds: helm version
+    dc:
+        alias: docker compose";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .returning(|execution_config, _| {
+                let status = match execution_config.command_template().as_str() {
+                    "kubectl version --client" => ExitStatus::Success,
+                    _ => ExitStatus::Fail(127),
+                };
+                Ok(Output {
+                    status,
+                    stdout: vec![],
+                    stderr: vec![],
+                })
+            });
+
+        // Act
+        let commands = remove_unavailable_aliases(&config.commands, &command_executor);
+
+        // Assert
+        let mut names: Vec<&String> = commands.keys().collect();
+        names.sort();
+        assert_eq!(names, vec!["dc", "k", "tools"]);
+        assert!(commands.get("tools").unwrap().commands.is_empty());
+    }
+
+    #[test]
+    fn hide_commands_without_tags_keeps_subcommands_of_tagged_commands() {
+        // Arrange
+        let yaml = "commands:
+    db:
+        tags: [ci]
+        commands:
+            migrate:
+                action: ./migrate.sh
+            seed:
+                action: ./seed.sh";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+
+        // Act
+        let commands = hide_commands_without_tags(&config.commands, &vec!["ci".to_string()]);
+
+        // Assert
+        let db_command = commands.get("db").unwrap();
+        assert!(!db_command.hidden);
+        assert!(db_command
+            .commands
+            .values()
+            .all(|command_config| !command_config.hidden));
+    }
+
+    #[test]
+    fn find_subcommand_prefers_child_variables_over_parent_variables() {
+        // Arrange
+        let yaml = "variables:
+    greeting: Hello
+    name: Godzilla
+commands:
+    parent:
+        variables:
+            name: Mothra
+        commands:
+            child:
+                variables:
+                    greeting: Howdy
+                action: echo $greeting $name";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+        let root_command = create_root_command(&config, &mock_platform_provider());
+        let matches = root_command
+            .clone()
+            .get_matches_from(vec!["dingus", "parent", "child"]);
+
+        // Act
+        let (_, variables, _) =
+            find_subcommand(&matches, &root_command, &config.commands, &config.variables).unwrap();
+
+        // Assert
+        let greeting = variables.get("greeting").unwrap();
+        let name = variables.get("name").unwrap();
+        assert!(matches!(greeting, VariableConfig::ShorthandLiteral(value) if value == "Howdy"));
+        assert!(matches!(name, VariableConfig::ShorthandLiteral(value) if value == "Mothra"));
+    }
+
+    #[test]
+    fn create_root_command_inherits_root_args_by_default() {
+        // Arrange
+        let yaml = "variables:
+    name:
+        value: Dingus
+        arg: name
+commands:
+    greet:
+        variables:
+            age:
+                value: 42
+                arg: age
+        action: echo $name $age";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+
+        // Act
+        let root_command = create_root_command(&config, &mock_platform_provider());
+
+        // Assert
+        let greet_command = root_command.find_subcommand("greet").unwrap();
+        let arg_ids: Vec<&str> = greet_command
+            .get_arguments()
+            .map(|arg| arg.get_id().as_str())
+            .collect();
+        assert!(arg_ids.contains(&"name"));
+        assert!(arg_ids.contains(&"age"));
+    }
+
+    #[test]
+    fn create_root_command_does_not_inherit_root_args_when_disabled() {
+        // Arrange
+        let yaml = "options:
+    inherit_root_args: false
+variables:
+    name:
+        value: Dingus
+        arg: name
+commands:
+    greet:
+        variables:
+            age:
+                value: 42
+                arg: age
+        action: echo $name $age";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+
+        // Act
+        let root_command = create_root_command(&config, &mock_platform_provider());
+
+        // Assert
+        assert!(root_command
+            .get_arguments()
+            .any(|arg| arg.get_id().as_str() == "name"));
+
+        let greet_command = root_command.find_subcommand("greet").unwrap();
+        let arg_ids: Vec<&str> = greet_command
+            .get_arguments()
+            .map(|arg| arg.get_id().as_str())
+            .collect();
+        assert!(!arg_ids.contains(&"name"));
+        assert!(arg_ids.contains(&"age"));
+
+        // Root-level variables are still available to the subcommand
+        let arg_matches = root_command
+            .clone()
+            .get_matches_from(vec!["dingus", "--name", "Dungus", "greet"]);
+        let (_, variables, _) = find_subcommand(
+            &arg_matches,
+            &root_command,
+            &config.commands,
+            &config.variables,
+        )
+        .unwrap();
+        assert!(variables.contains_key("name"));
+    }
+
+    #[test]
+    fn program_name_prefers_config_name() {
+        // Act
+        let name = program_name(
+            Some(&"acme".to_string()),
+            Some(OsString::from("/usr/local/bin/dingus")),
+        );
+
+        // Assert
+        assert_eq!(name, "acme");
+    }
+
+    #[test]
+    fn program_name_uses_executable_name() {
+        // Act
+        let unix_name = program_name(None, Some(OsString::from("/usr/local/bin/acme")));
+        let windows_name = program_name(None, Some(OsString::from("acme.exe")));
+        let default_name = program_name(None, None);
+
+        // Assert
+        assert_eq!(unix_name, "acme");
+        assert_eq!(windows_name, "acme");
+        assert_eq!(default_name, "dingus");
+    }
+
+    #[test]
+    fn create_root_command_uses_program_name_in_help() {
+        // Arrange
+        let yaml = "name: acme
+commands:
+    greet:
+        action: echo Hello";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+
+        // Act
+        let mut root_command = create_root_command(&config, &mock_platform_provider());
+
+        // Assert
+        assert_eq!(root_command.get_name(), "acme");
+        assert_eq!(root_command.get_bin_name(), Some("acme"));
+        assert!(root_command
+            .render_usage()
+            .to_string()
+            .starts_with("Usage: acme"));
+        assert!(root_command
+            .render_help()
+            .to_string()
+            .contains("Usage: acme"));
+    }
+
+    #[test]
+    fn create_root_command_allows_variables_named_after_plan() {
+        // Arrange
+        let yaml = "commands:
+    deploy:
+        variables:
+            plan:
+                arg: target
+                value: production
+        action: ./deploy.sh $plan";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+        let root_command = create_root_command(&config, &mock_platform_provider());
+
+        // Act
+        let matches = root_command
+            .get_matches_from(vec!["dingus", "deploy", "--plan", "--target", "staging"]);
+
+        // Assert
+        let (_, subcommand_matches) = matches.subcommand().unwrap();
+        assert!(is_plan_set(subcommand_matches));
+        assert_eq!(
+            subcommand_matches.get_one::<String>("plan"),
+            Some(&"staging".to_string())
+        );
+    }
+
+    #[test]
+    fn create_root_command_allows_variables_named_after_list_flags() {
+        // Arrange
+        let yaml = "commands:
+    deploy:
+        variables:
+            list-flags:
+                arg: flags
+                value: none
+        action: ./deploy.sh";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+        let root_command = create_root_command(&config, &mock_platform_provider());
+
+        // Act
+        let matches = root_command.get_matches_from(vec![
+            "dingus",
+            "deploy",
+            "--list-flags",
+            "--flags",
+            "all",
+        ]);
+
+        // Assert
+        let (_, subcommand_matches) = matches.subcommand().unwrap();
+        assert!(is_list_flags_set(subcommand_matches));
+        assert_eq!(
+            subcommand_matches.get_one::<String>("list-flags"),
+            Some(&"all".to_string())
+        );
+    }
+
+    #[test]
+    fn create_root_command_allows_root_variables_named_after_global_args() {
+        // Arrange
+        let yaml = "variables:
+    tag:
+        arg: release-tag
+        value: latest
+    config:
+        arg: profile
+        value: debug
+commands:
+    build:
+        action: ./build.sh $tag $config";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+        let root_command = create_root_command(&config, &mock_platform_provider());
+
+        // Act
+        let matches = root_command.get_matches_from(vec![
+            "dingus",
+            "--tag",
+            "ci",
+            "--release-tag",
+            "v1.0.0",
+            "--profile",
+            "release",
+            "build",
+        ]);
+
+        // Assert
+        assert_eq!(
+            matches.get_one::<String>("tag"),
+            Some(&"v1.0.0".to_string())
+        );
+        assert_eq!(
+            matches.get_one::<String>("config"),
+            Some(&"release".to_string())
+        );
+        assert_eq!(
+            matches
+                .get_many::<String>(TAG_ARG_ID)
+                .unwrap()
+                .collect::<Vec<_>>(),
+            vec!["ci"]
+        );
+    }
+
+    #[test]
+    fn parse_global_args_finds_tags() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "--tag", "ci", "--tag", "danger"]);
+
+        // Assert
+        assert_eq!(global_args.tags, vec!["ci", "danger"]);
+    }
+
+    #[test]
+    fn parse_global_args_finds_show_config_path() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "--show-config-path"]);
+
+        // Assert
+        assert!(global_args.show_config_path);
+    }
+
+    #[test]
+    fn parse_global_args_finds_allow_missing() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "--allow-missing", "greet"]);
+
+        // Assert
+        assert!(global_args.allow_missing);
+    }
+
+    #[test]
+    fn parse_global_args_finds_log_answers() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "--log-answers", "greet"]);
+
+        // Assert
+        assert!(global_args.log_answers);
+        assert!(!global_args.show_config_path);
+    }
+
+    #[test]
+    fn parse_global_args_finds_config() {
+        // Act
+        let global_args = parse_global_args(vec![
+            "dingus",
+            "--config",
+            "https://example.com/dingus.yaml",
+            "greet",
+        ]);
+
+        // Assert
+        assert_eq!(
+            global_args.config,
+            Some("https://example.com/dingus.yaml".to_string())
+        );
+        assert!(!global_args.allow_insecure_config);
+    }
+
+    #[test]
+    fn parse_global_args_finds_short_config() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "-c", "./tools/dingus.yaml", "greet"]);
+
+        // Assert
+        assert_eq!(global_args.config, Some("./tools/dingus.yaml".to_string()));
+    }
+
+    #[test]
+    fn parse_global_args_finds_working_directory() {
+        // Act
+        let short_global_args = parse_global_args(vec!["dingus", "-C", "docs", "greet"]);
+        let long_global_args = parse_global_args(vec!["dingus", "--working-dir", "docs", "greet"]);
+
+        // Assert
+        assert_eq!(
+            short_global_args.working_directory,
+            Some(PathBuf::from("docs"))
+        );
+        assert_eq!(
+            long_global_args.working_directory,
+            Some(PathBuf::from("docs"))
+        );
+    }
+
+    #[test]
+    fn parse_global_args_finds_pick_config() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "--pick-config", "greet"]);
+
+        // Assert
+        assert!(global_args.pick_config);
+    }
+
+    #[test]
+    fn parse_global_args_finds_describe() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "--describe"]);
+
+        // Assert
+        assert!(global_args.describe);
+    }
+
+    #[test]
+    fn parse_global_args_finds_browse() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "--browse"]);
+
+        // Assert
+        assert!(global_args.browse);
+    }
+
+    #[test]
+    fn parse_global_args_finds_dry_run() {
+        // Act
+        let default_global_args = parse_global_args(vec!["dingus", "deploy"]);
+        let dry_run_global_args = parse_global_args(vec!["dingus", "--dry-run", "deploy"]);
+
+        // Assert
+        assert!(!default_global_args.dry_run);
+        assert!(dry_run_global_args.dry_run);
+    }
+
+    #[test]
+    fn parse_global_args_finds_variable_overrides() {
+        // Act
+        let global_args = parse_global_args(vec![
+            "dingus",
+            "--var",
+            "name=Godzilla",
+            "--var",
+            "query=a=b",
+            "greet",
+        ]);
+
+        // Assert
+        assert_eq!(global_args.variable_overrides.len(), 2);
+        assert_eq!(global_args.variable_overrides["name"], "Godzilla");
+        assert_eq!(global_args.variable_overrides["query"], "a=b");
+    }
+
+    #[test]
+    fn parse_global_args_ignores_invalid_variable_overrides() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "--var", "name", "--dry-run", "greet"]);
+
+        // Assert
+        assert!(global_args.variable_overrides.is_empty());
+        assert!(global_args.dry_run);
+    }
+
+    #[test]
+    fn subcommand_names_lists_matched_subcommands() {
+        // Arrange
+        let yaml = "commands:
+    db:
+        commands:
+            migrate:
+                action: ./migrate.sh";
+        let config = parse_config(&yaml.to_string(), Linux).unwrap();
+        let root_command = create_root_command(&config, &mock_platform_provider());
+        let arg_matches = root_command.get_matches_from(vec!["dingus", "db", "migrate"]);
+
+        // Act
+        let names = subcommand_names(&arg_matches);
+
+        // Assert
+        assert_eq!(names, vec!["db".to_string(), "migrate".to_string()]);
+    }
+
+    #[test]
+    fn variable_overrides_must_have_a_name_and_value() {
+        // Arrange
+        let config = parse_config(&"commands: {}".to_string(), Linux).unwrap();
+        let root_command = create_root_command(&config, &mock_platform_provider());
+
+        // Act
+        let result = root_command.try_get_matches_from(vec!["dingus", "--var", "name"]);
+
+        // Assert
+        assert!(result.is_err());
+    }
+
+    #[test]
+    fn parse_global_args_finds_log_format() {
+        // Act
+        let default_global_args = parse_global_args(vec!["dingus", "greet"]);
+        let json_global_args = parse_global_args(vec!["dingus", "--log-format", "json", "greet"]);
+
+        // Assert
+        assert_eq!(default_global_args.log_format, LogFormat::Text);
+        assert_eq!(json_global_args.log_format, LogFormat::Json);
+    }
+
+    #[test]
+    fn parse_global_args_finds_export_format() {
+        // Act
+        let default_global_args = parse_global_args(vec!["dingus", "--export-vars", "deploy"]);
+        let fish_global_args = parse_global_args(vec![
+            "dingus",
+            "--export-vars=fish",
+            "--no-secrets",
+            "deploy",
+        ]);
+
+        // Assert
+        assert_eq!(default_global_args.export_format, Some(ExportFormat::Bash));
+        assert!(!default_global_args.no_secrets);
+        assert_eq!(fish_global_args.export_format, Some(ExportFormat::Fish));
+        assert!(fish_global_args.no_secrets);
+    }
+
+    #[test]
+    fn parse_global_args_ignores_unknown_args_and_subcommands() {
+        // Act
+        let global_args = parse_global_args(vec!["dingus", "greet", "--name", "Dingus"]);
+
+        // Assert
+        assert!(!global_args.show_config_path);
+        assert!(!global_args.log_answers);
+    }
 }
@@ -1,14 +1,48 @@
+use crate::cli::{find_command_by_name, find_command_by_path, RESERVED_ARG_ID_PREFIX};
 use crate::platform::{current_platform_provider, is_current_platform};
+use crate::remote;
+use crate::remote::RemoteError;
 use linked_hash_map::LinkedHashMap;
+use regex::Regex;
 use serde::{Deserialize, Serialize};
 use std::collections::HashMap;
+use std::fmt::Formatter;
 use std::io::IsTerminal;
 use std::io::Read;
-use std::path::PathBuf;
-use std::{env, fs, io};
+use std::path::{Path, PathBuf};
+use std::{env, fmt, fs, io};
 use thiserror::Error;
 
-const CONFIG_FILE_NAMES: [&str; 4] = ["dingus.yaml", "Dingus.yaml", "dingus.yml", "Dingus.yml"];
+const CONFIG_FILE_NAMES: [&str; 10] = [
+    "dingus.yaml",
+    "Dingus.yaml",
+    "dingus.yml",
+    "Dingus.yml",
+    "dingus.toml",
+    "Dingus.toml",
+    "dingus.json",
+    "Dingus.json",
+    ".dingus.yaml",
+    ".dingus.yml",
+];
+
+/// The shells which can be used to execute shell commands.
+const SUPPORTED_SHELLS: [&str; 8] = [
+    "bash",
+    "sh",
+    "zsh",
+    "dash",
+    "ksh",
+    "powershell",
+    "pwsh",
+    "cmd",
+];
+
+/// The shell used by default when the user's shell can't be used, as long as it's installed.
+const DEFAULT_SHELL: &str = "bash";
+
+/// The shell used when neither the user's shell nor the default shell can be used.
+const FALLBACK_SHELL: &str = "sh";
 
 const DEFAULT_CONFIG_FILE: &str = "description: My Dingus file
 
@@ -20,65 +54,158 @@ commands:
     action: echo \"Hello, $name!\"";
 
 pub enum Source {
-    Unknown,
     Stdin,
     File(PathBuf),
+    Url(String),
+}
+
+impl fmt::Display for Source {
+    fn fmt(&self, f: &mut Formatter<'_>) -> fmt::Result {
+        match self {
+            Source::Stdin => write!(f, "<stdin>"),
+            Source::File(path) => write!(f, "{}", path.display()),
+            Source::Url(url) => write!(f, "{}", url),
+        }
+    }
+}
+
+/// The formats that a config file can be written in.
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub enum ConfigFormat {
+    Yaml,
+    Toml,
+    Json,
+}
+
+impl ConfigFormat {
+    /// Determines the [`ConfigFormat`] from the extension of the provided path.
+    /// Paths without an extension are assumed to be YAML.
+    pub fn from_path(path: &Path) -> Result<ConfigFormat, ConfigError> {
+        let Some(extension) = path.extension() else {
+            return Ok(ConfigFormat::Yaml);
+        };
+
+        return match extension.to_string_lossy().to_lowercase().as_str() {
+            "yaml" | "yml" => Ok(ConfigFormat::Yaml),
+            "toml" => Ok(ConfigFormat::Toml),
+            "json" => Ok(ConfigFormat::Json),
+            extension => Err(ConfigError::UnsupportedFormat {
+                extension: extension.to_string(),
+            }),
+        };
+    }
+}
+
+impl fmt::Display for ConfigFormat {
+    fn fmt(&self, f: &mut Formatter<'_>) -> fmt::Result {
+        match self {
+            ConfigFormat::Yaml => write!(f, "YAML"),
+            ConfigFormat::Toml => write!(f, "TOML"),
+            ConfigFormat::Json => write!(f, "JSON"),
+        }
+    }
 }
 
 pub struct FoundConfig {
     pub source: Source,
+    pub format: ConfigFormat,
+    pub text: String,
     pub config: Config,
 }
 
-/// Loads the [`Config`] from stdin, or a file in the current directory.
-pub fn load() -> Result<FoundConfig, ConfigError> {
+/// Loads the [`Config`] from the provided location, stdin, or a file in the current directory.
+/// The location can either be a path to a file, or a URL.
+/// Plain HTTP URLs are only allowed when `allow_insecure` is `true`, and remote configs must match
+/// the SHA-256 `checksum` when one is provided.
+pub fn load(
+    location: Option<&String>,
+    allow_insecure: bool,
+    checksum: Option<&String>,
+) -> Result<FoundConfig, ConfigError> {
     let input = io::stdin();
 
-    let mut source = Source::Unknown;
     let mut config_text = String::new();
-
-    if input.is_terminal() {
-        let mut found = false;
-        let mut directory = env::current_dir().unwrap();
-        while !found {
-            for config_file_name in CONFIG_FILE_NAMES {
-                let config_file_path = directory.join(config_file_name);
-                if !config_file_path.exists() {
-                    continue;
+    let mut format = ConfigFormat::Yaml;
+
+    let source = if let Some(location) = location {
+        if remote::is_remote(location) {
+            config_text = remote::fetch_config(location, allow_insecure, checksum)
+                .map_err(|err| ConfigError::FetchFailed(err))?;
+            Source::Url(location.clone())
+        } else {
+            let config_file_path = PathBuf::from(location);
+            format = ConfigFormat::from_path(&config_file_path)?;
+            config_text = fs::read_to_string(&config_file_path).map_err(|err| {
+                if err.kind() == io::ErrorKind::NotFound {
+                    return ConfigError::PathNotFound {
+                        path: config_file_path.clone(),
+                    };
                 }
 
-                source = Source::File(config_file_path.clone());
-                config_text = fs::read_to_string(config_file_path)
-                    .map_err(|err| ConfigError::ReadFailed(err))?;
-                found = true;
-                break;
-            }
-
-            if found {
-                break;
-            }
+                return ConfigError::ReadFailed(err);
+            })?;
 
-            if let Some(parent) = directory.parent() {
-                directory = parent.to_owned();
-            } else {
-                break;
-            }
+            // Relative paths need to be made absolute so that the config file has a parent
+            // directory to run commands from
+            let config_file_path =
+                fs::canonicalize(&config_file_path).map_err(|err| ConfigError::ReadFailed(err))?;
+            Source::File(config_file_path)
         }
-
-        if !found {
+    } else if input.is_terminal() {
+        let current_dir = env::current_dir().map_err(|err| ConfigError::ReadFailed(err))?;
+        let Some(config_file_path) = find_config_file(&current_dir) else {
             return Err(ConfigError::FileNotFound);
-        }
+        };
+
+        format = ConfigFormat::from_path(&config_file_path)?;
+        config_text =
+            fs::read_to_string(&config_file_path).map_err(|err| ConfigError::ReadFailed(err))?;
+        Source::File(config_file_path)
     } else {
-        source = Source::Stdin;
         input
             .lock()
             .read_to_string(&mut config_text)
             .map_err(|err| ConfigError::ReadFailed(err))?;
+        Source::Stdin
+    };
+
+    // Included files are relative to the config file, rather than the current directory
+    let directory = match &source {
+        Source::File(config_file_path) => config_file_path.parent().unwrap_or(Path::new("")),
+        Source::Stdin | Source::Url(_) => Path::new(""),
     };
 
     let current_platform = current_platform_provider().get_platform();
-    let config = parse_config(&config_text, current_platform)?;
-    Ok(FoundConfig { source, config })
+    let config = parse_config_as(&config_text, format, current_platform, directory)?;
+    Ok(FoundConfig {
+        source,
+        format,
+        text: config_text,
+        config,
+    })
+}
+
+/// Searches the provided directory, and then each of its parents, for a config file.
+/// Returns the path to the first config file found.
+fn find_config_file(directory: &Path) -> Option<PathBuf> {
+    return directory.ancestors().find_map(find_config_file_in);
+}
+
+/// Searches the provided directory, and then each of its parents, for config files.
+/// Returns the paths to all of the config files found, closest first.
+pub fn find_config_files(directory: &Path) -> Vec<PathBuf> {
+    return directory
+        .ancestors()
+        .filter_map(find_config_file_in)
+        .collect();
+}
+
+/// Returns the path to the config file in the provided directory, if one exists.
+fn find_config_file_in(directory: &Path) -> Option<PathBuf> {
+    return CONFIG_FILE_NAMES
+        .iter()
+        .map(|config_file_name| directory.join(config_file_name))
+        .find(|config_file_path| config_file_path.exists());
 }
 
 /// Creates a new config file in the current directory.
@@ -89,17 +216,82 @@ pub fn init() -> Result<String, ConfigError> {
     Ok(file_name.to_string())
 }
 
+/// Parses the config file at the provided path so that it can be imported into another config.
+/// Called commands are validated once the imported commands have been added to the other config.
 fn parse_config_from(path: &String, current_platform: Platform) -> Result<Config, ConfigError> {
+    let format = ConfigFormat::from_path(Path::new(path))?;
     let config_text = fs::read_to_string(path).map_err(|err| ConfigError::ReadFailed(err))?;
 
-    parse_config(&config_text, current_platform)
+    let config = deserialize_config(&config_text, format)?;
+    let directory = Path::new(path).parent().unwrap_or(Path::new(""));
+    return resolve_config(config, current_platform, directory);
 }
 
-fn parse_config(text: &String, current_platform: Platform) -> Result<Config, ConfigError> {
-    // Parse the base config
-    let mut base_config: Config =
-        serde_yaml::from_str(text.as_str()).map_err(|err| ConfigError::ParseFailed(err))?;
+/// Parses the config file at the provided path so that it can be included in another config.
+/// Included config files can't include other files, since they could end up including themselves.
+fn parse_included_config_from(
+    path: &Path,
+    current_platform: Platform,
+) -> Result<Config, ConfigError> {
+    let format = ConfigFormat::from_path(path)?;
+    let config_text = fs::read_to_string(path).map_err(|err| ConfigError::ReadFailed(err))?;
 
+    let config = deserialize_config(&config_text, format)?;
+    if config.include.is_some() {
+        return Err(ConfigError::NestedInclude);
+    }
+
+    let directory = path.parent().unwrap_or(Path::new(""));
+    return resolve_config(config, current_platform, directory);
+}
+
+/// Parses the provided YAML text into a [`Config`], including any imports for the current [`Platform`].
+pub fn parse_config(text: &String, current_platform: Platform) -> Result<Config, ConfigError> {
+    return parse_config_as(text, ConfigFormat::Yaml, current_platform, Path::new(""));
+}
+
+/// Parses the provided text in the given [`ConfigFormat`] into a [`Config`], including any imports
+/// for the current [`Platform`].
+/// Relative include patterns are resolved from the provided directory.
+pub fn parse_config_as(
+    text: &String,
+    format: ConfigFormat,
+    current_platform: Platform,
+    directory: &Path,
+) -> Result<Config, ConfigError> {
+    let base_config = deserialize_config(text, format)?;
+    let config = resolve_config(base_config, current_platform, directory)?;
+    validate_calls(&config.commands, &config.commands, &vec![])?;
+    validate_dependencies(&config.commands, &config.commands, &vec![])?;
+    return Ok(config);
+}
+
+/// Deserializes the provided text in the given [`ConfigFormat`] into a [`Config`], without
+/// resolving any imports or includes.
+fn deserialize_config(text: &String, format: ConfigFormat) -> Result<Config, ConfigError> {
+    let config: Config = match format {
+        ConfigFormat::Yaml => {
+            serde_yaml::from_str(text.as_str()).map_err(|err| ConfigError::ParseFailed(err))?
+        }
+        ConfigFormat::Toml => {
+            toml::from_str(text.as_str()).map_err(|err| ConfigError::ParseTomlFailed(err))?
+        }
+        ConfigFormat::Json => {
+            serde_json::from_str(text.as_str()).map_err(|err| ConfigError::ParseJsonFailed(err))?
+        }
+    };
+
+    return Ok(config);
+}
+
+/// Resolves the imports and includes of the provided base [`Config`] for the current [`Platform`],
+/// then validates the result.
+/// Relative include patterns are resolved from the provided directory.
+fn resolve_config(
+    mut base_config: Config,
+    current_platform: Platform,
+    directory: &Path,
+) -> Result<Config, ConfigError> {
     // Parse the imports too
     for import in &base_config.imports {
         // Don't even try parsing the import if it's not for the current platform
@@ -119,26 +311,286 @@ fn parse_config(text: &String, current_platform: Platform) -> Result<Config, Con
 
         // Create a top-level command for every import
         let command = CommandConfig {
-            name: None,
             description: child_config.description,
             hidden: import.hidden,
             platform: import.platform.clone(),
             variables: child_config.variables,
             commands: child_config.commands,
-            action: None,
+            ..Default::default()
         };
 
         base_config.commands.insert(import.alias.clone(), command);
     }
 
+    // Included files contribute their commands directly, in sorted order
+    let include_patterns = match &base_config.include {
+        Some(include) => include.patterns(),
+        None => vec![],
+    };
+    for pattern in include_patterns {
+        for path in expand_glob(&pattern, directory)? {
+            let child_config = parse_included_config_from(&path, current_platform.clone())
+                .map_err(|err| ConfigError::IncludeFailed {
+                    path: path.clone(),
+                    source: Box::new(err),
+                })?;
+
+            for (name, mut command) in child_config.commands {
+                if base_config.commands.contains_key(&name) {
+                    return Err(ConfigError::DuplicateCommand { name, path });
+                }
+
+                // The commands still need the root-level variables from their own file
+                let mut variables = child_config.variables.clone();
+                variables.extend(command.variables);
+                command.variables = variables;
+
+                base_config.commands.insert(name, command);
+            }
+        }
+    }
+
+    validate_shell(&base_config.options.shell)?;
+    validate_command_shells(&base_config.commands)?;
+    validate_variable_names(&base_config.variables)?;
+    validate_command_variable_names(&base_config.commands)?;
+
     Ok(base_config)
 }
 
+/// Returns the paths matching the provided glob pattern, sorted by path.
+/// Relative patterns are resolved from the provided directory.
+/// Each part of the pattern can use `*` to match any characters, and `?` to match a single
+/// character. Hidden files are only matched when the pattern starts with a `.`.
+/// Patterns without any wildcards are returned as-is, even if the file doesn't exist.
+fn expand_glob(pattern: &str, directory: &Path) -> Result<Vec<PathBuf>, ConfigError> {
+    // Absolute patterns replace the directory once their root is pushed
+    let mut paths = vec![directory.to_path_buf()];
+    for component in Path::new(pattern).components() {
+        let component = component.as_os_str().to_string_lossy().to_string();
+        if !component.contains(['*', '?']) {
+            for path in &mut paths {
+                path.push(&component);
+            }
+
+            continue;
+        }
+
+        let component_regex = glob_regex(&component);
+        let mut matching_paths = vec![];
+        for directory in paths {
+            let entries = match fs::read_dir(directory.join(".")) {
+                Ok(entries) => entries,
+
+                // Directories that don't exist can't have any matches
+                Err(err) if err.kind() == io::ErrorKind::NotFound => continue,
+                Err(err) => return Err(ConfigError::ReadFailed(err)),
+            };
+
+            for entry in entries {
+                let entry = entry.map_err(|err| ConfigError::ReadFailed(err))?;
+                let file_name = entry.file_name().to_string_lossy().to_string();
+                if file_name.starts_with('.') && !component.starts_with('.') {
+                    continue;
+                }
+
+                if component_regex.is_match(&file_name) {
+                    matching_paths.push(directory.join(file_name));
+                }
+            }
+        }
+
+        paths = matching_paths;
+    }
+
+    paths.sort();
+    return Ok(paths);
+}
+
+/// Converts a single part of a glob pattern into an equivalent [`Regex`].
+fn glob_regex(pattern: &str) -> Regex {
+    let mut regex_pattern = String::from("^");
+    for ch in pattern.chars() {
+        match ch {
+            '*' => regex_pattern.push_str(".*"),
+            '?' => regex_pattern.push('.'),
+            _ => regex_pattern.push_str(&regex::escape(&ch.to_string())),
+        }
+    }
+    regex_pattern.push('$');
+
+    // Safe to unwrap: everything other than the wildcards has been escaped
+    return Regex::new(&regex_pattern).unwrap();
+}
+
+/// Ensures the shell of each of the provided commands, and their subcommands, is supported.
+fn validate_command_shells(commands: &CommandConfigMap) -> Result<(), ConfigError> {
+    for command in commands.values() {
+        if let Some(shell) = &command.shell {
+            validate_shell(shell)?;
+        }
+
+        validate_command_shells(&command.commands)?;
+    }
+
+    return Ok(());
+}
+
+/// Ensures none of the provided variables use a name reserved for the arguments used by Dingus
+/// itself.
+fn validate_variable_names(variables: &VariableConfigMap) -> Result<(), ConfigError> {
+    for name in variables.keys() {
+        if name.starts_with(RESERVED_ARG_ID_PREFIX) {
+            return Err(ConfigError::ReservedVariableName { name: name.clone() });
+        }
+    }
+
+    return Ok(());
+}
+
+/// Ensures the variables of each of the provided commands, and their subcommands, don't use a
+/// reserved name.
+fn validate_command_variable_names(commands: &CommandConfigMap) -> Result<(), ConfigError> {
+    for command in commands.values() {
+        validate_variable_names(&command.variables)?;
+        validate_command_variable_names(&command.commands)?;
+    }
+
+    return Ok(());
+}
+
+/// Ensures the commands called by each of the provided commands, and their subcommands, exist in
+/// the provided root commands and have an action, so that typos are caught before anything runs.
+fn validate_calls(
+    root_commands: &CommandConfigMap,
+    commands: &CommandConfigMap,
+    parent_names: &Vec<String>,
+) -> Result<(), ConfigError> {
+    for (key, command) in commands {
+        let mut names = parent_names.clone();
+        names.push(command.name.clone().unwrap_or(key.clone()));
+
+        if let Some(ActionConfig::Calls(calls_action)) = &command.action {
+            for target in &calls_action.calls {
+                if !has_action(target, root_commands) {
+                    return Err(ConfigError::CallTargetNotFound {
+                        command: names.join(" "),
+                        target: target.clone(),
+                    });
+                }
+            }
+        }
+
+        validate_calls(root_commands, &command.commands, &names)?;
+    }
+
+    return Ok(());
+}
+
+/// Ensures the dependencies of each of the provided commands, and their subcommands, exist in the
+/// provided root commands and have an action, and that no command depends on itself, either
+/// directly or through its dependencies.
+fn validate_dependencies(
+    root_commands: &CommandConfigMap,
+    commands: &CommandConfigMap,
+    parent_names: &Vec<String>,
+) -> Result<(), ConfigError> {
+    for (key, command) in commands {
+        let mut names = parent_names.clone();
+        names.push(command.name.clone().unwrap_or(key.clone()));
+
+        for target in &command.depends_on {
+            if !has_action(target, root_commands) {
+                return Err(ConfigError::DependencyNotFound {
+                    command: names.join(" "),
+                    target: target.clone(),
+                });
+            }
+        }
+
+        find_dependency_cycle(
+            root_commands,
+            &command.depends_on,
+            &mut vec![names.join(" ")],
+        )?;
+        validate_dependencies(root_commands, &command.commands, &names)?;
+    }
+
+    return Ok(());
+}
+
+/// Follows the provided dependencies, failing if any of them lead back to a command which is
+/// already in the `chain` of dependencies.
+fn find_dependency_cycle(
+    root_commands: &CommandConfigMap,
+    depends_on: &Vec<String>,
+    chain: &mut Vec<String>,
+) -> Result<(), ConfigError> {
+    for target in depends_on {
+        let is_cycle = chain.contains(target);
+        chain.push(target.clone());
+        if is_cycle {
+            return Err(ConfigError::DependencyCycle {
+                cycle: chain.clone(),
+            });
+        }
+
+        // Missing dependencies are reported when the command depending on them is validated
+        if let Some(target_command) = find_command_by_path(target, root_commands) {
+            find_dependency_cycle(root_commands, &target_command.depends_on, chain)?;
+        }
+
+        chain.pop();
+    }
+
+    return Ok(());
+}
+
+/// Returns `true` if the command at the provided path has an action.
+/// Subcommands are separated by spaces, the same way they would be on the command-line.
+fn has_action(command_path: &str, commands: &CommandConfigMap) -> bool {
+    let mut commands = commands.clone();
+    let mut has_action = false;
+    for name in command_path.split_whitespace() {
+        let Some(command_config) = find_command_by_name(&name.to_string(), &commands) else {
+            return false;
+        };
+
+        has_action = command_config.action.is_some();
+        commands = command_config.commands;
+    }
+
+    return has_action;
+}
+
+/// Ensures the provided shell is one of the [`SUPPORTED_SHELLS`].
+/// The shell can also be a path to one of the supported shells, such as `/bin/zsh`.
+fn validate_shell(shell: &str) -> Result<(), ConfigError> {
+    if !is_supported_shell(shell) {
+        return Err(ConfigError::UnsupportedShell {
+            shell: shell.to_string(),
+        });
+    }
+
+    return Ok(());
+}
+
+fn is_supported_shell(shell: &str) -> bool {
+    let shell_name = Path::new(shell)
+        .file_stem()
+        .map(|file_stem| file_stem.to_string_lossy().to_lowercase())
+        .unwrap_or_default();
+    return SUPPORTED_SHELLS.contains(&shell_name.as_str());
+}
+
 #[derive(Error, Debug)]
 pub enum ConfigError {
-    #[error("config file not found")]
+    #[error("no config file found in the current directory or any of its parents")]
     FileNotFound,
 
+    #[error("config file {} does not exist", path.display())]
+    PathNotFound { path: PathBuf },
+
     #[error("failed to read config")]
     ReadFailed(#[source] io::Error),
 
@@ -148,11 +600,55 @@ pub enum ConfigError {
     #[error("failed to parse config file")]
     ParseFailed(#[source] serde_yaml::Error),
 
+    #[error("failed to parse config file")]
+    ParseTomlFailed(#[source] toml::de::Error),
+
+    #[error("failed to parse config file")]
+    ParseJsonFailed(#[source] serde_json::Error),
+
+    #[error(
+        "unsupported config file extension \".{extension}\", expected .yaml, .yml, .toml, or .json"
+    )]
+    UnsupportedFormat { extension: String },
+
+    #[error("unsupported shell \"{shell}\", expected one of {}", SUPPORTED_SHELLS.join(", "))]
+    UnsupportedShell { shell: String },
+
+    #[error(
+        "variable name \"{name}\" is reserved, names can't start with \"{}\"",
+        RESERVED_ARG_ID_PREFIX
+    )]
+    ReservedVariableName { name: String },
+
+    #[error("failed to fetch remote config")]
+    FetchFailed(#[source] RemoteError),
+
     #[error("failed to import {alias}")]
     ImportFailed {
         alias: String,
         source: Box<ConfigError>, // Need to box this so the size isn't infinite
     },
+
+    #[error("failed to include {}", path.display())]
+    IncludeFailed {
+        path: PathBuf,
+        source: Box<ConfigError>,
+    },
+
+    #[error("included config files can't include other config files")]
+    NestedInclude,
+
+    #[error("command \"{name}\" from {} is already defined", path.display())]
+    DuplicateCommand { name: String, path: PathBuf },
+
+    #[error("command \"{command}\" calls \"{target}\", which doesn't exist or has no action")]
+    CallTargetNotFound { command: String, target: String },
+
+    #[error("command \"{command}\" depends on \"{target}\", which doesn't exist or has no action")]
+    DependencyNotFound { command: String, target: String },
+
+    #[error("cyclic command dependencies detected: {}", cycle.join(" -> "))]
+    DependencyCycle { cycle: Vec<String> },
 }
 
 /// The root-level of the Configuration.
@@ -162,10 +658,30 @@ pub struct Config {
     #[serde(default = "default_imports")]
     pub imports: Vec<Import>,
 
+    /// Additional config files whose commands are added alongside the commands in this file.
+    /// Paths can be glob patterns, in which case the matching files are included in sorted order.
+    #[serde(alias = "includes")]
+    pub include: Option<IncludeConfigVariant>,
+
     /// A user-friendly description.
     #[serde(alias = "desc")]
     pub description: Option<String>,
 
+    /// An optional name for the program, used in the help output.
+    /// Defaults to the name of the executable, without the `.exe` extension on Windows.
+    pub name: Option<String>,
+
+    /// An optional directory to execute the actions of all commands in, unless they specify
+    /// their own. Relative paths are resolved from the directory containing the config file.
+    #[serde(rename = "workdir")]
+    #[serde(alias = "wd")]
+    pub working_directory: Option<String>,
+
+    /// Additional environment variables to set when executing the actions of all commands.
+    /// Variables can be referenced in the values.
+    #[serde(default = "default_env")]
+    pub env: LinkedHashMap<String, String>,
+
     /// Root-level [`VariableConfig`]s that are available to all subsequent commands.
     #[serde(default = "default_variables")]
     #[serde(alias = "vars")]
@@ -184,6 +700,10 @@ fn default_imports() -> Vec<Import> {
     Vec::new()
 }
 
+fn default_env() -> LinkedHashMap<String, String> {
+    LinkedHashMap::new()
+}
+
 fn default_variables() -> VariableConfigMap {
     VariableConfigMap::new()
 }
@@ -192,6 +712,24 @@ fn default_commands() -> CommandConfigMap {
     CommandConfigMap::new()
 }
 
+/// Encapsulates either a single path or glob pattern for a config file to include, or many.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+#[serde(untagged)]
+pub enum IncludeConfigVariant {
+    Single(String),
+    Many(Vec<String>),
+}
+
+impl IncludeConfigVariant {
+    /// Returns each of the paths or glob patterns to include.
+    pub fn patterns(&self) -> Vec<String> {
+        return match self {
+            IncludeConfigVariant::Single(pattern) => vec![pattern.clone()],
+            IncludeConfigVariant::Many(patterns) => patterns.clone(),
+        };
+    }
+}
+
 #[derive(Serialize, Deserialize, Debug)]
 pub struct Import {
     pub alias: String,
@@ -223,6 +761,101 @@ pub struct DingusOptions {
     /// Defaults to `false`.
     #[serde(default = "default_auto_args")]
     pub auto_args: bool,
+
+    /// When set to `true`, the value entered for each prompt will be printed to stderr once the
+    /// prompt has completed. Sensitive values are obscured.
+    /// Defaults to `false`.
+    #[serde(default = "default_log_answers")]
+    pub log_answers: bool,
+
+    /// When set to `true`, a breakdown of how long each step of an action took will be printed to
+    /// stderr once the action has completed.
+    /// Defaults to `false`.
+    #[serde(default = "default_print_timings")]
+    pub print_timings: bool,
+
+    /// Regular expressions for text that should be redacted from the output of commands.
+    /// Any matches will be replaced with `***`.
+    /// Note that the output of commands is piped through dingus when this is set, so commands
+    /// aren't attached to the terminal and may disable colours or progress bars.
+    #[serde(default = "default_redact")]
+    pub redact: Vec<String>,
+
+    /// The arguments to pass to bash before the command, for each platform.
+    /// The first [`BashArgsConfig`] for the current platform is used.
+    /// If none match, then `-c` is used.
+    #[serde(default = "default_bash_args")]
+    pub bash_args: Vec<BashArgsConfig>,
+
+    /// The shell used to execute shell commands, such as `sh`, `zsh`, or `powershell`.
+    /// Defaults to `powershell` on Windows, and the user's `$SHELL` (or `sh`) everywhere else.
+    #[serde(default = "default_shell")]
+    pub shell: String,
+
+    /// When set to `true`, prompt variables will only be prompted for when they're referenced by
+    /// the action being executed, or by another variable it references.
+    /// Defaults to `false`.
+    #[serde(default = "default_lazy_prompts")]
+    pub lazy_prompts: bool,
+
+    /// When set to `false`, arguments for root-level variables will only be created on the root
+    /// command, rather than on every command. Root-level variables are still available to all
+    /// commands.
+    /// Defaults to `true`.
+    #[serde(default = "default_inherit_root_args", alias = "inherit_global_args")]
+    pub inherit_root_args: bool,
+
+    /// When set to `true`, a required argument which hasn't been provided will be read from stdin
+    /// instead of failing. This is useful when piping values into Dingus.
+    /// Defaults to `false`.
+    #[serde(default = "default_allow_stdin")]
+    pub allow_stdin: bool,
+
+    /// When set to `true`, commands whose output is captured (such as the commands used for
+    /// `execute` variables and `options_from`) will inherit stdin from the current process.
+    /// By default, they're given an empty stdin so that commands waiting for input don't hang.
+    /// Defaults to `false`.
+    #[serde(default = "default_capture_stdin")]
+    pub capture_stdin: bool,
+
+    /// The symbol displayed before the message of text prompts.
+    /// Defaults to `?`.
+    #[serde(default = "default_prompt_symbol")]
+    pub prompt_symbol: String,
+
+    /// When set to `true`, raw commands referencing variables that aren't set will be executed
+    /// with the references left as is, rather than failing.
+    /// Defaults to `false`.
+    #[serde(default = "default_allow_missing_variables")]
+    pub allow_missing_variables: bool,
+
+    /// When set to `true`, the user will be able to go back and change their answers once every
+    /// prompt has been answered, before the command is executed.
+    /// Defaults to `false`.
+    #[serde(default = "default_review_answers")]
+    pub review_answers: bool,
+
+    /// An optional command to run once a command has finished, regardless of whether it
+    /// succeeded. The `status` and `exit_code` variables describe the outcome.
+    pub notify: Option<ExecutionConfigVariant>,
+
+    /// An optional niceness (from `-20` to `19`) to execute commands with, where higher values
+    /// have a lower priority.
+    /// This is only supported on Unix, it has no effect on Windows.
+    pub nice: Option<i32>,
+}
+
+/// The arguments to pass to bash on specific platforms.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct BashArgsConfig {
+    /// An optional platform to restrict these arguments to.
+    /// When not specified, the arguments are used on all platforms.
+    #[serde(flatten)]
+    pub platform: Option<OneOrManyPlatforms>,
+
+    /// The arguments to pass to bash.
+    /// The command will be passed as the last argument.
+    pub args: Vec<String>,
 }
 
 impl Default for DingusOptions {
@@ -231,6 +864,20 @@ impl Default for DingusOptions {
             print_commands: default_print_commands(),
             print_variables: default_print_variables(),
             auto_args: default_auto_args(),
+            log_answers: default_log_answers(),
+            print_timings: default_print_timings(),
+            redact: default_redact(),
+            bash_args: default_bash_args(),
+            shell: default_shell(),
+            lazy_prompts: default_lazy_prompts(),
+            inherit_root_args: default_inherit_root_args(),
+            allow_stdin: default_allow_stdin(),
+            capture_stdin: default_capture_stdin(),
+            prompt_symbol: default_prompt_symbol(),
+            allow_missing_variables: default_allow_missing_variables(),
+            review_answers: default_review_answers(),
+            notify: None,
+            nice: None,
         }
     }
 }
@@ -256,6 +903,118 @@ fn default_auto_args() -> bool {
     }
 }
 
+fn default_log_answers() -> bool {
+    match env::var("DINGUS_LOG_ANSWERS") {
+        Ok(str) => is_truthy(str),
+        Err(_) => false,
+    }
+}
+
+fn default_print_timings() -> bool {
+    match env::var("DINGUS_PRINT_TIMINGS") {
+        Ok(str) => is_truthy(str),
+        Err(_) => false,
+    }
+}
+
+fn default_lazy_prompts() -> bool {
+    match env::var("DINGUS_LAZY_PROMPTS") {
+        Ok(str) => is_truthy(str),
+        Err(_) => false,
+    }
+}
+
+fn default_inherit_root_args() -> bool {
+    match env::var("DINGUS_INHERIT_ROOT_ARGS") {
+        Ok(str) => is_truthy(str),
+        Err(_) => true,
+    }
+}
+
+fn default_shell() -> String {
+    match env::var("DINGUS_SHELL") {
+        Ok(str) => str,
+        Err(_) if cfg!(windows) => "powershell".to_string(),
+        Err(_) => select_default_shell(env::var("SHELL").ok(), is_on_path),
+    }
+}
+
+/// Returns the user's preferred shell (from `$SHELL`), as long as it's one of the
+/// [`SUPPORTED_SHELLS`] and it can be found.
+/// Otherwise, `bash` is used if it can be found, since most configs are written with `bash` in
+/// mind. If all else fails, `sh` is used.
+fn select_default_shell(user_shell: Option<String>, is_installed: impl Fn(&str) -> bool) -> String {
+    if let Some(user_shell) = user_shell {
+        if is_supported_shell(&user_shell) && is_installed(&user_shell) {
+            return user_shell;
+        }
+    }
+
+    if is_installed(DEFAULT_SHELL) {
+        return DEFAULT_SHELL.to_string();
+    }
+
+    return FALLBACK_SHELL.to_string();
+}
+
+/// Returns `true` if the provided program exists.
+/// Paths are checked directly, otherwise each directory in the `PATH` is searched.
+fn is_on_path(program: &str) -> bool {
+    let program_path = Path::new(program);
+    if program_path.components().count() > 1 {
+        return program_path.is_file();
+    }
+
+    let Some(paths) = env::var_os("PATH") else {
+        return false;
+    };
+
+    return env::split_paths(&paths).any(|directory| directory.join(program).is_file());
+}
+
+fn default_allow_stdin() -> bool {
+    match env::var("DINGUS_ALLOW_STDIN") {
+        Ok(str) => is_truthy(str),
+        Err(_) => false,
+    }
+}
+
+fn default_capture_stdin() -> bool {
+    match env::var("DINGUS_CAPTURE_STDIN") {
+        Ok(str) => is_truthy(str),
+        Err(_) => false,
+    }
+}
+
+fn default_prompt_symbol() -> String {
+    match env::var("DINGUS_PROMPT_SYMBOL") {
+        Ok(str) => str,
+        Err(_) => "?".to_string(),
+    }
+}
+
+fn default_allow_missing_variables() -> bool {
+    match env::var("DINGUS_ALLOW_MISSING_VARIABLES") {
+        Ok(str) => is_truthy(str),
+        Err(_) => false,
+    }
+}
+
+fn default_review_answers() -> bool {
+    match env::var("DINGUS_REVIEW_ANSWERS") {
+        Ok(str) => is_truthy(str),
+        Err(_) => false,
+    }
+}
+
+fn default_redact() -> Vec<String> {
+    Vec::new()
+}
+
+fn default_bash_args() -> Vec<BashArgsConfig> {
+    Vec::new()
+}
+
 fn is_truthy(s: String) -> bool {
     s == "true" || s == "TRUE" || s == "t" || s == "T"
 }
@@ -277,6 +1036,18 @@ pub enum VariableConfig {
     /// Encapsulates a [`ExecutionVariableConfig`].
     Execution(ExecutionVariableConfig),
 
+    /// Encapsulates a [`ExitCodeVariableConfig`].
+    ExitCode(ExitCodeVariableConfig),
+
+    /// Encapsulates a [`HttpVariableConfig`].
+    Http(HttpVariableConfig),
+
+    /// Encapsulates a [`FileVariableConfig`].
+    File(FileVariableConfig),
+
+    /// Encapsulates a [`KeyringVariableConfig`].
+    Keyring(KeyringVariableConfig),
+
     /// Encapsulates a [`PromptVariableConfig`].
     Prompt(PromptVariableConfig),
 
@@ -285,6 +1056,64 @@ pub enum VariableConfig {
 }
 
 impl VariableConfig {
+    pub fn description(&self) -> Option<String> {
+        match self {
+            VariableConfig::ShorthandLiteral(_) => None,
+            VariableConfig::Literal(literal_conf) => literal_conf.description.clone(),
+            VariableConfig::Execution(execution_conf) => execution_conf.description.clone(),
+            VariableConfig::ExitCode(exit_code_conf) => exit_code_conf.description.clone(),
+            VariableConfig::Http(http_conf) => http_conf.description.clone(),
+            VariableConfig::File(file_conf) => file_conf.description.clone(),
+            VariableConfig::Keyring(keyring_conf) => keyring_conf.description.clone(),
+            VariableConfig::Prompt(prompt_conf) => prompt_conf.description.clone(),
+            VariableConfig::Argument(argument_conf) => argument_conf.description.clone(),
+        }
+    }
+
+    pub fn validation_rules(&self) -> Vec<ValidationRuleConfig> {
+        match self {
+            VariableConfig::ShorthandLiteral(_) => vec![],
+            VariableConfig::Literal(literal_conf) => literal_conf.validate.clone(),
+            VariableConfig::Execution(execution_conf) => execution_conf.validate.clone(),
+            VariableConfig::ExitCode(exit_code_conf) => exit_code_conf.validate.clone(),
+            VariableConfig::Http(http_conf) => http_conf.validate.clone(),
+            VariableConfig::File(file_conf) => file_conf.validate.clone(),
+            VariableConfig::Keyring(keyring_conf) => keyring_conf.validate.clone(),
+            VariableConfig::Prompt(prompt_conf) => prompt_conf.validate.clone(),
+            VariableConfig::Argument(argument_conf) => argument_conf.validate.clone(),
+        }
+    }
+
+    /// Returns the name of the environment variable to source the value from, if any.
+    pub fn source_environment_variable(&self) -> Option<String> {
+        match self {
+            VariableConfig::ShorthandLiteral(_) => None,
+            VariableConfig::Literal(literal_conf) => literal_conf.from_env.clone(),
+            VariableConfig::Execution(execution_conf) => execution_conf.from_env.clone(),
+            VariableConfig::ExitCode(exit_code_conf) => exit_code_conf.from_env.clone(),
+            VariableConfig::Http(http_conf) => http_conf.from_env.clone(),
+            VariableConfig::File(file_conf) => file_conf.from_env.clone(),
+            VariableConfig::Keyring(keyring_conf) => keyring_conf.from_env.clone(),
+            VariableConfig::Prompt(prompt_conf) => prompt_conf.from_env.clone(),
+            VariableConfig::Argument(argument_conf) => argument_conf.from_env.clone(),
+        }
+    }
+
+    /// Returns the condition for resolving this variable, if any.
+    pub fn condition(&self) -> Option<String> {
+        match self {
+            VariableConfig::ShorthandLiteral(_) => None,
+            VariableConfig::Literal(literal_conf) => literal_conf.when.clone(),
+            VariableConfig::Execution(execution_conf) => execution_conf.when.clone(),
+            VariableConfig::ExitCode(exit_code_conf) => exit_code_conf.when.clone(),
+            VariableConfig::Http(http_conf) => http_conf.when.clone(),
+            VariableConfig::File(file_conf) => file_conf.when.clone(),
+            VariableConfig::Keyring(keyring_conf) => keyring_conf.when.clone(),
+            VariableConfig::Prompt(prompt_conf) => prompt_conf.when.clone(),
+            VariableConfig::Argument(argument_conf) => argument_conf.when.clone(),
+        }
+    }
+
     pub fn environment_variable_name(&self, key: &str) -> String {
         match self {
             VariableConfig::ShorthandLiteral(_) => None,
@@ -292,6 +1121,12 @@ impl VariableConfig {
             VariableConfig::Execution(execution_conf) => {
                 execution_conf.clone().environment_variable_name
             }
+            VariableConfig::ExitCode(exit_code_conf) => {
+                exit_code_conf.clone().environment_variable_name
+            }
+            VariableConfig::Http(http_conf) => http_conf.clone().environment_variable_name,
+            VariableConfig::File(file_conf) => file_conf.clone().environment_variable_name,
+            VariableConfig::Keyring(keyring_conf) => keyring_conf.clone().environment_variable_name,
             VariableConfig::Prompt(prompt_conf) => prompt_conf.clone().environment_variable_name,
             VariableConfig::Argument(argument_conf) => {
                 argument_conf.clone().environment_variable_name
@@ -301,6 +1136,27 @@ impl VariableConfig {
     }
 }
 
+/// A rule that the value of a variable must satisfy.
+///
+/// Example:
+/// ```yaml
+/// validate:
+///     - pattern: ^\d+\.\d+\.\d+$
+///       message: the version must look like 1.2.3
+/// ```
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct ValidationRuleConfig {
+    /// A regular expression that the value must match.
+    pub pattern: String,
+
+    /// The message to show when the value doesn't match the pattern.
+    pub message: String,
+}
+
+fn default_validation_rules() -> Vec<ValidationRuleConfig> {
+    Vec::new()
+}
+
 /// Denotes a literal variable where the value is hard-coded.
 ///
 /// Example:
@@ -311,6 +1167,12 @@ impl VariableConfig {
 /// ```
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
 pub struct LiteralVariableConfig {
+    /// An optional description for the variable.
+    /// This is used as the help text for the variable's argument and prompt, unless they provide
+    /// their own.
+    #[serde(alias = "desc")]
+    pub description: Option<String>,
+
     /// An optional argument configuration.
     #[serde(rename(deserialize = "argument"))]
     #[serde(alias = "arg")]
@@ -321,25 +1183,278 @@ pub struct LiteralVariableConfig {
     ///
     /// This is **not** the name of the environment variable to source the value from.
     /// If you want to source a variables value from an environment variable,
-    /// use an [`ExecutionVariableConfig`].
+    /// use the `from_env` field.
     #[serde(rename(deserialize = "environment_variable"))]
     #[serde(alias = "env")]
     pub environment_variable_name: Option<String>,
 
+    /// An optional environment variable to source the value from.
+    /// If the environment variable is set, its value is used instead of resolving the variable.
+    /// Values from command-line arguments still take precedence.
+    pub from_env: Option<String>,
+
+    /// An optional condition for resolving the variable, which can reference other variables.
+    /// If the condition is falsy, the variable is left unset, and its prompt is skipped.
+    #[serde(alias = "condition")]
+    pub when: Option<String>,
+
+    /// Rules that the value must satisfy, regardless of where the value came from.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
     /// The value of the variable
     pub value: String,
 }
 
-/// Denotes a variable whose value is determined by the output of a command.
+/// Denotes a variable whose value is determined by the output of a command.
+///
+/// Example:
+/// ```yaml
+/// name:
+///     arg: name
+///     exec: cat name.txt
+/// ```
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct ExecutionVariableConfig {
+    /// An optional description for the variable.
+    /// This is used as the help text for the variable's argument and prompt, unless they provide
+    /// their own.
+    #[serde(alias = "desc")]
+    pub description: Option<String>,
+
+    /// An optional argument configuration.
+    #[serde(rename(deserialize = "argument"))]
+    #[serde(alias = "arg")]
+    pub argument: Option<ArgumentConfigVariant>,
+
+    /// An optional environment variable name.
+    /// If specified, the environment variable for this variable will have the specified name.
+    ///
+    /// This is **not** the name of the environment variable to source the value from.
+    /// If you want to source a variables value from an environment variable,
+    /// use the `from_env` field.
+    #[serde(rename(deserialize = "environment_variable"))]
+    #[serde(alias = "env")]
+    pub environment_variable_name: Option<String>,
+
+    /// An optional environment variable to source the value from.
+    /// If the environment variable is set, its value is used instead of resolving the variable.
+    /// Values from command-line arguments still take precedence.
+    pub from_env: Option<String>,
+
+    /// An optional condition for resolving the variable, which can reference other variables.
+    /// If the condition is falsy, the variable is left unset, and its prompt is skipped.
+    #[serde(alias = "condition")]
+    pub when: Option<String>,
+
+    /// Rules that the value must satisfy, regardless of where the value came from.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
+    /// The [`ExecutionConfigVariant`] to use to determine the value of this variable.
+    #[serde(rename = "execute")]
+    #[serde(alias = "exec")]
+    pub execution: ExecutionConfigVariant,
+
+    /// When set to `true`, the command will always be executed, rather than reusing the output of
+    /// an identical command that has already been executed.
+    /// Defaults to `false`.
+    #[serde(default = "default_no_cache")]
+    pub no_cache: bool,
+}
+
+fn default_no_cache() -> bool {
+    false
+}
+
+/// Denotes a variable whose value is the exit code of a command.
+/// Non-zero exit codes are not treated as errors.
+///
+/// Example:
+/// ```yaml
+/// has_docker:
+///     exit_code: which docker
+/// ```
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct ExitCodeVariableConfig {
+    /// An optional description for the variable.
+    /// This is used as the help text for the variable's argument and prompt, unless they provide
+    /// their own.
+    #[serde(alias = "desc")]
+    pub description: Option<String>,
+
+    /// An optional argument configuration.
+    #[serde(rename(deserialize = "argument"))]
+    #[serde(alias = "arg")]
+    pub argument: Option<ArgumentConfigVariant>,
+
+    /// An optional environment variable name.
+    /// If specified, the environment variable for this variable will have the specified name.
+    ///
+    /// This is **not** the name of the environment variable to source the value from.
+    /// If you want to source a variables value from an environment variable,
+    /// use the `from_env` field.
+    #[serde(rename(deserialize = "environment_variable"))]
+    #[serde(alias = "env")]
+    pub environment_variable_name: Option<String>,
+
+    /// An optional environment variable to source the value from.
+    /// If the environment variable is set, its value is used instead of resolving the variable.
+    /// Values from command-line arguments still take precedence.
+    pub from_env: Option<String>,
+
+    /// An optional condition for resolving the variable, which can reference other variables.
+    /// If the condition is falsy, the variable is left unset, and its prompt is skipped.
+    #[serde(alias = "condition")]
+    pub when: Option<String>,
+
+    /// Rules that the value must satisfy, regardless of where the value came from.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
+    /// The [`ExecutionConfigVariant`] whose exit code will be used as the value of this variable.
+    #[serde(rename = "exit_code")]
+    pub execution: ExecutionConfigVariant,
+
+    /// When set to `true`, the command will always be executed, rather than reusing the output of
+    /// an identical command that has already been executed.
+    /// Defaults to `false`.
+    #[serde(default = "default_no_cache")]
+    pub no_cache: bool,
+}
+
+/// Denotes a variable whose value is fetched from a JSON API.
+///
+/// Example:
+/// ```yaml
+/// latest_version:
+///     http:
+///         url: https://api.github.com/repos/$owner/$repo/releases/latest
+///         json_path: tag_name
+///         headers:
+///             Accept: application/json
+/// ```
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct HttpVariableConfig {
+    /// An optional description for the variable.
+    /// This is used as the help text for the variable's argument and prompt, unless they provide
+    /// their own.
+    #[serde(alias = "desc")]
+    pub description: Option<String>,
+
+    /// An optional argument configuration.
+    #[serde(rename(deserialize = "argument"))]
+    #[serde(alias = "arg")]
+    pub argument: Option<ArgumentConfigVariant>,
+
+    /// An optional environment variable name.
+    /// If specified, the environment variable for this variable will have the specified name.
+    ///
+    /// This is **not** the name of the environment variable to source the value from.
+    /// If you want to source a variables value from an environment variable,
+    /// use the `from_env` field.
+    #[serde(rename(deserialize = "environment_variable"))]
+    #[serde(alias = "env")]
+    pub environment_variable_name: Option<String>,
+
+    /// An optional environment variable to source the value from.
+    /// If the environment variable is set, its value is used instead of resolving the variable.
+    /// Values from command-line arguments still take precedence.
+    pub from_env: Option<String>,
+
+    /// An optional condition for resolving the variable, which can reference other variables.
+    /// If the condition is falsy, the variable is left unset, and its prompt is skipped.
+    #[serde(alias = "condition")]
+    pub when: Option<String>,
+
+    /// Rules that the value must satisfy, regardless of where the value came from.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
+    /// The [`HttpRequestConfig`] used to fetch the value of this variable.
+    #[serde(rename = "http")]
+    pub request: HttpRequestConfig,
+}
+
+/// Denotes a variable whose value is read from a file.
+///
+/// Example:
+/// ```yaml
+/// token:
+///     file: ~/.config/app/token
+/// ```
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct FileVariableConfig {
+    /// An optional description for the variable.
+    /// This is used as the help text for the variable's argument and prompt, unless they provide
+    /// their own.
+    #[serde(alias = "desc")]
+    pub description: Option<String>,
+
+    /// An optional argument configuration.
+    #[serde(rename(deserialize = "argument"))]
+    #[serde(alias = "arg")]
+    pub argument: Option<ArgumentConfigVariant>,
+
+    /// An optional environment variable name.
+    /// If specified, the environment variable for this variable will have the specified name.
+    ///
+    /// This is **not** the name of the environment variable to source the value from.
+    /// If you want to source a variables value from an environment variable,
+    /// use the `from_env` field.
+    #[serde(rename(deserialize = "environment_variable"))]
+    #[serde(alias = "env")]
+    pub environment_variable_name: Option<String>,
+
+    /// An optional environment variable to source the value from.
+    /// If the environment variable is set, its value is used instead of resolving the variable.
+    /// Values from command-line arguments still take precedence.
+    pub from_env: Option<String>,
+
+    /// An optional condition for resolving the variable, which can reference other variables.
+    /// If the condition is falsy, the variable is left unset, and its prompt is skipped.
+    #[serde(alias = "condition")]
+    pub when: Option<String>,
+
+    /// Rules that the value must satisfy, regardless of where the value came from.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
+    /// The path to the file containing the value. Leading and trailing whitespace is removed
+    /// from the contents of the file.
+    /// A leading `~` is expanded to the home directory, and relative paths are resolved from the
+    /// directory containing the config file. Variables can be referenced in the path.
+    pub file: String,
+
+    /// When set to `false`, the variable is left unset if the file doesn't exist, rather than
+    /// failing.
+    /// Defaults to `true`.
+    #[serde(default = "default_file_required")]
+    pub required: bool,
+}
+
+fn default_file_required() -> bool {
+    true
+}
+
+/// Denotes a variable whose value is read from the operating system's keyring.
+/// Values from the keyring are treated as sensitive.
 ///
 /// Example:
 /// ```yaml
-/// name:
-///     arg: name
-///     exec: cat name.txt
+/// api_key:
+///     keyring:
+///         service: my-app
+///         account: api-key
 /// ```
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
-pub struct ExecutionVariableConfig {
+pub struct KeyringVariableConfig {
+    /// An optional description for the variable.
+    /// This is used as the help text for the variable's argument and prompt, unless they provide
+    /// their own.
+    #[serde(alias = "desc")]
+    pub description: Option<String>,
+
     /// An optional argument configuration.
     #[serde(rename(deserialize = "argument"))]
     #[serde(alias = "arg")]
@@ -350,15 +1465,60 @@ pub struct ExecutionVariableConfig {
     ///
     /// This is **not** the name of the environment variable to source the value from.
     /// If you want to source a variables value from an environment variable,
-    /// use an [`ExecutionVariableConfig`].
+    /// use the `from_env` field.
     #[serde(rename(deserialize = "environment_variable"))]
     #[serde(alias = "env")]
     pub environment_variable_name: Option<String>,
 
-    /// The [`ExecutionConfigVariant`] to use to determine the value of this variable.
-    #[serde(rename = "execute")]
-    #[serde(alias = "exec")]
-    pub execution: ExecutionConfigVariant,
+    /// An optional environment variable to source the value from.
+    /// If the environment variable is set, its value is used instead of resolving the variable.
+    /// Values from command-line arguments still take precedence.
+    pub from_env: Option<String>,
+
+    /// An optional condition for resolving the variable, which can reference other variables.
+    /// If the condition is falsy, the variable is left unset, and its prompt is skipped.
+    #[serde(alias = "condition")]
+    pub when: Option<String>,
+
+    /// Rules that the value must satisfy, regardless of where the value came from.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
+    /// The [`KeyringEntryConfig`] identifying the entry to read the value from.
+    pub keyring: KeyringEntryConfig,
+}
+
+/// An entry in the operating system's keyring.
+/// Variables can be referenced in the service and account.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct KeyringEntryConfig {
+    /// The name of the service the entry belongs to.
+    pub service: String,
+
+    /// The name of the account the entry is for.
+    #[serde(alias = "user", alias = "username")]
+    pub account: String,
+}
+
+/// An HTTP GET request whose response is used as the value of a variable.
+/// Variables can be referenced in the URL and headers.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct HttpRequestConfig {
+    /// The URL to send the request to.
+    pub url: String,
+
+    /// An optional dot-separated path to the value within the JSON response (e.g. `items.0.name`).
+    /// When not specified, the entire response body is used.
+    #[serde(alias = "path")]
+    pub json_path: Option<String>,
+
+    /// Any headers to send with the request.
+    #[serde(default = "default_headers")]
+    pub headers: LinkedHashMap<String, String>,
+}
+
+fn default_headers() -> LinkedHashMap<String, String> {
+    LinkedHashMap::new()
 }
 
 /// Denotes a variable whose value is determined by prompting the user for input.
@@ -369,9 +1529,21 @@ pub struct ExecutionVariableConfig {
 ///     arg: name
 ///     prompt:
 ///         message: What is your name?
+///
+/// food:
+///     prompt: What is your favourite food?
+///     options:
+///         - Burger
+///         - Pizza
 /// ```
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
 pub struct PromptVariableConfig {
+    /// An optional description for the variable.
+    /// This is used as the help text for the variable's argument and prompt, unless they provide
+    /// their own.
+    #[serde(alias = "desc")]
+    pub description: Option<String>,
+
     /// An optional argument configuration.
     #[serde(rename(deserialize = "argument"))]
     #[serde(alias = "arg")]
@@ -382,13 +1554,67 @@ pub struct PromptVariableConfig {
     ///
     /// This is **not** the name of the environment variable to source the value from.
     /// If you want to source a variables value from an environment variable,
-    /// use an [`ExecutionVariableConfig`].
+    /// use the `from_env` field.
     #[serde(rename(deserialize = "environment_variable"))]
     #[serde(alias = "env")]
     pub environment_variable_name: Option<String>,
 
-    /// The [`PromptConfig`] to use for the prompt.
-    pub prompt: PromptConfig,
+    /// An optional environment variable to source the value from.
+    /// If the environment variable is set, its value is used instead of resolving the variable.
+    /// Values from command-line arguments still take precedence.
+    pub from_env: Option<String>,
+
+    /// An optional condition for resolving the variable, which can reference other variables.
+    /// If the condition is falsy, the variable is left unset, and its prompt is skipped.
+    #[serde(alias = "condition")]
+    pub when: Option<String>,
+
+    /// Rules that the value must satisfy, regardless of where the value came from.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
+    /// The [`PromptConfigVariant`] to use for the prompt.
+    pub prompt: PromptConfigVariant,
+
+    /// Optional [`SelectOptionsConfig`] for use with a shorthand prompt.
+    /// When specified, a select prompt is inferred instead of a text prompt.
+    #[serde(alias = "opts")]
+    pub options: Option<SelectOptionsConfig>,
+}
+
+impl PromptVariableConfig {
+    /// Returns the [`PromptConfig`] for this variable.
+    /// Explicit prompts are returned as-is, shorthand prompts are inferred from the shape of the
+    /// variable.
+    /// If the prompt doesn't specify any help text, then the variable's description is used.
+    pub fn prompt_config(&self) -> PromptConfig {
+        match &self.prompt {
+            PromptConfigVariant::Shorthand(message) => {
+                let options = match &self.options {
+                    Some(select_options) => PromptOptionsVariant::Select(SelectPromptOptions {
+                        options: select_options.clone(),
+                        allow_other: false,
+                    }),
+                    None => PromptOptionsVariant::default(),
+                };
+
+                PromptConfig {
+                    message: message.clone(),
+                    help: self.description.clone(),
+                    options,
+                    ..Default::default()
+                }
+            }
+            PromptConfigVariant::PromptConfig(prompt_config) => {
+                let mut prompt_config = prompt_config.clone();
+                if prompt_config.help.is_none() {
+                    prompt_config.help = self.description.clone();
+                }
+
+                prompt_config
+            }
+        }
+    }
 }
 
 /// Denotes a variable whose value is sourced from command-line arguments.
@@ -403,6 +1629,12 @@ pub struct PromptVariableConfig {
 /// ```
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
 pub struct ArgumentVariableConfig {
+    /// An optional description for the variable.
+    /// This is used as the help text for the variable's argument and prompt, unless they provide
+    /// their own.
+    #[serde(alias = "desc")]
+    pub description: Option<String>,
+
     /// An optional argument configuration.
     #[serde(rename(deserialize = "argument"))]
     #[serde(alias = "arg")]
@@ -413,10 +1645,29 @@ pub struct ArgumentVariableConfig {
     ///
     /// This is **not** the name of the environment variable to source the value from.
     /// If you want to source a variables value from an environment variable,
-    /// use an [`ExecutionVariableConfig`].
+    /// use the `from_env` field.
     #[serde(rename(deserialize = "environment_variable"))]
     #[serde(alias = "env")]
     pub environment_variable_name: Option<String>,
+
+    /// An optional environment variable to source the value from.
+    /// If the environment variable is set, its value is used instead of resolving the variable.
+    /// Values from command-line arguments still take precedence.
+    pub from_env: Option<String>,
+
+    /// An optional condition for resolving the variable, which can reference other variables.
+    /// If the condition is falsy, the variable is left unset, and its prompt is skipped.
+    #[serde(alias = "condition")]
+    pub when: Option<String>,
+
+    /// Rules that the value must satisfy, regardless of where the value came from.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
+    /// Optional conditions under which the argument must be provided.
+    /// When specified, the argument is required if every variable listed here has the
+    /// corresponding value. The listed variables are always resolved before this one.
+    pub required_when: Option<HashMap<String, String>>,
 }
 
 /// The kind of argument configuration.
@@ -456,22 +1707,63 @@ pub struct PositionalArgumentConfig {
     pub position: usize,
 }
 
-/// The configuration for a prompt to the user for input.
+/// The kind of prompt configuration.
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+#[serde(untagged)]
+pub enum PromptConfigVariant {
+    /// Denotes a shorthand prompt where only the message is provided.
+    ///
+    /// Example:
+    /// ```yaml
+    /// prompt: What is your name?
+    /// ```
+    Shorthand(String),
+
+    /// Encapsulates a [`PromptConfig`].
+    PromptConfig(PromptConfig),
+}
+
+/// The configuration for a prompt to the user for input.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone, Default)]
 pub struct PromptConfig {
     /// The message to display to the user.
     pub message: String,
 
+    /// Optional help text to display alongside the prompt.
+    pub help: Option<String>,
+
+    /// An optional default value for the prompt.
+    /// For select prompts, this is the option that is initially selected.
+    pub default: Option<String>,
+
+    /// When set to `true`, cancelling the prompt (by pressing Esc) will use the `default` value
+    /// rather than aborting the command.
+    /// Defaults to `false`.
+    #[serde(default = "default_cancel_uses_default")]
+    pub cancel_uses_default: bool,
+
     /// Additional, type-specific options for the prompt.
     #[serde(flatten)]
     pub options: PromptOptionsVariant,
 }
 
+fn default_cancel_uses_default() -> bool {
+    false
+}
+
 impl Default for PromptOptionsVariant {
     fn default() -> Self {
         return PromptOptionsVariant::Text(TextPromptOptions {
             multi_line: false,
             sensitive: false,
+            validate_with: None,
+            max_attempts: None,
+            trim: false,
+            case: None,
+            confirm: false,
+            validate: vec![],
+            prompt_symbol: None,
+            max_length: None,
         });
     }
 }
@@ -480,8 +1772,12 @@ impl Default for PromptOptionsVariant {
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
 #[serde(untagged)]
 pub enum PromptOptionsVariant {
-    // Note: Select needs to come first here because SelectPromptOptions is the most specific.
+    // Note: MultiSelect and Select need to come first here because they're the most specific.
     // Serde will use the type it matches on.
+    /// Encapsulates a [`MultiSelectPromptOptions]`, indicating that the user can choose any number
+    /// of the options.
+    MultiSelect(MultiSelectPromptOptions),
+
     /// Encapsulates a [`SelectPromptOptions]`, indicating that the prompt should be a select-style
     /// prompt.
     Select(SelectPromptOptions),
@@ -501,22 +1797,108 @@ pub struct TextPromptOptions {
     /// When set to `true`, the input value will be obscured.
     #[serde(default = "default_sensitive")]
     pub sensitive: bool,
+
+    /// When set to `true`, sensitive values will need to be entered twice to ensure they match.
+    /// Defaults to `false`.
+    #[serde(default = "default_confirm_sensitive")]
+    pub confirm: bool,
+
+    /// An optional command used to validate the input value.
+    /// The input value is available to the command as the `value` variable, and is only accepted
+    /// if the command exits with a zero exit code.
+    pub validate_with: Option<ExecutionConfigVariant>,
+
+    /// Rules that the input value must satisfy.
+    /// Values which don't satisfy a rule are rejected with the rule's message, and the user is
+    /// asked to try again.
+    #[serde(default = "default_validation_rules", alias = "validation")]
+    pub validate: Vec<ValidationRuleConfig>,
+
+    /// An optional limit on the number of times the input value can fail validation before giving
+    /// up. When not specified, the user will be asked to try again until the value is valid.
+    pub max_attempts: Option<u32>,
+
+    /// When set to `true`, leading and trailing whitespace will be removed from the input value.
+    /// Defaults to `false`.
+    #[serde(default = "default_trim")]
+    pub trim: bool,
+
+    /// An optional [`TextCase`] to convert the input value to.
+    pub case: Option<TextCase>,
+
+    /// An optional symbol to display before the message, instead of `options.prompt_symbol`.
+    pub prompt_symbol: Option<String>,
+
+    /// An optional limit on the number of characters in the input value.
+    /// Longer values are rejected, and the user is asked to try again.
+    pub max_length: Option<usize>,
+}
+
+/// The case to convert the input value of a text prompt to.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+#[serde(rename_all = "snake_case")]
+pub enum TextCase {
+    Lower,
+    Upper,
 }
 
 fn default_multi_line() -> bool {
     false
 }
 
+fn default_trim() -> bool {
+    false
+}
+
 fn default_sensitive() -> bool {
     false
 }
 
+fn default_confirm_sensitive() -> bool {
+    false
+}
+
 /// The options for a select prompt.
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
 pub struct SelectPromptOptions {
     /// The [`SelectOptionsConfig`] for determining the options the user can choose from.
     #[serde(alias = "opts")]
     pub options: SelectOptionsConfig,
+
+    /// When set to `true`, an "Other..." option is added to the end of the options.
+    /// Choosing it will show a text prompt, allowing the user to enter a value which isn't listed.
+    /// Defaults to `false`.
+    #[serde(default = "default_allow_other")]
+    pub allow_other: bool,
+}
+
+fn default_allow_other() -> bool {
+    false
+}
+
+/// The options for a multi-select prompt.
+/// The chosen options are joined by newlines to form the variable's value.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct MultiSelectPromptOptions {
+    /// The [`SelectOptionsConfig`] for determining the options the user can choose from.
+    #[serde(rename = "multi_select")]
+    #[serde(alias = "multiselect")]
+    pub options: SelectOptionsConfig,
+
+    /// The minimum number of options the user must choose.
+    pub min: Option<usize>,
+
+    /// The maximum number of options the user can choose.
+    pub max: Option<usize>,
+
+    /// The options which are chosen initially.
+    /// Each of them must be one of the available options.
+    #[serde(default = "default_multi_select_defaults", alias = "selected")]
+    pub defaults: Vec<String>,
+}
+
+fn default_multi_select_defaults() -> Vec<String> {
+    Vec::new()
 }
 
 /// The kind of select prompt options.
@@ -576,12 +1958,319 @@ pub struct CommandConfig {
     /// The [`ActionConfig`] that this command will perform when executed.
     #[serde(flatten)]
     pub action: Option<ActionConfig>,
+
+    /// A list of directories to prepend to the `PATH` when executing this command.
+    /// Relative paths are resolved from the directory containing the config file.
+    #[serde(default = "default_path_prepend")]
+    pub path_prepend: Vec<String>,
+
+    /// An optional directory to execute the action in, overriding the root-level `workdir`.
+    /// Relative paths are resolved from the directory containing the config file.
+    #[serde(rename = "workdir")]
+    #[serde(alias = "wd")]
+    pub working_directory: Option<String>,
+
+    /// Additional environment variables to set when executing the action, overriding the
+    /// root-level `env`. Variables can be referenced in the values.
+    #[serde(default = "default_env")]
+    pub env: LinkedHashMap<String, String>,
+
+    /// Whether to show a summary of the resolved variables and ask the user to confirm before
+    /// executing this command.
+    #[serde(default = "default_confirm_with_summary")]
+    pub confirm_with_summary: bool,
+
+    /// An optional phrase the user must type to confirm before executing this command, such as
+    /// the name of the environment. Variables are substituted into the phrase.
+    pub confirm_phrase: Option<String>,
+
+    /// Whether this command should fail if any of the variables referenced by its actions
+    /// are empty.
+    #[serde(default = "default_require_non_empty")]
+    pub require_non_empty: bool,
+
+    /// A list of [`CommandTestConfig`]s used to verify the commands this command will execute.
+    #[serde(default = "default_tests")]
+    pub tests: Vec<CommandTestConfig>,
+
+    /// A list of tags used to filter the commands shown in the --help output.
+    #[serde(default = "default_tags")]
+    pub tags: Vec<String>,
+
+    /// An optional command to run once this command has finished, regardless of whether it
+    /// succeeded. Overrides the notification command in the [`DingusOptions`].
+    pub notify: Option<ExecutionConfigVariant>,
+
+    /// An optional message to show alongside a spinner while the action is executed.
+    /// When specified, the output of the action is hidden unless it fails.
+    pub spinner: Option<String>,
+
+    /// An optional octal umask (e.g. `027`) to apply while the action is executed, so that files
+    /// created by the action have the right permissions.
+    /// This is only supported on Unix, it has no effect on Windows.
+    pub umask: Option<String>,
+
+    /// When set to `true`, a lock is held while this command runs so that it can't be run again
+    /// on the same machine until it has finished.
+    /// Defaults to `false`.
+    #[serde(default = "default_lock")]
+    pub lock: bool,
+
+    /// When set to `true`, the command will wait for the lock to be released if it's already
+    /// running, rather than failing.
+    /// Defaults to `false`.
+    #[serde(default = "default_wait_for_lock")]
+    pub wait_for_lock: bool,
+
+    /// An optional duration (e.g. `30s`, `5m`, or `1h30m`) after which each step of the action is
+    /// killed, along with any processes it started.
+    pub timeout: Option<String>,
+
+    /// An optional shell used to execute this command, overriding the `shell` option.
+    pub shell: Option<String>,
+
+    /// An optional niceness to execute the action with, overriding the `nice` option.
+    pub nice: Option<i32>,
+
+    /// A list of [`PreconditionConfig`]s which must all pass before this command is executed.
+    #[serde(default = "default_preconditions")]
+    pub preconditions: Vec<PreconditionConfig>,
+
+    /// The paths of other commands which must be executed successfully before this command, in
+    /// order. Subcommands are separated by spaces, the same way they would be on the command-line.
+    /// The dependencies of each command are executed before it.
+    #[serde(default = "default_depends_on")]
+    pub depends_on: Vec<String>,
+
+    /// Optional [`HooksConfig`] to execute before the action, once the variables have been
+    /// resolved. If any of them fail, the action isn't executed.
+    pub before: Option<HooksConfig>,
+
+    /// Optional [`HooksConfig`] to execute once the action has succeeded. Hooks with `always` set
+    /// are executed even if the action, or an earlier hook, fails.
+    pub after: Option<HooksConfig>,
+
+    /// The number of times variables should be substituted, so that variables whose values
+    /// reference other variables are expanded.
+    /// Defaults to `1`, meaning variable values are used as-is.
+    #[serde(default = "default_render_passes")]
+    pub render_passes: u32,
+}
+
+impl Default for CommandConfig {
+    fn default() -> Self {
+        return CommandConfig {
+            name: None,
+            description: None,
+            hidden: default_hidden(),
+            platform: None,
+            variables: default_variables(),
+            commands: default_commands(),
+            action: None,
+            path_prepend: default_path_prepend(),
+            working_directory: None,
+            env: default_env(),
+            confirm_with_summary: default_confirm_with_summary(),
+            confirm_phrase: None,
+            require_non_empty: default_require_non_empty(),
+            tests: default_tests(),
+            tags: default_tags(),
+            notify: None,
+            spinner: None,
+            umask: None,
+            lock: default_lock(),
+            wait_for_lock: default_wait_for_lock(),
+            timeout: None,
+            shell: None,
+            nice: None,
+            preconditions: default_preconditions(),
+            depends_on: default_depends_on(),
+            before: None,
+            after: None,
+            render_passes: default_render_passes(),
+        };
+    }
 }
 
 fn default_hidden() -> bool {
     false
 }
 
+fn default_path_prepend() -> Vec<String> {
+    Vec::new()
+}
+
+fn default_confirm_with_summary() -> bool {
+    false
+}
+
+fn default_require_non_empty() -> bool {
+    false
+}
+
+fn default_lock() -> bool {
+    false
+}
+
+fn default_wait_for_lock() -> bool {
+    false
+}
+
+fn default_tests() -> Vec<CommandTestConfig> {
+    Vec::new()
+}
+
+fn default_tags() -> Vec<String> {
+    Vec::new()
+}
+
+fn default_preconditions() -> Vec<PreconditionConfig> {
+    Vec::new()
+}
+
+fn default_render_passes() -> u32 {
+    1
+}
+
+/// An assertion about the commands that a [`CommandConfig`] will execute for a given set of
+/// variable values.
+///
+/// Example:
+/// ```yaml
+/// tests:
+///     - variables:
+///         name: Dingus
+///       expected:
+///         - echo Hello, Dingus!
+/// ```
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct CommandTestConfig {
+    /// The variable values to use when rendering the command.
+    #[serde(default = "default_test_variables")]
+    #[serde(alias = "vars")]
+    pub variables: HashMap<String, String>,
+
+    /// The commands that are expected to be executed, in order.
+    pub expected: Vec<String>,
+}
+
+fn default_test_variables() -> HashMap<String, String> {
+    HashMap::new()
+}
+
+/// A check which must pass before a [`CommandConfig`] is executed.
+///
+/// Example:
+/// ```yaml
+/// preconditions:
+///     - file_exists: ./docker-compose.yaml
+///     - command_succeeds: docker info
+///       message: Docker isn't running
+///     - env_set: AWS_PROFILE
+/// ```
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct PreconditionConfig {
+    /// The [`PreconditionCheckConfig`] to perform.
+    #[serde(flatten)]
+    pub check: PreconditionCheckConfig,
+
+    /// An optional message to show when the check fails, instead of the default message.
+    pub message: Option<String>,
+}
+
+/// The kind of check to perform for a [`PreconditionConfig`].
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+#[serde(rename_all = "snake_case")]
+pub enum PreconditionCheckConfig {
+    /// Checks that a file or directory exists at the provided path.
+    FileExists(String),
+
+    /// Checks that the provided [`ExecutionConfigVariant`] exits with a zero exit code.
+    CommandSucceeds(ExecutionConfigVariant),
+
+    /// Checks that the provided environment variable is set to a non-empty value.
+    EnvSet(String),
+}
+
+/// One or more commands to execute before or after a command's action.
+///
+/// Example:
+/// ```yaml
+/// before: npm ci
+/// after:
+///     - ./cleanup.sh
+///     - bash: docker compose down
+///       always: true
+/// ```
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+#[serde(untagged)]
+pub enum HooksConfig {
+    /// A single [`HookConfigVariant`].
+    One(HookConfigVariant),
+
+    /// A list of [`HookConfigVariant`]s, executed in order.
+    Many(Vec<HookConfigVariant>),
+}
+
+impl HooksConfig {
+    /// Returns each of the hooks, in the order they should be executed.
+    pub fn hooks(&self) -> Vec<HookConfigVariant> {
+        match self {
+            HooksConfig::One(hook) => vec![hook.clone()],
+            HooksConfig::Many(hooks) => hooks.clone(),
+        }
+    }
+}
+
+/// A command to execute before or after a command's action.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+#[serde(untagged)]
+pub enum HookConfigVariant {
+    /// Denotes a shorthand hook, which is executed as a raw command.
+    Shorthand(String),
+
+    /// Encapsulates a [`HookConfig`].
+    HookConfig(HookConfig),
+}
+
+impl HookConfigVariant {
+    /// Returns the [`ExecutionConfigVariant`] to execute for this hook.
+    pub fn execution(&self) -> ExecutionConfigVariant {
+        match self {
+            HookConfigVariant::Shorthand(command) => ExecutionConfigVariant::RawCommand(
+                RawCommandConfigVariant::Shorthand(command.clone()),
+            ),
+            HookConfigVariant::HookConfig(hook_config) => hook_config.execution.clone(),
+        }
+    }
+
+    /// Whether this hook should be executed even if the action, or an earlier hook, failed.
+    pub fn always(&self) -> bool {
+        match self {
+            HookConfigVariant::Shorthand(_) => false,
+            HookConfigVariant::HookConfig(hook_config) => hook_config.always,
+        }
+    }
+}
+
+/// The configuration for a hook.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct HookConfig {
+    /// The [`ExecutionConfigVariant`] to execute.
+    #[serde(flatten)]
+    pub execution: ExecutionConfigVariant,
+
+    /// When set to `true`, the hook is executed even if the action, or an earlier hook, failed.
+    /// Only applies to `after` hooks.
+    /// Defaults to `false`.
+    #[serde(default = "default_always")]
+    pub always: bool,
+}
+
+fn default_always() -> bool {
+    false
+}
+
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
 #[serde(untagged)]
 pub enum OneOrManyPlatforms {
@@ -614,12 +2303,63 @@ pub enum ActionConfig {
     SingleStep(SingleActionConfig),
     MultiStep(MultiActionConfig),
     Alias(AliasActionConfig),
+    Calls(CallsActionConfig),
+    Steps(StepsActionConfig),
+}
+
+/// Contains named steps, each of which is executed once the steps it depends on have been executed.
+/// Steps which don't depend on each other are executed in parallel.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct StepsActionConfig {
+    pub steps: LinkedHashMap<String, StepConfig>,
+}
+
+/// A named step of a [`StepsActionConfig`].
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct StepConfig {
+    /// The [`ExecutionConfigVariant`] to execute for this step.
+    pub action: ExecutionConfigVariant,
+
+    /// The names of the steps which must be executed before this one.
+    #[serde(default = "default_depends_on")]
+    pub depends_on: Vec<String>,
+
+    /// The name of a variable to store the output of this step in, rather than printing it.
+    /// The variable is available to the steps executed after this one.
+    pub output: Option<String>,
+}
+
+fn default_depends_on() -> Vec<String> {
+    Vec::new()
+}
+
+/// Contains the paths of other commands to run, each with its own variables and settings.
+/// Subcommands are separated by spaces, the same way they would be on the command-line.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct CallsActionConfig {
+    #[serde(alias = "runs")]
+    pub calls: Vec<String>,
+
+    /// When set to `true`, the remaining commands will still be called after one of them fails.
+    /// The first failure is still reported once all of the commands have been called.
+    /// Defaults to `false`.
+    #[serde(default = "default_continue_on_error")]
+    pub continue_on_error: bool,
+}
+
+fn default_continue_on_error() -> bool {
+    false
 }
 
 /// Contains the prefix for a command to execute.
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
 pub struct AliasActionConfig {
     pub alias: String,
+
+    /// A list of [`PreconditionConfig`]s which must all pass for the alias to be available.
+    /// Aliases which aren't available are left out of the command-line entirely.
+    #[serde(default = "default_preconditions")]
+    pub requires: Vec<PreconditionConfig>,
 }
 
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
@@ -641,6 +2381,27 @@ pub enum ExecutionConfigVariant {
 
     /// Encapsulates a [`RawCommandConfigVariant`].
     RawCommand(RawCommandConfigVariant),
+
+    /// Encapsulates a [`PipelineConfig`].
+    Pipeline(PipelineConfig),
+}
+
+impl ExecutionConfigVariant {
+    /// Returns the command text for this execution, before any variables have been substituted.
+    pub fn command_template(&self) -> String {
+        match self {
+            ExecutionConfigVariant::ShellCommand(ShellCommandConfigVariant::Bash(bash_config)) => {
+                bash_config.command.clone()
+            }
+            ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(command)) => {
+                command.clone()
+            }
+            ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::RawCommandConfig(
+                raw_config,
+            )) => raw_config.command.clone(),
+            ExecutionConfigVariant::Pipeline(pipeline_config) => pipeline_config.stages.join(" | "),
+        }
+    }
 }
 
 /// The configuration for a raw command.
@@ -674,6 +2435,22 @@ pub struct RawCommandConfig {
     pub command: String,
 }
 
+/// The configuration for a pipeline.
+/// Each stage is executed as a raw command, without a shell, with the output from each stage
+/// being piped into the next.
+#[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
+pub struct PipelineConfig {
+    /// An optional working directory for the stages to be executed in.
+    /// If not specified, then the stages will be executed in the current directory.
+    #[serde(rename = "workdir")]
+    #[serde(alias = "wd")]
+    pub working_directory: Option<String>,
+
+    /// The commands to execute, in order.
+    #[serde(rename = "pipeline")]
+    pub stages: Vec<String>,
+}
+
 /// The configuration for a shell command.
 #[derive(Serialize, Deserialize, PartialEq, Debug, Clone)]
 #[serde(untagged)]
@@ -695,6 +2472,16 @@ pub struct BashCommandConfig {
     #[serde(rename = "bash")]
     #[serde(alias = "sh")]
     pub command: String,
+
+    /// Whether the command should be written to a temporary script file and executed from there,
+    /// rather than being passed to bash as an argument.
+    /// This avoids argument length limits and quoting issues for large scripts.
+    #[serde(default = "default_script_file")]
+    pub script_file: bool,
+}
+
+fn default_script_file() -> bool {
+    false
 }
 
 #[cfg(test)]
@@ -704,13 +2491,15 @@ mod tests {
     use crate::config::Platform::Linux;
     use crate::config::RawCommandConfigVariant::Shorthand;
     use std::io::Write;
-    use tempfile::NamedTempFile;
+    use std::path::Component;
+    use tempfile::{NamedTempFile, TempDir};
 
     fn bash_exec(command: &str, workdir: Option<String>) -> ExecutionConfigVariant {
         return ExecutionConfigVariant::ShellCommand(ShellCommandConfigVariant::Bash(
             BashCommandConfig {
                 working_directory: workdir,
                 command: command.to_string(),
+                script_file: false,
             },
         ));
     }
@@ -721,6 +2510,223 @@ mod tests {
         ));
     }
 
+    #[test]
+    fn find_config_file_finds_file_in_directory() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join("dingus.yaml");
+        fs::write(&config_file_path, DEFAULT_CONFIG_FILE).unwrap();
+
+        // Act
+        let result = find_config_file(temp_dir.path());
+
+        // Assert
+        assert_eq!(result, Some(config_file_path));
+    }
+
+    #[test]
+    fn find_config_file_finds_file_in_parent_directory() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join("Dingus.yml");
+        fs::write(&config_file_path, DEFAULT_CONFIG_FILE).unwrap();
+
+        let nested_directory = temp_dir.path().join("src").join("nested");
+        fs::create_dir_all(&nested_directory).unwrap();
+
+        // Act
+        let result = find_config_file(&nested_directory);
+
+        // Assert
+        assert_eq!(result, Some(config_file_path));
+    }
+
+    #[test]
+    fn find_config_file_finds_hidden_file_in_parent_directory() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join(".dingus.yaml");
+        fs::write(&config_file_path, DEFAULT_CONFIG_FILE).unwrap();
+
+        let nested_directory = temp_dir.path().join("src").join("nested");
+        fs::create_dir_all(&nested_directory).unwrap();
+
+        // Act
+        let result = find_config_file(&nested_directory);
+
+        // Assert
+        assert_eq!(result, Some(config_file_path));
+    }
+
+    #[test]
+    fn find_config_file_prefers_closest_file() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(temp_dir.path().join("dingus.yaml"), DEFAULT_CONFIG_FILE).unwrap();
+
+        let nested_directory = temp_dir.path().join("nested");
+        fs::create_dir_all(&nested_directory).unwrap();
+        let nested_config_file_path = nested_directory.join("dingus.yaml");
+        fs::write(&nested_config_file_path, DEFAULT_CONFIG_FILE).unwrap();
+
+        // Act
+        let result = find_config_file(&nested_directory);
+
+        // Assert
+        assert_eq!(result, Some(nested_config_file_path));
+    }
+
+    #[test]
+    fn load_fails_for_missing_config_file() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join("missing.yaml");
+
+        // Act
+        let result = load(Some(&config_file_path.display().to_string()), false, None);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(ConfigError::PathNotFound { path }) if path == config_file_path
+        ));
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn load_resolves_relative_config_file_path() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join("dingus.yaml");
+        fs::write(&config_file_path, DEFAULT_CONFIG_FILE).unwrap();
+
+        // Walk up from the current directory to the root, then back down to the config file
+        let current_dir = env::current_dir().unwrap();
+        let relative_path: PathBuf = current_dir
+            .components()
+            .filter(|component| matches!(component, Component::Normal(_)))
+            .map(|_| Component::ParentDir)
+            .chain(
+                config_file_path
+                    .components()
+                    .filter(|component| matches!(component, Component::Normal(_))),
+            )
+            .collect();
+        assert!(relative_path.is_relative());
+
+        // Act
+        let found_config = load(Some(&relative_path.display().to_string()), false, None).unwrap();
+
+        // Assert
+        let Source::File(path) = found_config.source else {
+            panic!("expected the config to come from a file");
+        };
+        assert_eq!(path, fs::canonicalize(&config_file_path).unwrap());
+        assert!(path.parent().unwrap().is_dir());
+    }
+
+    #[test]
+    fn load_parses_toml_config() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join("dingus.toml");
+        let toml = "description = \"My Dingus file\"
+
+[variables]
+name = \"Godzilla\"
+
+[commands.greet]
+action = \"echo \\\"Hello, $name!\\\"\"
+confirm_phrase = \"greet\"";
+        fs::write(&config_file_path, toml).unwrap();
+
+        // Act
+        let found_config =
+            load(Some(&config_file_path.display().to_string()), false, None).unwrap();
+
+        // Assert
+        let config = found_config.config;
+        assert_eq!(found_config.format, ConfigFormat::Toml);
+        assert_eq!(config.description, Some("My Dingus file".to_string()));
+        assert!(matches!(
+            config.variables.get("name").unwrap(),
+            VariableConfig::ShorthandLiteral(value) if value == "Godzilla"
+        ));
+
+        let command = &config.commands["greet"];
+        assert_eq!(command.confirm_phrase, Some("greet".to_string()));
+        assert!(command.action.is_some());
+    }
+
+    #[test]
+    fn load_parses_json_config() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join("dingus.json");
+        let json = "{
+    \"description\": \"My Dingus file\",
+    \"variables\": { \"name\": \"Godzilla\" },
+    \"commands\": {
+        \"greet\": { \"action\": \"echo \\\"Hello, $name!\\\"\", \"confirm_phrase\": \"greet\" }
+    }
+}";
+        fs::write(&config_file_path, json).unwrap();
+
+        // Act
+        let found_config =
+            load(Some(&config_file_path.display().to_string()), false, None).unwrap();
+
+        // Assert
+        let config = found_config.config;
+        assert_eq!(found_config.format, ConfigFormat::Json);
+        assert_eq!(config.description, Some("My Dingus file".to_string()));
+        assert!(matches!(
+            config.variables.get("name").unwrap(),
+            VariableConfig::ShorthandLiteral(value) if value == "Godzilla"
+        ));
+
+        let command = &config.commands["greet"];
+        assert_eq!(command.confirm_phrase, Some("greet".to_string()));
+        assert!(command.action.is_some());
+    }
+
+    #[test]
+    fn load_fails_for_unsupported_format() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join("dingus.ini");
+        fs::write(&config_file_path, "").unwrap();
+
+        // Act
+        let result = load(Some(&config_file_path.display().to_string()), false, None);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(ConfigError::UnsupportedFormat { extension }) if extension == "ini"
+        ));
+    }
+
+    #[test]
+    fn find_config_files_finds_all_files_closest_first() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let config_file_path = temp_dir.path().join("dingus.yaml");
+        fs::write(&config_file_path, DEFAULT_CONFIG_FILE).unwrap();
+
+        let nested_directory = temp_dir.path().join("nested").join("deeper");
+        fs::create_dir_all(&nested_directory).unwrap();
+        let nested_config_file_path = nested_directory.join("Dingus.yml");
+        fs::write(&nested_config_file_path, DEFAULT_CONFIG_FILE).unwrap();
+
+        // Act
+        let result = find_config_files(&nested_directory);
+
+        // Assert
+        // Only check the first two, in case there's another config file further up
+        assert_eq!(result[..2], [nested_config_file_path, config_file_path]);
+    }
+
     #[test]
     fn empty_root_variables_allowed() {
         let yaml = "commands:
@@ -782,6 +2788,10 @@ commands:
                 value: "My root value".to_string(),
                 argument: None,
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             })
         );
 
@@ -793,6 +2803,10 @@ commands:
                 value: "My command value".to_string(),
                 argument: Some(ArgumentConfigVariant::Shorthand("command-arg".to_string())),
                 environment_variable_name: Some("MY_VAR".to_string()),
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             })
         )
     }
@@ -839,6 +2853,11 @@ commands:
                 execution: bash_exec("echo \"My root value\"", Some("../".to_string())),
                 argument: None,
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
             })
         );
 
@@ -852,6 +2871,11 @@ commands:
                     "command-arg-1".to_string()
                 )),
                 environment_variable_name: Some("MY_VAR_1".to_string()),
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
             })
         );
 
@@ -866,6 +2890,11 @@ commands:
                     short: Some('c'),
                 })),
                 environment_variable_name: Some("MY_VAR_2".to_string()),
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
             })
         );
 
@@ -881,10 +2910,151 @@ commands:
                     }
                 )),
                 environment_variable_name: Some("MY_VAR_3".to_string()),
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
             })
         )
     }
 
+    #[test]
+    fn exit_code_variable_parsed() {
+        let yaml = "variables:
+    has_docker:
+        arg: has-docker
+        exit_code: which docker
+commands:
+    demo:
+        action: echo $has_docker";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let has_docker_variable = config.variables.get("has_docker").unwrap();
+        assert_eq!(
+            has_docker_variable,
+            &VariableConfig::ExitCode(ExitCodeVariableConfig {
+                description: None,
+                argument: Some(ArgumentConfigVariant::Shorthand("has-docker".to_string())),
+                environment_variable_name: None,
+                execution: raw_exec("which docker"),
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
+            })
+        );
+    }
+
+    #[test]
+    fn pipeline_variable_parsed() {
+        let yaml = "variables:
+    latest_tag:
+        exec:
+            pipeline:
+                - git tag --list
+                - sort --version-sort
+                - tail -n 1
+commands:
+    demo:
+        action: echo $latest_tag";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let latest_tag_variable = config.variables.get("latest_tag").unwrap();
+        assert_eq!(
+            latest_tag_variable,
+            &VariableConfig::Execution(ExecutionVariableConfig {
+                description: None,
+                argument: None,
+                environment_variable_name: None,
+                execution: ExecutionConfigVariant::Pipeline(PipelineConfig {
+                    working_directory: None,
+                    stages: vec![
+                        "git tag --list".to_string(),
+                        "sort --version-sort".to_string(),
+                        "tail -n 1".to_string(),
+                    ],
+                }),
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
+            })
+        );
+    }
+
+    #[test]
+    fn http_variable_parsed() {
+        let yaml = "variables:
+    latest_version:
+        http:
+            url: https://api.github.com/repos/$owner/$repo/releases/latest
+            json_path: tag_name
+            headers:
+                Accept: application/json
+commands:
+    demo:
+        action: echo $latest_version";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut headers = LinkedHashMap::new();
+        headers.insert("Accept".to_string(), "application/json".to_string());
+
+        let latest_version_variable = config.variables.get("latest_version").unwrap();
+        assert_eq!(
+            latest_version_variable,
+            &VariableConfig::Http(HttpVariableConfig {
+                description: None,
+                argument: None,
+                environment_variable_name: None,
+                request: HttpRequestConfig {
+                    url: "https://api.github.com/repos/$owner/$repo/releases/latest".to_string(),
+                    json_path: Some("tag_name".to_string()),
+                    headers,
+                },
+                validate: vec![],
+                from_env: None,
+                when: None,
+            })
+        );
+    }
+
+    #[test]
+    fn preconditions_parsed() {
+        let yaml = "commands:
+    up:
+        preconditions:
+            - file_exists: ./docker-compose.yaml
+            - command_succeeds: docker info
+              message: Docker isn't running
+            - env_set: AWS_PROFILE
+        action: docker compose up";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let up_command = config.commands.get("up").unwrap();
+        assert_eq!(
+            up_command.preconditions,
+            vec![
+                PreconditionConfig {
+                    check: PreconditionCheckConfig::FileExists("./docker-compose.yaml".to_string()),
+                    message: None,
+                },
+                PreconditionConfig {
+                    check: PreconditionCheckConfig::CommandSucceeds(
+                        ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                            "docker info".to_string()
+                        ))
+                    ),
+                    message: Some("Docker isn't running".to_string()),
+                },
+                PreconditionConfig {
+                    check: PreconditionCheckConfig::EnvSet("AWS_PROFILE".to_string()),
+                    message: None,
+                },
+            ]
+        );
+    }
+
     #[test]
     fn prompt_variable_parsed() {
         let yaml = "variables:
@@ -929,13 +3099,27 @@ commands:
             &VariableConfig::Prompt(PromptVariableConfig {
                 argument: None,
                 environment_variable_name: None,
-                prompt: PromptConfig {
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
                     message: "What's your name?".to_string(),
                     options: PromptOptionsVariant::Text(TextPromptOptions {
                         multi_line: false,
                         sensitive: false,
-                    })
-                },
+                        validate_with: None,
+                        max_attempts: None,
+                        trim: false,
+                        case: None,
+                        confirm: false,
+                        validate: vec![],
+                        prompt_symbol: None,
+                        max_length: None,
+                    }),
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             })
         );
 
@@ -945,16 +3129,23 @@ commands:
             &VariableConfig::Prompt(PromptVariableConfig {
                 argument: Some(ArgumentConfigVariant::Shorthand("food".to_string())),
                 environment_variable_name: Some("FAV_FOOD".to_string()),
-                prompt: PromptConfig {
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
                     message: "What's your favourite food?".to_string(),
                     options: PromptOptionsVariant::Select(SelectPromptOptions {
                         options: SelectOptionsConfig::Literal(vec![
                             "Burger".to_string(),
                             "Pizza".to_string(),
                             "Fries".to_string()
-                        ])
-                    })
-                },
+                        ]),
+                        allow_other: false,
+                    }),
+                    ..Default::default()
+                }),
+                options: None,
+                description: Some("Favourite food".to_string()),
+                validate: vec![],
+                from_env: None,
+                when: None,
             })
         );
 
@@ -965,48 +3156,333 @@ commands:
             &VariableConfig::Prompt(PromptVariableConfig {
                 argument: None,
                 environment_variable_name: None,
-                prompt: PromptConfig {
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
                     message: "What's your password?".to_string(),
                     options: PromptOptionsVariant::Text(TextPromptOptions {
                         multi_line: false,
-                        sensitive: true
-                    })
-                },
+                        sensitive: true,
+                        validate_with: None,
+                        max_attempts: None,
+                        trim: false,
+                        case: None,
+                        confirm: false,
+                        validate: vec![],
+                        prompt_symbol: None,
+                        max_length: None,
+                    }),
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            })
+        );
+
+        let life_story_variable = demo_command.variables.get("life-story").unwrap();
+        assert_eq!(
+            life_story_variable,
+            &VariableConfig::Prompt(PromptVariableConfig {
+                argument: None,
+                environment_variable_name: None,
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
+                    message: "What's your life story?".to_string(),
+                    options: PromptOptionsVariant::Text(TextPromptOptions {
+                        multi_line: true,
+                        sensitive: false,
+                        validate_with: None,
+                        max_attempts: None,
+                        trim: false,
+                        case: None,
+                        confirm: false,
+                        validate: vec![],
+                        prompt_symbol: None,
+                        max_length: None,
+                    }),
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            })
+        );
+
+        let fav_line_variable = demo_command.variables.get("favourite-line").unwrap();
+        assert_eq!(
+            fav_line_variable,
+            &VariableConfig::Prompt(PromptVariableConfig {
+                argument: None,
+                environment_variable_name: None,
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
+                    message: "What's your favourite line?".to_string(),
+                    options: PromptOptionsVariant::Select(SelectPromptOptions {
+                        options: SelectOptionsConfig::Execution(ExecutionSelectOptionsConfig {
+                            execution: raw_exec("cat example.txt")
+                        }),
+                        allow_other: false,
+                    }),
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            })
+        )
+    }
+
+    #[test]
+    fn shorthand_prompt_variable_infers_prompt_type() {
+        let yaml = "variables:
+    name:
+        prompt: What's your name?
+    food:
+        prompt: What's your favourite food?
+        options:
+            - Burger
+            - Pizza
+    line:
+        prompt: What's your favourite line?
+        opts:
+            exec: cat example.txt
+commands:
+    demo:
+        action: echo \"Hello, World!\"";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let VariableConfig::Prompt(name_variable) = config.variables.get("name").unwrap() else {
+            panic!("expected a prompt variable");
+        };
+        assert_eq!(
+            name_variable.prompt_config(),
+            PromptConfig {
+                message: "What's your name?".to_string(),
+                options: PromptOptionsVariant::Text(TextPromptOptions {
+                    multi_line: false,
+                    sensitive: false,
+                    validate_with: None,
+                    max_attempts: None,
+                    trim: false,
+                    case: None,
+                    confirm: false,
+                    validate: vec![],
+                    prompt_symbol: None,
+                    max_length: None,
+                }),
+                ..Default::default()
+            }
+        );
+
+        let VariableConfig::Prompt(food_variable) = config.variables.get("food").unwrap() else {
+            panic!("expected a prompt variable");
+        };
+        assert_eq!(
+            food_variable.prompt_config(),
+            PromptConfig {
+                message: "What's your favourite food?".to_string(),
+                options: PromptOptionsVariant::Select(SelectPromptOptions {
+                    options: SelectOptionsConfig::Literal(vec![
+                        "Burger".to_string(),
+                        "Pizza".to_string()
+                    ]),
+                    allow_other: false,
+                }),
+                ..Default::default()
+            }
+        );
+
+        let VariableConfig::Prompt(line_variable) = config.variables.get("line").unwrap() else {
+            panic!("expected a prompt variable");
+        };
+        assert_eq!(
+            line_variable.prompt_config(),
+            PromptConfig {
+                message: "What's your favourite line?".to_string(),
+                options: PromptOptionsVariant::Select(SelectPromptOptions {
+                    options: SelectOptionsConfig::Execution(ExecutionSelectOptionsConfig {
+                        execution: raw_exec("cat example.txt")
+                    }),
+                    allow_other: false,
+                }),
+                ..Default::default()
+            }
+        );
+    }
+
+    #[test]
+    fn select_prompt_with_other_option_parses() {
+        let yaml = "variables:
+    monster:
+        prompt:
+            message: Which monster?
+            options:
+                - Godzilla
+                - Mothra
+            allow_other: true
+commands:
+    demo:
+        action: echo $monster";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let VariableConfig::Prompt(monster_variable) = config.variables.get("monster").unwrap()
+        else {
+            panic!("expected a prompt variable");
+        };
+        assert_eq!(
+            monster_variable.prompt_config().options,
+            PromptOptionsVariant::Select(SelectPromptOptions {
+                options: SelectOptionsConfig::Literal(vec![
+                    "Godzilla".to_string(),
+                    "Mothra".to_string(),
+                ]),
+                allow_other: true,
             })
         );
+    }
+
+    #[test]
+    fn prompt_symbol_parses() {
+        let yaml = "options:
+    prompt_symbol: »
+variables:
+    name:
+        prompt:
+            message: What's your name?
+            prompt_symbol: ›
+commands:
+    demo:
+        action: echo $name";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        assert_eq!(config.options.prompt_symbol, "»");
+
+        let VariableConfig::Prompt(name_variable) = config.variables.get("name").unwrap() else {
+            panic!("expected a prompt variable");
+        };
+        let PromptOptionsVariant::Text(text_prompt_options) = name_variable.prompt_config().options
+        else {
+            panic!("expected a text prompt");
+        };
+        assert_eq!(text_prompt_options.prompt_symbol, Some("›".to_string()));
+    }
+
+    #[test]
+    fn multi_select_prompt_parses() {
+        let yaml = "variables:
+    packages:
+        prompt:
+            message: Which packages do you want to build?
+            multi_select:
+                - api
+                - web
+                - cli
+            min: 1
+            max: 2
+            defaults:
+                - web
+commands:
+    demo:
+        action: echo $packages";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let VariableConfig::Prompt(packages_variable) = config.variables.get("packages").unwrap()
+        else {
+            panic!("expected a prompt variable");
+        };
+        assert_eq!(
+            packages_variable.prompt_config(),
+            PromptConfig {
+                message: "Which packages do you want to build?".to_string(),
+                options: PromptOptionsVariant::MultiSelect(MultiSelectPromptOptions {
+                    options: SelectOptionsConfig::Literal(vec![
+                        "api".to_string(),
+                        "web".to_string(),
+                        "cli".to_string(),
+                    ]),
+                    min: Some(1),
+                    max: Some(2),
+                    defaults: vec!["web".to_string()],
+                }),
+                ..Default::default()
+            }
+        );
+    }
+
+    #[test]
+    fn explicit_prompt_takes_precedence_over_shorthand_options() {
+        let yaml = "variables:
+    name:
+        prompt:
+            message: What's your name?
+            sensitive: true
+        options:
+            - Alice
+            - Bob
+commands:
+    demo:
+        action: echo \"Hello, World!\"";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let VariableConfig::Prompt(name_variable) = config.variables.get("name").unwrap() else {
+            panic!("expected a prompt variable");
+        };
+        assert_eq!(
+            name_variable.prompt_config(),
+            PromptConfig {
+                message: "What's your name?".to_string(),
+                options: PromptOptionsVariant::Text(TextPromptOptions {
+                    multi_line: false,
+                    sensitive: true,
+                    validate_with: None,
+                    max_attempts: None,
+                    trim: false,
+                    case: None,
+                    confirm: false,
+                    validate: vec![],
+                    prompt_symbol: None,
+                    max_length: None,
+                }),
+                ..Default::default()
+            }
+        );
+    }
+
+    #[test]
+    fn prompt_help_defaults_to_variable_description() {
+        let yaml = "variables:
+    name:
+        description: The name to greet
+        prompt: What's your name?
+    food:
+        desc: Your favourite food
+        prompt:
+            message: What's your favourite food?
+            help: Choose wisely
+commands:
+    demo:
+        action: echo \"Hello, World!\"";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
 
-        let life_story_variable = demo_command.variables.get("life-story").unwrap();
+        let VariableConfig::Prompt(name_variable) = config.variables.get("name").unwrap() else {
+            panic!("expected a prompt variable");
+        };
         assert_eq!(
-            life_story_variable,
-            &VariableConfig::Prompt(PromptVariableConfig {
-                argument: None,
-                environment_variable_name: None,
-                prompt: PromptConfig {
-                    message: "What's your life story?".to_string(),
-                    options: PromptOptionsVariant::Text(TextPromptOptions {
-                        multi_line: true,
-                        sensitive: false
-                    })
-                },
-            })
+            name_variable.prompt_config().help,
+            Some("The name to greet".to_string())
         );
 
-        let fav_line_variable = demo_command.variables.get("favourite-line").unwrap();
+        // The prompt's own help takes precedence
+        let VariableConfig::Prompt(food_variable) = config.variables.get("food").unwrap() else {
+            panic!("expected a prompt variable");
+        };
         assert_eq!(
-            fav_line_variable,
-            &VariableConfig::Prompt(PromptVariableConfig {
-                argument: None,
-                environment_variable_name: None,
-                prompt: PromptConfig {
-                    message: "What's your favourite line?".to_string(),
-                    options: PromptOptionsVariant::Select(SelectPromptOptions {
-                        options: SelectOptionsConfig::Execution(ExecutionSelectOptionsConfig {
-                            execution: raw_exec("cat example.txt")
-                        }),
-                    })
-                }
-            })
-        )
+            food_variable.prompt_config().help,
+            Some("Choose wisely".to_string())
+        );
     }
 
     #[test]
@@ -1040,6 +3516,11 @@ commands:
                     short: Some('n'),
                 }),
                 environment_variable_name: None,
+                description: None,
+                required_when: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             })
         );
 
@@ -1049,6 +3530,11 @@ commands:
             &VariableConfig::Argument(ArgumentVariableConfig {
                 argument: ArgumentConfigVariant::Shorthand("age".to_string()),
                 environment_variable_name: None,
+                description: None,
+                required_when: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             })
         );
 
@@ -1061,6 +3547,11 @@ commands:
                     position: 1
                 }),
                 environment_variable_name: None,
+                description: None,
+                required_when: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             })
         );
     }
@@ -1112,17 +3603,12 @@ commands:
         assert_eq!(
             demo_command,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
                         "ls".to_string()
                     )),
                 })),
+                ..Default::default()
             }
         );
     }
@@ -1138,15 +3624,11 @@ commands:
         assert_eq!(
             demo_command,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::Alias(AliasActionConfig {
-                    alias: "docker compose -f docker-compose.deps.yml".to_string()
+                    alias: "docker compose -f docker-compose.deps.yml".to_string(),
+                    requires: Vec::new(),
                 })),
+                ..Default::default()
             }
         );
     }
@@ -1163,17 +3645,13 @@ commands:
         assert_eq!(
             demo_command,
             &CommandConfig {
-                name: None,
-                platform: None,
                 description: Some("Says hello.".to_string()),
-                hidden: false,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
                         "ls".to_string()
                     )),
                 })),
+                ..Default::default()
             }
         );
     }
@@ -1194,17 +3672,12 @@ commands:
         assert_eq!(
             gday_command,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
                         "ls".to_string()
                     )),
                 })),
+                ..Default::default()
             }
         );
 
@@ -1214,17 +3687,13 @@ commands:
         assert_eq!(
             demo_command,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
                 commands: map,
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
                         "cat example.txt".to_string()
                     )),
                 })),
+                ..Default::default()
             }
         );
     }
@@ -1244,17 +3713,12 @@ commands:
         assert_eq!(
             gday_command,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
                         "ls".to_string()
                     )),
                 })),
+                ..Default::default()
             }
         );
 
@@ -1264,13 +3728,8 @@ commands:
         assert_eq!(
             demo_command,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
                 commands: map,
-                action: None,
+                ..Default::default()
             }
         );
     }
@@ -1290,12 +3749,6 @@ commands:
         assert_eq!(
             demo_command,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::MultiStep(MultiActionConfig {
                     actions: vec![
                         ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
@@ -1306,10 +3759,425 @@ commands:
                         )),
                     ],
                 })),
+                ..Default::default()
             }
         );
     }
 
+    #[test]
+    fn bash_args_parse() {
+        let yaml = "options:
+    bash_args:
+        - platform: Windows
+          args:
+            - --login
+            - -c
+        - args:
+            - -e
+            - -c
+commands:
+    demo:
+        action: cat example.txt";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        assert_eq!(
+            config.options.bash_args,
+            vec![
+                BashArgsConfig {
+                    platform: Some(One(OnePlatform {
+                        platform: Platform::Windows
+                    })),
+                    args: vec!["--login".to_string(), "-c".to_string()],
+                },
+                BashArgsConfig {
+                    platform: None,
+                    args: vec!["-e".to_string(), "-c".to_string()],
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn shells_parse() {
+        let yaml = "options:
+    shell: /bin/zsh
+commands:
+    demo:
+        shell: sh
+        action: cat example.txt
+    other:
+        action: cat example.txt";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        assert_eq!(config.options.shell, "/bin/zsh");
+        assert_eq!(config.commands["demo"].shell, Some("sh".to_string()));
+        assert_eq!(config.commands["other"].shell, None);
+    }
+
+    #[test]
+    fn nice_parses() {
+        let yaml = "options:
+    nice: 10
+commands:
+    demo:
+        nice: 19
+        action: cargo build --release
+    other:
+        action: cat example.txt";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        assert_eq!(config.options.nice, Some(10));
+        assert_eq!(config.commands["demo"].nice, Some(19));
+        assert_eq!(config.commands["other"].nice, None);
+    }
+
+    #[test]
+    fn hooks_parse() {
+        let yaml = "commands:
+    demo:
+        before: npm ci
+        after:
+            - ./report.sh
+            - bash: docker compose down
+              always: true
+        action: npm test
+    other:
+        action: npm test";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let before = config.commands["demo"].before.clone().unwrap().hooks();
+        assert_eq!(before.len(), 1);
+        assert_eq!(before[0].execution(), raw_exec("npm ci"));
+        assert!(!before[0].always());
+
+        let after = config.commands["demo"].after.clone().unwrap().hooks();
+        assert_eq!(after.len(), 2);
+        assert_eq!(after[0].execution(), raw_exec("./report.sh"));
+        assert!(!after[0].always());
+        assert_eq!(after[1].execution(), bash_exec("docker compose down", None));
+        assert!(after[1].always());
+
+        assert_eq!(config.commands["other"].before, None);
+        assert_eq!(config.commands["other"].after, None);
+    }
+
+    #[test]
+    fn working_directories_parse() {
+        let yaml = "workdir: ./src
+commands:
+    demo:
+        workdir: ~/projects
+        action: cat example.txt
+    other:
+        action: cat example.txt";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        assert_eq!(config.working_directory, Some("./src".to_string()));
+        assert_eq!(
+            config.commands["demo"].working_directory,
+            Some("~/projects".to_string())
+        );
+        assert_eq!(config.commands["other"].working_directory, None);
+    }
+
+    #[test]
+    fn env_retains_declaration_order() {
+        let yaml = "env:
+    ZONE: a
+    REGION: ap-southeast-2
+commands:
+    deploy:
+        env:
+            STAGE: prod
+            API_URL: https://example.com
+            BUCKET: assets
+        action: ./deploy.sh";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let root_names: Vec<&String> = config.env.keys().collect();
+        assert_eq!(root_names, vec!["ZONE", "REGION"]);
+
+        let command_names: Vec<&String> = config.commands["deploy"].env.keys().collect();
+        assert_eq!(command_names, vec!["STAGE", "API_URL", "BUCKET"]);
+    }
+
+    #[test]
+    fn unsupported_shell_fails() {
+        let yaml = "commands:
+    parent:
+        commands:
+            demo:
+                shell: fish
+                action: ls";
+        let result = parse_config(&yaml.to_string(), Platform::Linux);
+
+        assert!(matches!(
+            result,
+            Err(ConfigError::UnsupportedShell { shell }) if shell == "fish"
+        ));
+    }
+
+    #[test]
+    fn reserved_variable_name_fails() {
+        let yaml = "commands:
+    parent:
+        commands:
+            demo:
+                variables:
+                    __dingus_plan: true
+                action: ls";
+        let result = parse_config(&yaml.to_string(), Platform::Linux);
+
+        assert!(matches!(
+            result,
+            Err(ConfigError::ReservedVariableName { name }) if name == "__dingus_plan"
+        ));
+    }
+
+    #[test]
+    fn reserved_root_variable_name_fails() {
+        let yaml = "variables:
+    __dingus_list_flags: true
+commands:
+    demo:
+        action: ls";
+        let result = parse_config(&yaml.to_string(), Platform::Linux);
+
+        assert!(matches!(
+            result,
+            Err(ConfigError::ReservedVariableName { name }) if name == "__dingus_list_flags"
+        ));
+    }
+
+    #[test]
+    fn calls_to_existing_commands_are_allowed() {
+        let yaml = "commands:
+    build:
+        action: ./build.sh
+    deploy:
+        commands:
+            application:
+                name: app
+                action: ./deploy.sh
+    release:
+        calls:
+            - build
+            - deploy app";
+        let result = parse_config(&yaml.to_string(), Platform::Linux);
+
+        assert!(result.is_ok());
+    }
+
+    #[test]
+    fn dependencies_parse() {
+        let yaml = "commands:
+    build:
+        action: ./build.sh
+    lint:
+        action: ./lint.sh
+    db:
+        commands:
+            migrate:
+                action: ./migrate.sh
+    release:
+        depends_on:
+            - build
+            - lint
+            - db migrate
+        action: ./release.sh";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        assert_eq!(
+            config.commands["release"].depends_on,
+            vec!["build", "lint", "db migrate"]
+        );
+        assert!(config.commands["build"].depends_on.is_empty());
+    }
+
+    #[test]
+    fn dependencies_on_unknown_commands_fail() {
+        let yaml = "commands:
+    build:
+        action: ./build.sh
+    release:
+        depends_on:
+            - biuld
+        action: ./release.sh";
+        let result = parse_config(&yaml.to_string(), Platform::Linux);
+
+        assert_eq!(
+            result.unwrap_err().to_string(),
+            "command \"release\" depends on \"biuld\", which doesn't exist or has no action"
+        );
+    }
+
+    #[test]
+    fn cyclic_dependencies_fail() {
+        let yaml = "commands:
+    build:
+        depends_on:
+            - generate
+        action: ./build.sh
+    generate:
+        depends_on:
+            - db migrate
+        action: ./generate.sh
+    db:
+        commands:
+            migrate:
+                depends_on:
+                    - build
+                action: ./migrate.sh";
+        let result = parse_config(&yaml.to_string(), Platform::Linux);
+
+        assert!(matches!(
+            result,
+            Err(ConfigError::DependencyCycle { cycle })
+                if cycle.first() == cycle.last() && cycle.len() == 4
+        ));
+    }
+
+    #[test]
+    fn calls_to_unknown_commands_fail() {
+        let yaml = "commands:
+    build:
+        action: ./build.sh
+    deploy:
+        commands:
+            app:
+                action: ./deploy.sh
+    ci:
+        commands:
+            release:
+                runs:
+                    - build
+                    - deploy ap";
+        let result = parse_config(&yaml.to_string(), Platform::Linux);
+
+        assert_eq!(
+            result.unwrap_err().to_string(),
+            "command \"ci release\" calls \"deploy ap\", which doesn't exist or has no action"
+        );
+    }
+
+    #[test]
+    fn calls_to_commands_without_actions_fail() {
+        let yaml = "commands:
+    deploy:
+        commands:
+            app:
+                action: ./deploy.sh
+    release:
+        calls:
+            - deploy";
+        let result = parse_config(&yaml.to_string(), Platform::Linux);
+
+        assert!(matches!(
+            result,
+            Err(ConfigError::CallTargetNotFound { command, target })
+                if command == "release" && target == "deploy"
+        ));
+    }
+
+    #[test]
+    fn user_shell_is_used_by_default() {
+        // Arrange
+        let user_shell = Some("/bin/zsh".to_string());
+
+        // Act
+        let shell = select_default_shell(user_shell, |_| true);
+
+        // Assert
+        assert_eq!(shell, "/bin/zsh");
+    }
+
+    #[test]
+    fn bash_is_used_when_user_shell_is_not_set() {
+        // Arrange
+        let user_shell = None;
+
+        // Act
+        let shell = select_default_shell(user_shell, |_| true);
+
+        // Assert
+        assert_eq!(shell, "bash");
+    }
+
+    #[test]
+    fn bash_is_used_when_user_shell_is_not_found() {
+        // Arrange
+        let user_shell = Some("/bin/zsh".to_string());
+
+        // Act
+        let shell = select_default_shell(user_shell, |shell| shell == "bash");
+
+        // Assert
+        assert_eq!(shell, "bash");
+    }
+
+    #[test]
+    fn bash_is_used_when_user_shell_is_not_supported() {
+        // Arrange
+        let user_shell = Some("/usr/bin/fish".to_string());
+
+        // Act
+        let shell = select_default_shell(user_shell, |_| true);
+
+        // Assert
+        assert_eq!(shell, "bash");
+    }
+
+    #[test]
+    fn sh_is_used_when_user_shell_is_not_set_and_bash_is_not_found() {
+        // Arrange
+        let user_shell = None;
+
+        // Act
+        let shell = select_default_shell(user_shell, |shell| shell != "bash");
+
+        // Assert
+        assert_eq!(shell, "sh");
+    }
+
+    #[test]
+    fn sh_is_used_when_neither_user_shell_nor_bash_are_found() {
+        // Arrange
+        let user_shell = Some("/bin/zsh".to_string());
+
+        // Act
+        let shell = select_default_shell(user_shell, |_| false);
+
+        // Assert
+        assert_eq!(shell, "sh");
+    }
+
+    #[test]
+    fn sh_is_used_when_user_shell_is_not_supported_and_bash_is_not_found() {
+        // Arrange
+        let user_shell = Some("/usr/bin/fish".to_string());
+
+        // Act
+        let shell = select_default_shell(user_shell, |shell| shell != "bash");
+
+        // Assert
+        assert_eq!(shell, "sh");
+    }
+
+    #[test]
+    fn is_on_path_finds_programs() {
+        // Arrange
+        let existing_program = "sh";
+        let missing_program = "definitely-not-a-real-shell";
+
+        // Act
+        let found = is_on_path(existing_program);
+        let not_found = is_on_path(missing_program);
+
+        // Assert
+        assert!(found);
+        assert!(!not_found);
+    }
+
     #[test]
     fn commands_with_specific_platforms_parse() {
         let yaml = "commands:
@@ -1328,38 +4196,30 @@ commands:
         assert_eq!(
             demo_command_nix,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
                 platform: Some(Many(ManyPlatforms {
                     platforms: vec![Platform::Linux, Platform::MacOS]
                 })),
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
                         "cat example.txt".to_string()
                     ))
                 })),
+                ..Default::default()
             }
         );
 
         assert_eq!(
             demo_command_win,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
                 platform: Some(One(OnePlatform {
                     platform: Platform::Windows
                 })),
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
                         "Get-Content example.txt".to_string()
                     ))
                 })),
+                ..Default::default()
             }
         );
     }
@@ -1377,16 +4237,12 @@ commands:
             demo_command,
             &CommandConfig {
                 name: Some("demonstration".to_string()),
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::SingleStep(SingleActionConfig {
                     action: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
                         "cat example.txt".to_string()
                     ))
                 })),
+                ..Default::default()
             }
         );
     }
@@ -1405,28 +4261,25 @@ commands:
         assert_eq!(
             demo_command,
             &CommandConfig {
-                name: None,
-                description: None,
-                hidden: false,
-                platform: None,
-                variables: Default::default(),
-                commands: Default::default(),
                 action: Some(ActionConfig::MultiStep(MultiActionConfig {
                     actions: vec![
                         ExecutionConfigVariant::ShellCommand(ShellCommandConfigVariant::Bash(
                             BashCommandConfig {
                                 working_directory: None,
                                 command: "echo \"Hello, World!\"".to_string(),
+                                script_file: false,
                             }
                         )),
                         ExecutionConfigVariant::ShellCommand(ShellCommandConfigVariant::Bash(
                             BashCommandConfig {
                                 working_directory: Some("/".to_string()),
                                 command: "pwd".to_string(),
+                                script_file: false,
                             }
                         )),
                     ]
                 })),
+                ..Default::default()
             }
         );
     }
@@ -1558,6 +4411,181 @@ commands:
         assert_eq!(second_level_command, None);
     }
 
+    #[test]
+    fn include_glob_adds_commands_from_matching_files() {
+        let temp_dir = TempDir::new().unwrap();
+        let commands_dir = temp_dir.path().join("commands");
+        fs::create_dir(&commands_dir).unwrap();
+        fs::write(
+            commands_dir.join("web.yaml"),
+            "commands:
+    serve:
+        action: npm start",
+        )
+        .unwrap();
+        fs::write(
+            commands_dir.join("db.yaml"),
+            "variables:
+    database: postgres
+commands:
+    migrate:
+        action: ./migrate.sh $database
+    seed:
+        action: ./seed.sh",
+        )
+        .unwrap();
+        fs::write(commands_dir.join("notes.txt"), "not a config file").unwrap();
+        fs::write(
+            commands_dir.join(".draft.yaml"),
+            "commands:
+    draft:
+        action: echo draft",
+        )
+        .unwrap();
+
+        let yaml = format!(
+            "include: {}/commands/*.yaml
+commands:
+    build:
+        action: cargo build",
+            temp_dir.path().to_str().unwrap()
+        );
+
+        let config = parse_config(&yaml, Platform::Linux).unwrap();
+
+        let mut command_names: Vec<&String> = config.commands.keys().collect();
+        command_names.sort();
+        assert_eq!(command_names, vec!["build", "migrate", "seed", "serve"]);
+        assert_eq!(
+            config
+                .commands
+                .get("migrate")
+                .unwrap()
+                .variables
+                .get("database")
+                .unwrap(),
+            &VariableConfig::ShorthandLiteral("postgres".to_string())
+        );
+    }
+
+    #[test]
+    fn include_glob_is_relative_to_config_file() {
+        // Arrange
+        // The tests run from the crate root, so this is like running from an unrelated directory
+        let temp_dir = TempDir::new().unwrap();
+        let commands_dir = temp_dir.path().join("commands");
+        fs::create_dir(&commands_dir).unwrap();
+        fs::write(
+            commands_dir.join("web.yaml"),
+            "commands:
+    serve:
+        action: npm start",
+        )
+        .unwrap();
+
+        let config_file_path = temp_dir.path().join("dingus.yaml");
+        fs::write(
+            &config_file_path,
+            "include: commands/*.yaml
+commands:
+    build:
+        action: cargo build",
+        )
+        .unwrap();
+
+        // Act
+        let found_config =
+            load(Some(&config_file_path.display().to_string()), false, None).unwrap();
+
+        // Assert
+        let mut command_names: Vec<&String> = found_config.config.commands.keys().collect();
+        command_names.sort();
+        assert_eq!(command_names, vec!["build", "serve"]);
+    }
+
+    #[test]
+    fn expand_glob_resolves_relative_patterns_from_directory() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let nested_directory = temp_dir.path().join("nested");
+        fs::create_dir(&nested_directory).unwrap();
+        fs::write(nested_directory.join("a.yaml"), "").unwrap();
+
+        // Act
+        let paths = expand_glob("nested/*.yaml", temp_dir.path()).unwrap();
+
+        // Assert
+        assert_eq!(paths, vec![nested_directory.join("a.yaml")]);
+    }
+
+    #[test]
+    fn include_fails_for_duplicate_commands() {
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(
+            temp_dir.path().join("a.yaml"),
+            "commands:
+    build:
+        action: make",
+        )
+        .unwrap();
+        fs::write(
+            temp_dir.path().join("b.yaml"),
+            "commands:
+    build:
+        action: cargo build",
+        )
+        .unwrap();
+
+        let yaml = format!(
+            "includes:
+    - {}/*.yaml
+commands: {{}}",
+            temp_dir.path().to_str().unwrap()
+        );
+
+        let result = parse_config(&yaml, Platform::Linux);
+
+        assert!(matches!(
+            result,
+            Err(ConfigError::DuplicateCommand { name, path })
+                if name == "build" && path == temp_dir.path().join("b.yaml")
+        ));
+    }
+
+    #[test]
+    fn include_fails_for_nested_includes() {
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(
+            temp_dir.path().join("dingus.yaml"),
+            "include: ./*.yaml
+commands: {}",
+        )
+        .unwrap();
+
+        let yaml = format!(
+            "include: {}/dingus.yaml
+commands: {{}}",
+            temp_dir.path().to_str().unwrap()
+        );
+
+        let result = parse_config(&yaml, Platform::Linux);
+
+        let Err(ConfigError::IncludeFailed { source, .. }) = result else {
+            panic!("expected the include to fail");
+        };
+        assert!(matches!(*source, ConfigError::NestedInclude));
+    }
+
+    #[test]
+    fn expand_glob_without_matches_is_empty() {
+        let temp_dir = TempDir::new().unwrap();
+        let pattern = format!("{}/missing/*.yaml", temp_dir.path().to_str().unwrap());
+
+        let paths = expand_glob(&pattern, Path::new("")).unwrap();
+
+        assert!(paths.is_empty());
+    }
+
     fn create_temp_file(content: &str) -> NamedTempFile {
         let mut temp_file = NamedTempFile::new().unwrap();
         temp_file.write_all(content.as_bytes()).unwrap();
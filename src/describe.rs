@@ -0,0 +1,380 @@
+use crate::config::{
+    ArgumentConfigVariant, CommandConfigMap, Config, PromptOptionsVariant, VariableConfig,
+    VariableConfigMap,
+};
+use std::collections::HashMap;
+
+/// Renders Markdown documentation for all of the commands in the provided [`Config`], including
+/// the variables available to each of them.
+/// Hidden commands are excluded.
+pub fn describe_markdown(config: &Config) -> String {
+    let mut markdown = String::from("# Commands\n");
+    if let Some(description) = &config.description {
+        markdown.push_str(&format!("\n{}\n", description));
+    }
+
+    describe_commands(&config.commands, &config.variables, None, &mut markdown);
+    return markdown;
+}
+
+fn describe_commands(
+    commands: &CommandConfigMap,
+    parent_variables: &VariableConfigMap,
+    parent_name: Option<&str>,
+    markdown: &mut String,
+) {
+    // Sort the commands so the output is consistent
+    let mut keys: Vec<&String> = commands.keys().collect();
+    keys.sort();
+
+    for key in keys {
+        let command_config = &commands[key];
+        if command_config.hidden {
+            continue;
+        }
+
+        let name = command_config.name.clone().unwrap_or(key.clone());
+        let command_name = match parent_name {
+            Some(parent_name) => format!("{} {}", parent_name, name),
+            None => name,
+        };
+
+        let mut variables = parent_variables.clone();
+        variables.extend(command_config.variables.clone());
+
+        markdown.push_str(&format!("\n## `{}`\n", command_name));
+        if let Some(description) = &command_config.description {
+            markdown.push_str(&format!("\n{}\n", description));
+        }
+
+        if !variables.is_empty() {
+            markdown.push_str(
+                "\n| Variable | Argument | Description | Default | Required | Prompt |\n",
+            );
+            markdown.push_str("|---|---|---|---|---|---|\n");
+            for (variable_name, variable_config) in &variables {
+                markdown.push_str(&describe_variable(variable_name, variable_config));
+            }
+        }
+
+        describe_commands(
+            &command_config.commands,
+            &variables,
+            Some(&command_name),
+            markdown,
+        );
+    }
+}
+
+fn describe_variable(name: &String, variable_config: &VariableConfig) -> String {
+    let required = match variable_required_when(variable_config) {
+        Some(Some(conditions)) => describe_conditions(conditions),
+        Some(None) => "Yes".to_string(),
+        None => "No".to_string(),
+    };
+
+    return format!(
+        "| `{}` | {} | {} | {} | {} | {} |\n",
+        name,
+        variable_argument(variable_config)
+            .map(|argument| describe_argument(&argument))
+            .unwrap_or_default(),
+        escape_cell(&variable_config.description().unwrap_or_default()),
+        variable_default(variable_config)
+            .map(|default| format!("`{}`", escape_cell(&default)))
+            .unwrap_or_default(),
+        required,
+        escape_cell(&variable_prompt(variable_config).unwrap_or_default()),
+    );
+}
+
+/// Lists the inputs accepted by a command with the provided [`VariableConfigMap`] as a plain-text
+/// table, including the argument, source, default value, and whether each is required.
+pub fn list_inputs(variable_configs: &VariableConfigMap) -> String {
+    let mut rows = vec![[
+        "VARIABLE".to_string(),
+        "FLAG".to_string(),
+        "SOURCE".to_string(),
+        "DEFAULT".to_string(),
+        "REQUIRED".to_string(),
+    ]];
+
+    for (name, variable_config) in variable_configs {
+        let required = match variable_required_when(variable_config) {
+            Some(Some(conditions)) => {
+                let conditions: Vec<String> = sorted_conditions(conditions)
+                    .iter()
+                    .map(|(name, value)| format!("{} is {}", name, value))
+                    .collect();
+                format!("when {}", conditions.join(" and "))
+            }
+            Some(None) => "yes".to_string(),
+            None => "no".to_string(),
+        };
+
+        rows.push([
+            name.clone(),
+            variable_argument(variable_config)
+                .map(|argument| list_argument(&argument))
+                .unwrap_or_default(),
+            variable_source(variable_config).to_string(),
+            variable_default(variable_config).unwrap_or_default(),
+            required,
+        ]);
+    }
+
+    let mut widths = [0; 5];
+    for row in &rows {
+        for (index, cell) in row.iter().enumerate() {
+            widths[index] = widths[index].max(cell.len());
+        }
+    }
+
+    let mut listing = String::new();
+    for row in &rows {
+        let cells: Vec<String> = row
+            .iter()
+            .enumerate()
+            .map(|(index, cell)| format!("{:width$}", cell, width = widths[index]))
+            .collect();
+        listing.push_str(cells.join("  ").trim_end());
+        listing.push('\n');
+    }
+
+    return listing;
+}
+
+/// Returns where the value of the provided [`VariableConfig`] comes from when no argument is
+/// provided.
+fn variable_source(variable_config: &VariableConfig) -> &str {
+    return match variable_config {
+        VariableConfig::ShorthandLiteral(_) | VariableConfig::Literal(_) => "value",
+        VariableConfig::Execution(_) => "execute",
+        VariableConfig::ExitCode(_) => "exit code",
+        VariableConfig::Http(_) => "http",
+        VariableConfig::File(_) => "file",
+        VariableConfig::Keyring(_) => "keyring",
+        VariableConfig::Prompt(prompt) => match prompt.prompt_config().options {
+            PromptOptionsVariant::MultiSelect(_) => "prompt (multi-select)",
+            PromptOptionsVariant::Select(_) => "prompt (select)",
+            PromptOptionsVariant::Text(_) => "prompt (text)",
+        },
+        VariableConfig::Argument(_) => "argument",
+    };
+}
+
+fn variable_argument(variable_config: &VariableConfig) -> Option<ArgumentConfigVariant> {
+    return match variable_config {
+        VariableConfig::ShorthandLiteral(_) => None,
+        VariableConfig::Literal(literal) => literal.argument.clone(),
+        VariableConfig::Execution(exec) => exec.argument.clone(),
+        VariableConfig::ExitCode(exit_code) => exit_code.argument.clone(),
+        VariableConfig::Http(http) => http.argument.clone(),
+        VariableConfig::File(file) => file.argument.clone(),
+        VariableConfig::Keyring(keyring) => keyring.argument.clone(),
+        VariableConfig::Prompt(prompt) => prompt.argument.clone(),
+        VariableConfig::Argument(argument) => Some(argument.argument.clone()),
+    };
+}
+
+fn variable_default(variable_config: &VariableConfig) -> Option<String> {
+    return match variable_config {
+        VariableConfig::ShorthandLiteral(value) => Some(value.clone()),
+        VariableConfig::Literal(literal) => Some(literal.value.clone()),
+        VariableConfig::Prompt(prompt) => prompt.prompt_config().default,
+        _ => None,
+    };
+}
+
+fn variable_prompt(variable_config: &VariableConfig) -> Option<String> {
+    return match variable_config {
+        VariableConfig::Prompt(prompt) => Some(prompt.prompt_config().message),
+        _ => None,
+    };
+}
+
+/// Returns `None` if the provided [`VariableConfig`] isn't required, otherwise the conditions
+/// under which it's required, if there are any.
+/// Argument and keyring variables have no other way of getting a value, so they're always
+/// required.
+fn variable_required_when(
+    variable_config: &VariableConfig,
+) -> Option<Option<&HashMap<String, String>>> {
+    return match variable_config {
+        VariableConfig::Argument(argument) => Some(argument.required_when.as_ref()),
+        VariableConfig::File(file) if file.required => Some(None),
+        VariableConfig::Keyring(_) => Some(None),
+        _ => None,
+    };
+}
+
+fn describe_argument(argument: &ArgumentConfigVariant) -> String {
+    return match argument {
+        ArgumentConfigVariant::Shorthand(long) => format!("`--{}`", long),
+        ArgumentConfigVariant::Named(named) => match named.short {
+            Some(short) => format!("`-{}`, `--{}`", short, named.long),
+            None => format!("`--{}`", named.long),
+        },
+        ArgumentConfigVariant::Positional(positional) => {
+            format!("Position {}", positional.position)
+        }
+    };
+}
+
+fn list_argument(argument: &ArgumentConfigVariant) -> String {
+    return match argument {
+        ArgumentConfigVariant::Shorthand(long) => format!("--{}", long),
+        ArgumentConfigVariant::Named(named) => match named.short {
+            Some(short) => format!("-{}, --{}", short, named.long),
+            None => format!("--{}", named.long),
+        },
+        ArgumentConfigVariant::Positional(positional) => {
+            format!("<position {}>", positional.position)
+        }
+    };
+}
+
+fn describe_conditions(conditions: &HashMap<String, String>) -> String {
+    let conditions: Vec<String> = sorted_conditions(conditions)
+        .iter()
+        .map(|(name, value)| format!("`{}` is `{}`", name, escape_cell(value)))
+        .collect();
+
+    return format!("When {}", conditions.join(" and "));
+}
+
+/// Sorts the provided conditions by variable name so the output is consistent.
+fn sorted_conditions(conditions: &HashMap<String, String>) -> Vec<(&String, &String)> {
+    let mut conditions: Vec<(&String, &String)> = conditions.iter().collect();
+    conditions.sort();
+    return conditions;
+}
+
+/// Escapes the provided text so that it can be used within a Markdown table cell.
+fn escape_cell(text: &str) -> String {
+    return text.replace('|', "\\|").replace('\n', " ");
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{parse_config, Platform};
+
+    const CONFIG: &str = "description: Project tooling
+variables:
+    environment:
+        value: Production
+        arg:
+            long: environment
+            short: e
+        description: The environment to use
+commands:
+    greet:
+        description: Says hello
+        variables:
+            name:
+                prompt:
+                    message: What's your name?
+                    default: Dingus
+        action: echo Hello, $name!
+    db:
+        description: Database commands
+        commands:
+            migrate:
+                description: Runs the migrations
+                variables:
+                    url:
+                        arg: url
+                    provider:
+                        value: aws
+                        arg: provider
+                    key:
+                        arg: key
+                        required_when:
+                            provider: aws
+                action: ./migrate.sh $url
+    secret:
+        hidden: true
+        action: echo shh";
+
+    #[test]
+    fn describe_markdown_contains_commands() {
+        // Arrange
+        let config = parse_config(&CONFIG.to_string(), Platform::Linux).unwrap();
+
+        // Act
+        let markdown = describe_markdown(&config);
+
+        // Assert
+        assert!(markdown.starts_with("# Commands\n\nProject tooling\n"));
+        assert!(markdown.contains("\n## `greet`\n\nSays hello\n"));
+        assert!(markdown.contains("\n## `db`\n\nDatabase commands\n"));
+        assert!(markdown.contains("\n## `db migrate`\n\nRuns the migrations\n"));
+        assert!(!markdown.contains("secret"));
+    }
+
+    #[test]
+    fn describe_markdown_contains_variables() {
+        // Arrange
+        let config = parse_config(&CONFIG.to_string(), Platform::Linux).unwrap();
+
+        // Act
+        let markdown = describe_markdown(&config);
+
+        // Assert
+        assert!(markdown.contains(
+            "| `environment` | `-e`, `--environment` | The environment to use | `Production` | No |  |"
+        ));
+        assert!(markdown.contains("| `name` |  |  | `Dingus` | No | What's your name? |"));
+        assert!(markdown.contains("| `url` | `--url` |  |  | Yes |  |"));
+        assert!(markdown.contains("| `key` | `--key` |  |  | When `provider` is `aws` |  |"));
+    }
+
+    #[test]
+    fn list_inputs_lists_variables() {
+        // Arrange
+        let config = parse_config(&CONFIG.to_string(), Platform::Linux).unwrap();
+        let mut variable_configs = config.variables.clone();
+        variable_configs.extend(config.commands["db"].commands["migrate"].variables.clone());
+
+        // Act
+        let listing = list_inputs(&variable_configs);
+
+        // Assert
+        let lines: Vec<&str> = listing.lines().collect();
+        assert_eq!(
+            lines,
+            vec![
+                "VARIABLE     FLAG               SOURCE    DEFAULT     REQUIRED",
+                "environment  -e, --environment  value     Production  no",
+                "url          --url              argument              yes",
+                "provider     --provider         value     aws         no",
+                "key          --key              argument              when provider is aws",
+            ]
+        );
+    }
+
+    #[test]
+    fn list_inputs_lists_prompt_type() {
+        // Arrange
+        let config = parse_config(&CONFIG.to_string(), Platform::Linux).unwrap();
+
+        // Act
+        let listing = list_inputs(&config.commands["greet"].variables);
+
+        // Assert
+        assert_eq!(
+            listing,
+            "VARIABLE  FLAG  SOURCE         DEFAULT  REQUIRED\nname            prompt (text)  Dingus   no\n"
+        );
+    }
+
+    #[test]
+    fn escape_cell_escapes_pipes_and_newlines() {
+        // Act
+        let escaped = escape_cell("one | two\nthree");
+
+        // Assert
+        assert_eq!(escaped, "one \\| two three");
+    }
+}
@@ -1,17 +1,38 @@
 use colored::Colorize;
 use mockall::automock;
+use std::ffi::OsString;
 use std::fmt::Formatter;
-use std::process::Command;
-use std::{fmt, io};
+use std::io::Write;
+use std::path::{Path, PathBuf};
+use std::process::{Child, ChildStdout, Command, Stdio};
+use std::sync::mpsc;
+use std::time::Duration;
+use std::{env, fmt, io, thread};
+use tempfile::NamedTempFile;
 use thiserror::Error;
 
 use crate::config::{
-    DingusOptions, ExecutionConfigVariant, RawCommandConfigVariant, ShellCommandConfigVariant,
+    BashArgsConfig, DingusOptions, ExecutionConfigVariant, Platform, RawCommandConfigVariant,
+    ShellCommandConfigVariant,
 };
 use crate::exec::ExitStatus::Unknown;
+use crate::platform::{current_platform_provider, is_current_platform};
+use crate::redact::Redactor;
 use crate::variables;
 use crate::variables::VariableMap;
 
+const PATH_VARIABLE_NAME: &str = "PATH";
+
+#[cfg(not(windows))]
+const HOME_VARIABLE_NAME: &str = "HOME";
+
+#[cfg(windows)]
+const HOME_VARIABLE_NAME: &str = "USERPROFILE";
+const DEFAULT_BASH_ARG: &str = "-c";
+const POWERSHELL_ARGS: [&str; 2] = ["-NoProfile", "-Command"];
+const POWERSHELL_SCRIPT_ARGS: [&str; 4] = ["-NoProfile", "-ExecutionPolicy", "Bypass", "-File"];
+const CMD_ARGS: [&str; 1] = ["/C"];
+
 pub type ExecutionResult = Result<ExitStatus, ExecutionError>;
 pub type ExecutionOutputResult = Result<Output, ExecutionError>;
 
@@ -64,8 +85,9 @@ impl Output {
 // TODO: Consider refactoring these to take stdio as args so we can test with stdin.
 
 /// Capable of executing an [`ExecutionConfigVariant`].
+/// Executors can be shared between threads so that independent steps can be executed in parallel.
 #[automock]
-pub trait CommandExecutor {
+pub trait CommandExecutor: Send + Sync {
     /// Executes the provided [`ExecutionConfigVariant`] with the provided [`VariableMap`]
     /// inheriting stdin, stdout, and stderr from the current process.
     fn execute(
@@ -83,14 +105,110 @@ pub trait CommandExecutor {
     ) -> ExecutionOutputResult;
 }
 
+/// Creates a [`CommandExecutor`] for resolving variables and checking requirements.
+/// The output of executed commands isn't redacted, use [`create_action_command_executor`] for
+/// executing actions.
 pub fn create_command_executor(options: &DingusOptions) -> Box<dyn CommandExecutor> {
+    let current_platform = current_platform_provider().get_platform();
+    return create_command_executor_for(options, current_platform, None, None, None);
+}
+
+/// Creates a [`CommandExecutor`] which prints the commands it's asked to execute rather than
+/// executing them. Commands always succeed, and have no output.
+/// The values of the provided sensitive variables are obscured in the printed commands.
+pub fn create_dry_run_command_executor(
+    sensitive_variable_names: Vec<String>,
+) -> Box<dyn CommandExecutor> {
+    Box::new(DryRunCommandExecutor {
+        sensitive_variable_names,
+    })
+}
+
+/// Creates a [`CommandExecutor`] for executing actions.
+/// When a timeout is provided, any command that runs for longer than it is killed, along with any
+/// processes it started.
+/// When a [`Redactor`] is provided, the output of executed commands is redacted. Note that this
+/// means the output is piped through the current process, so the commands won't be attached to
+/// the terminal.
+/// When a working directory is provided, commands are executed there rather than in the current
+/// directory, and any working directories they specify are resolved from it.
+pub fn create_action_command_executor(
+    options: &DingusOptions,
+    timeout: Option<Duration>,
+    redactor: Option<Redactor>,
+    working_directory: Option<PathBuf>,
+) -> Box<dyn CommandExecutor> {
+    let current_platform = current_platform_provider().get_platform();
+    return create_command_executor_for(
+        options,
+        current_platform,
+        timeout,
+        redactor,
+        working_directory,
+    );
+}
+
+/// Creates a [`Redactor`] for the `redact` patterns in the provided [`DingusOptions`], if there
+/// are any.
+pub fn create_redactor(options: &DingusOptions) -> Result<Option<Redactor>, ExecutionError> {
+    if options.redact.is_empty() {
+        return Ok(None);
+    }
+
+    let redactor = Redactor::new(&options.redact).map_err(|err| ExecutionError::Redact(err))?;
+    return Ok(Some(redactor));
+}
+
+fn create_command_executor_for(
+    options: &DingusOptions,
+    platform: Platform,
+    timeout: Option<Duration>,
+    redactor: Option<Redactor>,
+    working_directory: Option<PathBuf>,
+) -> Box<dyn CommandExecutor> {
     Box::new(CommandExecutorImpl {
         options: options.clone(),
+        bash_args: select_bash_args(&options.bash_args, platform),
+        shell: options.shell.clone(),
+        timeout,
+        redactor,
+        working_directory,
     })
 }
 
+/// Returns the arguments from the first [`BashArgsConfig`] for the provided [`Platform`].
+/// If there are none, then `-c` is returned.
+fn select_bash_args(bash_args_configs: &Vec<BashArgsConfig>, platform: Platform) -> Vec<String> {
+    for bash_args_config in bash_args_configs {
+        if let Some(one_or_many_platforms) = &bash_args_config.platform {
+            if !is_current_platform(platform.clone(), one_or_many_platforms) {
+                continue;
+            }
+        }
+
+        return bash_args_config.args.clone();
+    }
+
+    return vec![DEFAULT_BASH_ARG.to_string()];
+}
+
 struct CommandExecutorImpl {
     options: DingusOptions,
+
+    /// The arguments to pass to bash before the command.
+    bash_args: Vec<String>,
+
+    /// The shell to execute shell commands with.
+    shell: String,
+
+    /// How long each command can run for before it's killed.
+    timeout: Option<Duration>,
+
+    /// An optional [`Redactor`] for the output of executed commands.
+    redactor: Option<Redactor>,
+
+    /// An optional directory to execute commands in, instead of the current directory.
+    working_directory: Option<PathBuf>,
 }
 
 impl CommandExecutor for CommandExecutorImpl {
@@ -99,17 +217,15 @@ impl CommandExecutor for CommandExecutorImpl {
         execution_config: &ExecutionConfigVariant,
         variables: &VariableMap,
     ) -> ExecutionResult {
-        let mut command = get_command_for(execution_config, variables);
+        self.ensure_variables_are_set(execution_config, variables)?;
 
-        self.log(&command);
+        // The script file needs to outlive the command, it will be deleted when dropped
+        let (mut commands, _script_file) =
+            get_commands_for(execution_config, variables, &self.shell, &self.bash_args)?;
+        self.apply_working_directory(&mut commands);
 
-        let exit_status = command
-            .spawn()
-            .map_err(|io_err| ExecutionError::IO(io_err))?
-            .wait()
-            .map_err(|io_err| ExecutionError::IO(io_err))?;
-
-        Ok(ExitStatus::from_std_exitstatus(&exit_status))
+        let output = self.run(commands, false, self.redactor.as_ref())?;
+        Ok(output.status)
     }
 
     fn get_output(
@@ -117,15 +233,15 @@ impl CommandExecutor for CommandExecutorImpl {
         execution_config: &ExecutionConfigVariant,
         variables: &VariableMap,
     ) -> ExecutionOutputResult {
-        let mut command = get_command_for(execution_config, variables);
-
-        self.log(&command);
+        self.ensure_variables_are_set(execution_config, variables)?;
 
-        let output = command
-            .output()
-            .map_err(|io_err| ExecutionError::IO(io_err))?;
+        // The script file needs to outlive the command, it will be deleted when dropped
+        let (mut commands, _script_file) =
+            get_commands_for(execution_config, variables, &self.shell, &self.bash_args)?;
+        self.apply_working_directory(&mut commands);
 
-        Ok(Output::from_std_output(&output))
+        // Captured output is used for variable values, so it doesn't need to be redacted
+        self.run(commands, true, None)
     }
 }
 
@@ -136,23 +252,523 @@ impl CommandExecutorImpl {
             println!("Executing: {}", command_text.green())
         }
     }
+
+    /// Executes the provided commands in the executor's working directory, if it has one.
+    /// Working directories set by the commands themselves are resolved from it.
+    fn apply_working_directory(&self, commands: &mut Vec<Command>) {
+        let Some(working_directory) = &self.working_directory else {
+            return;
+        };
+
+        for command in commands {
+            let directory = match command.get_current_dir() {
+                Some(directory) => working_directory.join(directory),
+                None => working_directory.clone(),
+            };
+
+            command.current_dir(directory);
+        }
+    }
+
+    /// Ensures the variables referenced by the provided raw command or pipeline are set, unless
+    /// `options.allow_missing_variables` is `true`.
+    /// Raw commands aren't executed by a shell, so missing variables would otherwise be passed to
+    /// the command as is. Shell commands are left to the shell.
+    fn ensure_variables_are_set(
+        &self,
+        execution_config: &ExecutionConfigVariant,
+        variables: &VariableMap,
+    ) -> Result<(), ExecutionError> {
+        if self.options.allow_missing_variables {
+            return Ok(());
+        }
+
+        let templates = match execution_config {
+            ExecutionConfigVariant::ShellCommand(_) => return Ok(()),
+            ExecutionConfigVariant::RawCommand(_) => vec![execution_config.command_template()],
+            ExecutionConfigVariant::Pipeline(pipeline_config) => pipeline_config.stages.clone(),
+        };
+
+        let mut names: Vec<String> = Vec::new();
+        for template in templates {
+            for name in variables::find_missing_variables(&template, variables) {
+                if !names.contains(&name) {
+                    names.push(name);
+                }
+            }
+        }
+
+        if !names.is_empty() {
+            return Err(ExecutionError::MissingVariables { names });
+        }
+
+        return Ok(());
+    }
+
+    /// Runs the provided commands, piping the stdout of each command into the stdin of the next.
+    /// When `capture_output` is `true`, the stdout and stderr of the last command are returned,
+    /// otherwise they are written to the stdout and stderr of the current process, redacted by the
+    /// provided [`Redactor`] if there is one.
+    /// If any command exits with a non-zero exit code, then the first non-zero exit code is used.
+    fn run(
+        &self,
+        commands: Vec<Command>,
+        capture_output: bool,
+        redactor: Option<&Redactor>,
+    ) -> ExecutionOutputResult {
+        if commands.is_empty() {
+            return Err(ExecutionError::EmptyPipeline);
+        }
+
+        // Commands with a timeout are run in their own process group so that any processes they
+        // start can be killed along with them. The process group is given the terminal while it
+        // runs, so that it still receives Ctrl+C and can read from the terminal.
+        #[cfg(unix)]
+        let terminal = match self.timeout {
+            Some(_) => Some(TerminalHandover::new()),
+            None => None,
+        };
+
+        #[cfg(unix)]
+        let mut process_group_id: Option<u32> = None;
+
+        let last_index = commands.len() - 1;
+        let mut children = Vec::with_capacity(commands.len());
+        let mut previous_stdout: Option<ChildStdout> = None;
+        for (index, mut command) in commands.into_iter().enumerate() {
+            self.log(&command);
+
+            match previous_stdout.take() {
+                Some(stdout) => {
+                    command.stdin(stdout);
+                }
+
+                // Match the behaviour of Command::output, which doesn't inherit stdin, so that
+                // captured commands waiting for input don't hang
+                None if capture_output && !self.options.capture_stdin => {
+                    command.stdin(Stdio::null());
+                }
+
+                None => {}
+            }
+
+            let is_last = index == last_index;
+            let is_redacted = redactor.is_some() && !capture_output;
+            if !is_last || capture_output || is_redacted {
+                command.stdout(Stdio::piped());
+            }
+
+            if is_last && (capture_output || is_redacted) {
+                command.stderr(Stdio::piped());
+            }
+
+            #[cfg(unix)]
+            if let Some(terminal) = &terminal {
+                use std::os::unix::process::CommandExt;
+
+                // Every command in the pipeline joins the process group of the first one
+                command.process_group(process_group_id.unwrap_or(0) as i32);
+
+                // Safety: the child only calls async-signal-safe functions before it executes the
+                // command
+                let is_foreground = terminal.is_foreground;
+                unsafe {
+                    command.pre_exec(move || {
+                        if is_foreground {
+                            take_terminal();
+                        }
+
+                        Ok(())
+                    });
+                }
+            }
+
+            #[cfg(unix)]
+            if let Some(nice) = self.options.nice {
+                use std::os::unix::process::CommandExt;
+
+                // Safety: setpriority is async-signal-safe, so it can be called in the child
+                // before it executes the command
+                unsafe {
+                    command.pre_exec(move || set_priority(nice));
+                }
+            }
+
+            let mut child = match command.spawn() {
+                Ok(child) => child,
+                Err(io_err) => {
+                    // The earlier commands would otherwise be left running with nothing to reap them
+                    kill_children(children);
+                    return Err(ExecutionError::IO(io_err));
+                }
+            };
+            if !is_last {
+                previous_stdout = child.stdout.take();
+            }
+
+            // The child takes the terminal too, but it may not have done so yet
+            #[cfg(unix)]
+            if let Some(terminal) = &terminal {
+                let group_id = *process_group_id.get_or_insert(child.id());
+                terminal.hand_over(group_id);
+            }
+
+            children.push(child);
+        }
+
+        let Some(timeout) = self.timeout else {
+            return wait_for_children(children, capture_output, redactor);
+        };
+
+        let process_ids: Vec<u32> = children.iter().map(|child| child.id()).collect();
+        let (finished_sender, finished_receiver) = mpsc::channel::<()>();
+        let (result, timed_out) = thread::scope(|scope| {
+            let watchdog = scope.spawn(move || {
+                // The sender is dropped once the children have finished, which ends the wait early
+                if let Err(mpsc::RecvTimeoutError::Timeout) =
+                    finished_receiver.recv_timeout(timeout)
+                {
+                    for process_id in process_ids {
+                        kill_process_group(process_id);
+                    }
+
+                    return true;
+                }
+
+                return false;
+            });
+
+            let result = wait_for_children(children, capture_output, redactor);
+            drop(finished_sender);
+            (result, watchdog.join().unwrap())
+        });
+
+        if timed_out {
+            return Err(ExecutionError::TimedOut(timeout));
+        }
+
+        return result;
+    }
+}
+
+/// Hands the terminal over to another process group, and takes it back once dropped.
+/// This only happens when the current process is in the foreground, otherwise the terminal is left
+/// alone.
+#[cfg(unix)]
+struct TerminalHandover {
+    is_foreground: bool,
+}
+
+#[cfg(unix)]
+impl TerminalHandover {
+    fn new() -> TerminalHandover {
+        // Safety: these only query the state of the terminal
+        let is_foreground = unsafe {
+            libc::isatty(libc::STDIN_FILENO) == 1
+                && libc::tcgetpgrp(libc::STDIN_FILENO) == libc::getpgrp()
+        };
+
+        return TerminalHandover { is_foreground };
+    }
+
+    /// Makes the provided process group the foreground process group of the terminal.
+    fn hand_over(&self, process_group_id: u32) {
+        if !self.is_foreground {
+            return;
+        }
+
+        // Safety: tcsetpgrp only changes which process group the terminal belongs to
+        unsafe {
+            libc::tcsetpgrp(libc::STDIN_FILENO, process_group_id as libc::pid_t);
+        }
+    }
+}
+
+#[cfg(unix)]
+impl Drop for TerminalHandover {
+    fn drop(&mut self) {
+        if self.is_foreground {
+            take_terminal();
+        }
+    }
 }
 
-fn get_command_for(execution_config: &ExecutionConfigVariant, variables: &VariableMap) -> Command {
+/// Makes the process group of the current process the foreground process group of the terminal.
+/// This is async-signal-safe, so it can be called in a child before it executes a command.
+#[cfg(unix)]
+fn take_terminal() {
+    // Safety: SIGTTOU would stop a background process which changes the foreground process group,
+    // so it's ignored until the terminal has been taken
+    unsafe {
+        let previous_handler = libc::signal(libc::SIGTTOU, libc::SIG_IGN);
+        libc::tcsetpgrp(libc::STDIN_FILENO, libc::getpgrp());
+        libc::signal(libc::SIGTTOU, previous_handler);
+    }
+}
+
+/// Sets the niceness of the current process.
+/// Values outside of the supported range are clamped, and lowering the niceness below its current
+/// value requires elevated privileges.
+#[cfg(unix)]
+fn set_priority(nice: i32) -> io::Result<()> {
+    // Safety: setpriority doesn't access any memory, it only changes the priority of the process
+    let result = unsafe { libc::setpriority(libc::PRIO_PROCESS, 0, nice) };
+    if result != 0 {
+        return Err(io::Error::last_os_error());
+    }
+
+    return Ok(());
+}
+
+/// Kills the provided children and waits for them to exit, ignoring any errors since the children
+/// may have already exited.
+fn kill_children(children: Vec<Child>) {
+    for mut child in children {
+        let _ = child.kill();
+        let _ = child.wait();
+    }
+}
+
+/// Waits for the provided children to exit.
+/// The output of the last child is returned, along with the first non-zero exit code.
+fn wait_for_children(
+    mut children: Vec<Child>,
+    capture_output: bool,
+    redactor: Option<&Redactor>,
+) -> ExecutionOutputResult {
+    let mut last_child = children.pop().unwrap();
+    let output = match redactor {
+        Some(redactor) if !capture_output => {
+            redact_output(&mut last_child, redactor)?;
+            let status = last_child
+                .wait()
+                .map_err(|io_err| ExecutionError::IO(io_err))?;
+
+            std::process::Output {
+                status,
+                stdout: vec![],
+                stderr: vec![],
+            }
+        }
+        _ => last_child
+            .wait_with_output()
+            .map_err(|io_err| ExecutionError::IO(io_err))?,
+    };
+
+    let mut result = Output::from_std_output(&output);
+    for mut child in children.into_iter().rev() {
+        let exit_status = child.wait().map_err(|io_err| ExecutionError::IO(io_err))?;
+        if !exit_status.success() {
+            result.status = ExitStatus::from_std_exitstatus(&exit_status);
+        }
+    }
+
+    Ok(result)
+}
+
+struct DryRunCommandExecutor {
+    sensitive_variable_names: Vec<String>,
+}
+
+impl DryRunCommandExecutor {
+    fn print(&self, execution_config: &ExecutionConfigVariant, variables: &VariableMap) {
+        let variables =
+            variables::mask_sensitive_variables(variables, &self.sensitive_variable_names);
+        println!("{}", render_command(execution_config, &variables));
+    }
+}
+
+impl CommandExecutor for DryRunCommandExecutor {
+    fn execute(
+        &self,
+        execution_config: &ExecutionConfigVariant,
+        variables: &VariableMap,
+    ) -> ExecutionResult {
+        self.print(execution_config, variables);
+        Ok(ExitStatus::Success)
+    }
+
+    fn get_output(
+        &self,
+        execution_config: &ExecutionConfigVariant,
+        variables: &VariableMap,
+    ) -> ExecutionOutputResult {
+        self.print(execution_config, variables);
+        Ok(Output {
+            status: ExitStatus::Success,
+            stdout: vec![],
+            stderr: vec![],
+        })
+    }
+}
+
+/// Returns the command text for the provided [`ExecutionConfigVariant`] with the provided
+/// variables substituted, as it would be executed.
+fn render_command(execution_config: &ExecutionConfigVariant, variables: &VariableMap) -> String {
+    return variables::substitute_variables(&execution_config.command_template(), variables);
+}
+
+/// Streams the stdout and stderr of the provided [`Child`] to the stdout and stderr of the current
+/// process, redacting them along the way.
+fn redact_output(child: &mut Child, redactor: &Redactor) -> Result<(), ExecutionError> {
+    let stdout = child.stdout.take().unwrap();
+    let stderr = child.stderr.take().unwrap();
+
+    // Both streams need to be read at the same time, otherwise the child could block writing to one
+    // while we're waiting on the other
+    let (stdout_result, stderr_result) = thread::scope(|scope| {
+        let stdout_handle = scope.spawn(|| redactor.redact_stream(stdout, io::stdout()));
+        let stderr_handle = scope.spawn(|| redactor.redact_stream(stderr, io::stderr()));
+        (stdout_handle.join().unwrap(), stderr_handle.join().unwrap())
+    });
+
+    stdout_result.map_err(|io_err| ExecutionError::IO(io_err))?;
+    stderr_result.map_err(|io_err| ExecutionError::IO(io_err))?;
+    return Ok(());
+}
+
+/// Kills the process group led by the provided process, so that any processes it started are
+/// killed too.
+#[cfg(unix)]
+fn kill_process_group(process_id: u32) {
+    // Safety: kill only sends a signal, the negative pid targets the whole process group
+    unsafe {
+        libc::kill(-(process_id as libc::pid_t), libc::SIGKILL);
+    }
+}
+
+/// Kills the provided process, along with any processes it started.
+#[cfg(not(unix))]
+fn kill_process_group(process_id: u32) {
+    let _ = Command::new("taskkill")
+        .args(["/F", "/T", "/PID", &process_id.to_string()])
+        .stdout(Stdio::null())
+        .stderr(Stdio::null())
+        .status();
+}
+
+/// The conventions a shell uses for executing commands and scripts.
+#[derive(PartialEq, Debug)]
+enum ShellKind {
+    /// Shells such as bash, sh, and zsh, which execute commands using `-c <command>`.
+    Posix,
+
+    /// PowerShell, which executes commands using `-Command <command>`.
+    PowerShell,
+
+    /// The Windows command prompt, which executes commands using `/C <command>`.
+    Cmd,
+}
+
+impl ShellKind {
+    /// Determines the [`ShellKind`] from the name or path of the provided shell.
+    fn from_shell(shell: &str) -> ShellKind {
+        let shell_name = Path::new(shell)
+            .file_stem()
+            .map(|file_stem| file_stem.to_string_lossy().to_lowercase())
+            .unwrap_or_default();
+
+        return match shell_name.as_str() {
+            "powershell" | "pwsh" => ShellKind::PowerShell,
+            "cmd" => ShellKind::Cmd,
+            _ => ShellKind::Posix,
+        };
+    }
+
+    /// The extension the shell expects script files to have.
+    fn script_extension(&self) -> &str {
+        return match self {
+            ShellKind::Posix => "",
+            ShellKind::PowerShell => ".ps1",
+            ShellKind::Cmd => ".cmd",
+        };
+    }
+}
+
+/// Creates a [`Command`] which executes the provided command text with the provided shell.
+/// The command text is passed to the shell unchanged.
+fn get_shell_command(shell: &str, bash_args: &Vec<String>, command_text: &str) -> Command {
+    let mut command = Command::new(shell);
+    match ShellKind::from_shell(shell) {
+        ShellKind::Posix => {
+            command.args(bash_args).arg(command_text);
+        }
+
+        ShellKind::PowerShell => {
+            command.args(POWERSHELL_ARGS).arg(command_text);
+        }
+
+        ShellKind::Cmd => {
+            command.args(CMD_ARGS);
+
+            // cmd doesn't follow the usual quoting rules, so the command can't be quoted like a
+            // regular argument
+            #[cfg(windows)]
+            {
+                use std::os::windows::process::CommandExt;
+                command.raw_arg(command_text);
+            }
+
+            #[cfg(not(windows))]
+            command.arg(command_text);
+        }
+    }
+
+    return command;
+}
+
+/// Creates a [`Command`] which executes the provided script file with the provided shell.
+fn get_shell_script_command(shell: &str, script_path: &Path) -> Command {
+    let mut command = Command::new(shell);
+    match ShellKind::from_shell(shell) {
+        ShellKind::Posix => {}
+        ShellKind::PowerShell => {
+            command.args(POWERSHELL_SCRIPT_ARGS);
+        }
+        ShellKind::Cmd => {
+            command.args(CMD_ARGS);
+        }
+    }
+
+    command.arg(script_path);
+    return command;
+}
+
+/// Creates the [`Command`]s for the provided [`ExecutionConfigVariant`].
+/// Pipelines will produce a [`Command`] for each stage, everything else produces a single [`Command`].
+fn get_commands_for(
+    execution_config: &ExecutionConfigVariant,
+    variables: &VariableMap,
+    shell: &str,
+    bash_args: &Vec<String>,
+) -> Result<(Vec<Command>, Option<NamedTempFile>), ExecutionError> {
     match execution_config {
         ExecutionConfigVariant::ShellCommand(shell_command_config) => match shell_command_config {
             ShellCommandConfigVariant::Bash(bash_command_config) => {
-                let mut binding = Command::new("bash");
-                binding
-                    .arg("-c")
-                    .envs(variables)
-                    .arg(bash_command_config.clone().command);
+                let mut script_file = None;
+                let mut binding = if bash_command_config.script_file {
+                    // Some shells won't execute scripts without the right extension
+                    let mut file = tempfile::Builder::new()
+                        .suffix(ShellKind::from_shell(shell).script_extension())
+                        .tempfile()
+                        .map_err(|io_err| ExecutionError::IO(io_err))?;
+                    file.write_all(bash_command_config.command.as_bytes())
+                        .map_err(|io_err| ExecutionError::IO(io_err))?;
+
+                    let binding = get_shell_script_command(shell, file.path());
+                    script_file = Some(file);
+                    binding
+                } else {
+                    get_shell_command(shell, bash_args, &bash_command_config.command)
+                };
+
+                binding.envs(variables);
 
                 if let Some(wd) = bash_command_config.clone().working_directory {
                     binding.current_dir(wd);
                 }
 
-                binding
+                Ok((vec![binding], script_file))
             }
         },
 
@@ -165,27 +781,183 @@ fn get_command_for(execution_config: &ExecutionConfigVariant, variables: &Variab
                 ),
             };
 
-            // Substitute any variables in the command invocation
-            let command = variables::substitute_variables(&command_template, variables);
+            let command = get_raw_command(&command_template, &working_directory, variables);
+            Ok((vec![command], None))
+        }
 
-            const DELIMITER: &str = " ";
-            let mut cmd = match command.split_once(DELIMITER) {
-                Some((program, args)) => {
-                    let argv = args.split(DELIMITER);
-                    let mut binding = Command::new(program);
-                    binding.args(argv).envs(variables);
-                    binding
+        ExecutionConfigVariant::Pipeline(pipeline_config) => {
+            let commands = pipeline_config
+                .stages
+                .iter()
+                .map(|stage| get_raw_command(stage, &pipeline_config.working_directory, variables))
+                .collect();
+            Ok((commands, None))
+        }
+    }
+}
+
+fn get_raw_command(
+    command_template: &String,
+    working_directory: &Option<String>,
+    variables: &VariableMap,
+) -> Command {
+    // Split the command before substituting any variables so that values containing spaces,
+    // quotes, or other special characters are always passed as a single argument.
+    // Arguments made up of braced expressions which expand to nothing (e.g. `${verbose:+-v}`) are
+    // dropped, rather than being passed as empty arguments.
+    const DELIMITER: &str = " ";
+    let mut argv = command_template.split(DELIMITER).filter_map(|arg| {
+        let value = variables::substitute_variables(arg, variables);
+        if value.is_empty() && arg.starts_with("${") && arg.ends_with('}') {
+            return None;
+        }
+
+        return Some(value);
+    });
+
+    let program = argv.next().unwrap_or_default();
+    let mut cmd = Command::new(program);
+    cmd.args(argv);
+
+    cmd.envs(variables);
+
+    if let Some(wd) = working_directory {
+        cmd.current_dir(wd);
+    }
+
+    return cmd;
+}
+
+/// Prepends the provided directories to the `PATH` in the provided [`VariableMap`].
+/// Relative directories are resolved from the current directory.
+/// If no `PATH` exists in the [`VariableMap`], then the `PATH` of the current process is used.
+pub fn prepend_path(
+    directories: &Vec<String>,
+    variables: &mut VariableMap,
+) -> Result<(), ExecutionError> {
+    if directories.is_empty() {
+        return Ok(());
+    }
+
+    let current_dir = env::current_dir().map_err(|io_err| ExecutionError::IO(io_err))?;
+    let mut paths: Vec<PathBuf> = directories
+        .iter()
+        .map(|directory| current_dir.join(directory))
+        .collect();
+
+    let existing_path = match variables.get(PATH_VARIABLE_NAME) {
+        Some(path) => Some(OsString::from(path)),
+        None => env::var_os(PATH_VARIABLE_NAME),
+    };
+
+    if let Some(existing_path) = existing_path {
+        paths.extend(env::split_paths(&existing_path));
+    }
+
+    let path = env::join_paths(paths).map_err(|err| ExecutionError::JoinPaths(err))?;
+    variables.insert(
+        PATH_VARIABLE_NAME.to_string(),
+        path.to_string_lossy().to_string(),
+    );
+
+    return Ok(());
+}
+
+/// Resolves the provided working directory, expanding a leading `~` to the home directory.
+/// Relative directories are resolved from the current directory.
+pub fn resolve_working_directory(directory: &str) -> Result<PathBuf, ExecutionError> {
+    let current_dir = env::current_dir().map_err(|io_err| ExecutionError::IO(io_err))?;
+    return Ok(current_dir.join(expand_home_directory(directory)));
+}
+
+/// Expands a leading `~` in the provided path to the home directory.
+pub fn expand_home_directory(path: &str) -> PathBuf {
+    let home_directory = env::var_os(HOME_VARIABLE_NAME).map(PathBuf::from);
+    return match (path.strip_prefix('~'), home_directory) {
+        (Some(""), Some(home_directory)) => home_directory,
+        (Some(rest), Some(home_directory)) if rest.starts_with(['/', '\\']) => {
+            home_directory.join(rest.trim_start_matches(['/', '\\']))
+        }
+        _ => PathBuf::from(path),
+    };
+}
+
+/// Parses a duration such as `30s`, `5m`, or `1h30m`.
+/// The supported units are `ms`, `s`, `m`, and `h`, and each number can have a fractional part.
+pub fn parse_duration(text: &str) -> Result<Duration, ExecutionError> {
+    let invalid = || ExecutionError::InvalidTimeout(text.to_string());
+
+    let mut remaining = text.trim();
+    if remaining.is_empty() {
+        return Err(invalid());
+    }
+
+    let mut seconds = 0.0;
+    while !remaining.is_empty() {
+        let number_length = remaining
+            .find(|c: char| !c.is_ascii_digit() && c != '.')
+            .ok_or_else(invalid)?;
+        let unit_length = remaining[number_length..]
+            .find(|c: char| c.is_ascii_digit() || c == '.')
+            .unwrap_or(remaining.len() - number_length);
+
+        let number: f64 = remaining[..number_length].parse().map_err(|_| invalid())?;
+        let unit_seconds = match &remaining[number_length..number_length + unit_length] {
+            "ms" => 0.001,
+            "s" => 1.0,
+            "m" => 60.0,
+            "h" => 3600.0,
+            _ => return Err(invalid()),
+        };
+
+        seconds += number * unit_seconds;
+        remaining = &remaining[number_length + unit_length..];
+    }
+
+    return Duration::try_from_secs_f64(seconds).map_err(|_| invalid());
+}
+
+/// Removes any ANSI escape sequences (colours, cursor movement, hyperlinks, etc.) from the provided
+/// text so that captured output can be used as a plain value.
+pub fn strip_ansi_escapes(text: &str) -> String {
+    let mut stripped = String::with_capacity(text.len());
+    let mut chars = text.chars().peekable();
+    while let Some(c) = chars.next() {
+        if c != '\x1b' {
+            stripped.push(c);
+            continue;
+        }
+
+        match chars.next() {
+            // Control Sequence Introducer: parameters and intermediates, followed by a final byte
+            Some('[') => {
+                while let Some(c) = chars.next() {
+                    if ('\x40'..='\x7e').contains(&c) {
+                        break;
+                    }
                 }
-                None => Command::new(command),
-            };
+            }
 
-            if let Some(wd) = working_directory {
-                cmd.current_dir(wd);
+            // Operating System Command: terminated by BEL or ESC \
+            Some(']') => {
+                while let Some(c) = chars.next() {
+                    if c == '\x07' {
+                        break;
+                    }
+
+                    if c == '\x1b' && chars.peek() == Some(&'\\') {
+                        chars.next();
+                        break;
+                    }
+                }
             }
 
-            return cmd;
+            // Anything else is a two character sequence
+            _ => {}
         }
     }
+
+    return stripped;
 }
 
 fn get_command_text(command: &Command) -> String {
@@ -204,20 +976,104 @@ fn get_command_text(command: &Command) -> String {
 pub enum ExecutionError {
     #[error(transparent)]
     IO(io::Error),
+
+    #[error("failed to prepend to PATH")]
+    JoinPaths(#[source] env::JoinPathsError),
+
+    #[error("pipelines must have at least one stage")]
+    EmptyPipeline,
+
+    #[error("invalid redact pattern")]
+    Redact(#[source] regex::Error),
+
+    #[error("invalid timeout \"{0}\", expected a duration such as 30s, 5m, or 1h30m")]
+    InvalidTimeout(String),
+
+    #[error("timed out after {0:?}")]
+    TimedOut(Duration),
+
+    #[error(
+        "the command references variables that aren't set: {} (use --allow-missing to ignore this)",
+        names.join(", ")
+    )]
+    MissingVariables { names: Vec<String> },
 }
 
 #[cfg(test)]
 mod tests {
     use super::*;
-    use crate::config::{BashCommandConfig, RawCommandConfig};
+    use crate::config::{
+        BashCommandConfig, ManyPlatforms, OneOrManyPlatforms, OnePlatform, PipelineConfig,
+        RawCommandConfig,
+    };
     use std::collections::HashMap;
+    use std::ffi::OsStr;
     use std::fs;
     use std::io::Write;
+    #[cfg(not(windows))]
+    use std::os::unix::fs::PermissionsExt;
     use std::path::Path;
     use tempfile::{NamedTempFile, TempDir};
 
     // TODO: Testing with stdin?
 
+    #[test]
+    fn get_raw_command_passes_variables_as_single_arguments() {
+        // Arrange
+        let mut variables = HashMap::new();
+        variables.insert("path".to_string(), "My Documents/notes.txt".to_string());
+        variables.insert(
+            "message".to_string(),
+            "it's \"$HOME\"; rm -rf /".to_string(),
+        );
+
+        // Act
+        let command = get_raw_command(&"grep -F $message $path".to_string(), &None, &variables);
+
+        // Assert
+        assert_eq!(command.get_program(), "grep");
+
+        let args: Vec<&OsStr> = command.get_args().collect();
+        assert_eq!(
+            args,
+            vec!["-F", "it's \"$HOME\"; rm -rf /", "My Documents/notes.txt"]
+        );
+    }
+
+    #[test]
+    fn get_raw_command_substitutes_variables_within_arguments() {
+        // Arrange
+        let mut variables = HashMap::new();
+        variables.insert("name".to_string(), "Dingus Bingus".to_string());
+
+        // Act
+        let command = get_raw_command(&"echo --name=$name!".to_string(), &None, &variables);
+
+        // Assert
+        let args: Vec<&OsStr> = command.get_args().collect();
+        assert_eq!(args, vec!["--name=Dingus Bingus!"]);
+    }
+
+    #[test]
+    fn get_raw_command_drops_empty_conditional_arguments() {
+        // Arrange
+        let mut variables = HashMap::new();
+        variables.insert("verbose".to_string(), "".to_string());
+        variables.insert("release".to_string(), "true".to_string());
+        variables.insert("message".to_string(), "".to_string());
+
+        // Act
+        let command = get_raw_command(
+            &"cargo build ${verbose:+--verbose} ${release:+--release} $message".to_string(),
+            &None,
+            &variables,
+        );
+
+        // Assert
+        let args: Vec<&OsStr> = command.get_args().collect();
+        assert_eq!(args, vec!["build", "--release", ""]);
+    }
+
     #[test]
     #[cfg(not(windows))]
     fn bash_command_execute_executes_command() {
@@ -229,6 +1085,7 @@ mod tests {
             ShellCommandConfigVariant::Bash(BashCommandConfig {
                 working_directory: None,
                 command: format!("echo \"Hello, World!\" > {temp_file_path}"),
+                script_file: false,
             }),
         );
         let command_executor = create_command_executor(&DingusOptions::default());
@@ -258,6 +1115,7 @@ mod tests {
             ShellCommandConfigVariant::Bash(BashCommandConfig {
                 working_directory: None,
                 command: format!("echo \"Hello, ${variable_name}!\" > {temp_file_path}"),
+                script_file: false,
             }),
         );
         let command_executor = create_command_executor(&DingusOptions::default());
@@ -279,6 +1137,7 @@ mod tests {
             ShellCommandConfigVariant::Bash(BashCommandConfig {
                 working_directory: None,
                 command: "exit 42".to_string(),
+                script_file: false,
             }),
         );
         let command_executor = create_command_executor(&DingusOptions::default());
@@ -305,6 +1164,7 @@ mod tests {
             ShellCommandConfigVariant::Bash(BashCommandConfig {
                 working_directory: None,
                 command: format!("echo \"Hello, ${variable_name}!\""),
+                script_file: false,
             }),
         );
         let command_executor = create_command_executor(&DingusOptions::default());
@@ -330,6 +1190,7 @@ mod tests {
             ShellCommandConfigVariant::Bash(BashCommandConfig {
                 working_directory: None,
                 command: "echo \"Hello, World!\"".to_string(),
+                script_file: false,
             }),
         );
         let command_executor = create_command_executor(&DingusOptions::default());
@@ -355,54 +1216,495 @@ mod tests {
             ShellCommandConfigVariant::Bash(BashCommandConfig {
                 working_directory: None,
                 command: ">&2 echo \"Error message\"".to_string(),
+                script_file: false,
+            }),
+        );
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let result = command_executor.get_output(&bash_exec_config, &HashMap::new());
+        assert!(!result.is_err());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(output.status, ExitStatus::Success);
+        assert!(output.stdout.is_empty());
+
+        let output_value = String::from_utf8(output.stderr).unwrap();
+        assert_eq!(output_value, "Error message\n");
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn bash_command_get_output_does_not_wait_for_stdin() {
+        // Arrange
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: None,
+                command: "cat".to_string(),
+                script_file: false,
+            }),
+        );
+
+        // The timeout ensures a regression fails the test rather than hanging it
+        let command_executor = create_action_command_executor(
+            &DingusOptions::default(),
+            Some(Duration::from_secs(5)),
+            None,
+            None,
+        );
+
+        // Act
+        let result = command_executor.get_output(&bash_exec_config, &HashMap::new());
+        assert!(!result.is_err());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(output.status, ExitStatus::Success);
+        assert!(output.stdout.is_empty());
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn bash_command_get_output_returns_exit_code() {
+        // Arrange
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: None,
+                command: "exit 42".to_string(),
+                script_file: false,
             }),
         );
         let command_executor = create_command_executor(&DingusOptions::default());
 
         // Act
-        let result = command_executor.get_output(&bash_exec_config, &HashMap::new());
-        assert!(!result.is_err());
+        let result = command_executor.get_output(&bash_exec_config, &HashMap::new());
+        assert!(!result.is_err());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(output.status, ExitStatus::Fail(42));
+        assert!(output.stdout.is_empty());
+        assert!(output.stderr.is_empty());
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn bash_command_honours_workdir() {
+        // Arrange
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: Some("./src".to_string()),
+                command: "pwd".to_string(),
+                script_file: false,
+            }),
+        );
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let result = command_executor.get_output(&bash_exec_config, &HashMap::new());
+        assert!(!result.is_err());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(output.status, ExitStatus::Success);
+        assert!(output.stderr.is_empty());
+
+        let output_value = String::from_utf8(output.stdout).unwrap();
+        assert!(output_value.ends_with("/src\n"));
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn action_command_executor_resolves_workdir_from_working_directory() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        fs::create_dir(temp_dir.path().join("src")).unwrap();
+
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: Some("./src".to_string()),
+                command: "pwd".to_string(),
+                script_file: false,
+            }),
+        );
+        let command_executor = create_action_command_executor(
+            &DingusOptions::default(),
+            None,
+            None,
+            Some(temp_dir.path().to_path_buf()),
+        );
+
+        // Act
+        let result = command_executor.get_output(&bash_exec_config, &HashMap::new());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(output.status, ExitStatus::Success);
+
+        let directory_name = temp_dir.path().file_name().unwrap().to_str().unwrap();
+        let output_value = String::from_utf8(output.stdout).unwrap();
+        assert!(output_value.ends_with(&format!("{directory_name}/src\n")));
+    }
+
+    fn bash_args_configs() -> Vec<BashArgsConfig> {
+        return vec![
+            BashArgsConfig {
+                platform: Some(OneOrManyPlatforms::One(OnePlatform {
+                    platform: Platform::Windows,
+                })),
+                args: vec!["--login".to_string(), "-c".to_string()],
+            },
+            BashArgsConfig {
+                platform: Some(OneOrManyPlatforms::Many(ManyPlatforms {
+                    platforms: vec![Platform::Linux, Platform::MacOS],
+                })),
+                args: vec!["-e".to_string(), "-c".to_string()],
+            },
+        ];
+    }
+
+    #[test]
+    fn select_bash_args_selects_args_for_platform() {
+        // Act
+        let windows_args = select_bash_args(&bash_args_configs(), Platform::Windows);
+        let linux_args = select_bash_args(&bash_args_configs(), Platform::Linux);
+        let mac_args = select_bash_args(&bash_args_configs(), Platform::MacOS);
+
+        // Assert
+        assert_eq!(windows_args, vec!["--login", "-c"]);
+        assert_eq!(linux_args, vec!["-e", "-c"]);
+        assert_eq!(mac_args, vec!["-e", "-c"]);
+    }
+
+    #[test]
+    fn select_bash_args_uses_args_without_platform() {
+        // Arrange
+        let mut bash_args_configs = bash_args_configs();
+        bash_args_configs.remove(1);
+        bash_args_configs.push(BashArgsConfig {
+            platform: None,
+            args: vec!["-o".to_string(), "pipefail".to_string(), "-c".to_string()],
+        });
+
+        // Act
+        let linux_args = select_bash_args(&bash_args_configs, Platform::Linux);
+
+        // Assert
+        assert_eq!(linux_args, vec!["-o", "pipefail", "-c"]);
+    }
+
+    #[test]
+    fn select_bash_args_defaults() {
+        // Act
+        let linux_args = select_bash_args(&vec![], Platform::Linux);
+
+        // Assert
+        assert_eq!(linux_args, vec!["-c"]);
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn bash_command_uses_bash_args_for_platform() {
+        // Arrange
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: None,
+                command: "false; echo Hello, World!".to_string(),
+                script_file: false,
+            }),
+        );
+
+        let mut options = DingusOptions::default();
+        options.bash_args = bash_args_configs();
+
+        // Act
+        let linux_output = create_command_executor_for(&options, Platform::Linux, None, None, None)
+            .get_output(&bash_exec_config, &HashMap::new())
+            .unwrap();
+        let default_output = create_command_executor_for(
+            &DingusOptions::default(),
+            Platform::Linux,
+            None,
+            None,
+            None,
+        )
+        .get_output(&bash_exec_config, &HashMap::new())
+        .unwrap();
+
+        // Assert
+        // Errexit (-e) should stop the command at the first failure on Linux
+        assert_eq!(linux_output.status, ExitStatus::Fail(1));
+        assert!(linux_output.stdout.is_empty());
+
+        assert_eq!(default_output.status, ExitStatus::Success);
+        assert_eq!(
+            String::from_utf8(default_output.stdout).unwrap(),
+            "Hello, World!\n"
+        );
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn bash_command_uses_configured_shell() {
+        // Arrange
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: None,
+                command: "echo $0".to_string(),
+                script_file: false,
+            }),
+        );
+
+        let mut options = DingusOptions::default();
+        options.shell = "sh".to_string();
+
+        // Act
+        let output = create_command_executor_for(&options, Platform::Linux, None, None, None)
+            .get_output(&bash_exec_config, &HashMap::new())
+            .unwrap();
+
+        // Assert
+        assert_eq!(output.status, ExitStatus::Success);
+        assert_eq!(String::from_utf8(output.stdout).unwrap(), "sh\n");
+    }
+
+    #[cfg(not(windows))]
+    #[test]
+    fn bash_command_is_killed_after_timeout() {
+        // Arrange
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: None,
+                command: "sleep 10; echo done".to_string(),
+                script_file: false,
+            }),
+        );
+        let command_executor = create_command_executor_for(
+            &DingusOptions::default(),
+            Platform::Linux,
+            Some(Duration::from_millis(200)),
+            None,
+            None,
+        );
+
+        // Act
+        let start = std::time::Instant::now();
+        let result = command_executor.get_output(&bash_exec_config, &HashMap::new());
+
+        // Assert
+        assert!(matches!(result, Err(ExecutionError::TimedOut(_))));
+        assert!(start.elapsed() < Duration::from_secs(5));
+    }
+
+    #[test]
+    fn bash_command_finishes_within_timeout() {
+        // Arrange
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: None,
+                command: "echo done".to_string(),
+                script_file: false,
+            }),
+        );
+        let command_executor = create_command_executor_for(
+            &DingusOptions::default(),
+            Platform::Linux,
+            Some(Duration::from_secs(10)),
+            None,
+            None,
+        );
+
+        // Act
+        let output = command_executor
+            .get_output(&bash_exec_config, &HashMap::new())
+            .unwrap();
+
+        // Assert
+        assert_eq!(output.status, ExitStatus::Success);
+        assert_eq!(String::from_utf8(output.stdout).unwrap(), "done\n");
+    }
+
+    #[test]
+    fn render_command_substitutes_variables() {
+        // Arrange
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: None,
+                command: "rm -rf \"$directory\"".to_string(),
+                script_file: false,
+            }),
+        );
+        let mut variables = HashMap::new();
+        variables.insert("directory".to_string(), "./dist".to_string());
+
+        // Act
+        let command = render_command(&bash_exec_config, &variables);
+
+        // Assert
+        assert_eq!(command, "rm -rf \"./dist\"");
+    }
+
+    #[test]
+    fn parse_duration_parses_durations() {
+        // Act
+        let seconds = parse_duration("30s").unwrap();
+        let combined = parse_duration("1h30m").unwrap();
+        let fractional = parse_duration("1.5m").unwrap();
+        let milliseconds = parse_duration("250ms").unwrap();
+
+        // Assert
+        assert_eq!(seconds, Duration::from_secs(30));
+        assert_eq!(combined, Duration::from_secs(5400));
+        assert_eq!(fractional, Duration::from_secs(90));
+        assert_eq!(milliseconds, Duration::from_millis(250));
+    }
+
+    #[test]
+    fn parse_duration_fails_for_invalid_durations() {
+        // Act
+        let empty = parse_duration("");
+        let no_unit = parse_duration("30");
+        let no_number = parse_duration("s");
+        let unknown_unit = parse_duration("3d");
+
+        // Assert
+        assert!(matches!(empty, Err(ExecutionError::InvalidTimeout(_))));
+        assert!(matches!(no_unit, Err(ExecutionError::InvalidTimeout(_))));
+        assert!(matches!(no_number, Err(ExecutionError::InvalidTimeout(_))));
+        assert!(matches!(
+            unknown_unit,
+            Err(ExecutionError::InvalidTimeout(_))
+        ));
+    }
+
+    #[test]
+    fn shell_kind_from_shell() {
+        // Act
+        let bash = ShellKind::from_shell("/usr/local/bin/bash");
+        let powershell = ShellKind::from_shell("powershell.exe");
+        let pwsh = ShellKind::from_shell("pwsh");
+        let cmd = ShellKind::from_shell("CMD.EXE");
+
+        // Assert
+        assert_eq!(bash, ShellKind::Posix);
+        assert_eq!(powershell, ShellKind::PowerShell);
+        assert_eq!(pwsh, ShellKind::PowerShell);
+        assert_eq!(cmd, ShellKind::Cmd);
+    }
+
+    #[test]
+    fn get_shell_command_uses_shell_conventions() {
+        // Arrange
+        let bash_args = vec!["-e".to_string(), "-c".to_string()];
+        let command_text = "echo \"Hello, $name!\"";
+
+        // Act
+        let bash_command = get_shell_command("bash", &bash_args, command_text);
+        let powershell_command = get_shell_command("powershell", &bash_args, command_text);
+        let cmd_command = get_shell_command("cmd", &bash_args, command_text);
+
+        // Assert
+        let args = |command: &Command| -> Vec<String> {
+            command
+                .get_args()
+                .map(|arg| arg.to_string_lossy().to_string())
+                .collect()
+        };
+
+        assert_eq!(bash_command.get_program(), "bash");
+        assert_eq!(args(&bash_command), vec!["-e", "-c", command_text]);
+
+        assert_eq!(powershell_command.get_program(), "powershell");
+        assert_eq!(
+            args(&powershell_command),
+            vec!["-NoProfile", "-Command", command_text]
+        );
+
+        assert_eq!(cmd_command.get_program(), "cmd");
+        assert_eq!(args(&cmd_command), vec!["/C", command_text]);
+    }
+
+    #[test]
+    fn get_shell_script_command_uses_shell_conventions() {
+        // Arrange
+        let script_path = Path::new("script");
+
+        // Act
+        let bash_command = get_shell_script_command("bash", script_path);
+        let powershell_command = get_shell_script_command("pwsh", script_path);
+        let cmd_command = get_shell_script_command("cmd", script_path);
 
         // Assert
-        let output = result.unwrap();
-        assert_eq!(output.status, ExitStatus::Success);
-        assert!(output.stdout.is_empty());
-
-        let output_value = String::from_utf8(output.stderr).unwrap();
-        assert_eq!(output_value, "Error message\n");
+        let args = |command: &Command| -> Vec<String> {
+            command
+                .get_args()
+                .map(|arg| arg.to_string_lossy().to_string())
+                .collect()
+        };
+
+        assert_eq!(args(&bash_command), vec!["script"]);
+        assert_eq!(
+            args(&powershell_command),
+            vec![
+                "-NoProfile",
+                "-ExecutionPolicy",
+                "Bypass",
+                "-File",
+                "script"
+            ]
+        );
+        assert_eq!(args(&cmd_command), vec!["/C", "script"]);
     }
 
     #[test]
     #[cfg(not(windows))]
-    fn bash_command_get_output_returns_exit_code() {
+    fn bash_command_executes_large_script_from_file() {
         // Arrange
+        let variable_name = "name";
+        let variable_value = "Dingus";
+        let mut variables = HashMap::new();
+        variables.insert(variable_name.to_string(), variable_value.to_string());
+
+        // Build a script that's too large to comfortably fit in an argument, with some awkward
+        // quoting thrown in for good measure.
+        let mut script = String::from("total=0\n");
+        for i in 0..20000 {
+            script.push_str(&format!("total=$((total + {i})) # it's \"line\" {i}\n"));
+        }
+        script.push_str("echo \"Hello, ${name}! The total is $total\"\n");
+
         let bash_exec_config = ExecutionConfigVariant::ShellCommand(
             ShellCommandConfigVariant::Bash(BashCommandConfig {
                 working_directory: None,
-                command: "exit 42".to_string(),
+                command: script,
+                script_file: true,
             }),
         );
         let command_executor = create_command_executor(&DingusOptions::default());
 
         // Act
-        let result = command_executor.get_output(&bash_exec_config, &HashMap::new());
+        let result = command_executor.get_output(&bash_exec_config, &variables);
         assert!(!result.is_err());
 
         // Assert
         let output = result.unwrap();
-        assert_eq!(output.status, ExitStatus::Fail(42));
-        assert!(output.stdout.is_empty());
+        assert_eq!(output.status, ExitStatus::Success);
         assert!(output.stderr.is_empty());
+
+        let output_value = String::from_utf8(output.stdout).unwrap();
+        assert_eq!(output_value, "Hello, Dingus! The total is 199990000\n");
     }
 
     #[test]
     #[cfg(not(windows))]
-    fn bash_command_honours_workdir() {
+    fn bash_command_script_file_is_removed_after_execution() {
         // Arrange
         let bash_exec_config = ExecutionConfigVariant::ShellCommand(
             ShellCommandConfigVariant::Bash(BashCommandConfig {
-                working_directory: Some("./src".to_string()),
-                command: "pwd".to_string(),
+                working_directory: None,
+                command: "echo \"$0\"".to_string(),
+                script_file: true,
             }),
         );
         let command_executor = create_command_executor(&DingusOptions::default());
@@ -414,10 +1716,9 @@ mod tests {
         // Assert
         let output = result.unwrap();
         assert_eq!(output.status, ExitStatus::Success);
-        assert!(output.stderr.is_empty());
 
-        let output_value = String::from_utf8(output.stdout).unwrap();
-        assert!(output_value.ends_with("/src\n"));
+        let script_path = String::from_utf8(output.stdout).unwrap();
+        assert!(!Path::new(script_path.trim_end()).exists());
     }
 
     #[test]
@@ -491,6 +1792,86 @@ mod tests {
         assert!(matches!(exit_status, ExitStatus::Fail(101)));
     }
 
+    #[test]
+    fn raw_command_execute_fails_for_missing_variables() {
+        // Arrange
+        let mut variables = HashMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        let exec_config = ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "echo $name $nmae ${greeting:-Hello}".to_string(),
+        ));
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let result = command_executor.execute(&exec_config, &variables);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(ExecutionError::MissingVariables { names }) if names == vec!["nmae"]
+        ));
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn raw_command_execute_allows_missing_variables() {
+        // Arrange
+        let exec_config = ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "echo $nmae".to_string(),
+        ));
+        let options = DingusOptions {
+            allow_missing_variables: true,
+            ..Default::default()
+        };
+        let command_executor = create_command_executor(&options);
+
+        // Act
+        let result = command_executor.get_output(&exec_config, &Default::default());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(String::from_utf8(output.stdout).unwrap(), "$nmae\n");
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn raw_command_get_output_passes_escaped_variables_as_is() {
+        // Arrange
+        let exec_config = ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "echo \\$nmae".to_string(),
+        ));
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let result = command_executor.get_output(&exec_config, &Default::default());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(String::from_utf8(output.stdout).unwrap(), "$nmae\n");
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn raw_command_get_output_applies_nice() {
+        // Arrange
+        let exec_config = ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "nice".to_string(),
+        ));
+        let options = DingusOptions {
+            nice: Some(19),
+            ..Default::default()
+        };
+        let command_executor = create_command_executor(&options);
+
+        // Act
+        let result = command_executor.get_output(&exec_config, &Default::default());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(String::from_utf8(output.stdout).unwrap(), "19\n");
+    }
+
     #[test]
     fn raw_command_get_output_substitutes_variables_in_invocation() {
         // Arrange
@@ -639,6 +2020,226 @@ mod tests {
         assert!(result.is_err());
     }
 
+    #[test]
+    #[cfg(not(windows))]
+    fn prepend_path_makes_tools_available_to_commands() {
+        // Arrange
+        let temp_dir = create_temp_dir();
+        let tool_path = temp_dir.path().join("dingus-test-tool");
+        fs::write(&tool_path, "#!/bin/sh\necho \"Hello from the tool!\"\n").unwrap();
+        fs::set_permissions(&tool_path, fs::Permissions::from_mode(0o755)).unwrap();
+
+        let mut variables = HashMap::new();
+        prepend_path(&vec![get_path(temp_dir.path())], &mut variables).unwrap();
+
+        let raw_exec_config = ExecutionConfigVariant::RawCommand(
+            RawCommandConfigVariant::Shorthand("dingus-test-tool".to_string()),
+        );
+        let bash_exec_config = ExecutionConfigVariant::ShellCommand(
+            ShellCommandConfigVariant::Bash(BashCommandConfig {
+                working_directory: None,
+                command: "dingus-test-tool".to_string(),
+                script_file: false,
+            }),
+        );
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let raw_output = command_executor
+            .get_output(&raw_exec_config, &variables)
+            .unwrap();
+        let bash_output = command_executor
+            .get_output(&bash_exec_config, &variables)
+            .unwrap();
+
+        // Assert
+        assert_eq!(raw_output.status, ExitStatus::Success);
+        assert_eq!(
+            String::from_utf8(raw_output.stdout).unwrap(),
+            "Hello from the tool!\n"
+        );
+
+        assert_eq!(bash_output.status, ExitStatus::Success);
+        assert_eq!(
+            String::from_utf8(bash_output.stdout).unwrap(),
+            "Hello from the tool!\n"
+        );
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn pipeline_get_output_pipes_stages() {
+        // Arrange
+        let temp_file = create_temp_file("Charlie\nAlice\nBob\n");
+        let temp_file_path = temp_file.path().to_str().unwrap().to_string();
+
+        let exec_config = ExecutionConfigVariant::Pipeline(PipelineConfig {
+            working_directory: None,
+            stages: vec![format!("cat {temp_file_path}"), "sort".to_string()],
+        });
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let result = command_executor.get_output(&exec_config, &HashMap::new());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(output.status, ExitStatus::Success);
+
+        let output_value = String::from_utf8(output.stdout).unwrap();
+        assert_eq!(output_value, "Alice\nBob\nCharlie\n");
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn pipeline_get_output_substitutes_variables_in_stages() {
+        // Arrange
+        let temp_file = create_temp_file("Hello, World!\nGoodbye, World!\n");
+        let temp_file_path = temp_file.path().to_str().unwrap().to_string();
+
+        let exec_config = ExecutionConfigVariant::Pipeline(PipelineConfig {
+            working_directory: None,
+            stages: vec!["cat $file".to_string(), "grep $greeting".to_string()],
+        });
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        let mut variables = HashMap::new();
+        variables.insert("file".to_string(), temp_file_path);
+        variables.insert("greeting".to_string(), "Goodbye".to_string());
+
+        // Act
+        let result = command_executor.get_output(&exec_config, &variables);
+
+        // Assert
+        let output_value = String::from_utf8(result.unwrap().stdout).unwrap();
+        assert_eq!(output_value, "Goodbye, World!\n");
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn pipeline_returns_exit_code_of_failed_stage() {
+        // Arrange
+        let exec_config = ExecutionConfigVariant::Pipeline(PipelineConfig {
+            working_directory: None,
+            stages: vec!["cat does_not_exist.txt".to_string(), "sort".to_string()],
+        });
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let result = command_executor.get_output(&exec_config, &HashMap::new());
+
+        // Assert
+        let output = result.unwrap();
+        assert_eq!(output.status, ExitStatus::Fail(1));
+        assert!(output.stdout.is_empty());
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn pipeline_fails_when_a_later_stage_cannot_be_started() {
+        // Arrange
+        let exec_config = ExecutionConfigVariant::Pipeline(PipelineConfig {
+            working_directory: None,
+            stages: vec!["sleep 30".to_string(), "does_not_exist".to_string()],
+        });
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let result = command_executor.execute(&exec_config, &HashMap::new());
+
+        // Assert
+        assert!(matches!(result, Err(ExecutionError::IO(_))));
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn kill_children_kills_and_reaps_children() {
+        // Arrange
+        let child = Command::new("sleep").arg("30").spawn().unwrap();
+        let process_id = child.id() as libc::pid_t;
+
+        // Act
+        kill_children(vec![child]);
+
+        // Assert
+        // Once a child has been reaped, its process ID no longer refers to a process
+        assert_eq!(unsafe { libc::kill(process_id, 0) }, -1);
+    }
+
+    #[test]
+    fn pipeline_without_stages_fails() {
+        // Arrange
+        let exec_config = ExecutionConfigVariant::Pipeline(PipelineConfig {
+            working_directory: None,
+            stages: vec![],
+        });
+        let command_executor = create_command_executor(&DingusOptions::default());
+
+        // Act
+        let result = command_executor.execute(&exec_config, &HashMap::new());
+
+        // Assert
+        assert!(matches!(result, Err(ExecutionError::EmptyPipeline)));
+    }
+
+    #[test]
+    fn prepend_path_resolves_relative_directories() {
+        // Arrange
+        let mut variables = HashMap::new();
+        variables.insert("PATH".to_string(), "/usr/bin".to_string());
+
+        // Act
+        prepend_path(&vec!["./bin".to_string()], &mut variables).unwrap();
+
+        // Assert
+        let paths: Vec<PathBuf> = env::split_paths(variables.get("PATH").unwrap()).collect();
+        assert_eq!(
+            paths,
+            vec![
+                env::current_dir().unwrap().join("./bin"),
+                PathBuf::from("/usr/bin")
+            ]
+        );
+    }
+
+    #[test]
+    fn resolve_working_directory_expands_home_directory() {
+        // Arrange
+        let home_directory = PathBuf::from(env::var_os(HOME_VARIABLE_NAME).unwrap());
+
+        // Act
+        let home = resolve_working_directory("~").unwrap();
+        let projects = resolve_working_directory("~/projects").unwrap();
+
+        // Assert
+        assert_eq!(home, home_directory);
+        assert_eq!(projects, home_directory.join("projects"));
+    }
+
+    #[test]
+    fn resolve_working_directory_resolves_relative_directories() {
+        // Act
+        let directory = resolve_working_directory("./build").unwrap();
+        let not_home = resolve_working_directory("~build").unwrap();
+
+        // Assert
+        let current_dir = env::current_dir().unwrap();
+        assert_eq!(directory, current_dir.join("./build"));
+        assert_eq!(not_home, current_dir.join("~build"));
+    }
+
+    #[test]
+    fn prepend_path_does_nothing_without_directories() {
+        // Arrange
+        let mut variables = HashMap::new();
+
+        // Act
+        prepend_path(&vec![], &mut variables).unwrap();
+
+        // Assert
+        assert!(variables.is_empty());
+    }
+
     fn create_temp_dir() -> TempDir {
         let temp_dir = TempDir::new().unwrap();
         return temp_dir;
@@ -658,4 +2259,77 @@ mod tests {
     fn get_path(path: &Path) -> String {
         return path.to_str().unwrap().to_string();
     }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn execute_with_redaction_returns_exit_code() {
+        // Arrange
+        let exec_config = ExecutionConfigVariant::ShellCommand(ShellCommandConfigVariant::Bash(
+            BashCommandConfig {
+                working_directory: None,
+                command: "echo ghp_abc123; echo ghp_def456 >&2; exit 3".to_string(),
+                script_file: false,
+            },
+        ));
+
+        let mut options = DingusOptions::default();
+        options.redact = vec!["ghp_[A-Za-z0-9]+".to_string()];
+        let redactor = create_redactor(&options).unwrap();
+        let command_executor = create_action_command_executor(&options, None, redactor, None);
+
+        // Act
+        let result = command_executor.execute(&exec_config, &HashMap::new());
+
+        // Assert
+        assert_eq!(result.unwrap(), ExitStatus::Fail(3));
+    }
+
+    #[test]
+    fn create_redactor_fails_for_invalid_pattern() {
+        // Arrange
+        let mut options = DingusOptions::default();
+        options.redact = vec!["ghp_[".to_string()];
+
+        // Act
+        let result = create_redactor(&options);
+
+        // Assert
+        assert!(matches!(result, Err(ExecutionError::Redact(_))));
+    }
+
+    #[test]
+    fn strip_ansi_escapes_removes_colours() {
+        // Arrange
+        let text = "\x1b[1;32mDingus\x1b[0m is \x1b[38;5;208mgreat\x1b[m";
+
+        // Act
+        let stripped = strip_ansi_escapes(text);
+
+        // Assert
+        assert_eq!(stripped, "Dingus is great");
+    }
+
+    #[test]
+    fn strip_ansi_escapes_removes_hyperlinks() {
+        // Arrange
+        let text = "\x1b]8;;https://example.com\x1b\\Dingus\x1b]8;;\x07";
+
+        // Act
+        let stripped = strip_ansi_escapes(text);
+
+        // Assert
+        assert_eq!(stripped, "Dingus");
+    }
+
+    #[test]
+    fn strip_ansi_escapes_preserves_plain_text() {
+        // Arrange
+        let text = "Hello, World!\nGoodbye, World!";
+
+        // Act
+        let stripped = strip_ansi_escapes(text);
+
+        // Assert
+        assert_eq!(stripped, text);
+    }
 }
@@ -0,0 +1,368 @@
+use crate::config::{Config, ConfigFormat};
+use serde::de::{MapAccess, SeqAccess, Visitor};
+use serde::ser::{SerializeMap, SerializeSeq};
+use serde::{Deserialize, Deserializer, Serialize, Serializer};
+use std::cmp::Ordering;
+use std::fmt;
+use thiserror::Error;
+
+/// Keys whose mappings must retain their order, since variables without dependencies on each other
+/// are resolved (and prompted for) in the order they're declared, and steps are planned (and their
+/// failures reported) in the order they're declared.
+const ORDERED_KEYS: [&str; 3] = ["variables", "vars", "steps"];
+
+/// Formats the provided config text into a canonical form.
+/// Keys are sorted alphabetically, except for variables and steps which retain their original order.
+/// Note that comments are not retained, see [`has_comments`].
+pub fn format_config(text: &String) -> Result<String, FormatError> {
+    // Make sure the config is actually valid before we go and re-write it
+    serde_yaml::from_str::<Config>(text.as_str()).map_err(|err| FormatError::Invalid(err))?;
+
+    let mut node: Node =
+        serde_yaml::from_str(text.as_str()).map_err(|err| FormatError::Invalid(err))?;
+    node.sort(false);
+
+    return serde_yaml::to_string(&node).map_err(|err| FormatError::Serialize(err));
+}
+
+/// Returns `true` if the provided config text looks like it contains comments, which would be lost
+/// by formatting it.
+/// This errs on the side of caution, so a `#` within a string may also be treated as a comment.
+pub fn has_comments(text: &String) -> bool {
+    return text
+        .lines()
+        .any(|line| line.trim_start().starts_with('#') || line.contains(" #"));
+}
+
+/// A YAML node which retains the order of mapping keys, so that they can be sorted.
+#[derive(Debug, Clone, PartialEq)]
+enum Node {
+    Null,
+    Bool(bool),
+    Signed(i64),
+    Unsigned(u64),
+    Float(f64),
+    String(String),
+    Sequence(Vec<Node>),
+    Mapping(Vec<(Node, Node)>),
+}
+
+impl Node {
+    fn sort(&mut self, retain_order: bool) {
+        match self {
+            Node::Sequence(nodes) => {
+                for node in nodes {
+                    node.sort(false);
+                }
+            }
+
+            Node::Mapping(entries) => {
+                if !retain_order {
+                    entries.sort_by(|(a, _), (b, _)| a.compare_keys(b));
+                }
+
+                for (key, value) in entries {
+                    let is_ordered_key = match key {
+                        Node::String(key) => ORDERED_KEYS.contains(&key.as_str()),
+                        _ => false,
+                    };
+
+                    // Only the variables and steps themselves need to retain their order,
+                    // anything within them can be sorted
+                    if is_ordered_key && !retain_order {
+                        value.sort(true);
+                    } else {
+                        value.sort(false);
+                    }
+                }
+            }
+
+            _ => {}
+        }
+    }
+
+    fn compare_keys(&self, other: &Node) -> Ordering {
+        match (self, other) {
+            (Node::String(a), Node::String(b)) => a.cmp(b),
+            _ => Ordering::Equal,
+        }
+    }
+}
+
+impl Serialize for Node {
+    fn serialize<S>(&self, serializer: S) -> Result<S::Ok, S::Error>
+    where
+        S: Serializer,
+    {
+        match self {
+            Node::Null => serializer.serialize_unit(),
+            Node::Bool(value) => serializer.serialize_bool(*value),
+            Node::Signed(value) => serializer.serialize_i64(*value),
+            Node::Unsigned(value) => serializer.serialize_u64(*value),
+            Node::Float(value) => serializer.serialize_f64(*value),
+            Node::String(value) => serializer.serialize_str(value),
+            Node::Sequence(nodes) => {
+                let mut seq = serializer.serialize_seq(Some(nodes.len()))?;
+                for node in nodes {
+                    seq.serialize_element(node)?;
+                }
+                seq.end()
+            }
+            Node::Mapping(entries) => {
+                let mut map = serializer.serialize_map(Some(entries.len()))?;
+                for (key, value) in entries {
+                    map.serialize_entry(key, value)?;
+                }
+                map.end()
+            }
+        }
+    }
+}
+
+impl<'de> Deserialize<'de> for Node {
+    fn deserialize<D>(deserializer: D) -> Result<Self, D::Error>
+    where
+        D: Deserializer<'de>,
+    {
+        deserializer.deserialize_any(NodeVisitor)
+    }
+}
+
+struct NodeVisitor;
+
+impl<'de> Visitor<'de> for NodeVisitor {
+    type Value = Node;
+
+    fn expecting(&self, formatter: &mut fmt::Formatter) -> fmt::Result {
+        formatter.write_str("any YAML value")
+    }
+
+    fn visit_bool<E>(self, value: bool) -> Result<Node, E> {
+        Ok(Node::Bool(value))
+    }
+
+    fn visit_i64<E>(self, value: i64) -> Result<Node, E> {
+        Ok(Node::Signed(value))
+    }
+
+    fn visit_u64<E>(self, value: u64) -> Result<Node, E> {
+        Ok(Node::Unsigned(value))
+    }
+
+    fn visit_f64<E>(self, value: f64) -> Result<Node, E> {
+        Ok(Node::Float(value))
+    }
+
+    fn visit_str<E>(self, value: &str) -> Result<Node, E> {
+        Ok(Node::String(value.to_string()))
+    }
+
+    fn visit_string<E>(self, value: String) -> Result<Node, E> {
+        Ok(Node::String(value))
+    }
+
+    fn visit_unit<E>(self) -> Result<Node, E> {
+        Ok(Node::Null)
+    }
+
+    fn visit_none<E>(self) -> Result<Node, E> {
+        Ok(Node::Null)
+    }
+
+    fn visit_some<D>(self, deserializer: D) -> Result<Node, D::Error>
+    where
+        D: Deserializer<'de>,
+    {
+        Node::deserialize(deserializer)
+    }
+
+    fn visit_seq<A>(self, mut seq: A) -> Result<Node, A::Error>
+    where
+        A: SeqAccess<'de>,
+    {
+        let mut nodes = Vec::new();
+        while let Some(node) = seq.next_element()? {
+            nodes.push(node);
+        }
+
+        Ok(Node::Sequence(nodes))
+    }
+
+    fn visit_map<A>(self, mut map: A) -> Result<Node, A::Error>
+    where
+        A: MapAccess<'de>,
+    {
+        let mut entries = Vec::new();
+        while let Some(entry) = map.next_entry()? {
+            entries.push(entry);
+        }
+
+        Ok(Node::Mapping(entries))
+    }
+}
+
+#[derive(Error, Debug)]
+pub enum FormatError {
+    #[error("failed to parse config file")]
+    Invalid(#[source] serde_yaml::Error),
+
+    #[error("failed to format config file")]
+    Serialize(#[source] serde_yaml::Error),
+
+    #[error("only YAML config files can be formatted, found {0}")]
+    UnsupportedFormat(ConfigFormat),
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{parse_config, Platform};
+
+    const UNFORMATTED_CONFIG: &str = "variables:
+    name: Dingus
+    greeting:
+        value: Hello
+        arg:
+            long: greeting
+            short: g
+    message: $greeting, $name!
+commands:
+    greet:
+        action: echo $message
+        description: Says hello
+    deploy:
+        vars:
+            environment: Production
+            region: $environment-east
+        actions:
+            - ./build.sh
+            - ./deploy.sh $region
+description: My Dingus file";
+
+    #[test]
+    fn format_config_sorts_keys_but_retains_variable_order() {
+        // Act
+        let formatted = format_config(&UNFORMATTED_CONFIG.to_string()).unwrap();
+
+        // Assert
+        let node: Node = serde_yaml::from_str(formatted.as_str()).unwrap();
+        let Node::Mapping(root) = node else {
+            panic!("expected a mapping");
+        };
+
+        let keys = mapping_keys(&root);
+        assert_eq!(keys, vec!["commands", "description", "variables"]);
+
+        let Node::Mapping(variables) = &root[2].1 else {
+            panic!("expected a mapping");
+        };
+        assert_eq!(mapping_keys(variables), vec!["name", "greeting", "message"]);
+
+        let Node::Mapping(commands) = &root[0].1 else {
+            panic!("expected a mapping");
+        };
+        assert_eq!(mapping_keys(commands), vec!["deploy", "greet"]);
+    }
+
+    #[test]
+    fn format_config_retains_step_order() {
+        // Arrange
+        let config = "commands:
+    release:
+        steps:
+            version:
+                output: version
+                action: git describe --tags
+            backend:
+                action: cargo build
+            package:
+                depends_on: [version, backend]
+                action: ./package.sh $version";
+
+        // Act
+        let formatted = format_config(&config.to_string()).unwrap();
+
+        // Assert
+        let node: Node = serde_yaml::from_str(formatted.as_str()).unwrap();
+        let Node::Mapping(root) = node else {
+            panic!("expected a mapping");
+        };
+        let Node::Mapping(commands) = &root[0].1 else {
+            panic!("expected a mapping");
+        };
+        let Node::Mapping(release) = &commands[0].1 else {
+            panic!("expected a mapping");
+        };
+        let Node::Mapping(steps) = &release[0].1 else {
+            panic!("expected a mapping");
+        };
+        assert_eq!(mapping_keys(steps), vec!["version", "backend", "package"]);
+
+        let Node::Mapping(package) = &steps[2].1 else {
+            panic!("expected a mapping");
+        };
+        assert_eq!(mapping_keys(package), vec!["action", "depends_on"]);
+    }
+
+    #[test]
+    fn format_config_is_idempotent() {
+        // Arrange
+        let formatted = format_config(&UNFORMATTED_CONFIG.to_string()).unwrap();
+
+        // Act
+        let reformatted = format_config(&formatted).unwrap();
+
+        // Assert
+        assert_eq!(reformatted, formatted);
+    }
+
+    #[test]
+    fn format_config_preserves_meaning() {
+        // Act
+        let formatted = format_config(&UNFORMATTED_CONFIG.to_string()).unwrap();
+
+        // Assert
+        let original = parse_config(&UNFORMATTED_CONFIG.to_string(), Platform::Linux).unwrap();
+        let formatted = parse_config(&formatted, Platform::Linux).unwrap();
+        assert_eq!(formatted.description, original.description);
+        assert_eq!(formatted.variables, original.variables);
+        assert_eq!(formatted.commands, original.commands);
+    }
+
+    #[test]
+    fn format_config_fails_for_invalid_config() {
+        // Act
+        let result = format_config(&"commands: 42".to_string());
+
+        // Assert
+        assert!(matches!(result, Err(FormatError::Invalid(_))));
+    }
+
+    #[test]
+    fn has_comments_finds_comments() {
+        // Arrange
+        let full_line_comment = "# Build the project\ncommands: {}".to_string();
+        let trailing_comment = "commands: {} # No commands yet".to_string();
+        let without_comments = UNFORMATTED_CONFIG.to_string();
+
+        // Act
+        let full_line_result = has_comments(&full_line_comment);
+        let trailing_result = has_comments(&trailing_comment);
+        let without_comments_result = has_comments(&without_comments);
+
+        // Assert
+        assert!(full_line_result);
+        assert!(trailing_result);
+        assert!(!without_comments_result);
+    }
+
+    fn mapping_keys(entries: &Vec<(Node, Node)>) -> Vec<String> {
+        return entries
+            .iter()
+            .map(|(key, _)| match key {
+                Node::String(key) => key.clone(),
+                _ => panic!("expected a string key"),
+            })
+            .collect();
+    }
+}
@@ -0,0 +1,199 @@
+use mockall::automock;
+use std::io;
+use std::process::{Command, Output};
+use std::string::FromUtf8Error;
+use thiserror::Error;
+
+pub fn system_keyring() -> Box<dyn Keyring> {
+    return Box::new(SystemKeyring {});
+}
+
+/// Reads secrets from a keyring.
+#[automock]
+pub trait Keyring {
+    /// Returns the secret stored in the entry for the provided service and account.
+    fn get_secret(&self, service: &str, account: &str) -> Result<String, KeyringError>;
+}
+
+/// Reads secrets from the operating system's keyring using the tools it provides, so that Dingus
+/// doesn't need to link against any platform-specific libraries.
+/// On macOS, this uses `security` to read from the login keychain, and on Linux, this uses
+/// `secret-tool` to read from the Secret Service (e.g. GNOME Keyring or KWallet).
+struct SystemKeyring;
+
+impl Keyring for SystemKeyring {
+    fn get_secret(&self, service: &str, account: &str) -> Result<String, KeyringError> {
+        let mut command = keyring_command(service, account)?;
+        let program = command.get_program().to_string_lossy().to_string();
+        let output = command.output().map_err(|err| KeyringError::Unavailable {
+            program: program.clone(),
+            source: err,
+        })?;
+
+        return parse_secret(service, account, &program, output);
+    }
+}
+
+#[cfg(target_os = "macos")]
+fn keyring_command(service: &str, account: &str) -> Result<Command, KeyringError> {
+    let mut command = Command::new("security");
+    command
+        .arg("find-generic-password")
+        .args(["-s", service])
+        .args(["-a", account])
+        .arg("-w");
+    return Ok(command);
+}
+
+#[cfg(target_os = "linux")]
+fn keyring_command(service: &str, account: &str) -> Result<Command, KeyringError> {
+    let mut command = Command::new("secret-tool");
+    command
+        .arg("lookup")
+        .args(["service", service])
+        .args(["account", account]);
+    return Ok(command);
+}
+
+#[cfg(not(any(target_os = "macos", target_os = "linux")))]
+fn keyring_command(_service: &str, _account: &str) -> Result<Command, KeyringError> {
+    return Err(KeyringError::Unsupported);
+}
+
+/// The exit code `security` uses when there's no matching entry.
+const SECURITY_ITEM_NOT_FOUND: i32 = 44;
+
+/// Parses the secret from the output of the keyring command.
+/// `secret-tool` fails without any output when there's no matching entry, whereas `security`
+/// uses a specific exit code.
+fn parse_secret(
+    service: &str,
+    account: &str,
+    program: &str,
+    output: Output,
+) -> Result<String, KeyringError> {
+    let stdout = String::from_utf8(output.stdout).map_err(|err| KeyringError::Parse(err))?;
+    if output.status.success() {
+        // Only the trailing newline is removed, secrets can contain other whitespace
+        let secret = stdout
+            .strip_suffix('\n')
+            .map(|secret| secret.strip_suffix('\r').unwrap_or(secret))
+            .unwrap_or(&stdout);
+        return Ok(secret.to_string());
+    }
+
+    let not_found = output.status.code() == Some(SECURITY_ITEM_NOT_FOUND)
+        || (stdout.is_empty() && output.stderr.is_empty());
+    if not_found {
+        return Err(KeyringError::NotFound {
+            service: service.to_string(),
+            account: account.to_string(),
+        });
+    }
+
+    return Err(KeyringError::Failed {
+        program: program.to_string(),
+        stderr: String::from_utf8_lossy(&output.stderr)
+            .trim_end()
+            .to_string(),
+    });
+}
+
+#[derive(Error, Debug)]
+pub enum KeyringError {
+    #[error("no keyring entry found for account \"{account}\" in service \"{service}\"")]
+    NotFound { service: String, account: String },
+
+    #[error("failed to run {program}, is it installed?")]
+    Unavailable {
+        program: String,
+        #[source]
+        source: io::Error,
+    },
+
+    #[error("{program} failed: {stderr}")]
+    Failed { program: String, stderr: String },
+
+    #[error("failed to parse secret")]
+    Parse(#[source] FromUtf8Error),
+
+    #[cfg(not(any(target_os = "macos", target_os = "linux")))]
+    #[error("reading from the keyring isn't supported on this platform")]
+    Unsupported,
+}
+
+#[cfg(test)]
+#[cfg(unix)]
+mod tests {
+    use super::*;
+    use std::os::unix::process::ExitStatusExt;
+    use std::process::ExitStatus;
+
+    fn output(code: i32, stdout: &str, stderr: &str) -> Output {
+        return Output {
+            // Wait statuses store the exit code in the second byte
+            status: ExitStatus::from_raw(code << 8),
+            stdout: stdout.as_bytes().to_vec(),
+            stderr: stderr.as_bytes().to_vec(),
+        };
+    }
+
+    #[test]
+    fn parse_secret_removes_trailing_newline() {
+        // Arrange
+        let output = output(0, "  hunter2 \n", "");
+
+        // Act
+        let secret = parse_secret("my-app", "api-key", "secret-tool", output);
+
+        // Assert
+        assert_eq!(secret.unwrap(), "  hunter2 ");
+    }
+
+    #[test]
+    fn parse_secret_fails_for_missing_entry() {
+        // Arrange
+        let output = output(
+            44,
+            "",
+            "The specified item could not be found in the keychain.",
+        );
+
+        // Act
+        let secret = parse_secret("my-app", "api-key", "security", output);
+
+        // Assert
+        assert!(matches!(
+            secret,
+            Err(KeyringError::NotFound { service, account })
+                if service == "my-app" && account == "api-key"
+        ));
+    }
+
+    #[test]
+    fn parse_secret_fails_for_missing_entry_without_output() {
+        // Arrange
+        let output = output(1, "", "");
+
+        // Act
+        let secret = parse_secret("my-app", "api-key", "secret-tool", output);
+
+        // Assert
+        assert!(matches!(secret, Err(KeyringError::NotFound { .. })));
+    }
+
+    #[test]
+    fn parse_secret_includes_errors() {
+        // Arrange
+        let output = output(1, "", "Cannot autolaunch D-Bus without X11 $DISPLAY\n");
+
+        // Act
+        let secret = parse_secret("my-app", "api-key", "secret-tool", output);
+
+        // Assert
+        assert_eq!(
+            secret.unwrap_err().to_string(),
+            "secret-tool failed: Cannot autolaunch D-Bus without X11 $DISPLAY"
+        );
+    }
+}
@@ -0,0 +1,199 @@
+use std::collections::hash_map::DefaultHasher;
+use std::fs::{File, OpenOptions};
+use std::hash::{Hash, Hasher};
+use std::io;
+use std::path::{Path, PathBuf};
+use thiserror::Error;
+
+#[cfg(not(unix))]
+use std::{fs, thread, time::Duration};
+
+/// How long to wait between attempts to acquire a held lock on platforms without `flock`.
+#[cfg(not(unix))]
+const RETRY_INTERVAL: Duration = Duration::from_millis(100);
+
+/// Holds an exclusive lock on a file, preventing other Dingus processes from acquiring the same
+/// lock until it's dropped.
+/// On Unix, this uses `flock`, so the lock is released even if the process is killed. Elsewhere,
+/// the lock file is created exclusively and removed once the lock is released.
+pub struct CommandLock {
+    #[cfg(unix)]
+    _file: File,
+
+    #[cfg(not(unix))]
+    path: PathBuf,
+}
+
+impl CommandLock {
+    /// Acquires the lock at the provided path.
+    /// If the lock is already held, this waits for it to be released when `wait` is `true`,
+    /// otherwise it fails immediately.
+    pub fn acquire(path: &Path, wait: bool) -> Result<CommandLock, LockError> {
+        #[cfg(unix)]
+        {
+            use std::os::fd::AsRawFd;
+
+            let file = open_lock_file(path)?;
+            let operation = match wait {
+                true => libc::LOCK_EX,
+                false => libc::LOCK_EX | libc::LOCK_NB,
+            };
+
+            // Safety: the file descriptor is valid for as long as the file is open
+            let result = unsafe { libc::flock(file.as_raw_fd(), operation) };
+            if result != 0 {
+                let err = io::Error::last_os_error();
+                if err.kind() == io::ErrorKind::WouldBlock {
+                    return Err(LockError::Held {
+                        path: path.to_path_buf(),
+                    });
+                }
+
+                return Err(LockError::IO(err));
+            }
+
+            return Ok(CommandLock { _file: file });
+        }
+
+        #[cfg(not(unix))]
+        loop {
+            match open_lock_file(path) {
+                Ok(_) => {
+                    return Ok(CommandLock {
+                        path: path.to_path_buf(),
+                    })
+                }
+                Err(LockError::IO(err)) if err.kind() == io::ErrorKind::AlreadyExists => {
+                    if !wait {
+                        return Err(LockError::Held {
+                            path: path.to_path_buf(),
+                        });
+                    }
+
+                    thread::sleep(RETRY_INTERVAL);
+                }
+                Err(err) => return Err(err),
+            }
+        }
+    }
+}
+
+#[cfg(not(unix))]
+impl Drop for CommandLock {
+    fn drop(&mut self) {
+        let _ = fs::remove_file(&self.path);
+    }
+}
+
+#[cfg(unix)]
+fn open_lock_file(path: &Path) -> Result<File, LockError> {
+    // The file is left behind once the lock is released, removing it would let another process
+    // lock a different file with the same path
+    return OpenOptions::new()
+        .create(true)
+        .write(true)
+        .truncate(false)
+        .open(path)
+        .map_err(|err| LockError::IO(err));
+}
+
+#[cfg(not(unix))]
+fn open_lock_file(path: &Path) -> Result<File, LockError> {
+    return OpenOptions::new()
+        .create_new(true)
+        .write(true)
+        .open(path)
+        .map_err(|err| LockError::IO(err));
+}
+
+/// Returns the path to the lock file for the command with the provided names, from the config in
+/// the provided directory. Commands with the same names in other directories use different locks.
+pub fn lock_path(directory: &Path, command_names: &Vec<String>) -> PathBuf {
+    let mut hasher = DefaultHasher::new();
+    directory.hash(&mut hasher);
+
+    let command_name: String = command_names
+        .join("-")
+        .chars()
+        .map(|ch| match ch.is_alphanumeric() || ch == '-' || ch == '_' {
+            true => ch,
+            false => '_',
+        })
+        .collect();
+
+    let file_name = format!("dingus-{:016x}-{}.lock", hasher.finish(), command_name);
+    return std::env::temp_dir().join(file_name);
+}
+
+#[derive(Error, Debug)]
+pub enum LockError {
+    #[error("the command is already running (locked by {})", path.display())]
+    Held { path: PathBuf },
+
+    #[error("failed to acquire lock")]
+    IO(#[source] io::Error),
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::mpsc;
+    use std::thread;
+    use std::time::Duration;
+    use tempfile::TempDir;
+
+    #[test]
+    fn acquire_fails_while_lock_is_held() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let path = temp_dir.path().join("deploy.lock");
+        let lock = CommandLock::acquire(&path, false).unwrap();
+
+        // Act
+        let result = CommandLock::acquire(&path, false);
+
+        // Assert
+        assert!(matches!(result, Err(LockError::Held { path: held }) if held == path));
+        drop(lock);
+        assert!(CommandLock::acquire(&path, false).is_ok());
+    }
+
+    #[test]
+    fn acquire_waits_for_lock_to_be_released() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let path = temp_dir.path().join("deploy.lock");
+        let lock = CommandLock::acquire(&path, false).unwrap();
+
+        // Act
+        let (sender, receiver) = mpsc::channel();
+        let waiting_path = path.clone();
+        let waiter = thread::spawn(move || {
+            let result = CommandLock::acquire(&waiting_path, true);
+            sender.send(()).unwrap();
+            result.map(|_| ())
+        });
+
+        // Assert
+        assert!(receiver.recv_timeout(Duration::from_millis(250)).is_err());
+        drop(lock);
+        assert!(receiver.recv_timeout(Duration::from_secs(5)).is_ok());
+        assert!(waiter.join().unwrap().is_ok());
+    }
+
+    #[test]
+    fn lock_path_is_unique_to_directory_and_command() {
+        // Arrange
+        let names = vec!["db".to_string(), "migrate up".to_string()];
+
+        // Act
+        let path = lock_path(Path::new("/projects/web"), &names);
+        let other_directory_path = lock_path(Path::new("/projects/api"), &names);
+
+        // Assert
+        let file_name = path.file_name().unwrap().to_string_lossy().to_string();
+        assert!(file_name.starts_with("dingus-"));
+        assert!(file_name.ends_with("-db-migrate_up.lock"));
+        assert_ne!(path, other_directory_path);
+    }
+}
@@ -1,39 +1,84 @@
-use crate::actions::ActionExecutor;
 use crate::args::ClapArgumentResolver;
 use crate::config::ConfigError;
 use crate::exec::create_command_executor;
 use crate::platform::current_platform_provider;
 use crate::prompt::TerminalPromptExecutor;
-use crate::variables::{RealVariableResolver, VariableResolver};
+use crate::runner::ConfigCommandRunner;
 use anyhow::Result;
-use std::env;
+use std::{env, fs, process};
 use thiserror::Error;
 
 mod actions;
 mod args;
+mod browse;
 mod cli;
 mod config;
+mod describe;
 mod exec;
+mod format;
+mod keyring;
+mod lock;
+mod plan;
 mod platform;
+mod preconditions;
 mod prompt;
+mod redact;
+mod remote;
+mod runner;
+mod selftest;
+mod spinner;
+mod umask;
 mod variables;
 
 // Ideas:
-// - Preconditions: Specify a list of applications that must be installed, or a custom script that must succeed before running a command
-// - Deferred actions: Always executes at the end, even if one of the actions fails.
 // - Cached variable results: Allow the results of an execution variable to be cached on disk for future use.
 // - Remote commands: Execute commands on a remote machine (Like a mini Ansible)
 // - Container actions: Run an action inside a docker container
-// - Include other config files with a remote link
 // - YAML schema.
 
-fn main() -> Result<()> {
-    let config_result = config::load();
+fn main() {
+    if let Err(err) = run() {
+        eprintln!("Error: {err:?}");
+
+        // Exit with the same exit code as the command that failed, if there was one
+        let exit_code = match err.downcast_ref::<runner::RunError>() {
+            Some(run_err) => run_err.exit_code(),
+            None => 1,
+        };
+        process::exit(exit_code);
+    }
+}
+
+fn run() -> Result<()> {
+    let global_args = cli::parse_global_args(env::args_os());
+
+    // Change directory before looking for the config file so that it's discovered from there
+    if let Some(working_directory) = &global_args.working_directory {
+        env::set_current_dir(working_directory)?;
+    }
+
+    // Let the user choose from any config files we can find, unless one has been provided
+    let mut config_location = global_args.config.clone();
+    if global_args.pick_config && config_location.is_none() {
+        let config_file_paths = config::find_config_files(&env::current_dir()?);
+        if !config_file_paths.is_empty() {
+            let prompt_executor =
+                TerminalPromptExecutor::new(create_command_executor(&Default::default()));
+            let config_file_path = prompt::pick_config_file(&prompt_executor, &config_file_paths)?;
+            config_location = Some(config_file_path.display().to_string());
+        }
+    }
+
+    let config_result = config::load(
+        config_location.as_ref(),
+        global_args.allow_insecure_config,
+        global_args.config_checksum.as_ref(),
+    );
 
     // Offer to create the config file if one doesn't exist
     if let Err(config_err) = config_result {
         return match config_err {
-            ConfigError::FileNotFound => {
+            ConfigError::FileNotFound if !global_args.show_config_path => {
                 let should_init = inquire::Confirm::new(
                     "Couldn't find a config file in this directory. Do you want to create one?",
                 )
@@ -53,7 +98,59 @@ fn main() -> Result<()> {
     }
 
     let found_config = config_result?;
-    let config = found_config.config;
+    if global_args.show_config_path {
+        println!("{}", found_config.source);
+        return Ok(());
+    }
+
+    if global_args.format_config {
+        if found_config.format != config::ConfigFormat::Yaml {
+            return Err(format::FormatError::UnsupportedFormat(found_config.format).into());
+        }
+
+        let formatted = format::format_config(&found_config.text)?;
+        match &found_config.source {
+            config::Source::File(config_file_path) if global_args.write => {
+                // Formatting removes comments, so make sure they aren't lost by accident
+                if format::has_comments(&found_config.text) {
+                    let should_write = inquire::Confirm::new(&format!(
+                        "{} contains comments which will be removed. Do you want to continue?",
+                        config_file_path.display()
+                    ))
+                    .with_default(false)
+                    .prompt()?;
+
+                    if !should_write {
+                        return Ok(());
+                    }
+                }
+
+                fs::write(config_file_path, formatted)?;
+                println!("formatted {}", config_file_path.display());
+            }
+            _ => print!("{formatted}"),
+        }
+
+        return Ok(());
+    }
+
+    if global_args.describe {
+        print!("{}", describe::describe_markdown(&found_config.config));
+        return Ok(());
+    }
+
+    let mut config = found_config.config;
+    if global_args.log_answers {
+        config.options.log_answers = true;
+    }
+
+    if global_args.timings {
+        config.options.print_timings = true;
+    }
+
+    if global_args.allow_missing {
+        config.options.allow_missing_variables = true;
+    }
 
     // Change the current working directory to the directory that the config file came from.
     if let config::Source::File(config_file_path) = found_config.source {
@@ -62,12 +159,47 @@ fn main() -> Result<()> {
         }
     }
 
+    if global_args.test_config {
+        let outcomes = selftest::run_tests(&config.commands);
+        for outcome in &outcomes {
+            println!("{}", selftest::format_outcome(outcome));
+        }
+
+        let failed = outcomes.iter().filter(|outcome| !outcome.passed()).count();
+        if failed > 0 {
+            return Err(CommandError::TestsFailed { failed }.into());
+        }
+
+        return Ok(());
+    }
+
+    // Aliases are only available when their requirements are met
+    config.commands = cli::remove_unavailable_aliases(
+        &config.commands,
+        create_command_executor(&config.options).as_ref(),
+    );
+
+    if !global_args.tags.is_empty() {
+        config.commands = cli::hide_commands_without_tags(&config.commands, &global_args.tags);
+    }
+
     let platform_provider = current_platform_provider();
 
     let root_command = cli::create_root_command(&config, &platform_provider);
 
     // This will exit on any match failures
-    let arg_matches = root_command.clone().get_matches();
+    let arg_matches = if global_args.browse {
+        let mut browser =
+            browse::CommandBrowser::new(config.commands.clone(), platform_provider.get_platform());
+        let prompt_executor = TerminalPromptExecutor::new(create_command_executor(&config.options))
+            .with_prompt_symbol(&config.options.prompt_symbol);
+        let command_names = browse::browse(&prompt_executor, &mut browser)?;
+
+        let args = env::args().take(1).chain(command_names);
+        root_command.clone().get_matches_from(args)
+    } else {
+        root_command.clone().get_matches()
+    };
 
     // Otherwise, look for a configured command
     let find_result = cli::find_subcommand(
@@ -79,28 +211,64 @@ fn main() -> Result<()> {
 
     if let Some((target_command, available_variable_configs, sucbommand_arg_matches)) = find_result
     {
-        if let Some(command_action) = target_command.action {
-            // Set up the dependencies
-            let arg_resolver = ClapArgumentResolver::from_arg_matches(&sucbommand_arg_matches);
-            let variable_resolver = RealVariableResolver {
-                command_executor: create_command_executor(&config.options),
-                prompt_executor: Box::new(TerminalPromptExecutor::new(create_command_executor(
-                    &config.options,
-                ))),
-                argument_resolver: Box::new(arg_resolver),
-                dingus_options: config.options.clone(),
-            };
+        if cli::is_list_flags_set(&sucbommand_arg_matches) {
+            print!("{}", describe::list_inputs(&available_variable_configs));
+            return Ok(());
+        }
 
-            let variables = variable_resolver.resolve_variables(&available_variable_configs)?;
+        if cli::is_plan_set(&sucbommand_arg_matches) {
+            if let Some(command_action) = &target_command.action {
+                print!("{}", plan::format_plan(&plan::plan_action(command_action)?));
+            }
 
-            let action_executor = ActionExecutor {
-                command_executor: create_command_executor(&config.options),
-                arg_resolver: Box::new(ClapArgumentResolver::from_arg_matches(
-                    &sucbommand_arg_matches,
-                )),
+            return Ok(());
+        }
+
+        if target_command.action.is_some() {
+            let command_runner = ConfigCommandRunner {
+                commands: config.commands.clone(),
+                variables: config.variables.clone(),
+                env: config.env.clone(),
+                working_directory: config.working_directory.clone(),
+                options: config.options.clone(),
+                root_arg_matches: arg_matches.clone(),
+                variable_overrides: global_args.variable_overrides.clone(),
+                dry_run: global_args.dry_run,
+                log_format: global_args.log_format.clone(),
             };
 
-            action_executor.execute(&command_action, &variables)?;
+            // Values provided with --var take precedence over everything else
+            let arg_resolver = ClapArgumentResolver::from_arg_matches(&sucbommand_arg_matches)
+                .with_root_arg_matches(&arg_matches)
+                .with_overrides(&global_args.variable_overrides);
+            let command_path = cli::subcommand_names(&arg_matches).join(" ");
+            let prepared_command = command_runner.prepare(
+                &command_path,
+                &target_command,
+                &available_variable_configs,
+                Box::new(arg_resolver),
+            )?;
+
+            if let Some(export_format) = &global_args.export_format {
+                let exports = variables::format_exports(
+                    &prepared_command.variable_configs,
+                    &prepared_command.variables,
+                    export_format,
+                    !global_args.no_secrets,
+                );
+                println!("{exports}");
+                return Ok(());
+            }
+
+            command_runner.execute(
+                prepared_command,
+                vec![command_path],
+                Box::new(
+                    ClapArgumentResolver::from_arg_matches(&sucbommand_arg_matches)
+                        .with_root_arg_matches(&arg_matches),
+                ),
+                config.options.notify.as_ref(),
+            )?;
             return Ok(());
         }
     }
@@ -112,4 +280,7 @@ fn main() -> Result<()> {
 enum CommandError {
     #[error("could not find a suitable command")]
     CommandNotFound,
+
+    #[error("{failed} config test(s) failed")]
+    TestsFailed { failed: usize },
 }
@@ -0,0 +1,318 @@
+use crate::config::{ActionConfig, StepConfig};
+use linked_hash_map::LinkedHashMap;
+use thiserror::Error;
+
+/// A step of an action, as it appears in an execution plan.
+#[derive(PartialEq, Debug)]
+pub struct PlannedStep {
+    pub name: String,
+
+    /// The command text for the step, before any variables have been substituted.
+    pub command: String,
+
+    /// The names of the steps which must be executed before this one.
+    pub depends_on: Vec<String>,
+}
+
+/// Sorts the provided steps into stages, where each stage only depends on the stages before it.
+/// Steps in the same stage don't depend on each other, so they could be executed in parallel.
+/// Within each stage, the steps are in the order they were declared.
+pub fn plan_steps(
+    steps: &LinkedHashMap<String, StepConfig>,
+) -> Result<Vec<Vec<String>>, PlanError> {
+    for (name, step) in steps {
+        for dependency in &step.depends_on {
+            if !steps.contains_key(dependency) {
+                return Err(PlanError::UnknownDependency {
+                    step: name.clone(),
+                    dependency: dependency.clone(),
+                });
+            }
+        }
+    }
+
+    let mut stages: Vec<Vec<String>> = Vec::new();
+    let mut planned: Vec<String> = Vec::new();
+    while planned.len() < steps.len() {
+        let stage: Vec<String> = steps
+            .iter()
+            .filter(|(name, step)| {
+                !planned.contains(name)
+                    && step
+                        .depends_on
+                        .iter()
+                        .all(|dependency| planned.contains(dependency))
+            })
+            .map(|(name, _)| name.clone())
+            .collect();
+
+        // If nothing is ready, then the remaining steps must depend on each other
+        if stage.is_empty() {
+            let cycle = steps
+                .keys()
+                .filter(|name| !planned.contains(name))
+                .cloned()
+                .collect();
+            return Err(PlanError::Cycle { steps: cycle });
+        }
+
+        planned.extend(stage.clone());
+        stages.push(stage);
+    }
+
+    return Ok(stages);
+}
+
+/// Returns the stages that the provided [`ActionConfig`] will be executed in.
+/// Steps without a name are numbered in the order they're executed.
+pub fn plan_action(action_config: &ActionConfig) -> Result<Vec<Vec<PlannedStep>>, PlanError> {
+    let commands = match action_config {
+        ActionConfig::SingleStep(single_action_config) => {
+            vec![single_action_config.action.command_template()]
+        }
+        ActionConfig::MultiStep(multi_action_config) => multi_action_config
+            .actions
+            .iter()
+            .map(|execution_config| execution_config.command_template())
+            .collect(),
+        ActionConfig::Alias(alias_action_config) => vec![alias_action_config.alias.clone()],
+        ActionConfig::Calls(calls_action_config) => calls_action_config
+            .calls
+            .iter()
+            .map(|command_path| format!("calls {command_path}"))
+            .collect(),
+        ActionConfig::Steps(steps_action_config) => {
+            let steps = &steps_action_config.steps;
+            let stages = plan_steps(steps)?
+                .into_iter()
+                .map(|stage| {
+                    stage
+                        .into_iter()
+                        .map(|name| {
+                            // Safe to unwrap: the stages only contain names from the steps
+                            let step = steps.get(&name).unwrap();
+                            PlannedStep {
+                                name,
+                                command: step.action.command_template(),
+                                depends_on: step.depends_on.clone(),
+                            }
+                        })
+                        .collect()
+                })
+                .collect();
+
+            return Ok(stages);
+        }
+    };
+
+    // Everything else is executed one step after another
+    let stages = commands
+        .into_iter()
+        .enumerate()
+        .map(|(index, command)| {
+            vec![PlannedStep {
+                name: (index + 1).to_string(),
+                command,
+                depends_on: match index {
+                    0 => vec![],
+                    _ => vec![index.to_string()],
+                },
+            }]
+        })
+        .collect();
+
+    return Ok(stages);
+}
+
+/// Formats the provided stages as a human-readable execution plan.
+pub fn format_plan(stages: &Vec<Vec<PlannedStep>>) -> String {
+    let mut text = String::new();
+    for (index, stage) in stages.iter().enumerate() {
+        text.push_str(&format!("stage {}:\n", index + 1));
+
+        for step in stage {
+            text.push_str(&format!("  {}: {}", step.name, step.command));
+            if !step.depends_on.is_empty() {
+                text.push_str(&format!(" (after {})", step.depends_on.join(", ")));
+            }
+            text.push('\n');
+        }
+    }
+
+    return text;
+}
+
+#[derive(Error, Debug)]
+pub enum PlanError {
+    #[error("step \"{step}\" depends on unknown step \"{dependency}\"")]
+    UnknownDependency { step: String, dependency: String },
+
+    #[error("cyclic step dependencies detected between: {}", steps.join(", "))]
+    Cycle { steps: Vec<String> },
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{parse_config, Platform};
+
+    fn parse_action(yaml: &str) -> ActionConfig {
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+        return config.commands["release"].action.clone().unwrap();
+    }
+
+    fn parse_steps(yaml: &str) -> LinkedHashMap<String, StepConfig> {
+        let ActionConfig::Steps(steps_action_config) = parse_action(yaml) else {
+            panic!("expected a steps action");
+        };
+
+        return steps_action_config.steps;
+    }
+
+    #[test]
+    fn plan_steps_orders_steps_by_dependencies() {
+        // Arrange
+        let steps = parse_steps(
+            "commands:
+    release:
+        steps:
+            publish:
+                action: ./publish.sh
+                depends_on: [package]
+            package:
+                action: ./package.sh
+                depends_on: [frontend, backend]
+            frontend:
+                action: npm run build
+            backend:
+                action: cargo build",
+        );
+
+        // Act
+        let stages = plan_steps(&steps).unwrap();
+
+        // Assert
+        assert_eq!(
+            stages,
+            vec![
+                vec!["frontend".to_string(), "backend".to_string()],
+                vec!["package".to_string()],
+                vec!["publish".to_string()],
+            ]
+        );
+    }
+
+    #[test]
+    fn plan_steps_fails_for_unknown_dependency() {
+        // Arrange
+        let steps = parse_steps(
+            "commands:
+    release:
+        steps:
+            package:
+                action: ./package.sh
+                depends_on: [build]",
+        );
+
+        // Act
+        let result = plan_steps(&steps);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(PlanError::UnknownDependency { step, dependency })
+                if step == "package" && dependency == "build"
+        ));
+    }
+
+    #[test]
+    fn plan_steps_fails_for_cycle() {
+        // Arrange
+        let steps = parse_steps(
+            "commands:
+    release:
+        steps:
+            lint:
+                action: cargo clippy
+            build:
+                action: cargo build
+                depends_on: [test]
+            test:
+                action: cargo test
+                depends_on: [build]",
+        );
+
+        // Act
+        let result = plan_steps(&steps);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(PlanError::Cycle { steps }) if steps == vec!["build".to_string(), "test".to_string()]
+        ));
+    }
+
+    #[test]
+    fn plan_action_runs_multiple_actions_in_sequence() {
+        // Arrange
+        let action_config = parse_action(
+            "commands:
+    release:
+        actions:
+            - cargo build
+            - cargo test",
+        );
+
+        // Act
+        let stages = plan_action(&action_config).unwrap();
+
+        // Assert
+        assert_eq!(
+            stages,
+            vec![
+                vec![PlannedStep {
+                    name: "1".to_string(),
+                    command: "cargo build".to_string(),
+                    depends_on: vec![],
+                }],
+                vec![PlannedStep {
+                    name: "2".to_string(),
+                    command: "cargo test".to_string(),
+                    depends_on: vec!["1".to_string()],
+                }],
+            ]
+        );
+    }
+
+    #[test]
+    fn format_plan_shows_stages_and_dependencies() {
+        // Arrange
+        let action_config = parse_action(
+            "commands:
+    release:
+        steps:
+            frontend:
+                action: npm run build
+            backend:
+                action: cargo build
+            package:
+                action: ./package.sh
+                depends_on: [frontend, backend]",
+        );
+        let stages = plan_action(&action_config).unwrap();
+
+        // Act
+        let plan = format_plan(&stages);
+
+        // Assert
+        assert_eq!(
+            plan,
+            "stage 1:
+  frontend: npm run build
+  backend: cargo build
+stage 2:
+  package: ./package.sh (after frontend, backend)
+"
+        );
+    }
+}
@@ -0,0 +1,246 @@
+use crate::config::{PreconditionCheckConfig, PreconditionConfig};
+use crate::exec::{CommandExecutor, ExecutionError, ExitStatus};
+use crate::variables::VariableMap;
+use std::env;
+use std::path::Path;
+use thiserror::Error;
+
+/// Checks each of the provided [`PreconditionConfig`]s in order, returning an error for the first
+/// one that fails.
+pub fn check_preconditions(
+    preconditions: &Vec<PreconditionConfig>,
+    command_executor: &dyn CommandExecutor,
+) -> Result<(), PreconditionError> {
+    for precondition in preconditions {
+        let result = check_precondition(&precondition.check, command_executor);
+
+        // Custom messages replace the default message, but not execution errors
+        if let (Err(PreconditionError::Failed { .. }), Some(message)) =
+            (&result, &precondition.message)
+        {
+            return Err(PreconditionError::Failed {
+                message: message.clone(),
+            });
+        }
+
+        result?;
+    }
+
+    return Ok(());
+}
+
+fn check_precondition(
+    check_config: &PreconditionCheckConfig,
+    command_executor: &dyn CommandExecutor,
+) -> Result<(), PreconditionError> {
+    let failure_message = match check_config {
+        PreconditionCheckConfig::FileExists(path) => {
+            if Path::new(path).exists() {
+                return Ok(());
+            }
+
+            format!("{path} does not exist")
+        }
+
+        PreconditionCheckConfig::CommandSucceeds(execution_config) => {
+            let output = command_executor
+                .get_output(execution_config, &VariableMap::new())
+                .map_err(|err| PreconditionError::Execution(err))?;
+            if output.status == ExitStatus::Success {
+                return Ok(());
+            }
+
+            format!(
+                "{} failed: {}",
+                execution_config.command_template(),
+                output.status
+            )
+        }
+
+        PreconditionCheckConfig::EnvSet(name) => {
+            let is_set = env::var_os(name).is_some_and(|value| !value.is_empty());
+            if is_set {
+                return Ok(());
+            }
+
+            format!("the {name} environment variable is not set")
+        }
+    };
+
+    return Err(PreconditionError::Failed {
+        message: failure_message,
+    });
+}
+
+#[derive(Error, Debug)]
+pub enum PreconditionError {
+    #[error("precondition failed: {message}")]
+    Failed { message: String },
+
+    #[error("failed to check precondition")]
+    Execution(#[source] ExecutionError),
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{ExecutionConfigVariant, RawCommandConfigVariant};
+    use crate::exec::{MockCommandExecutor, Output};
+    use tempfile::TempDir;
+
+    #[test]
+    fn file_exists_passes_when_file_exists() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let file_path = temp_dir.path().join("docker-compose.yaml");
+        std::fs::write(&file_path, "").unwrap();
+
+        let preconditions = vec![precondition(PreconditionCheckConfig::FileExists(
+            file_path.display().to_string(),
+        ))];
+
+        // Act
+        let result = check_preconditions(&preconditions, &MockCommandExecutor::new());
+
+        // Assert
+        assert!(result.is_ok());
+    }
+
+    #[test]
+    fn file_exists_fails_when_file_is_missing() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let file_path = temp_dir.path().join("docker-compose.yaml");
+
+        let preconditions = vec![precondition(PreconditionCheckConfig::FileExists(
+            file_path.display().to_string(),
+        ))];
+
+        // Act
+        let result = check_preconditions(&preconditions, &MockCommandExecutor::new());
+
+        // Assert
+        let Err(PreconditionError::Failed { message }) = result else {
+            panic!("expected the precondition to fail");
+        };
+        assert_eq!(message, format!("{} does not exist", file_path.display()));
+    }
+
+    #[test]
+    fn command_succeeds_passes_when_command_succeeds() {
+        // Arrange
+        let preconditions = vec![precondition(PreconditionCheckConfig::CommandSucceeds(
+            ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                "docker info".to_string(),
+            )),
+        ))];
+
+        let command_executor = command_executor_returning(ExitStatus::Success);
+
+        // Act
+        let result = check_preconditions(&preconditions, &command_executor);
+
+        // Assert
+        assert!(result.is_ok());
+    }
+
+    #[test]
+    fn command_succeeds_fails_when_command_fails() {
+        // Arrange
+        let preconditions = vec![precondition(PreconditionCheckConfig::CommandSucceeds(
+            ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                "docker info".to_string(),
+            )),
+        ))];
+
+        let command_executor = command_executor_returning(ExitStatus::Fail(1));
+
+        // Act
+        let result = check_preconditions(&preconditions, &command_executor);
+
+        // Assert
+        let Err(PreconditionError::Failed { message }) = result else {
+            panic!("expected the precondition to fail");
+        };
+        assert_eq!(message, "docker info failed: process exited with code 1");
+    }
+
+    #[test]
+    fn env_set_passes_when_variable_is_set() {
+        // Arrange
+        let preconditions = vec![precondition(PreconditionCheckConfig::EnvSet(
+            "PATH".to_string(),
+        ))];
+
+        // Act
+        let result = check_preconditions(&preconditions, &MockCommandExecutor::new());
+
+        // Assert
+        assert!(result.is_ok());
+    }
+
+    #[test]
+    fn env_set_fails_when_variable_is_not_set() {
+        // Arrange
+        let preconditions = vec![precondition(PreconditionCheckConfig::EnvSet(
+            "DINGUS_MISSING_PRECONDITION_VARIABLE".to_string(),
+        ))];
+
+        // Act
+        let result = check_preconditions(&preconditions, &MockCommandExecutor::new());
+
+        // Assert
+        let Err(PreconditionError::Failed { message }) = result else {
+            panic!("expected the precondition to fail");
+        };
+        assert_eq!(
+            message,
+            "the DINGUS_MISSING_PRECONDITION_VARIABLE environment variable is not set"
+        );
+    }
+
+    #[test]
+    fn custom_message_replaces_default_message() {
+        // Arrange
+        let preconditions = vec![PreconditionConfig {
+            check: PreconditionCheckConfig::CommandSucceeds(ExecutionConfigVariant::RawCommand(
+                RawCommandConfigVariant::Shorthand("docker info".to_string()),
+            )),
+            message: Some("Docker isn't running".to_string()),
+        }];
+
+        let command_executor = command_executor_returning(ExitStatus::Fail(1));
+
+        // Act
+        let result = check_preconditions(&preconditions, &command_executor);
+
+        // Assert
+        let Err(PreconditionError::Failed { message }) = result else {
+            panic!("expected the precondition to fail");
+        };
+        assert_eq!(message, "Docker isn't running");
+    }
+
+    fn precondition(check: PreconditionCheckConfig) -> PreconditionConfig {
+        return PreconditionConfig {
+            check,
+            message: None,
+        };
+    }
+
+    fn command_executor_returning(status: ExitStatus) -> MockCommandExecutor {
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .once()
+            .returning(move |_, _| {
+                Ok(Output {
+                    status: status.clone(),
+                    stdout: vec![],
+                    stderr: vec![],
+                })
+            });
+
+        return command_executor;
+    }
+}
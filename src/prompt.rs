@@ -1,10 +1,24 @@
 use crate::config::{
-    PromptConfig, PromptOptionsVariant, SelectOptionsConfig, SelectPromptOptions, TextPromptOptions,
+    ExecutionConfigVariant, MultiSelectPromptOptions, PromptConfig, PromptOptionsVariant,
+    SelectOptionsConfig, SelectPromptOptions, TextCase, TextPromptOptions, ValidationRuleConfig,
+};
+use crate::exec::{strip_ansi_escapes, CommandExecutor, ExecutionError, ExitStatus};
+use crate::spinner::Spinner;
+use inquire::list_option::ListOption;
+use inquire::ui::{RenderConfig, Styled};
+use inquire::validator::{ErrorMessage, Validation};
+use inquire::{
+    Confirm, CustomUserError, InquireError, MultiSelect, Password, PasswordDisplayMode, Select,
+    Text,
 };
-use crate::exec::{CommandExecutor, ExecutionError};
-use inquire::{InquireError, Password, PasswordDisplayMode, Select, Text};
 use mockall::automock;
+use regex::Regex;
+use std::cell::Cell;
 use std::collections::HashMap;
+use std::io;
+use std::io::{BufRead, Write};
+use std::path::PathBuf;
+use std::rc::Rc;
 use std::string::FromUtf8Error;
 use thiserror::Error;
 
@@ -18,87 +32,1265 @@ pub enum PromptError {
 
     #[error("failed to parse prompt options")]
     ParseError(#[source] FromUtf8Error),
+
+    #[error("gave up after {attempts} failed attempts")]
+    TooManyAttempts { attempts: u32 },
+
+    #[error("failed to read from stdin")]
+    ReadFailed(#[source] io::Error),
+
+    #[error("reached the end of stdin before a value was entered")]
+    EndOfInput,
+
+    #[error("invalid validation pattern")]
+    InvalidPattern(#[source] regex::Error),
+
+    #[error("the default \"{default}\" is not one of the options")]
+    InvalidDefault { default: String },
 }
 
 #[automock]
 pub trait PromptExecutor {
     /// Prompts the user using the provided [`PromptConfig`], returning the user's response.
     fn execute(&self, prompt_config: &PromptConfig) -> Result<String, PromptError>;
+
+    /// Asks the user to confirm with the provided message, returning `true` if they agreed.
+    fn confirm(&self, message: &str) -> Result<bool, PromptError>;
+
+    /// Reads a single line from stdin after printing the provided label, without any of the
+    /// interactive features of a regular prompt.
+    fn read_line(&self, label: &str) -> Result<String, PromptError>;
 }
 
+/// The name of the variable containing the input value when validating a prompt.
+const VALIDATION_VALUE_VARIABLE_NAME: &str = "value";
+
+/// The option added to select prompts which allow the user to enter a value which isn't listed.
+const OTHER_OPTION: &str = "Other...";
+
+/// The option shown when reviewing answers which keeps the current answers.
+const CONTINUE_OPTION: &str = "Continue";
+
 pub struct TerminalPromptExecutor {
-    command_executor: Box<dyn CommandExecutor>,
+    // Prompt validators need their own reference to the executor
+    command_executor: Rc<dyn CommandExecutor>,
+
+    /// The symbol displayed before the message of text prompts.
+    prompt_symbol: String,
 }
 
 impl TerminalPromptExecutor {
     pub fn new(command_executor: Box<dyn CommandExecutor>) -> TerminalPromptExecutor {
-        return TerminalPromptExecutor { command_executor };
+        return TerminalPromptExecutor {
+            command_executor: Rc::from(command_executor),
+            prompt_symbol: "?".to_string(),
+        };
+    }
+
+    /// Uses the provided symbol before the message of text prompts which don't specify their own.
+    pub fn with_prompt_symbol(mut self, prompt_symbol: &str) -> TerminalPromptExecutor {
+        self.prompt_symbol = prompt_symbol.to_string();
+        return self;
     }
 }
 
 impl PromptExecutor for TerminalPromptExecutor {
     fn execute(&self, prompt_config: &PromptConfig) -> Result<String, PromptError> {
-        match prompt_config.clone().options {
-            PromptOptionsVariant::Text(text_prompt_options) => {
-                execute_text_prompt(prompt_config.message.as_str(), &text_prompt_options)
-            }
+        let help = prompt_config.help.as_deref();
+        let default = prompt_config.default.as_deref();
+        let result = match prompt_config.clone().options {
+            PromptOptionsVariant::Text(text_prompt_options) => execute_text_prompt(
+                prompt_config.message.as_str(),
+                help,
+                default,
+                &text_prompt_options,
+                &self.prompt_symbol,
+                &self.command_executor,
+            ),
             PromptOptionsVariant::Select(select_prompt_config) => execute_select_prompt(
                 prompt_config.message.as_str(),
+                help,
+                default,
                 &select_prompt_config,
-                &self.command_executor,
+                &self.prompt_symbol,
+                self.command_executor.as_ref(),
             ),
+            PromptOptionsVariant::MultiSelect(multi_select_prompt_options) => {
+                execute_multi_select_prompt(
+                    prompt_config.message.as_str(),
+                    help,
+                    &multi_select_prompt_options,
+                    self.command_executor.as_ref(),
+                )
+            }
+        };
+
+        return use_default_if_cancelled(result, prompt_config);
+    }
+
+    fn confirm(&self, message: &str) -> Result<bool, PromptError> {
+        Confirm::new(message)
+            .with_default(false)
+            .prompt()
+            .map_err(|err| PromptError::InquireError(err))
+    }
+
+    fn read_line(&self, label: &str) -> Result<String, PromptError> {
+        return read_line(&mut io::stdin().lock(), &mut io::stderr(), label);
+    }
+}
+
+/// Writes the provided label to the `writer`, then reads a single line from the `reader`.
+/// The trailing line ending is not included in the returned value.
+fn read_line(
+    reader: &mut impl BufRead,
+    writer: &mut impl Write,
+    label: &str,
+) -> Result<String, PromptError> {
+    write!(writer, "{}", label).map_err(|err| PromptError::ReadFailed(err))?;
+    writer.flush().map_err(|err| PromptError::ReadFailed(err))?;
+
+    let mut line = String::new();
+    let bytes_read = reader
+        .read_line(&mut line)
+        .map_err(|err| PromptError::ReadFailed(err))?;
+    if bytes_read == 0 {
+        return Err(PromptError::EndOfInput);
+    }
+
+    return Ok(line.trim_end_matches(['\r', '\n']).to_string());
+}
+
+/// Prints the provided summary, then asks the user whether they want to continue.
+/// Returns `true` if the user agreed.
+pub fn confirm_with_summary(
+    prompt_executor: &dyn PromptExecutor,
+    summary: &str,
+) -> Result<bool, PromptError> {
+    println!("{}", summary);
+    return prompt_executor.confirm("Do you want to continue?");
+}
+
+/// Asks the user to type the provided phrase to confirm that they want to continue.
+/// Returns `true` if the user typed the phrase exactly.
+pub fn confirm_phrase(
+    prompt_executor: &dyn PromptExecutor,
+    phrase: &str,
+) -> Result<bool, PromptError> {
+    let prompt_config = PromptConfig {
+        message: format!("Type \"{}\" to continue:", phrase),
+        options: PromptOptionsVariant::default(),
+        ..Default::default()
+    };
+
+    let value = prompt_executor.execute(&prompt_config)?;
+    return Ok(value == phrase);
+}
+
+/// Asks the user which of the provided config files to use, returning the chosen path.
+/// No prompt is shown if there is only one config file.
+pub fn pick_config_file(
+    prompt_executor: &dyn PromptExecutor,
+    config_file_paths: &Vec<PathBuf>,
+) -> Result<PathBuf, PromptError> {
+    if config_file_paths.len() == 1 {
+        return Ok(config_file_paths[0].clone());
+    }
+
+    let options: Vec<String> = config_file_paths
+        .iter()
+        .map(|config_file_path| config_file_path.display().to_string())
+        .collect();
+
+    let prompt_config = PromptConfig {
+        message: "Which config file do you want to use?".to_string(),
+        options: PromptOptionsVariant::Select(SelectPromptOptions {
+            options: SelectOptionsConfig::Literal(options),
+            allow_other: false,
+        }),
+        ..Default::default()
+    };
+
+    let choice = prompt_executor.execute(&prompt_config)?;
+    return Ok(PathBuf::from(choice));
+}
+
+/// Lists the provided answers, and asks the user whether they want to change any of them before
+/// continuing. Each answer is provided as a key, along with the label to show for it.
+/// Returns the key of the answer to change, or `None` if the user chose to continue.
+pub fn review_answers(
+    prompt_executor: &dyn PromptExecutor,
+    answers: &Vec<(String, String)>,
+) -> Result<Option<String>, PromptError> {
+    let mut options = vec![CONTINUE_OPTION.to_string()];
+    options.extend(answers.iter().map(|(_, label)| label.clone()));
+
+    let prompt_config = PromptConfig {
+        message: "Do you want to change any of your answers?".to_string(),
+        help: Some("Select an answer to change it".to_string()),
+        default: Some(CONTINUE_OPTION.to_string()),
+        options: PromptOptionsVariant::Select(SelectPromptOptions {
+            options: SelectOptionsConfig::Literal(options),
+            allow_other: false,
+        }),
+        ..Default::default()
+    };
+
+    let choice = prompt_executor.execute(&prompt_config)?;
+    return Ok(answers
+        .iter()
+        .find(|(_, label)| *label == choice)
+        .map(|(key, _)| key.clone()));
+}
+
+/// Returns the default value from the provided [`PromptConfig`] if the prompt was cancelled and the
+/// prompt allows cancellation to fall back to the default.
+/// Otherwise, the result is returned as-is.
+fn use_default_if_cancelled(
+    result: Result<String, PromptError>,
+    prompt_config: &PromptConfig,
+) -> Result<String, PromptError> {
+    if let Err(PromptError::InquireError(InquireError::OperationCanceled)) = result {
+        if prompt_config.cancel_uses_default {
+            if let Some(default) = &prompt_config.default {
+                return Ok(default.clone());
+            }
+        }
+    }
+
+    return result;
+}
+
+/// Validates the provided value by executing the provided [`ExecutionConfigVariant`].
+/// The value is only valid if the command exits with a zero exit code, otherwise the command's
+/// stderr is used as the error message.
+fn validate_with_command(
+    value: &str,
+    validation_config: &ExecutionConfigVariant,
+    command_executor: &dyn CommandExecutor,
+) -> Result<Validation, PromptError> {
+    let mut variables = HashMap::new();
+    variables.insert(
+        VALIDATION_VALUE_VARIABLE_NAME.to_string(),
+        value.to_string(),
+    );
+
+    let output = command_executor
+        .get_output(validation_config, &variables)
+        .map_err(|err| PromptError::ExecutionError(err))?;
+    if output.status == ExitStatus::Success {
+        return Ok(Validation::Valid);
+    }
+
+    let stderr = String::from_utf8_lossy(&output.stderr).trim().to_string();
+    if stderr.is_empty() {
+        return Ok(Validation::Invalid(ErrorMessage::Default));
+    }
+
+    return Ok(Validation::Invalid(ErrorMessage::Custom(stderr)));
+}
+
+/// Compiles the patterns of the provided [`ValidationRuleConfig`]s, alongside their messages.
+fn compile_validation_rules(
+    rules: &Vec<ValidationRuleConfig>,
+) -> Result<Vec<(Regex, String)>, PromptError> {
+    return rules
+        .iter()
+        .map(|rule| {
+            let regex =
+                Regex::new(&rule.pattern).map_err(|err| PromptError::InvalidPattern(err))?;
+            Ok((regex, rule.message.clone()))
+        })
+        .collect();
+}
+
+/// Validates the provided value against the provided compiled rules.
+/// The message from the first rule that the value doesn't match is used as the error message.
+fn validate_with_rules(value: &str, rules: &Vec<(Regex, String)>) -> Validation {
+    for (regex, message) in rules {
+        if !regex.is_match(value) {
+            return Validation::Invalid(ErrorMessage::Custom(message.clone()));
+        }
+    }
+
+    return Validation::Valid;
+}
+
+/// Validates that the provided value doesn't have more than the provided number of characters.
+fn validate_length(value: &str, max_length: Option<usize>) -> Validation {
+    if let Some(max_length) = max_length {
+        if value.chars().count() > max_length {
+            return Validation::Invalid(ErrorMessage::Custom(format!(
+                "must be at most {} characters",
+                max_length
+            )));
+        }
+    }
+
+    return Validation::Valid;
+}
+
+/// Counts the number of failed attempts, returning an error once the provided limit is reached.
+fn limit_attempts(
+    validation: Validation,
+    failed_attempts: &Cell<u32>,
+    max_attempts: Option<u32>,
+) -> Result<Validation, PromptError> {
+    if validation == Validation::Valid {
+        return Ok(validation);
+    }
+
+    failed_attempts.set(failed_attempts.get() + 1);
+    if let Some(max_attempts) = max_attempts {
+        if failed_attempts.get() >= max_attempts {
+            return Err(PromptError::TooManyAttempts {
+                attempts: max_attempts,
+            });
         }
     }
+
+    return Ok(validation);
+}
+
+/// Trims and converts the case of the provided input value according to the provided
+/// [`TextPromptOptions`].
+fn normalize_input(value: &str, text_prompt_options: &TextPromptOptions) -> String {
+    let value = if text_prompt_options.trim {
+        value.trim()
+    } else {
+        value
+    };
+
+    return match text_prompt_options.case {
+        Some(TextCase::Lower) => value.to_lowercase(),
+        Some(TextCase::Upper) => value.to_uppercase(),
+        None => value.to_string(),
+    };
 }
 
 fn execute_text_prompt(
     message: &str,
+    help: Option<&str>,
+    default: Option<&str>,
     text_prompt_options: &TextPromptOptions,
+    prompt_symbol: &str,
+    command_executor: &Rc<dyn CommandExecutor>,
 ) -> Result<String, PromptError> {
+    // Compile the rules up-front so that invalid patterns fail before the user is prompted
+    let validation_rules = compile_validation_rules(&text_prompt_options.validate)?;
+    let has_validation = text_prompt_options.validate_with.is_some()
+        || text_prompt_options.max_length.is_some()
+        || !validation_rules.is_empty();
+    let validator = has_validation.then(|| {
+        let command_executor = command_executor.clone();
+        let max_attempts = text_prompt_options.max_attempts;
+        let failed_attempts = Rc::new(Cell::new(0));
+        let text_prompt_options = text_prompt_options.clone();
+        move |value: &str| -> Result<Validation, CustomUserError> {
+            // Validate the value that will actually be used
+            let value = normalize_input(value, &text_prompt_options);
+            let mut validation = validate_length(&value, text_prompt_options.max_length);
+            if validation == Validation::Valid {
+                validation = validate_with_rules(&value, &validation_rules);
+            }
+
+            if validation == Validation::Valid {
+                if let Some(validation_config) = &text_prompt_options.validate_with {
+                    validation = validate_with_command(
+                        &value,
+                        validation_config,
+                        command_executor.as_ref(),
+                    )?;
+                }
+            }
+
+            // Returning an error here will abort the prompt
+            limit_attempts(validation, &failed_attempts, max_attempts).map_err(|err| err.into())
+        }
+    });
+
     let result = if text_prompt_options.sensitive {
-        Password::new(message)
-            .with_display_mode(PasswordDisplayMode::Masked)
-            .without_confirmation()
-            .prompt()
+        let mut prompt = Password::new(message).with_display_mode(PasswordDisplayMode::Masked);
+        if !text_prompt_options.confirm {
+            prompt = prompt.without_confirmation();
+        }
+
+        if let Some(help) = help {
+            prompt = prompt.with_help_message(help);
+        }
+
+        if let Some(validator) = validator {
+            prompt = prompt.with_validator(validator);
+        }
+
+        prompt.prompt()
     } else {
-        Text::new(message).prompt()
+        let prompt_symbol = text_prompt_options
+            .prompt_symbol
+            .as_deref()
+            .unwrap_or(prompt_symbol);
+        let mut prompt = create_text_prompt(message, help, prompt_symbol);
+        if let Some(default) = default {
+            prompt = prompt.with_default(default);
+        }
+
+        if let Some(validator) = validator {
+            prompt = prompt.with_validator(validator);
+        }
+
+        prompt.prompt()
     };
 
     match result {
-        Ok(value) => Ok(value),
+        Ok(value) => Ok(normalize_input(&value, text_prompt_options)),
         Err(err) => Err(PromptError::InquireError(err)),
     }
 }
 
 fn execute_select_prompt(
     message: &str,
+    help: Option<&str>,
+    default: Option<&str>,
     select_prompt_options: &SelectPromptOptions,
-    command_executor: &Box<dyn CommandExecutor>,
+    prompt_symbol: &str,
+    command_executor: &dyn CommandExecutor,
 ) -> Result<String, PromptError> {
-    let options = get_options(&select_prompt_options.options, command_executor)?;
-    let result = Select::new(message, options).prompt();
+    let mut options = get_options(&select_prompt_options.options, command_executor)?;
+    if select_prompt_options.allow_other {
+        options.push(OTHER_OPTION.to_string());
+    }
+
+    let starting_cursor = match default {
+        Some(default) => Some(default_option_indices(&options, &vec![default.to_string()])?[0]),
+        None => None,
+    };
+
+    let mut prompt = Select::new(message, options);
+    if let Some(help) = help {
+        prompt = prompt.with_help_message(help);
+    }
+
+    if let Some(starting_cursor) = starting_cursor {
+        prompt = prompt.with_starting_cursor(starting_cursor);
+    }
+
+    let choice = prompt
+        .prompt()
+        .map_err(|err| PromptError::InquireError(err))?;
+    return resolve_other_option(choice, select_prompt_options.allow_other, || {
+        create_text_prompt(message, help, prompt_symbol)
+            .prompt()
+            .map_err(|err| PromptError::InquireError(err))
+    });
+}
+
+/// Creates a single-line text prompt, displaying the provided symbol before the message.
+fn create_text_prompt<'a>(
+    message: &'a str,
+    help: Option<&'a str>,
+    prompt_symbol: &'a str,
+) -> Text<'a> {
+    let render_config = RenderConfig::default().with_prompt_prefix(Styled::new(prompt_symbol));
+    let mut prompt = Text::new(message).with_render_config(render_config);
+    if let Some(help) = help {
+        prompt = prompt.with_help_message(help);
+    }
+
+    return prompt;
+}
+
+/// Returns the option chosen in a select prompt.
+/// If the "Other..." option was chosen, then the user is prompted for the value instead.
+fn resolve_other_option(
+    choice: String,
+    allow_other: bool,
+    prompt_for_other: impl FnOnce() -> Result<String, PromptError>,
+) -> Result<String, PromptError> {
+    if allow_other && choice == OTHER_OPTION {
+        return prompt_for_other();
+    }
+
+    return Ok(choice);
+}
+
+fn execute_multi_select_prompt(
+    message: &str,
+    help: Option<&str>,
+    multi_select_prompt_options: &MultiSelectPromptOptions,
+    command_executor: &dyn CommandExecutor,
+) -> Result<String, PromptError> {
+    let options = get_options(&multi_select_prompt_options.options, command_executor)?;
+    let default_indices = default_option_indices(&options, &multi_select_prompt_options.defaults)?;
+    let min = multi_select_prompt_options.min;
+    let max = multi_select_prompt_options.max;
+
+    let mut prompt = MultiSelect::new(message, options).with_validator(
+        move |selections: &[ListOption<&String>]| -> Result<Validation, CustomUserError> {
+            Ok(validate_selection_count(selections.len(), min, max))
+        },
+    );
+    if let Some(help) = help {
+        prompt = prompt.with_help_message(help);
+    }
+
+    if !default_indices.is_empty() {
+        prompt = prompt.with_default(&default_indices);
+    }
+
+    let result = prompt.prompt();
     match result {
-        Ok(value) => Ok(value),
+        Ok(values) => Ok(values.join("\n")),
         Err(err) => Err(PromptError::InquireError(err)),
     }
 }
 
+/// Finds the index of each of the provided default values within the options.
+/// Fails if any of the defaults aren't one of the options.
+fn default_option_indices(
+    options: &Vec<String>,
+    defaults: &Vec<String>,
+) -> Result<Vec<usize>, PromptError> {
+    return defaults
+        .iter()
+        .map(|default| {
+            options
+                .iter()
+                .position(|option| option == default)
+                .ok_or_else(|| PromptError::InvalidDefault {
+                    default: default.clone(),
+                })
+        })
+        .collect();
+}
+
+/// Ensures the number of options chosen in a multi-select prompt is within the provided bounds.
+fn validate_selection_count(count: usize, min: Option<usize>, max: Option<usize>) -> Validation {
+    if let Some(min) = min {
+        if count < min {
+            return Validation::Invalid(ErrorMessage::Custom(format!(
+                "Choose at least {} option(s)",
+                min
+            )));
+        }
+    }
+
+    if let Some(max) = max {
+        if count > max {
+            return Validation::Invalid(ErrorMessage::Custom(format!(
+                "Choose at most {} option(s)",
+                max
+            )));
+        }
+    }
+
+    return Validation::Valid;
+}
+
 fn get_options(
     select_options_config: &SelectOptionsConfig,
-    command_executor: &Box<dyn CommandExecutor>,
+    command_executor: &dyn CommandExecutor,
 ) -> Result<Vec<String>, PromptError> {
     match select_options_config {
         SelectOptionsConfig::Literal(options) => Ok(options.clone()),
         SelectOptionsConfig::Execution(execution_config) => {
-            let output = command_executor
-                .get_output(&execution_config.execution, &HashMap::new())
-                .map_err(|err| PromptError::ExecutionError(err))?;
+            // The command may take a while, show a spinner so it doesn't look like we've hung
+            let spinner = Spinner::start("Loading options...");
+            let result = command_executor.get_output(&execution_config.execution, &HashMap::new());
+            spinner.stop();
+
+            let output = result.map_err(|err| PromptError::ExecutionError(err))?;
             let stdout =
                 String::from_utf8(output.stdout).map_err(|err| PromptError::ParseError(err))?;
-            let options = stdout.clone().lines().map(|s| String::from(s)).collect();
+            let options = strip_ansi_escapes(&stdout)
+                .lines()
+                .map(|s| String::from(s))
+                .collect();
             Ok(options)
         }
     }
 }
 
-// This is hard to write tests for. Fow now, let's assume the Inquire crate has sufficient tests.
+// The prompts themselves are hard to write tests for. Fow now, let's assume the Inquire crate has
+// sufficient tests.
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{
+        ExecutionConfigVariant, ExecutionSelectOptionsConfig, RawCommandConfigVariant,
+    };
+    use crate::exec::{ExitStatus, MockCommandExecutor, Output};
+    use std::thread;
+    use std::time::Duration;
+
+    #[test]
+    fn confirm_with_summary_returns_true_when_accepted() {
+        // Arrange
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_confirm()
+            .once()
+            .returning(|_| Ok(true));
+
+        // Act
+        let result = confirm_with_summary(&prompt_executor, "name=Dingus");
+
+        // Assert
+        assert_eq!(result.unwrap(), true);
+    }
+
+    #[test]
+    fn confirm_with_summary_returns_false_when_declined() {
+        // Arrange
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_confirm()
+            .once()
+            .returning(|_| Ok(false));
+
+        // Act
+        let result = confirm_with_summary(&prompt_executor, "name=Dingus");
+
+        // Assert
+        assert_eq!(result.unwrap(), false);
+    }
+
+    #[test]
+    fn read_line_reads_single_line() {
+        // Arrange
+        let mut reader = io::Cursor::new("Dingus\r\nGodzilla\n");
+        let mut writer = Vec::new();
+
+        // Act
+        let value = read_line(&mut reader, &mut writer, "name: ").unwrap();
+
+        // Assert
+        assert_eq!(value, "Dingus");
+        assert_eq!(String::from_utf8(writer).unwrap(), "name: ");
+    }
+
+    #[test]
+    fn read_line_fails_at_end_of_input() {
+        // Arrange
+        let mut reader = io::Cursor::new("");
+        let mut writer = Vec::new();
+
+        // Act
+        let result = read_line(&mut reader, &mut writer, "name: ");
+
+        // Assert
+        assert!(matches!(result, Err(PromptError::EndOfInput)));
+    }
+
+    #[test]
+    fn confirm_phrase_returns_true_for_exact_phrase() {
+        // Arrange
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .withf(|prompt_config| prompt_config.message == "Type \"production\" to continue:")
+            .once()
+            .returning(|_| Ok("production".to_string()));
+
+        // Act
+        let result = confirm_phrase(&prompt_executor, "production");
+
+        // Assert
+        assert_eq!(result.unwrap(), true);
+    }
+
+    #[test]
+    fn confirm_phrase_returns_false_for_mismatched_phrase() {
+        // Arrange
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .once()
+            .returning(|_| Ok("Production".to_string()));
+
+        // Act
+        let result = confirm_phrase(&prompt_executor, "production");
+
+        // Assert
+        assert_eq!(result.unwrap(), false);
+    }
+
+    #[test]
+    fn pick_config_file_offers_discovered_config_files() {
+        // Arrange
+        let config_file_paths = vec![
+            PathBuf::from("/home/dingus/project/api/dingus.yaml"),
+            PathBuf::from("/home/dingus/project/dingus.yaml"),
+        ];
+
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .once()
+            .withf(|prompt_config| {
+                prompt_config.options
+                    == PromptOptionsVariant::Select(SelectPromptOptions {
+                        options: SelectOptionsConfig::Literal(vec![
+                            "/home/dingus/project/api/dingus.yaml".to_string(),
+                            "/home/dingus/project/dingus.yaml".to_string(),
+                        ]),
+                        allow_other: false,
+                    })
+            })
+            .returning(|_| Ok("/home/dingus/project/dingus.yaml".to_string()));
+
+        // Act
+        let result = pick_config_file(&prompt_executor, &config_file_paths);
+
+        // Assert
+        assert_eq!(
+            result.unwrap(),
+            PathBuf::from("/home/dingus/project/dingus.yaml")
+        );
+    }
+
+    #[test]
+    fn pick_config_file_does_not_prompt_for_single_config_file() {
+        // Arrange
+        let config_file_paths = vec![PathBuf::from("/home/dingus/project/dingus.yaml")];
+
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor.expect_execute().times(0);
+
+        // Act
+        let result = pick_config_file(&prompt_executor, &config_file_paths);
+
+        // Assert
+        assert_eq!(result.unwrap(), config_file_paths[0]);
+    }
+
+    fn answers() -> Vec<(String, String)> {
+        return vec![
+            ("name".to_string(), "name: Dingus".to_string()),
+            (
+                "environment".to_string(),
+                "environment: production".to_string(),
+            ),
+        ];
+    }
+
+    #[test]
+    fn review_answers_returns_key_of_chosen_answer() {
+        // Arrange
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .once()
+            .withf(|prompt_config| {
+                prompt_config.options
+                    == PromptOptionsVariant::Select(SelectPromptOptions {
+                        options: SelectOptionsConfig::Literal(vec![
+                            "Continue".to_string(),
+                            "name: Dingus".to_string(),
+                            "environment: production".to_string(),
+                        ]),
+                        allow_other: false,
+                    })
+            })
+            .returning(|_| Ok("environment: production".to_string()));
+
+        // Act
+        let result = review_answers(&prompt_executor, &answers());
+
+        // Assert
+        assert_eq!(result.unwrap(), Some("environment".to_string()));
+    }
+
+    #[test]
+    fn review_answers_returns_none_when_continuing() {
+        // Arrange
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .once()
+            .returning(|_| Ok("Continue".to_string()));
+
+        // Act
+        let result = review_answers(&prompt_executor, &answers());
+
+        // Assert
+        assert_eq!(result.unwrap(), None);
+    }
+
+    fn text_prompt_config(default: Option<&str>, cancel_uses_default: bool) -> PromptConfig {
+        return PromptConfig {
+            message: "What's your name?".to_string(),
+            default: default.map(|default| default.to_string()),
+            cancel_uses_default,
+            options: PromptOptionsVariant::default(),
+            ..Default::default()
+        };
+    }
+
+    #[test]
+    fn cancelling_optional_prompt_uses_default() {
+        // Arrange
+        let prompt_config = text_prompt_config(Some("Dingus"), true);
+        let result = Err(PromptError::InquireError(InquireError::OperationCanceled));
+
+        // Act
+        let result = use_default_if_cancelled(result, &prompt_config);
+
+        // Assert
+        assert_eq!(result.unwrap(), "Dingus");
+    }
+
+    #[test]
+    fn cancelling_required_prompt_aborts() {
+        // Arrange
+        let prompt_config = text_prompt_config(Some("Dingus"), false);
+        let result = Err(PromptError::InquireError(InquireError::OperationCanceled));
+
+        // Act
+        let result = use_default_if_cancelled(result, &prompt_config);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(PromptError::InquireError(InquireError::OperationCanceled))
+        ));
+    }
+
+    #[test]
+    fn interrupting_optional_prompt_aborts() {
+        // Arrange
+        let prompt_config = text_prompt_config(Some("Dingus"), true);
+        let result = Err(PromptError::InquireError(
+            InquireError::OperationInterrupted,
+        ));
+
+        // Act
+        let result = use_default_if_cancelled(result, &prompt_config);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(PromptError::InquireError(
+                InquireError::OperationInterrupted
+            ))
+        ));
+    }
+
+    #[test]
+    fn answered_optional_prompt_uses_answer() {
+        // Arrange
+        let prompt_config = text_prompt_config(Some("Dingus"), true);
+
+        // Act
+        let result = use_default_if_cancelled(Ok("Bingus".to_string()), &prompt_config);
+
+        // Assert
+        assert_eq!(result.unwrap(), "Bingus");
+    }
+
+    fn validation_command() -> ExecutionConfigVariant {
+        return ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+            "git rev-parse --verify $value".to_string(),
+        ));
+    }
+
+    #[test]
+    fn validate_with_command_accepts_value_when_command_succeeds() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .once()
+            .withf(|execution_config, variables| {
+                execution_config == &validation_command()
+                    && variables.get("value") == Some(&"main".to_string())
+            })
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Success,
+                    stdout: vec![],
+                    stderr: vec![],
+                })
+            });
+
+        // Act
+        let result = validate_with_command("main", &validation_command(), &command_executor);
+
+        // Assert
+        assert_eq!(result.unwrap(), Validation::Valid);
+    }
+
+    #[test]
+    fn validate_with_command_rejects_value_with_stderr_when_command_fails() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .once()
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Fail(128),
+                    stdout: vec![],
+                    stderr: "fatal: Needed a single revision\n".as_bytes().to_vec(),
+                })
+            });
+
+        // Act
+        let result = validate_with_command("mian", &validation_command(), &command_executor);
+
+        // Assert
+        assert_eq!(
+            result.unwrap(),
+            Validation::Invalid(ErrorMessage::Custom(
+                "fatal: Needed a single revision".to_string()
+            ))
+        );
+    }
+
+    #[test]
+    fn validate_with_command_rejects_value_without_stderr_when_command_fails() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .once()
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Fail(1),
+                    stdout: vec![],
+                    stderr: vec![],
+                })
+            });
+
+        // Act
+        let result = validate_with_command("mian", &validation_command(), &command_executor);
+
+        // Assert
+        assert_eq!(result.unwrap(), Validation::Invalid(ErrorMessage::Default));
+    }
+
+    #[test]
+    fn validate_length_rejects_long_values() {
+        // Act
+        let short = validate_length("deploy", Some(6));
+        let long = validate_length("deploy!", Some(6));
+        let unlimited = validate_length("deploy!", None);
+
+        // Assert
+        assert_eq!(short, Validation::Valid);
+        assert_eq!(
+            long,
+            Validation::Invalid(ErrorMessage::Custom(
+                "must be at most 6 characters".to_string()
+            ))
+        );
+        assert_eq!(unlimited, Validation::Valid);
+    }
+
+    #[test]
+    fn validate_length_counts_characters() {
+        // Act
+        let validation = validate_length("héllo", Some(5));
+
+        // Assert
+        assert_eq!(validation, Validation::Valid);
+    }
+
+    #[test]
+    fn limit_attempts_allows_retries_until_limit_is_reached() {
+        // Arrange
+        let failed_attempts = Cell::new(0);
+        let invalid = Validation::Invalid(ErrorMessage::Custom("wrong password".to_string()));
+
+        // Act
+        let first = limit_attempts(invalid.clone(), &failed_attempts, Some(3));
+        let second = limit_attempts(invalid.clone(), &failed_attempts, Some(3));
+        let third = limit_attempts(invalid.clone(), &failed_attempts, Some(3));
+
+        // Assert
+        assert_eq!(first.unwrap(), invalid);
+        assert_eq!(second.unwrap(), invalid);
+        assert!(matches!(
+            third,
+            Err(PromptError::TooManyAttempts { attempts: 3 })
+        ));
+    }
+
+    #[test]
+    fn limit_attempts_accepts_valid_value_after_failures() {
+        // Arrange
+        let failed_attempts = Cell::new(0);
+        let invalid = Validation::Invalid(ErrorMessage::Default);
+
+        // Act
+        let first = limit_attempts(invalid.clone(), &failed_attempts, Some(3));
+        let second = limit_attempts(Validation::Valid, &failed_attempts, Some(3));
+
+        // Assert
+        assert_eq!(first.unwrap(), invalid);
+        assert_eq!(second.unwrap(), Validation::Valid);
+        assert_eq!(failed_attempts.get(), 1);
+    }
+
+    #[test]
+    fn limit_attempts_retries_forever_without_limit() {
+        // Arrange
+        let failed_attempts = Cell::new(0);
+        let invalid = Validation::Invalid(ErrorMessage::Default);
+
+        // Act
+        let results: Vec<Result<Validation, PromptError>> = (0..100)
+            .map(|_| limit_attempts(invalid.clone(), &failed_attempts, None))
+            .collect();
+
+        // Assert
+        assert!(results.iter().all(|result| result.is_ok()));
+    }
+
+    #[test]
+    fn validate_with_rules_rejects_values_that_do_not_match() {
+        // Arrange
+        let rules = compile_validation_rules(&vec![
+            ValidationRuleConfig {
+                pattern: "^[0-9]+\\.[0-9]+\\.[0-9]+$".to_string(),
+                message: "Enter a version like 1.2.3".to_string(),
+            },
+            ValidationRuleConfig {
+                pattern: "^[1-9]".to_string(),
+                message: "The major version must be at least 1".to_string(),
+            },
+        ])
+        .unwrap();
+
+        // Act
+        let valid = validate_with_rules("1.2.3", &rules);
+        let malformed = validate_with_rules("latest", &rules);
+        let prerelease = validate_with_rules("0.1.0", &rules);
+
+        // Assert
+        assert_eq!(valid, Validation::Valid);
+        assert_eq!(
+            malformed,
+            Validation::Invalid(ErrorMessage::Custom(
+                "Enter a version like 1.2.3".to_string()
+            ))
+        );
+        assert_eq!(
+            prerelease,
+            Validation::Invalid(ErrorMessage::Custom(
+                "The major version must be at least 1".to_string()
+            ))
+        );
+    }
+
+    #[test]
+    fn compile_validation_rules_fails_for_invalid_patterns() {
+        // Act
+        let result = compile_validation_rules(&vec![ValidationRuleConfig {
+            pattern: "[".to_string(),
+            message: "Never shown".to_string(),
+        }]);
+
+        // Assert
+        assert!(matches!(result, Err(PromptError::InvalidPattern(_))));
+    }
+
+    #[test]
+    fn create_text_prompt_uses_prompt_symbol() {
+        // Act
+        let prompt = create_text_prompt("Name", Some("Your name"), "›");
+
+        // Assert
+        assert_eq!(prompt.render_config.prompt_prefix.content, "›");
+        assert_eq!(prompt.help_message, Some("Your name"));
+    }
+
+    #[test]
+    fn resolve_other_option_prompts_for_other_value() {
+        // Act
+        let result =
+            resolve_other_option(OTHER_OPTION.to_string(), true, || Ok("Mothra".to_string()));
+
+        // Assert
+        assert_eq!(result.unwrap(), "Mothra");
+    }
+
+    #[test]
+    fn resolve_other_option_returns_listed_option() {
+        // Act
+        let result = resolve_other_option("Godzilla".to_string(), true, || {
+            panic!("should not prompt for another value")
+        });
+
+        // Assert
+        assert_eq!(result.unwrap(), "Godzilla");
+    }
+
+    #[test]
+    fn resolve_other_option_ignores_other_when_not_allowed() {
+        // Act
+        let result = resolve_other_option(OTHER_OPTION.to_string(), false, || {
+            panic!("should not prompt for another value")
+        });
+
+        // Assert
+        assert_eq!(result.unwrap(), OTHER_OPTION);
+    }
+
+    #[test]
+    fn default_option_indices_finds_defaults() {
+        // Arrange
+        let options = vec!["api".to_string(), "web".to_string(), "cli".to_string()];
+        let defaults = vec!["cli".to_string(), "api".to_string()];
+
+        // Act
+        let indices = default_option_indices(&options, &defaults);
+
+        // Assert
+        assert_eq!(indices.unwrap(), vec![2, 0]);
+    }
+
+    #[test]
+    fn default_option_indices_fails_for_unknown_default() {
+        // Arrange
+        let options = vec!["api".to_string(), "web".to_string()];
+        let defaults = vec!["docs".to_string()];
+
+        // Act
+        let result = default_option_indices(&options, &defaults);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(PromptError::InvalidDefault { default }) if default == "docs"
+        ));
+    }
+
+    #[test]
+    fn validate_selection_count_enforces_bounds() {
+        // Act
+        let too_few = validate_selection_count(0, Some(1), Some(2));
+        let within_bounds = validate_selection_count(2, Some(1), Some(2));
+        let too_many = validate_selection_count(3, Some(1), Some(2));
+        let unbounded = validate_selection_count(0, None, None);
+
+        // Assert
+        assert_eq!(
+            too_few,
+            Validation::Invalid(ErrorMessage::Custom(
+                "Choose at least 1 option(s)".to_string()
+            ))
+        );
+        assert_eq!(within_bounds, Validation::Valid);
+        assert_eq!(
+            too_many,
+            Validation::Invalid(ErrorMessage::Custom(
+                "Choose at most 2 option(s)".to_string()
+            ))
+        );
+        assert_eq!(unbounded, Validation::Valid);
+    }
+
+    #[test]
+    fn normalize_input_trims_and_converts_case() {
+        // Arrange
+        let mut text_prompt_options = text_prompt_options();
+        text_prompt_options.trim = true;
+        text_prompt_options.case = Some(TextCase::Lower);
+
+        // Act
+        let normalized = normalize_input("  Production \n", &text_prompt_options);
+
+        // Assert
+        assert_eq!(normalized, "production");
+    }
+
+    #[test]
+    fn normalize_input_converts_to_upper_case_without_trimming() {
+        // Arrange
+        let mut text_prompt_options = text_prompt_options();
+        text_prompt_options.case = Some(TextCase::Upper);
+
+        // Act
+        let normalized = normalize_input(" us-east ", &text_prompt_options);
+
+        // Assert
+        assert_eq!(normalized, " US-EAST ");
+    }
+
+    #[test]
+    fn normalize_input_returns_input_as_is_by_default() {
+        // Act
+        let normalized = normalize_input(" Dingus ", &text_prompt_options());
+
+        // Assert
+        assert_eq!(normalized, " Dingus ");
+    }
+
+    #[test]
+    fn get_options_returns_literal_options() {
+        // Arrange
+        let command_executor: Box<dyn CommandExecutor> = Box::new(MockCommandExecutor::new());
+        let options = vec!["Alice".to_string(), "Bob".to_string()];
+
+        // Act
+        let result = get_options(
+            &SelectOptionsConfig::Literal(options.clone()),
+            command_executor.as_ref(),
+        );
+
+        // Assert
+        assert_eq!(result.unwrap(), options);
+    }
+
+    #[test]
+    fn get_options_resolves_slow_execution_options() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .once()
+            .returning(|_, _| {
+                thread::sleep(Duration::from_millis(250));
+                Ok(Output {
+                    status: ExitStatus::Success,
+                    stdout: "Alice\n\x1b[31mBob\x1b[0m\nCharlie\n".as_bytes().to_vec(),
+                    stderr: vec![],
+                })
+            });
+        let command_executor: Box<dyn CommandExecutor> = Box::new(command_executor);
+
+        let options_config = SelectOptionsConfig::Execution(ExecutionSelectOptionsConfig {
+            execution: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                "cat names.txt".to_string(),
+            )),
+        });
+
+        // Act
+        let result = get_options(&options_config, command_executor.as_ref());
+
+        // Assert
+        assert_eq!(
+            result.unwrap(),
+            vec![
+                "Alice".to_string(),
+                "Bob".to_string(),
+                "Charlie".to_string()
+            ]
+        );
+    }
+
+    fn text_prompt_options() -> TextPromptOptions {
+        return TextPromptOptions {
+            multi_line: false,
+            sensitive: false,
+            validate_with: None,
+            max_attempts: None,
+            trim: false,
+            case: None,
+            confirm: false,
+            validate: vec![],
+            prompt_symbol: None,
+            max_length: None,
+        };
+    }
+}
@@ -0,0 +1,133 @@
+use regex::bytes::Regex;
+use std::io;
+use std::io::{BufRead, BufReader, Read, Write};
+
+/// Hard coded value used in place of redacted text.
+const REDACTED_VALUE: &[u8] = b"***";
+
+/// Replaces any text matching a set of patterns with `***`.
+/// Cloning is cheap, since the compiled patterns are shared between clones.
+#[derive(Clone)]
+pub struct Redactor {
+    patterns: Vec<Regex>,
+}
+
+impl Redactor {
+    /// Creates a new [`Redactor`] for the provided regular expressions.
+    pub fn new(patterns: &Vec<String>) -> Result<Redactor, regex::Error> {
+        let patterns = patterns
+            .iter()
+            .map(|pattern| Regex::new(pattern))
+            .collect::<Result<Vec<Regex>, regex::Error>>()?;
+
+        return Ok(Redactor { patterns });
+    }
+
+    /// Returns a copy of the provided text with any matches redacted.
+    pub fn redact(&self, text: &[u8]) -> Vec<u8> {
+        let mut redacted = text.to_vec();
+        for pattern in &self.patterns {
+            redacted = pattern.replace_all(&redacted, REDACTED_VALUE).to_vec();
+        }
+
+        return redacted;
+    }
+
+    /// Copies everything from the provided reader into the provided writer, redacting any matches
+    /// along the way.
+    /// Text is redacted one line at a time so that matches split across multiple reads are still
+    /// redacted.
+    pub fn redact_stream<R: Read, W: Write>(&self, reader: R, mut writer: W) -> io::Result<()> {
+        let mut reader = BufReader::new(reader);
+        let mut line = Vec::new();
+        loop {
+            line.clear();
+            if reader.read_until(b'\n', &mut line)? == 0 {
+                return Ok(());
+            }
+
+            writer.write_all(&self.redact(&line))?;
+            writer.flush()?;
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// A reader which only returns a few bytes at a time, simulating output arriving in chunks.
+    struct ChunkedReader {
+        data: Vec<u8>,
+        position: usize,
+        chunk_size: usize,
+    }
+
+    impl Read for ChunkedReader {
+        fn read(&mut self, buf: &mut [u8]) -> io::Result<usize> {
+            let remaining = self.data.len() - self.position;
+            let count = remaining.min(self.chunk_size).min(buf.len());
+            buf[..count].copy_from_slice(&self.data[self.position..self.position + count]);
+            self.position += count;
+            return Ok(count);
+        }
+    }
+
+    #[test]
+    fn redact_replaces_matches() {
+        // Arrange
+        let redactor = Redactor::new(&vec!["ghp_[A-Za-z0-9]+".to_string()]).unwrap();
+
+        // Act
+        let redacted = redactor.redact(b"token: ghp_abc123, other: ghp_def456");
+
+        // Assert
+        assert_eq!(redacted, b"token: ***, other: ***".to_vec());
+    }
+
+    #[test]
+    fn redact_supports_multiple_patterns() {
+        // Arrange
+        let redactor = Redactor::new(&vec![
+            "ghp_[A-Za-z0-9]+".to_string(),
+            "password=\\S+".to_string(),
+        ])
+        .unwrap();
+
+        // Act
+        let redacted = redactor.redact(b"ghp_abc123 password=hunter2");
+
+        // Assert
+        assert_eq!(redacted, b"*** ***".to_vec());
+    }
+
+    #[test]
+    fn redact_stream_redacts_matches_across_reads() {
+        // Arrange
+        let redactor = Redactor::new(&vec!["ghp_[A-Za-z0-9]+".to_string()]).unwrap();
+        let reader = ChunkedReader {
+            data: b"Logging in with ghp_abc123def456\nDone, no tokens here\nghp_zzz".to_vec(),
+            position: 0,
+            chunk_size: 3,
+        };
+        let mut output = Vec::new();
+
+        // Act
+        redactor.redact_stream(reader, &mut output).unwrap();
+
+        // Assert
+        assert_eq!(
+            String::from_utf8(output).unwrap(),
+            "Logging in with ***\nDone, no tokens here\n***"
+        );
+    }
+
+    #[test]
+    fn new_fails_for_invalid_pattern() {
+        // Act
+        let result = Redactor::new(&vec!["ghp_[".to_string()]);
+
+        // Assert
+        assert!(result.is_err());
+    }
+}
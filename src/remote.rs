@@ -0,0 +1,670 @@
+use linked_hash_map::LinkedHashMap;
+use sha2::{Digest, Sha256};
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+use std::{env, fs, io};
+use thiserror::Error;
+
+const HTTPS_SCHEME: &str = "https://";
+const HTTP_SCHEME: &str = "http://";
+
+/// The maximum amount of time to wait for a remote config to download.
+const FETCH_TIMEOUT_SECONDS: u64 = 10;
+
+/// Returns `true` if the provided location refers to a remote config.
+pub fn is_remote(location: &str) -> bool {
+    return location.starts_with(HTTPS_SCHEME) || location.starts_with(HTTP_SCHEME);
+}
+
+/// Downloads the config text from the provided URL.
+/// Successful downloads are cached so that the config can still be used when offline. The ETag of
+/// the cached copy is sent along with the request, so the config is only downloaded again once it
+/// has changed.
+///
+/// Plain HTTP URLs are rejected unless `allow_insecure` is `true`.
+/// When a `checksum` is provided, the SHA-256 hash of the config must match it.
+pub fn fetch_config(
+    url: &str,
+    allow_insecure: bool,
+    checksum: Option<&String>,
+) -> Result<String, RemoteError> {
+    if url.starts_with(HTTP_SCHEME) && !allow_insecure {
+        return Err(RemoteError::Insecure {
+            url: url.to_string(),
+        });
+    }
+
+    let cache_path = cache_path_for(url)?;
+    return fetch_with_cache(url, &cache_path, checksum, &download);
+}
+
+/// The result of downloading a remote config.
+enum Download {
+    /// The config has changed since the provided ETag, or no ETag was provided.
+    Modified { text: String, etag: Option<String> },
+
+    /// The config hasn't changed since the provided ETag.
+    NotModified,
+}
+
+fn fetch_with_cache(
+    url: &str,
+    cache_path: &Path,
+    checksum: Option<&String>,
+    download: &dyn Fn(&str, Option<&str>) -> Result<Download, RemoteError>,
+) -> Result<String, RemoteError> {
+    let etag_path = cache_path.with_extension("etag");
+    let cached_text = fs::read_to_string(cache_path).ok();
+
+    // Only ask the server whether the config has changed if there's a cached copy to fall back on
+    let etag = match &cached_text {
+        Some(_) => fs::read_to_string(&etag_path).ok(),
+        None => None,
+    };
+
+    match download(url, etag.as_deref()) {
+        Ok(Download::Modified { text, etag }) => {
+            verify_checksum(url, &text, checksum)?;
+
+            if let Some(cache_directory) = cache_path.parent() {
+                fs::create_dir_all(cache_directory).map_err(|err| RemoteError::Cache(err))?;
+            }
+
+            fs::write(cache_path, &text).map_err(|err| RemoteError::Cache(err))?;
+            match etag {
+                Some(etag) => fs::write(&etag_path, etag).map_err(|err| RemoteError::Cache(err))?,
+
+                // An old ETag would no longer match the cached copy
+                None => match fs::remove_file(&etag_path) {
+                    Err(err) if err.kind() != io::ErrorKind::NotFound => {
+                        return Err(RemoteError::Cache(err))
+                    }
+                    _ => {}
+                },
+            }
+
+            return Ok(text);
+        }
+
+        Ok(Download::NotModified) => {
+            let Some(text) = cached_text else {
+                return Err(RemoteError::DownloadFailed {
+                    url: url.to_string(),
+                    reason: "the config was not modified, but there is no cached copy".to_string(),
+                });
+            };
+
+            verify_checksum(url, &text, checksum)?;
+            return Ok(text);
+        }
+
+        Err(err) => {
+            // Fall back to the last downloaded copy so we can still work offline
+            let Some(text) = cached_text else {
+                return Err(err);
+            };
+
+            eprintln!("{err}, using cached copy instead");
+            verify_checksum(url, &text, checksum)?;
+            return Ok(text);
+        }
+    }
+}
+
+/// Ensures the SHA-256 hash of the provided text matches the `checksum`, if one was provided.
+fn verify_checksum(url: &str, text: &str, checksum: Option<&String>) -> Result<(), RemoteError> {
+    let Some(checksum) = checksum else {
+        return Ok(());
+    };
+
+    let actual = sha256_hex(text);
+    if !actual.eq_ignore_ascii_case(checksum.trim()) {
+        return Err(RemoteError::ChecksumMismatch {
+            url: url.to_string(),
+            expected: checksum.clone(),
+            actual,
+        });
+    }
+
+    return Ok(());
+}
+
+fn sha256_hex(text: &str) -> String {
+    return format!("{:x}", Sha256::digest(text.as_bytes()));
+}
+
+/// Fetches a value from the JSON returned by the provided URL.
+/// The value is found by following the dot-separated `json_path` through the response, where
+/// numeric segments index into arrays.
+/// When no `json_path` is provided, the entire response is returned.
+pub fn fetch_json_value(
+    url: &str,
+    headers: &LinkedHashMap<String, String>,
+    json_path: &Option<String>,
+) -> Result<String, RemoteError> {
+    let text = download_with_headers(url, headers)?;
+    let Some(json_path) = json_path else {
+        return Ok(text.trim_end().to_string());
+    };
+
+    let json: serde_json::Value =
+        serde_json::from_str(text.as_str()).map_err(|err| RemoteError::InvalidJson {
+            url: url.to_string(),
+            source: err,
+        })?;
+
+    let value = find_json_value(&json, json_path).ok_or_else(|| RemoteError::JsonPathNotFound {
+        url: url.to_string(),
+        path: json_path.clone(),
+    })?;
+
+    return json_value_to_string(value).ok_or_else(|| RemoteError::UnsupportedJsonValue {
+        path: json_path.clone(),
+    });
+}
+
+fn find_json_value<'a>(json: &'a serde_json::Value, path: &str) -> Option<&'a serde_json::Value> {
+    let mut value = json;
+    for segment in path.split('.') {
+        value = match segment.parse::<usize>() {
+            Ok(index) => value.get(index).or_else(|| value.get(segment))?,
+            Err(_) => value.get(segment)?,
+        };
+    }
+
+    return Some(value);
+}
+
+fn json_value_to_string(value: &serde_json::Value) -> Option<String> {
+    if let Some(text) = value.as_str() {
+        return Some(text.to_string());
+    }
+
+    if let Some(boolean) = value.as_bool() {
+        return Some(boolean.to_string());
+    }
+
+    if let Some(number) = value.as_i64() {
+        return Some(number.to_string());
+    }
+
+    if let Some(number) = value.as_f64() {
+        return Some(number.to_string());
+    }
+
+    if value.is_null() {
+        return Some(String::new());
+    }
+
+    // Objects and arrays can't be used as a variable's value
+    return None;
+}
+
+fn download(url: &str, etag: Option<&str>) -> Result<Download, RemoteError> {
+    let mut headers = LinkedHashMap::new();
+    if let Some(etag) = etag {
+        headers.insert("If-None-Match".to_string(), etag.to_string());
+    }
+
+    let response = send_request(url, &headers)?;
+    if response.status() == 304 {
+        return Ok(Download::NotModified);
+    }
+
+    let etag = response.header("ETag").map(|etag| etag.to_string());
+    let text = read_text(url, response)?;
+    return Ok(Download::Modified { text, etag });
+}
+
+fn download_with_headers(
+    url: &str,
+    headers: &LinkedHashMap<String, String>,
+) -> Result<String, RemoteError> {
+    let response = send_request(url, headers)?;
+    return read_text(url, response);
+}
+
+fn send_request(
+    url: &str,
+    headers: &LinkedHashMap<String, String>,
+) -> Result<ureq::Response, RemoteError> {
+    let agent = ureq::AgentBuilder::new()
+        .timeout(Duration::from_secs(FETCH_TIMEOUT_SECONDS))
+        .build();
+
+    let mut request = agent.get(url);
+    for (name, value) in headers {
+        request = request.set(name, value);
+    }
+
+    return request.call().map_err(|err| RemoteError::DownloadFailed {
+        url: url.to_string(),
+        reason: match err {
+            ureq::Error::Status(status, _) => format!("server responded with status {status}"),
+            ureq::Error::Transport(transport) => transport.to_string(),
+        },
+    });
+}
+
+fn read_text(url: &str, response: ureq::Response) -> Result<String, RemoteError> {
+    return response.into_string().map_err(|err| {
+        if err.kind() == io::ErrorKind::InvalidData {
+            return RemoteError::InvalidText {
+                url: url.to_string(),
+            };
+        }
+
+        return RemoteError::IO(err);
+    });
+}
+
+fn cache_path_for(url: &str) -> Result<PathBuf, RemoteError> {
+    let Some(cache_directory) = cache_directory() else {
+        return Err(RemoteError::NoCacheDirectory);
+    };
+
+    return Ok(cache_directory.join("dingus").join(cache_file_name(url)));
+}
+
+/// Returns the name of the file to cache the config from the provided URL in.
+/// The name is derived from a stable hash so that it doesn't change between versions of dingus.
+fn cache_file_name(url: &str) -> String {
+    return format!("{}.yaml", sha256_hex(url));
+}
+
+fn cache_directory() -> Option<PathBuf> {
+    if let Some(cache_home) = env::var_os("XDG_CACHE_HOME") {
+        return Some(PathBuf::from(cache_home));
+    }
+
+    if cfg!(windows) {
+        return env::var_os("LOCALAPPDATA").map(|local_app_data| PathBuf::from(local_app_data));
+    }
+
+    return env::var_os("HOME").map(|home| PathBuf::from(home).join(".cache"));
+}
+
+#[derive(Error, Debug)]
+pub enum RemoteError {
+    #[error("refusing to download {url} over plain HTTP, use --allow-insecure-config to allow it")]
+    Insecure { url: String },
+
+    #[error("failed to download {url}: {reason}")]
+    DownloadFailed { url: String, reason: String },
+
+    #[error("{url} did not return valid UTF-8 text")]
+    InvalidText { url: String },
+
+    #[error("{url} did not return valid JSON")]
+    InvalidJson {
+        url: String,
+        #[source]
+        source: serde_json::Error,
+    },
+
+    #[error("could not find {path} in the response from {url}")]
+    JsonPathNotFound { url: String, path: String },
+
+    #[error("{path} must refer to a string, number, or boolean")]
+    UnsupportedJsonValue { path: String },
+
+    #[error("the SHA-256 checksum of {url} is {actual}, expected {expected}")]
+    ChecksumMismatch {
+        url: String,
+        expected: String,
+        actual: String,
+    },
+
+    #[error("could not determine a directory to cache remote configs in")]
+    NoCacheDirectory,
+
+    #[error("failed to cache remote config")]
+    Cache(#[source] io::Error),
+
+    #[error(transparent)]
+    IO(io::Error),
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::io::{Read, Write};
+    use std::net::TcpListener;
+    use std::thread;
+    use tempfile::TempDir;
+
+    const CONFIG_TEXT: &str = "commands:
+    greet:
+        action: echo Hello!";
+
+    const CONFIG_SHA256: &str = "89a181632bd8e92604250e19e2320076e8aabba207a9ae030e8cd42e84d1dbed";
+
+    const CONFIG_ETAG: &str = "\"v1\"";
+
+    #[test]
+    fn fetch_config_rejects_http_without_opt_in() {
+        // Act
+        let result = fetch_config("http://example.com/dingus.yaml", false, None);
+
+        // Assert
+        assert!(matches!(result, Err(RemoteError::Insecure { .. })));
+    }
+
+    #[test]
+    fn fetch_with_cache_caches_downloaded_config() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let cache_path = temp_dir.path().join("dingus").join("config.yaml");
+
+        // Act
+        let result = fetch_with_cache(
+            "https://example.com/dingus.yaml",
+            &cache_path,
+            None,
+            &|_, _| {
+                Ok(Download::Modified {
+                    text: CONFIG_TEXT.to_string(),
+                    etag: Some(CONFIG_ETAG.to_string()),
+                })
+            },
+        );
+
+        // Assert
+        assert_eq!(result.unwrap(), CONFIG_TEXT);
+        assert_eq!(fs::read_to_string(&cache_path).unwrap(), CONFIG_TEXT);
+        assert_eq!(
+            fs::read_to_string(cache_path.with_extension("etag")).unwrap(),
+            CONFIG_ETAG
+        );
+    }
+
+    #[test]
+    fn fetch_with_cache_uses_cache_when_not_modified() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let cache_path = temp_dir.path().join("config.yaml");
+        fs::write(&cache_path, CONFIG_TEXT).unwrap();
+        fs::write(cache_path.with_extension("etag"), CONFIG_ETAG).unwrap();
+
+        // Act
+        let result = fetch_with_cache(
+            "https://example.com/dingus.yaml",
+            &cache_path,
+            None,
+            &|_, etag| {
+                assert_eq!(etag, Some(CONFIG_ETAG));
+                Ok(Download::NotModified)
+            },
+        );
+
+        // Assert
+        assert_eq!(result.unwrap(), CONFIG_TEXT);
+    }
+
+    #[test]
+    fn fetch_with_cache_falls_back_to_cache_when_offline() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let cache_path = temp_dir.path().join("config.yaml");
+        fs::write(&cache_path, CONFIG_TEXT).unwrap();
+
+        // Act
+        let result = fetch_with_cache(
+            "https://example.com/dingus.yaml",
+            &cache_path,
+            None,
+            &|url, _| {
+                Err(RemoteError::DownloadFailed {
+                    url: url.to_string(),
+                    reason: "offline".to_string(),
+                })
+            },
+        );
+
+        // Assert
+        assert_eq!(result.unwrap(), CONFIG_TEXT);
+    }
+
+    #[test]
+    fn fetch_with_cache_fails_when_offline_without_cache() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let cache_path = temp_dir.path().join("config.yaml");
+
+        // Act
+        let result = fetch_with_cache(
+            "https://example.com/dingus.yaml",
+            &cache_path,
+            None,
+            &|url, etag| {
+                assert_eq!(etag, None);
+                Err(RemoteError::DownloadFailed {
+                    url: url.to_string(),
+                    reason: "offline".to_string(),
+                })
+            },
+        );
+
+        // Assert
+        assert!(matches!(result, Err(RemoteError::DownloadFailed { .. })));
+    }
+
+    #[test]
+    fn fetch_with_cache_accepts_matching_checksum() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let cache_path = temp_dir.path().join("config.yaml");
+        let checksum = CONFIG_SHA256.to_uppercase();
+
+        // Act
+        let result = fetch_with_cache(
+            "https://example.com/dingus.yaml",
+            &cache_path,
+            Some(&checksum),
+            &|_, _| {
+                Ok(Download::Modified {
+                    text: CONFIG_TEXT.to_string(),
+                    etag: None,
+                })
+            },
+        );
+
+        // Assert
+        assert_eq!(result.unwrap(), CONFIG_TEXT);
+    }
+
+    #[test]
+    fn fetch_with_cache_rejects_checksum_mismatch() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let cache_path = temp_dir.path().join("config.yaml");
+        let checksum = "0".repeat(64);
+
+        // Act
+        let result = fetch_with_cache(
+            "https://example.com/dingus.yaml",
+            &cache_path,
+            Some(&checksum),
+            &|_, _| {
+                Ok(Download::Modified {
+                    text: CONFIG_TEXT.to_string(),
+                    etag: None,
+                })
+            },
+        );
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(RemoteError::ChecksumMismatch { actual, .. }) if actual == CONFIG_SHA256
+        ));
+        assert!(!cache_path.exists());
+    }
+
+    #[test]
+    fn cache_file_name_is_stable() {
+        // Act
+        let file_name = cache_file_name("https://example.com/dingus.yaml");
+
+        // Assert
+        assert_eq!(
+            file_name,
+            "284856626d02e3532f374b159f61816dea5dfb29245896bdc5035d3091e45b1d.yaml"
+        );
+    }
+
+    #[test]
+    fn download_fetches_config_from_server() {
+        // Arrange
+        let (url, server) = serve_once(
+            "200 OK",
+            format!("ETag: {CONFIG_ETAG}\r\n").as_str(),
+            CONFIG_TEXT,
+        );
+
+        // Act
+        let result = download(url.as_str(), None);
+        server.join().unwrap();
+
+        // Assert
+        let Ok(Download::Modified { text, etag }) = result else {
+            panic!("expected the config to be downloaded");
+        };
+        assert_eq!(text, CONFIG_TEXT);
+        assert_eq!(etag, Some(CONFIG_ETAG.to_string()));
+    }
+
+    #[test]
+    fn download_sends_etag() {
+        // Arrange
+        let (url, server) = serve_once("304 Not Modified", "", "");
+
+        // Act
+        let result = download(url.as_str(), Some(CONFIG_ETAG));
+        let request = server.join().unwrap();
+
+        // Assert
+        assert!(matches!(result, Ok(Download::NotModified)));
+        assert!(request.contains(format!("If-None-Match: {CONFIG_ETAG}").as_str()));
+    }
+
+    #[test]
+    fn fetch_json_value_extracts_value_at_path() {
+        // Arrange
+        let (url, server) = serve_once("200 OK", "", r#"{"releases": [{"tag_name": "v1.2.3"}]}"#);
+        let mut headers = LinkedHashMap::new();
+        headers.insert("Authorization".to_string(), "Bearer secret".to_string());
+
+        // Act
+        let result = fetch_json_value(
+            url.as_str(),
+            &headers,
+            &Some("releases.0.tag_name".to_string()),
+        );
+        let request = server.join().unwrap();
+
+        // Assert
+        assert_eq!(result.unwrap(), "v1.2.3");
+        assert!(request.contains("Authorization: Bearer secret"));
+    }
+
+    #[test]
+    fn fetch_json_value_fails_for_missing_path() {
+        // Arrange
+        let (url, server) = serve_once("200 OK", "", r#"{"name": "Dingus"}"#);
+
+        // Act
+        let result = fetch_json_value(
+            url.as_str(),
+            &LinkedHashMap::new(),
+            &Some("version".to_string()),
+        );
+        server.join().unwrap();
+
+        // Assert
+        assert!(matches!(result, Err(RemoteError::JsonPathNotFound { .. })));
+    }
+
+    #[test]
+    fn fetch_json_value_fails_for_server_error() {
+        // Arrange
+        let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+        let address = listener.local_addr().unwrap();
+        let server = thread::spawn(move || {
+            let (mut stream, _) = listener.accept().unwrap();
+            let mut request = [0; 1024];
+            let _ = stream.read(&mut request).unwrap();
+            stream
+                .write_all(b"HTTP/1.1 500 Internal Server Error\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+                .unwrap();
+        });
+
+        // Act
+        let result = fetch_json_value(
+            format!("http://{address}/").as_str(),
+            &LinkedHashMap::new(),
+            &None,
+        );
+        server.join().unwrap();
+
+        // Assert
+        assert!(matches!(result, Err(RemoteError::DownloadFailed { .. })));
+    }
+
+    #[test]
+    fn json_value_to_string_supports_scalars() {
+        // Arrange
+        let json: serde_json::Value = serde_json::from_str(
+            r#"{"name": "Dingus", "count": 42, "ratio": 0.5, "enabled": true, "missing": null, "items": []}"#,
+        )
+        .unwrap();
+
+        // Act
+        let values: Vec<Option<String>> = ["name", "count", "ratio", "enabled", "missing", "items"]
+            .iter()
+            .map(|path| json_value_to_string(find_json_value(&json, path).unwrap()))
+            .collect();
+
+        // Assert
+        assert_eq!(
+            values,
+            vec![
+                Some("Dingus".to_string()),
+                Some("42".to_string()),
+                Some("0.5".to_string()),
+                Some("true".to_string()),
+                Some("".to_string()),
+                None,
+            ]
+        );
+    }
+
+    /// Serves the provided body to a single request with the provided status and headers, returning
+    /// the URL to request and a handle which returns the request that was received.
+    /// Each header must end with `\r\n`.
+    fn serve_once(status: &str, headers: &str, body: &str) -> (String, thread::JoinHandle<String>) {
+        let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+        let address = listener.local_addr().unwrap();
+        let status = status.to_string();
+        let headers = headers.to_string();
+        let body = body.to_string();
+        let server = thread::spawn(move || {
+            let (mut stream, _) = listener.accept().unwrap();
+            let mut request = [0; 1024];
+            let count = stream.read(&mut request).unwrap();
+
+            let response = format!(
+                "HTTP/1.1 {}\r\n{}Content-Length: {}\r\nConnection: close\r\n\r\n{}",
+                status,
+                headers,
+                body.len(),
+                body
+            );
+            stream.write_all(response.as_bytes()).unwrap();
+
+            return String::from_utf8_lossy(&request[..count]).to_string();
+        });
+
+        return (format!("http://{address}/dingus.yaml"), server);
+    }
+}
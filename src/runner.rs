@@ -0,0 +1,560 @@
+use crate::actions;
+use crate::actions::{ActionError, ActionExecutor, LogFormat};
+use crate::args::{ArgumentResolver, ClapArgumentResolver};
+use crate::cli::find_command_by_name;
+use crate::config::{
+    ActionConfig, CommandConfig, CommandConfigMap, DingusOptions, ExecutionConfigVariant,
+    VariableConfigMap,
+};
+use crate::exec;
+use crate::exec::{create_command_executor, ExecutionError};
+use crate::keyring::system_keyring;
+use crate::lock;
+use crate::lock::{CommandLock, LockError};
+use crate::preconditions;
+use crate::preconditions::PreconditionError;
+use crate::prompt;
+use crate::prompt::{PromptError, TerminalPromptExecutor};
+use crate::redact::Redactor;
+use crate::variables;
+use crate::variables::{
+    RealVariableResolver, VariableMap, VariableResolutionError, VariableResolver,
+};
+use clap::ArgMatches;
+use linked_hash_map::LinkedHashMap;
+use mockall::automock;
+use std::collections::HashMap;
+use std::{env, io};
+use thiserror::Error;
+
+/// Capable of running the commands from the config in full, with their own variables and settings.
+#[automock]
+pub trait CommandRunner {
+    /// Runs the command at the provided path, where subcommands are separated by spaces.
+    /// The call stack contains the commands which led to this one, ending with the command itself,
+    /// so that calls back to any of them can be reported as a cycle.
+    /// When `run_dependencies` is `false`, the dependencies of the command are expected to have
+    /// been run already.
+    fn run(
+        &self,
+        command_path: &String,
+        call_stack: &Vec<String>,
+        run_dependencies: bool,
+    ) -> Result<(), RunError>;
+}
+
+/// Runs the commands from a [`crate::config::Config`] the same way they're run from the command-line: their
+/// preconditions are checked and their variables are resolved before their action is executed
+/// with their own environment and working directory.
+/// Relative paths are resolved from the current directory, which is expected to be the directory
+/// containing the config file.
+#[derive(Clone)]
+pub struct ConfigCommandRunner {
+    /// The top-level commands.
+    pub commands: CommandConfigMap,
+
+    /// The root-level variables, available to all commands.
+    pub variables: VariableConfigMap,
+
+    /// The root-level environment variables, set for all commands.
+    pub env: LinkedHashMap<String, String>,
+
+    /// The root-level working directory, used by commands which don't specify their own.
+    pub working_directory: Option<String>,
+
+    pub options: DingusOptions,
+
+    /// The arguments provided for the root-level variables, which every command can use.
+    pub root_arg_matches: ArgMatches,
+
+    /// Values provided with `--var`, which take precedence over everything else.
+    pub variable_overrides: HashMap<String, String>,
+
+    /// Whether commands should be printed rather than executed.
+    pub dry_run: bool,
+
+    /// The [`LogFormat`] to use when logging each step.
+    pub log_format: LogFormat,
+}
+
+/// A command whose preconditions have passed and whose variables have been resolved, ready to be
+/// executed.
+pub struct PreparedCommand {
+    command_config: CommandConfig,
+    action: ActionConfig,
+
+    /// The [`VariableConfigMap`] that the variables were resolved from.
+    pub variable_configs: VariableConfigMap,
+
+    /// The resolved variables, before the environment of the command has been applied.
+    pub variables: VariableMap,
+
+    options: DingusOptions,
+    redactor: Option<Redactor>,
+
+    /// Held until the command has finished so that it can't run more than once at a time.
+    lock: Option<CommandLock>,
+}
+
+impl ConfigCommandRunner {
+    /// Checks the preconditions of the provided command, then resolves its variables from the
+    /// provided [`VariableConfigMap`] using the provided [`ArgumentResolver`].
+    pub fn prepare(
+        &self,
+        command_path: &String,
+        command_config: &CommandConfig,
+        variable_configs: &VariableConfigMap,
+        argument_resolver: Box<dyn ArgumentResolver>,
+    ) -> Result<PreparedCommand, RunError> {
+        let Some(action) = command_config.action.clone() else {
+            return Err(RunError::CommandNotFound {
+                name: command_path.clone(),
+            });
+        };
+
+        // Commands can override the shell used to execute them
+        let mut options = self.options.clone();
+        if let Some(shell) = &command_config.shell {
+            options.shell = shell.clone();
+        }
+
+        // Build the redactor once so that the action and its log redact the same text
+        let redactor = exec::create_redactor(&options).map_err(|err| RunError::Execution(err))?;
+
+        // Check the preconditions before prompting for anything
+        let precondition_executor =
+            exec::create_action_command_executor(&options, None, redactor.clone(), None);
+        preconditions::check_preconditions(
+            &command_config.preconditions,
+            precondition_executor.as_ref(),
+        )
+        .map_err(|err| RunError::Precondition(err))?;
+
+        // Hold the lock until the command has finished so that it can't run more than once
+        // at a time. Dry runs don't change anything, so they don't need it.
+        let lock = match command_config.lock && !self.dry_run {
+            true => {
+                let current_dir = env::current_dir().map_err(|err| RunError::IO(err))?;
+                let command_names = command_path
+                    .split_whitespace()
+                    .map(|name| name.to_string())
+                    .collect();
+                let lock_path = lock::lock_path(&current_dir, &command_names);
+                Some(
+                    CommandLock::acquire(&lock_path, command_config.wait_for_lock)
+                        .map_err(|err| RunError::Lock(err))?,
+                )
+            }
+            false => None,
+        };
+
+        let mut variable_configs = variable_configs.clone();
+
+        // Skip any prompts that the action doesn't need
+        if self.options.lazy_prompts {
+            if let Some(mut templates) = actions::command_templates(&action) {
+                // Hooks can reference prompts too
+                templates.extend(
+                    actions::hooks(&command_config.before)
+                        .iter()
+                        .chain(actions::hooks(&command_config.after).iter())
+                        .map(|hook| hook.execution().command_template()),
+                );
+
+                variable_configs =
+                    variables::remove_unreferenced_prompts(&variable_configs, &templates);
+            }
+        }
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: create_command_executor(&options),
+            prompt_executor: Box::new(
+                TerminalPromptExecutor::new(create_command_executor(&options))
+                    .with_prompt_symbol(&options.prompt_symbol),
+            ),
+            argument_resolver,
+            keyring: system_keyring(),
+            dingus_options: options.clone(),
+        };
+
+        let mut variables = variable_resolver
+            .resolve_variables(&variable_configs)
+            .map_err(|err| RunError::Variables(err))?;
+        variables::expand_variables(&mut variables, command_config.render_passes);
+
+        return Ok(PreparedCommand {
+            command_config: command_config.clone(),
+            action,
+            variable_configs,
+            variables,
+            options,
+            redactor,
+            lock,
+        });
+    }
+
+    /// Asks the user to confirm the provided [`PreparedCommand`] if it needs to be confirmed, then
+    /// executes its action with its own environment and working directory.
+    /// The command's own notification command is used if it has one, otherwise the provided one
+    /// is used.
+    pub fn execute(
+        &self,
+        prepared_command: PreparedCommand,
+        call_stack: Vec<String>,
+        argument_resolver: Box<dyn ArgumentResolver>,
+        default_notify: Option<&ExecutionConfigVariant>,
+    ) -> Result<(), RunError> {
+        let PreparedCommand {
+            command_config,
+            action,
+            variable_configs,
+            mut variables,
+            mut options,
+            redactor,
+            lock: _lock,
+        } = prepared_command;
+
+        let prompt_executor = TerminalPromptExecutor::new(create_command_executor(&options))
+            .with_prompt_symbol(&options.prompt_symbol);
+
+        if command_config.confirm_with_summary {
+            let summary = variables::summarise_variables(&variable_configs, &variables);
+            let confirmed = prompt::confirm_with_summary(&prompt_executor, &summary)
+                .map_err(|err| RunError::Prompt(err))?;
+            if !confirmed {
+                return Err(RunError::Aborted);
+            }
+        }
+
+        if let Some(phrase) = &command_config.confirm_phrase {
+            let phrase = variables::substitute_variables(phrase, &variables);
+            let confirmed = prompt::confirm_phrase(&prompt_executor, &phrase)
+                .map_err(|err| RunError::Prompt(err))?;
+            if !confirmed {
+                return Err(RunError::Aborted);
+            }
+        }
+
+        if command_config.require_non_empty {
+            actions::ensure_variables_not_empty(&action, &variables)
+                .map_err(|err| RunError::Action(err))?;
+        }
+
+        // Command-level environment variables take precedence over root-level ones
+        variables::apply_environment(&self.env, &mut variables);
+        variables::apply_environment(&command_config.env, &mut variables);
+
+        exec::prepend_path(&command_config.path_prepend, &mut variables)
+            .map_err(|err| RunError::Execution(err))?;
+
+        let working_directory = match command_config
+            .working_directory
+            .as_ref()
+            .or(self.working_directory.as_ref())
+        {
+            Some(working_directory) => Some(
+                exec::resolve_working_directory(working_directory)
+                    .map_err(|err| RunError::Execution(err))?,
+            ),
+            None => None,
+        };
+
+        let timeout = match &command_config.timeout {
+            Some(timeout) => {
+                Some(exec::parse_duration(timeout).map_err(|err| RunError::Execution(err))?)
+            }
+            None => None,
+        };
+
+        // The command's niceness only applies to its action, not the commands used to resolve
+        // its variables
+        if let Some(nice) = command_config.nice {
+            options.nice = Some(nice);
+        }
+
+        let action_executor = ActionExecutor {
+            command_executor: match self.dry_run {
+                // Everything up until now has happened as usual so that the output is accurate
+                true => exec::create_dry_run_command_executor(variables::sensitive_variable_names(
+                    &variable_configs,
+                )),
+                false => exec::create_action_command_executor(
+                    &options,
+                    timeout,
+                    redactor.clone(),
+                    working_directory,
+                ),
+            },
+            arg_resolver: argument_resolver,
+            command_runner: Box::new(self.clone()),
+            commands: self.commands.clone(),
+            call_stack,
+            print_timings: options.print_timings,
+            spinner: command_config.spinner.clone(),
+            redactor,
+            umask: command_config.umask.clone(),
+            before: actions::hooks(&command_config.before),
+            after: actions::hooks(&command_config.after),
+            depends_on: command_config.depends_on.clone(),
+            log_format: self.log_format.clone(),
+        };
+
+        let result = action_executor.execute(&action, &variables);
+
+        let notify_config = command_config.notify.as_ref().or(default_notify);
+        if let Some(notify_config) = notify_config {
+            action_executor.notify(notify_config, &variables, &result);
+        }
+
+        return result.map_err(|err| RunError::Action(err));
+    }
+
+    /// Returns an [`ArgumentResolver`] for the commands being run by another command, which only
+    /// has the values provided for root-level variables and with `--var`.
+    fn argument_resolver(&self) -> ClapArgumentResolver {
+        return ClapArgumentResolver::from_arg_matches(&self.root_arg_matches)
+            .with_overrides(&self.variable_overrides);
+    }
+
+    /// Finds the command at the provided path, along with the variables available to it from the
+    /// root-level and its parent commands.
+    fn find_command(&self, command_path: &String) -> Option<(CommandConfig, VariableConfigMap)> {
+        let mut commands = self.commands.clone();
+        let mut variable_configs = self.variables.clone();
+        let mut found_command = None;
+        for name in command_path.split_whitespace() {
+            let command_config = find_command_by_name(&name.to_string(), &commands)?;
+
+            // Subcommand variables take precedence over the variables of their parents
+            variable_configs.extend(command_config.variables.clone());
+            commands = command_config.commands.clone();
+            found_command = Some(command_config);
+        }
+
+        return found_command.map(|command_config| (command_config, variable_configs));
+    }
+}
+
+impl CommandRunner for ConfigCommandRunner {
+    fn run(
+        &self,
+        command_path: &String,
+        call_stack: &Vec<String>,
+        run_dependencies: bool,
+    ) -> Result<(), RunError> {
+        let Some((mut command_config, variable_configs)) = self.find_command(command_path) else {
+            return Err(RunError::CommandNotFound {
+                name: command_path.clone(),
+            });
+        };
+
+        if !run_dependencies {
+            command_config.depends_on.clear();
+        }
+
+        let prepared_command = self.prepare(
+            command_path,
+            &command_config,
+            &variable_configs,
+            Box::new(self.argument_resolver()),
+        )?;
+
+        return self.execute(
+            prepared_command,
+            call_stack.clone(),
+            Box::new(self.argument_resolver()),
+            None,
+        );
+    }
+}
+
+#[derive(Error, Debug)]
+pub enum RunError {
+    #[error("could not find command \"{name}\"")]
+    CommandNotFound { name: String },
+
+    #[error("aborted")]
+    Aborted,
+
+    #[error(transparent)]
+    Precondition(PreconditionError),
+
+    #[error(transparent)]
+    Lock(LockError),
+
+    #[error(transparent)]
+    Variables(VariableResolutionError),
+
+    #[error(transparent)]
+    Prompt(PromptError),
+
+    #[error(transparent)]
+    Execution(ExecutionError),
+
+    #[error(transparent)]
+    Action(ActionError),
+
+    #[error(transparent)]
+    IO(io::Error),
+}
+
+impl RunError {
+    /// Returns the exit code that Dingus should exit with because of this error.
+    /// Errors from the action use the exit code of the step that failed, anything else results
+    /// in `1`.
+    pub fn exit_code(&self) -> i32 {
+        return match self {
+            RunError::Action(action_err) => action_err.exit_code(),
+            _ => 1,
+        };
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{parse_config, Config, Platform};
+    use tempfile::TempDir;
+
+    fn command_runner(config: Config) -> ConfigCommandRunner {
+        return ConfigCommandRunner {
+            commands: config.commands,
+            variables: config.variables,
+            env: config.env,
+            working_directory: config.working_directory,
+            options: config.options,
+            root_arg_matches: ArgMatches::default(),
+            variable_overrides: HashMap::new(),
+            dry_run: false,
+            log_format: LogFormat::Text,
+        };
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn run_executes_dependencies_in_their_own_working_directory() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let build_dir = temp_dir.path().join("build");
+        let release_dir = temp_dir.path().join("release");
+        std::fs::create_dir(&build_dir).unwrap();
+        std::fs::create_dir(&release_dir).unwrap();
+
+        let yaml = format!(
+            "commands:
+    build:
+        workdir: {}
+        action: touch built
+    release:
+        workdir: {}
+        depends_on:
+            - build
+        action: touch released",
+            build_dir.display(),
+            release_dir.display()
+        );
+        let config = parse_config(&yaml, Platform::Linux).unwrap();
+        let command_runner = command_runner(config);
+
+        // Act
+        let result = command_runner.run(&"release".to_string(), &vec!["release".to_string()], true);
+
+        // Assert
+        assert!(result.is_ok());
+        assert!(build_dir.join("built").exists());
+        assert!(release_dir.join("released").exists());
+        assert!(!release_dir.join("built").exists());
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn run_resolves_variables_of_dependencies_separately() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let yaml = format!(
+            "commands:
+    build:
+        workdir: {}
+        variables:
+            name: built
+        action: touch $name
+    release:
+        workdir: {}
+        variables:
+            name: released
+        depends_on:
+            - build
+        action: touch $name",
+            temp_dir.path().display(),
+            temp_dir.path().display()
+        );
+        let config = parse_config(&yaml, Platform::Linux).unwrap();
+        let command_runner = command_runner(config);
+
+        // Act
+        let result = command_runner.run(&"release".to_string(), &vec!["release".to_string()], true);
+
+        // Assert
+        assert!(result.is_ok());
+        assert!(temp_dir.path().join("built").exists());
+        assert!(temp_dir.path().join("released").exists());
+    }
+
+    #[test]
+    #[cfg(not(windows))]
+    fn run_runs_called_commands_with_their_own_variables_and_working_directory() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let build_dir = temp_dir.path().join("build");
+        let deploy_dir = temp_dir.path().join("deploy");
+        std::fs::create_dir(&build_dir).unwrap();
+        std::fs::create_dir(&deploy_dir).unwrap();
+
+        let yaml = format!(
+            "commands:
+    build:
+        workdir: {}
+        variables:
+            name: built
+        action: touch $name
+    deploy:
+        workdir: {}
+        variables:
+            name: deployed
+        action: touch $name
+    release:
+        variables:
+            name: released
+        calls:
+            - build
+            - deploy",
+            build_dir.display(),
+            deploy_dir.display()
+        );
+        let config = parse_config(&yaml, Platform::Linux).unwrap();
+        let command_runner = command_runner(config);
+
+        // Act
+        let result = command_runner.run(&"release".to_string(), &vec!["release".to_string()], true);
+
+        // Assert
+        assert!(result.is_ok());
+        assert!(build_dir.join("built").exists());
+        assert!(deploy_dir.join("deployed").exists());
+        assert!(!build_dir.join("released").exists());
+        assert!(!deploy_dir.join("released").exists());
+    }
+
+    #[test]
+    fn run_fails_for_unknown_commands() {
+        // Arrange
+        let config = parse_config(&"commands: {}".to_string(), Platform::Linux).unwrap();
+        let command_runner = command_runner(config);
+
+        // Act
+        let result = command_runner.run(&"build".to_string(), &vec!["build".to_string()], true);
+
+        // Assert
+        assert!(matches!(result, Err(RunError::CommandNotFound { name }) if name == "build"));
+    }
+}
@@ -0,0 +1,362 @@
+use crate::actions::{hooks, ActionError, ActionExecutor, LogFormat};
+use crate::args::ArgumentResolver;
+use crate::cli::find_command_by_path;
+use crate::config::{CommandConfig, CommandConfigMap, ExecutionConfigVariant};
+use crate::exec::{CommandExecutor, ExecutionOutputResult, ExecutionResult, ExitStatus, Output};
+use crate::runner::{CommandRunner, RunError};
+use crate::variables::{substitute_variables, VariableMap};
+use std::sync::{Arc, Mutex};
+
+/// The outcome of a single [`crate::config::CommandTestConfig`].
+pub struct TestOutcome {
+    /// The full name of the command that was tested, including any parent commands.
+    pub command_name: String,
+
+    /// The position of the test within the command's tests.
+    pub index: usize,
+
+    /// The commands that were expected to be executed.
+    pub expected: Vec<String>,
+
+    /// The commands that would have been executed.
+    pub actual: Vec<String>,
+
+    /// The error that stopped the action, if any. A test with an error never passes.
+    pub error: Option<ActionError>,
+}
+
+impl TestOutcome {
+    pub fn passed(&self) -> bool {
+        return self.error.is_none() && self.expected == self.actual;
+    }
+}
+
+/// Runs the tests for all of the provided commands and their subcommands.
+/// Commands are rendered using the variables provided by each test, but are never executed.
+pub fn run_tests(commands: &CommandConfigMap) -> Vec<TestOutcome> {
+    let mut outcomes = Vec::new();
+    collect_outcomes(commands, commands, None, &mut outcomes);
+    return outcomes;
+}
+
+fn collect_outcomes(
+    root_commands: &CommandConfigMap,
+    commands: &CommandConfigMap,
+    parent_name: Option<&str>,
+    outcomes: &mut Vec<TestOutcome>,
+) {
+    // Sort the commands so the results are reported in a consistent order
+    let mut keys: Vec<&String> = commands.keys().collect();
+    keys.sort();
+
+    for key in keys {
+        let command_config = &commands[key];
+        let name = command_config.name.clone().unwrap_or(key.clone());
+        let command_name = match parent_name {
+            Some(parent_name) => format!("{} {}", parent_name, name),
+            None => name,
+        };
+
+        if let Some(action) = &command_config.action {
+            for (index, test) in command_config.tests.iter().enumerate() {
+                let command_runner = RecordingCommandRunner {
+                    commands: root_commands.clone(),
+                    variables: test.variables.clone(),
+                    executed_commands: Arc::new(Mutex::new(Vec::new())),
+                };
+                let action_executor = command_runner.action_executor(
+                    command_config,
+                    vec![command_name.clone()],
+                    command_config.depends_on.clone(),
+                );
+
+                // The recording executor never fails, but the action can still fail to be planned or
+                // resolved, which should fail the test rather than being silently ignored.
+                let result = action_executor.execute(action, &command_runner.variables);
+
+                outcomes.push(TestOutcome {
+                    command_name: command_name.clone(),
+                    index,
+                    expected: test.expected.clone(),
+                    actual: command_runner.executed_commands.lock().unwrap().clone(),
+                    error: result.err(),
+                });
+            }
+        }
+
+        collect_outcomes(
+            root_commands,
+            &command_config.commands,
+            Some(&command_name),
+            outcomes,
+        );
+    }
+}
+
+/// Formats the provided [`TestOutcome`] for display.
+pub fn format_outcome(outcome: &TestOutcome) -> String {
+    if outcome.passed() {
+        return format!("PASS {} [{}]", outcome.command_name, outcome.index);
+    }
+
+    let mut text = format!(
+        "FAIL {} [{}]\n  expected: {:?}\n    actual: {:?}",
+        outcome.command_name, outcome.index, outcome.expected, outcome.actual
+    );
+
+    if let Some(err) = &outcome.error {
+        text.push_str(&format!("\n     error: {}", err));
+    }
+
+    return text;
+}
+
+/// A [`CommandExecutor`] that records the commands it would have executed rather than executing
+/// them.
+struct RecordingCommandExecutor {
+    executed_commands: Arc<Mutex<Vec<String>>>,
+}
+
+impl RecordingCommandExecutor {
+    fn record(&self, execution_config: &ExecutionConfigVariant, variables: &VariableMap) {
+        let command = substitute_variables(&execution_config.command_template(), variables);
+        self.executed_commands.lock().unwrap().push(command);
+    }
+}
+
+impl CommandExecutor for RecordingCommandExecutor {
+    fn execute(
+        &self,
+        execution_config: &ExecutionConfigVariant,
+        variables: &VariableMap,
+    ) -> ExecutionResult {
+        self.record(execution_config, variables);
+        Ok(ExitStatus::Success)
+    }
+
+    fn get_output(
+        &self,
+        execution_config: &ExecutionConfigVariant,
+        variables: &VariableMap,
+    ) -> ExecutionOutputResult {
+        self.record(execution_config, variables);
+        Ok(Output {
+            status: ExitStatus::Success,
+            stdout: vec![],
+            stderr: vec![],
+        })
+    }
+}
+
+/// A [`CommandRunner`] that records the commands that would have been executed by the commands
+/// it runs, rendered with the same variables.
+struct RecordingCommandRunner {
+    commands: CommandConfigMap,
+    variables: VariableMap,
+    executed_commands: Arc<Mutex<Vec<String>>>,
+}
+
+impl RecordingCommandRunner {
+    /// Creates an [`ActionExecutor`] for the provided [`CommandConfig`] which records the commands
+    /// it would have executed.
+    fn action_executor(
+        &self,
+        command_config: &CommandConfig,
+        call_stack: Vec<String>,
+        depends_on: Vec<String>,
+    ) -> ActionExecutor {
+        return ActionExecutor {
+            command_executor: Box::new(RecordingCommandExecutor {
+                executed_commands: self.executed_commands.clone(),
+            }),
+            arg_resolver: Box::new(EmptyArgumentResolver {}),
+            command_runner: Box::new(RecordingCommandRunner {
+                commands: self.commands.clone(),
+                variables: self.variables.clone(),
+                executed_commands: self.executed_commands.clone(),
+            }),
+            commands: self.commands.clone(),
+            call_stack,
+            print_timings: false,
+            spinner: None,
+            redactor: None,
+            umask: None,
+            before: hooks(&command_config.before),
+            after: hooks(&command_config.after),
+            depends_on,
+            log_format: LogFormat::Text,
+        };
+    }
+}
+
+impl CommandRunner for RecordingCommandRunner {
+    fn run(
+        &self,
+        command_path: &String,
+        call_stack: &Vec<String>,
+        run_dependencies: bool,
+    ) -> Result<(), RunError> {
+        let Some(command_config) = find_command_by_path(command_path, &self.commands) else {
+            return Err(RunError::CommandNotFound {
+                name: command_path.clone(),
+            });
+        };
+
+        let Some(action) = &command_config.action else {
+            return Err(RunError::CommandNotFound {
+                name: command_path.clone(),
+            });
+        };
+
+        let depends_on = match run_dependencies {
+            true => command_config.depends_on.clone(),
+            false => vec![],
+        };
+
+        return self
+            .action_executor(&command_config, call_stack.clone(), depends_on)
+            .execute(action, &self.variables)
+            .map_err(|err| RunError::Action(err));
+    }
+}
+
+/// An [`ArgumentResolver`] where no arguments have been provided.
+struct EmptyArgumentResolver {}
+
+impl ArgumentResolver for EmptyArgumentResolver {
+    fn get(&self, _: &String) -> Option<String> {
+        return None;
+    }
+
+    fn get_many(&self, _: &String) -> Option<Vec<String>> {
+        return None;
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{parse_config, Platform};
+
+    #[test]
+    fn run_tests_reports_passing_and_failing_tests() {
+        // Arrange
+        let yaml = "commands:
+    greet:
+        action: echo Hello, $name!
+        tests:
+            - variables:
+                name: Dingus
+              expected:
+                - echo Hello, Dingus!
+            - variables:
+                name: Bingus
+              expected:
+                - echo Hello, Dingus!
+    deploy:
+        commands:
+            app:
+                actions:
+                    - ./build.sh $environment
+                    - sh: ./deploy.sh $environment
+                tests:
+                    - vars:
+                        environment: Production
+                      expected:
+                        - ./build.sh Production
+                        - ./deploy.sh Production";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        // Act
+        let outcomes = run_tests(&config.commands);
+
+        // Assert
+        assert_eq!(outcomes.len(), 3);
+
+        assert_eq!(outcomes[0].command_name, "deploy app");
+        assert!(outcomes[0].passed());
+
+        assert_eq!(outcomes[1].command_name, "greet");
+        assert_eq!(outcomes[1].index, 0);
+        assert!(outcomes[1].passed());
+
+        assert_eq!(outcomes[2].command_name, "greet");
+        assert_eq!(outcomes[2].index, 1);
+        assert!(!outcomes[2].passed());
+        assert_eq!(outcomes[2].actual, vec!["echo Hello, Bingus!".to_string()]);
+    }
+
+    #[test]
+    fn format_outcome_describes_failure() {
+        // Arrange
+        let outcome = TestOutcome {
+            command_name: "greet".to_string(),
+            index: 1,
+            expected: vec!["echo Hello, Dingus!".to_string()],
+            actual: vec!["echo Hello, Bingus!".to_string()],
+            error: None,
+        };
+
+        // Act
+        let result = format_outcome(&outcome);
+
+        // Assert
+        assert_eq!(
+            result,
+            "FAIL greet [1]\n  expected: [\"echo Hello, Dingus!\"]\n    actual: [\"echo Hello, Bingus!\"]"
+        );
+    }
+
+    #[test]
+    fn run_tests_fails_tests_for_actions_with_errors() {
+        // Arrange
+        let yaml = "commands:
+    ping:
+        calls:
+            - pong
+        tests:
+            - expected: []
+    pong:
+        calls:
+            - ping";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        // Act
+        let outcomes = run_tests(&config.commands);
+
+        // Assert
+        assert_eq!(outcomes.len(), 1);
+        assert!(!outcomes[0].passed());
+        assert!(matches!(
+            &outcomes[0].error,
+            Some(ActionError::Call { source, .. })
+                if matches!(**source, RunError::Action(ActionError::CallCycle { .. }))
+        ));
+    }
+
+    #[test]
+    fn format_outcome_describes_error() {
+        // Arrange
+        let outcome = TestOutcome {
+            command_name: "release".to_string(),
+            index: 0,
+            expected: vec![],
+            actual: vec![],
+            error: Some(ActionError::CallCycle {
+                cycle: vec!["release".to_string(), "release".to_string()],
+            }),
+        };
+
+        // Act
+        let result = format_outcome(&outcome);
+
+        // Assert
+        assert_eq!(
+            result,
+            format!(
+                "FAIL release [0]\n  expected: []\n    actual: []\n     error: {}",
+                outcome.error.as_ref().unwrap()
+            )
+        );
+    }
+}
@@ -0,0 +1,133 @@
+use std::io::{IsTerminal, Write};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::Arc;
+use std::thread::JoinHandle;
+use std::time::{Duration, Instant};
+use std::{io, thread};
+
+const FRAMES: [&str; 10] = ["⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"];
+const FRAME_INTERVAL: Duration = Duration::from_millis(80);
+
+/// A loading indicator drawn to stderr on a background thread.
+/// The spinner is removed from the terminal when it is stopped or dropped.
+pub struct Spinner {
+    running: Arc<AtomicBool>,
+    handle: Option<JoinHandle<()>>,
+}
+
+impl Spinner {
+    /// Starts a new [`Spinner`] with the provided message.
+    /// Nothing is drawn if stderr is not a terminal.
+    pub fn start(message: &str) -> Spinner {
+        return Spinner::start_if(io::stderr().is_terminal(), message, false);
+    }
+
+    /// Starts a new [`Spinner`] with the provided message, followed by how long the spinner has
+    /// been running for.
+    /// Nothing is drawn if stderr is not a terminal.
+    pub fn start_with_elapsed(message: &str) -> Spinner {
+        return Spinner::start_if(io::stderr().is_terminal(), message, true);
+    }
+
+    fn start_if(enabled: bool, message: &str, show_elapsed: bool) -> Spinner {
+        let running = Arc::new(AtomicBool::new(enabled));
+        if !enabled {
+            return Spinner {
+                running,
+                handle: None,
+            };
+        }
+
+        let thread_running = running.clone();
+        let message = message.to_string();
+        let handle = thread::spawn(move || {
+            let mut stderr = io::stderr();
+            let start = Instant::now();
+            let mut frame = 0;
+            while thread_running.load(Ordering::Relaxed) {
+                let elapsed = show_elapsed.then(|| start.elapsed());
+                let _ = write!(stderr, "\r{}", format_frame(frame, &message, elapsed));
+                let _ = stderr.flush();
+
+                frame = (frame + 1) % FRAMES.len();
+                thread::sleep(FRAME_INTERVAL);
+            }
+
+            // Clear the line so the next prompt starts from a clean slate
+            let _ = write!(stderr, "\r\x1b[2K");
+            let _ = stderr.flush();
+        });
+
+        return Spinner {
+            running,
+            handle: Some(handle),
+        };
+    }
+
+    /// Stops the spinner and waits for it to be cleared from the terminal.
+    pub fn stop(mut self) {
+        self.finish();
+    }
+
+    fn finish(&mut self) {
+        self.running.store(false, Ordering::Relaxed);
+        if let Some(handle) = self.handle.take() {
+            let _ = handle.join();
+        }
+    }
+}
+
+impl Drop for Spinner {
+    fn drop(&mut self) {
+        self.finish();
+    }
+}
+
+/// Formats a single frame of the spinner, including the elapsed time in whole seconds if provided.
+fn format_frame(frame: usize, message: &str, elapsed: Option<Duration>) -> String {
+    return match elapsed {
+        Some(elapsed) => format!("{} {} ({}s)", FRAMES[frame], message, elapsed.as_secs()),
+        None => format!("{} {}", FRAMES[frame], message),
+    };
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn disabled_spinner_does_not_spawn_thread() {
+        // Act
+        let spinner = Spinner::start_if(false, "Loading...", false);
+
+        // Assert
+        assert!(spinner.handle.is_none());
+        assert_eq!(spinner.running.load(Ordering::Relaxed), false);
+    }
+
+    #[test]
+    fn enabled_spinner_stops() {
+        // Arrange
+        let spinner = Spinner::start_if(true, "Loading...", true);
+        let running = spinner.running.clone();
+        assert!(running.load(Ordering::Relaxed));
+
+        // Act
+        thread::sleep(FRAME_INTERVAL * 2);
+        spinner.stop();
+
+        // Assert
+        assert_eq!(running.load(Ordering::Relaxed), false);
+    }
+
+    #[test]
+    fn format_frame_includes_elapsed_time() {
+        // Act
+        let without_elapsed = format_frame(0, "Building...", None);
+        let with_elapsed = format_frame(1, "Building...", Some(Duration::from_millis(3500)));
+
+        // Assert
+        assert_eq!(without_elapsed, "⠋ Building...");
+        assert_eq!(with_elapsed, "⠙ Building... (3s)");
+    }
+}
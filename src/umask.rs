@@ -0,0 +1,107 @@
+use thiserror::Error;
+
+/// Sets the file mode creation mask of the current process, restoring the previous mask when
+/// dropped. Any commands executed in the meantime inherit the mask.
+/// This does nothing on platforms other than Unix.
+pub struct UmaskGuard {
+    #[cfg(unix)]
+    previous: libc::mode_t,
+}
+
+impl UmaskGuard {
+    /// Parses the provided octal umask (e.g. `027`) and applies it to the current process.
+    pub fn set(umask: &str) -> Result<UmaskGuard, UmaskError> {
+        let mask = parse_umask(umask)?;
+
+        #[cfg(unix)]
+        {
+            // Safety: umask can't fail, it only swaps the mask for the current process
+            let previous = unsafe { libc::umask(mask as libc::mode_t) };
+            return Ok(UmaskGuard { previous });
+        }
+
+        #[cfg(not(unix))]
+        {
+            let _ = mask;
+            return Ok(UmaskGuard {});
+        }
+    }
+}
+
+impl Drop for UmaskGuard {
+    fn drop(&mut self) {
+        #[cfg(unix)]
+        unsafe {
+            libc::umask(self.previous);
+        }
+    }
+}
+
+/// Parses the provided text as an octal umask.
+fn parse_umask(umask: &str) -> Result<u32, UmaskError> {
+    let mask = u32::from_str_radix(umask, 8).map_err(|_| UmaskError::Invalid(umask.to_string()))?;
+    if mask > 0o777 {
+        return Err(UmaskError::Invalid(umask.to_string()));
+    }
+
+    return Ok(mask);
+}
+
+#[derive(Error, Debug)]
+pub enum UmaskError {
+    #[error("invalid umask \"{0}\", expected an octal value such as 022")]
+    Invalid(String),
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn parse_umask_parses_octal() {
+        // Act
+        let mask = parse_umask("027").unwrap();
+
+        // Assert
+        assert_eq!(mask, 0o027);
+    }
+
+    #[test]
+    fn parse_umask_fails_for_invalid_umask() {
+        // Act
+        let not_octal = parse_umask("099");
+        let too_large = parse_umask("1000");
+
+        // Assert
+        assert!(matches!(not_octal, Err(UmaskError::Invalid(_))));
+        assert!(matches!(too_large, Err(UmaskError::Invalid(_))));
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn umask_guard_applies_umask_to_commands() {
+        use std::os::unix::fs::PermissionsExt;
+        use std::process::Command;
+        use tempfile::TempDir;
+
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let file_path = temp_dir.path().join("secret.txt");
+
+        // Act
+        let guard = UmaskGuard::set("077").unwrap();
+        let status = Command::new("touch").arg(&file_path).status().unwrap();
+        drop(guard);
+
+        // Assert
+        assert!(status.success());
+        let mode = file_path.metadata().unwrap().permissions().mode();
+        assert_eq!(mode & 0o777, 0o600);
+
+        // The previous umask is restored once the guard is dropped
+        let guard = UmaskGuard::set("022").unwrap();
+        let restored = guard.previous;
+        drop(guard);
+        assert_ne!(restored, 0o077);
+    }
+}
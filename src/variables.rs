@@ -1,15 +1,38 @@
 use crate::args::ArgumentResolver;
-use crate::config::{DingusOptions, PromptOptionsVariant, VariableConfig, VariableConfigMap};
-use crate::exec::{CommandExecutor, ExecutionError, ExitStatus};
+use crate::config::{
+    ArgumentConfigVariant, DingusOptions, ExecutionConfigVariant, PromptOptionsVariant,
+    ValidationRuleConfig, VariableConfig, VariableConfigMap,
+};
+use crate::exec::{
+    expand_home_directory, strip_ansi_escapes, CommandExecutor, ExecutionError,
+    ExecutionOutputResult, ExitStatus, Output,
+};
+use crate::keyring::{Keyring, KeyringError};
+use crate::prompt;
 use crate::prompt::{PromptError, PromptExecutor};
+use crate::remote::{fetch_json_value, RemoteError};
 use colored::Colorize;
+use linked_hash_map::LinkedHashMap;
+use regex::Regex;
 use std::collections::HashMap;
+use std::path::PathBuf;
 use std::string::FromUtf8Error;
+use std::{env, fs, io};
 use thiserror::Error;
 
 /// A [`HashMap`] where the key is the variable name, and the value is that variables value.
 pub type VariableMap = HashMap<String, String>;
 
+/// Hard coded value used in place of sensitive values to obscure the length.
+const SENSITIVE_VALUE_MASK: &str = "********";
+
+/// The maximum number of render passes, so that self-referencing variables can't grow forever.
+const MAX_RENDER_PASSES: u32 = 10;
+
+/// The maximum length of an expanded variable value, since variables that reference themselves
+/// more than once grow exponentially with each render pass.
+const MAX_EXPANDED_VALUE_LENGTH: usize = 64 * 1024;
+
 pub trait VariableResolver {
     /// Resolves variables from the provided [`VariableConfigMap`] into a [`VariableMap`].
     fn resolve_variables(
@@ -22,6 +45,7 @@ pub struct RealVariableResolver {
     pub command_executor: Box<dyn CommandExecutor>,
     pub prompt_executor: Box<dyn PromptExecutor>,
     pub argument_resolver: Box<dyn ArgumentResolver>,
+    pub keyring: Box<dyn Keyring>,
     pub dingus_options: DingusOptions,
 }
 
@@ -30,32 +54,112 @@ impl VariableResolver for RealVariableResolver {
         &self,
         variable_configs: &VariableConfigMap,
     ) -> Result<VariableMap, VariableResolutionError> {
+        let mut command_cache: Vec<CachedOutput> = vec![];
+        let mut answers = LinkedHashMap::new();
+        loop {
+            let resolution = self.resolve_pass(variable_configs, &answers, &mut command_cache)?;
+            answers = resolution.answers;
+
+            // Once everything has been answered, the user can go back and change their answers
+            let key_to_change = match self.dingus_options.review_answers && !answers.is_empty() {
+                true => self.review_answers(variable_configs, &answers)?,
+                false => None,
+            };
+
+            let Some(key_to_change) = key_to_change else {
+                self.log_variables(&resolution.variables, &resolution.sensitive_variable_names);
+                return Ok(resolution.variables);
+            };
+
+            // Anything depending on the changed answer is asked again, since the options or
+            // conditions of those prompts may have changed
+            for key in dependent_keys(variable_configs, &key_to_change) {
+                answers.remove(&key);
+            }
+        }
+    }
+}
+
+/// The result of resolving each of the variables once.
+struct Resolution {
+    variables: VariableMap,
+
+    /// The names of the variables whose values should be obscured.
+    sensitive_variable_names: Vec<String>,
+
+    /// The values entered by the user, keyed by the variable they were entered for, in the order
+    /// they were asked for.
+    answers: LinkedHashMap<String, String>,
+}
+
+/// The output of a command executed while resolving variables, so that identical commands only
+/// need to be executed once.
+struct CachedOutput {
+    execution_config: ExecutionConfigVariant,
+
+    /// The values of the variables referenced by the command when it was executed.
+    referenced_values: Vec<Option<String>>,
+
+    output: Output,
+}
+
+impl RealVariableResolver {
+    /// Resolves each of the variables from the provided [`VariableConfigMap`] once.
+    /// The user is only asked for the variables which don't already have an answer in
+    /// `previous_answers`.
+    fn resolve_pass(
+        &self,
+        variable_configs: &VariableConfigMap,
+        previous_answers: &LinkedHashMap<String, String>,
+        command_cache: &mut Vec<CachedOutput>,
+    ) -> Result<Resolution, VariableResolutionError> {
         // The names of sensitive variables are added to a separate vec so that the logging stuff
-        // below knows to obfuscate them.
+        // knows to obfuscate them.
         let mut resolved_variables = VariableMap::new();
         let mut sensitive_variable_names: Vec<String> = vec![];
+        let mut answers = LinkedHashMap::new();
 
-        for (key, config) in variable_configs.iter() {
+        // Variables are resolved after the variables they reference, so that their values can be
+        // substituted in.
+        for key in resolution_order(variable_configs)?.iter() {
+            // Safe to unwrap: the order only contains keys from the variable configs
+            let config = variable_configs.get(key).unwrap();
             let name = config.environment_variable_name(key);
 
+            // Variables whose condition isn't met are left unset
+            if let Some(condition) = config.condition() {
+                if !condition_met(&condition, &resolved_variables) {
+                    continue;
+                }
+            }
+
             // Args from the command-line have the highest priority, check there first.
             if let Some(arg_value) = self.argument_resolver.get(key) {
                 resolved_variables.insert(name.clone(), arg_value.clone());
+            } else if let Some(env_value) = source_environment_value(config) {
+                // Followed by the environment variable the value is sourced from
+                resolved_variables.insert(name.clone(), env_value);
             } else {
                 match config {
                     VariableConfig::ShorthandLiteral(value) => {
-                        resolved_variables.insert(name.clone(), value.clone());
+                        let value = substitute_variables(value, &resolved_variables);
+                        resolved_variables.insert(name.clone(), value);
                     }
 
                     VariableConfig::Literal(literal_conf) => {
-                        resolved_variables.insert(name.clone(), literal_conf.value.clone());
+                        let value = substitute_variables(&literal_conf.value, &resolved_variables);
+                        resolved_variables.insert(name.clone(), value);
                     }
 
                     VariableConfig::Execution(execution_conf) => {
-                        // Exec variables need access to the variables defined above them.
+                        // Exec variables need access to the variables they reference.
                         let output = self
-                            .command_executor
-                            .get_output(&execution_conf.execution, &resolved_variables)
+                            .get_output(
+                                &execution_conf.execution,
+                                &resolved_variables,
+                                execution_conf.no_cache,
+                                command_cache,
+                            )
                             .map_err(|err| VariableResolutionError::Execution {
                                 key: key.clone(),
                                 source: err,
@@ -71,46 +175,259 @@ impl VariableResolver for RealVariableResolver {
                             });
                         }
 
-                        let value = String::from_utf8(output.stdout)
-                            .map_err(|err| VariableResolutionError::Parse {
+                        let stdout = String::from_utf8(output.stdout).map_err(|err| {
+                            VariableResolutionError::Parse {
                                 key: key.clone(),
                                 source: err,
-                            })?
-                            .trim_end()
-                            .to_string();
+                            }
+                        })?;
+                        let value = strip_ansi_escapes(&stdout).trim_end().to_string();
 
                         resolved_variables.insert(name.clone(), value.clone());
                     }
 
-                    VariableConfig::Prompt(prompt_config) => {
-                        let value = self
-                            .prompt_executor
-                            .execute(&prompt_config.prompt)
-                            .map_err(|err| VariableResolutionError::Prompt {
+                    VariableConfig::ExitCode(exit_code_conf) => {
+                        // Exit code variables also need access to the variables they reference.
+                        let output = self
+                            .get_output(
+                                &exit_code_conf.execution,
+                                &resolved_variables,
+                                exit_code_conf.no_cache,
+                                command_cache,
+                            )
+                            .map_err(|err| VariableResolutionError::Execution {
+                                key: key.clone(),
+                                source: err,
+                            })?;
+
+                        let value = match output.status {
+                            ExitStatus::Success => 0,
+                            ExitStatus::Fail(code) => code,
+
+                            // Can't use an exit code we don't know
+                            ExitStatus::Unknown => {
+                                return Err(VariableResolutionError::ExitStatus {
+                                    key: key.clone(),
+                                    status: output.status.clone(),
+                                })
+                            }
+                        };
+
+                        resolved_variables.insert(name.clone(), value.to_string());
+                    }
+
+                    VariableConfig::Http(http_conf) => {
+                        // The URL and headers can reference other variables.
+                        let url = substitute_variables(&http_conf.request.url, &resolved_variables);
+                        let headers = http_conf
+                            .request
+                            .headers
+                            .iter()
+                            .map(|(name, value)| {
+                                (
+                                    name.clone(),
+                                    substitute_variables(value, &resolved_variables),
+                                )
+                            })
+                            .collect();
+
+                        let value = fetch_json_value(&url, &headers, &http_conf.request.json_path)
+                            .map_err(|err| VariableResolutionError::Http {
                                 key: key.clone(),
                                 source: err,
                             })?;
 
+                        resolved_variables.insert(name.clone(), value);
+                    }
+
+                    VariableConfig::File(file_conf) => {
+                        // The path can reference other variables too.
+                        let path = substitute_variables(&file_conf.file, &resolved_variables);
+                        let path = expand_home_directory(&path);
+                        match fs::read_to_string(&path) {
+                            Ok(contents) => {
+                                resolved_variables
+                                    .insert(name.clone(), contents.trim().to_string());
+                            }
+
+                            // Optional files are allowed to be missing
+                            Err(err)
+                                if err.kind() == io::ErrorKind::NotFound && !file_conf.required => {
+                            }
+
+                            Err(err) => {
+                                return Err(VariableResolutionError::ReadFile {
+                                    key: key.clone(),
+                                    path,
+                                    source: err,
+                                })
+                            }
+                        }
+                    }
+
+                    VariableConfig::Keyring(keyring_conf) => {
+                        // The service and account can reference other variables.
+                        let service = substitute_variables(
+                            &keyring_conf.keyring.service,
+                            &resolved_variables,
+                        );
+                        let account = substitute_variables(
+                            &keyring_conf.keyring.account,
+                            &resolved_variables,
+                        );
+                        let value = self.keyring.get_secret(&service, &account).map_err(|err| {
+                            VariableResolutionError::Keyring {
+                                key: key.clone(),
+                                source: err,
+                            }
+                        })?;
+
+                        resolved_variables.insert(name.clone(), value);
+                        sensitive_variable_names.push(name.clone());
+                    }
+
+                    VariableConfig::Prompt(prompt_config) => {
+                        let is_sensitive = is_variable_sensitive(config);
+                        let value = match previous_answers.get(key) {
+                            Some(answer) => answer.clone(),
+                            None => {
+                                let value = self
+                                    .prompt_executor
+                                    .execute(&prompt_config.prompt_config())
+                                    .map_err(|err| VariableResolutionError::Prompt {
+                                        key: key.clone(),
+                                        source: err,
+                                    })?;
+
+                                self.log_answer(&name, &value, is_sensitive);
+                                value
+                            }
+                        };
+
                         resolved_variables.insert(name.clone(), value.clone());
+                        answers.insert(key.clone(), value);
 
-                        if is_variable_sensitive(config) {
+                        if is_sensitive {
                             sensitive_variable_names.push(name.clone());
                         }
                     }
 
-                    // Arguments are checked above, nothing to do here.
-                    VariableConfig::Argument(_) => {}
+                    // Arguments are checked above, only need to make sure it wasn't required.
+                    VariableConfig::Argument(argument_conf) => {
+                        if let Some(conditions) = &argument_conf.required_when {
+                            if conditions_met(conditions, variable_configs, &resolved_variables) {
+                                if !self.dingus_options.allow_stdin {
+                                    return Err(VariableResolutionError::MissingArgument {
+                                        key: key.clone(),
+                                        suggestions: missing_argument_suggestions(
+                                            key,
+                                            &argument_conf.argument,
+                                            &argument_conf.from_env,
+                                        ),
+                                    });
+                                }
+
+                                let value = match previous_answers.get(key) {
+                                    Some(answer) => answer.clone(),
+                                    None => self
+                                        .prompt_executor
+                                        .read_line(&format!("{}: ", key))
+                                        .map_err(|err| VariableResolutionError::Prompt {
+                                        key: key.clone(),
+                                        source: err,
+                                    })?,
+                                };
+
+                                resolved_variables.insert(name.clone(), value.clone());
+                                answers.insert(key.clone(), value);
+                            }
+                        }
+                    }
                 }
             }
+
+            // Values are validated the same way regardless of where they came from
+            if let Some(value) = resolved_variables.get(&name) {
+                validate_value(key, value, &config.validation_rules())?;
+            }
         }
 
-        self.log_variables(&resolved_variables, &sensitive_variable_names);
+        return Ok(Resolution {
+            variables: resolved_variables,
+            sensitive_variable_names,
+            answers,
+        });
+    }
+
+    /// Lists the provided answers, and asks the user whether they want to change any of them.
+    /// Returns the key of the variable whose answer should be changed, or `None` to continue.
+    fn review_answers(
+        &self,
+        variable_configs: &VariableConfigMap,
+        answers: &LinkedHashMap<String, String>,
+    ) -> Result<Option<String>, VariableResolutionError> {
+        let labelled_answers = answers
+            .iter()
+            .map(|(key, value)| {
+                // Safe to unwrap: answers are only recorded for the variable configs
+                let config = variable_configs.get(key).unwrap();
+                let name = config.environment_variable_name(key);
+                let label = format_answer(&name, value, is_variable_sensitive(config));
+                (key.clone(), label)
+            })
+            .collect();
 
-        Ok(resolved_variables)
+        return prompt::review_answers(self.prompt_executor.as_ref(), &labelled_answers)
+            .map_err(|err| VariableResolutionError::Review { source: err });
+    }
+
+    /// Executes the provided [`ExecutionConfigVariant`], reusing the output from the provided cache
+    /// if an identical command has already been executed, unless `no_cache` is `true`.
+    /// Commands are identical when their configs match, and the variables they reference have the
+    /// same values.
+    fn get_output(
+        &self,
+        execution_config: &ExecutionConfigVariant,
+        variables: &VariableMap,
+        no_cache: bool,
+        command_cache: &mut Vec<CachedOutput>,
+    ) -> ExecutionOutputResult {
+        let referenced_values: Vec<Option<String>> =
+            find_variable_references(&execution_config.command_template())
+                .iter()
+                .map(|name| variables.get(name).cloned())
+                .collect();
+
+        if !no_cache {
+            let cached_output = command_cache.iter().find(|cached_output| {
+                cached_output.execution_config == *execution_config
+                    && cached_output.referenced_values == referenced_values
+            });
+            if let Some(cached_output) = cached_output {
+                return Ok(cached_output.output.clone());
+            }
+        }
+
+        let output = self
+            .command_executor
+            .get_output(execution_config, variables)?;
+        command_cache.push(CachedOutput {
+            execution_config: execution_config.clone(),
+            referenced_values,
+            output: output.clone(),
+        });
+
+        return Ok(output);
+    }
+
+    fn log_answer(&self, name: &str, value: &str, is_sensitive: bool) {
+        if !self.dingus_options.log_answers {
+            return;
+        }
+
+        eprintln!("{}", format_answer(name, value, is_sensitive));
     }
-}
 
-impl RealVariableResolver {
     fn log_variables(&self, variables: &VariableMap, sensitive_variable_names: &Vec<String>) {
         if !self.dingus_options.print_variables {
             return;
@@ -120,7 +437,7 @@ impl RealVariableResolver {
             let is_sensitive = sensitive_variable_names.contains(name);
 
             let variable_to_print = if is_sensitive {
-                "********".to_string() // Hard coded value to obscure the length
+                SENSITIVE_VALUE_MASK.to_string()
             } else {
                 value.clone()
             };
@@ -130,18 +447,494 @@ impl RealVariableResolver {
     }
 }
 
+/// Returns the ways that a missing argument could be provided, to help the user fix the problem.
+fn missing_argument_suggestions(
+    key: &str,
+    argument: &ArgumentConfigVariant,
+    from_env: &Option<String>,
+) -> Vec<String> {
+    let argument_suggestion = match argument {
+        ArgumentConfigVariant::Shorthand(long) => format!("use the --{} argument", long),
+        ArgumentConfigVariant::Named(named) => match named.short {
+            Some(short) => format!("use the -{} or --{} argument", short, named.long),
+            None => format!("use the --{} argument", named.long),
+        },
+        ArgumentConfigVariant::Positional(positional) => {
+            format!("provide it as positional argument {}", positional.position)
+        }
+    };
+
+    let mut suggestions = vec![argument_suggestion];
+    if let Some(env_name) = from_env {
+        suggestions.push(format!("set the {} environment variable", env_name));
+    }
+
+    suggestions.push(format!("use --var {}=<value> before the command", key));
+    suggestions.push("set DINGUS_ALLOW_STDIN=true to read it from stdin".to_string());
+    return suggestions;
+}
+
+fn format_suggestions(suggestions: &Vec<String>) -> String {
+    return suggestions
+        .iter()
+        .map(|suggestion| format!("\n  - {}", suggestion))
+        .collect();
+}
+
+/// Checks the provided value against each of the provided [`ValidationRuleConfig`]s.
+/// The message from the first rule that the value doesn't satisfy is returned as an error.
+fn validate_value(
+    key: &str,
+    value: &str,
+    rules: &Vec<ValidationRuleConfig>,
+) -> Result<(), VariableResolutionError> {
+    for rule in rules {
+        let regex =
+            Regex::new(&rule.pattern).map_err(|err| VariableResolutionError::InvalidPattern {
+                key: key.to_string(),
+                source: err,
+            })?;
+
+        if !regex.is_match(value) {
+            return Err(VariableResolutionError::Invalid {
+                key: key.to_string(),
+                message: rule.message.clone(),
+            });
+        }
+    }
+
+    return Ok(());
+}
+
+fn format_answer(name: &str, value: &str, is_sensitive: bool) -> String {
+    let value_to_print = if is_sensitive {
+        SENSITIVE_VALUE_MASK
+    } else {
+        value
+    };
+
+    format!("{}: {}", name, value_to_print)
+}
+
+/// Creates a summary of the provided [`VariableMap`], listing each variable defined in the
+/// [`VariableConfigMap`] alongside its resolved value.
+/// Sensitive values are obscured.
+pub fn summarise_variables(
+    variable_configs: &VariableConfigMap,
+    variables: &VariableMap,
+) -> String {
+    let names: Vec<(String, &VariableConfig)> = variable_configs
+        .iter()
+        .map(|(key, config)| (config.environment_variable_name(key), config))
+        .collect();
+
+    let width = names.iter().map(|(name, _)| name.len()).max().unwrap_or(0);
+
+    names
+        .iter()
+        .filter_map(|(name, config)| {
+            let value = variables.get(name)?;
+            let value_to_print = if is_variable_sensitive(config) {
+                SENSITIVE_VALUE_MASK.to_string()
+            } else {
+                value.clone()
+            };
+
+            Some(format!(
+                "{:width$}  {}",
+                name,
+                value_to_print,
+                width = width
+            ))
+        })
+        .collect::<Vec<String>>()
+        .join("\n")
+}
+
+/// Removes any prompt variables which aren't referenced by the provided templates, either directly
+/// or through the other variables they reference, so that they're never prompted for.
+pub fn remove_unreferenced_prompts(
+    variable_configs: &VariableConfigMap,
+    templates: &Vec<String>,
+) -> VariableConfigMap {
+    let mut referenced_names: Vec<String> = templates
+        .iter()
+        .flat_map(|template| find_variable_references(template))
+        .collect();
+
+    // Variables can reference each other in any order, so keep following the references until
+    // no more variables are found.
+    let mut referenced_keys: Vec<String> = vec![];
+    loop {
+        let newly_referenced: Vec<(&String, &VariableConfig)> = variable_configs
+            .iter()
+            .filter(|(key, config)| {
+                !referenced_keys.contains(key)
+                    && referenced_names.contains(&config.environment_variable_name(key))
+            })
+            .collect();
+        if newly_referenced.is_empty() {
+            break;
+        }
+
+        for (key, config) in newly_referenced {
+            referenced_keys.push(key.clone());
+            for template in variable_templates(config) {
+                referenced_names.extend(find_variable_references(&template));
+            }
+        }
+    }
+
+    return variable_configs
+        .iter()
+        .filter(|(key, config)| {
+            !matches!(config, VariableConfig::Prompt(_)) || referenced_keys.contains(key)
+        })
+        .map(|(key, config)| (key.clone(), config.clone()))
+        .collect();
+}
+
+/// The shell syntax used when exporting variables.
+#[derive(Debug, Clone, PartialEq)]
+pub enum ExportFormat {
+    Bash,
+    Fish,
+}
+
+/// Formats the provided [`VariableMap`] as shell commands which export each variable defined in
+/// the [`VariableConfigMap`], so that they can be evaluated by the calling shell.
+/// Sensitive variables are only included if `include_sensitive` is `true`.
+pub fn format_exports(
+    variable_configs: &VariableConfigMap,
+    variables: &VariableMap,
+    format: &ExportFormat,
+    include_sensitive: bool,
+) -> String {
+    return variable_configs
+        .iter()
+        .filter(|(_, config)| include_sensitive || !is_variable_sensitive(config))
+        .filter_map(|(key, config)| {
+            let name = config.environment_variable_name(key);
+            let value = variables.get(&name)?;
+            let export = match format {
+                ExportFormat::Bash => format!("export {}={}", name, quote_for_bash(value)),
+                ExportFormat::Fish => format!("set -gx {} {}", name, quote_for_fish(value)),
+            };
+
+            Some(export)
+        })
+        .collect::<Vec<String>>()
+        .join("\n");
+}
+
+/// Wraps the provided value in single quotes so that bash treats it literally.
+fn quote_for_bash(value: &str) -> String {
+    // Single quotes can't be escaped within single quotes, so we need to close the quotes, add an
+    // escaped single quote, then re-open them.
+    return format!("'{}'", value.replace('\'', "'\\''"));
+}
+
+/// Wraps the provided value in single quotes so that fish treats it literally.
+fn quote_for_fish(value: &str) -> String {
+    return format!("'{}'", value.replace('\\', "\\\\").replace('\'', "\\'"));
+}
+
+/// Returns the names of the sensitive variables in the provided [`VariableConfigMap`], as they
+/// appear in the [`VariableMap`].
+pub fn sensitive_variable_names(variable_configs: &VariableConfigMap) -> Vec<String> {
+    return variable_configs
+        .iter()
+        .filter(|(_, config)| is_variable_sensitive(config))
+        .map(|(key, config)| config.environment_variable_name(key))
+        .collect();
+}
+
+/// Returns a copy of the provided [`VariableMap`] where the values of the provided sensitive
+/// variables are obscured.
+pub fn mask_sensitive_variables(
+    variables: &VariableMap,
+    sensitive_variable_names: &Vec<String>,
+) -> VariableMap {
+    return variables
+        .iter()
+        .map(|(name, value)| {
+            let value = if sensitive_variable_names.contains(name) {
+                SENSITIVE_VALUE_MASK.to_string()
+            } else {
+                value.clone()
+            };
+
+            (name.clone(), value)
+        })
+        .collect();
+}
+
 fn is_variable_sensitive(variable_config: &VariableConfig) -> bool {
     match variable_config {
-        VariableConfig::Prompt(prompt_variable) => match prompt_variable.clone().prompt.options {
-            PromptOptionsVariant::Select(_) => false,
+        VariableConfig::Prompt(prompt_variable) => match prompt_variable.prompt_config().options {
+            PromptOptionsVariant::MultiSelect(_) | PromptOptionsVariant::Select(_) => false,
             PromptOptionsVariant::Text(text_prompt_options) => text_prompt_options.sensitive,
         },
+        VariableConfig::Keyring(_) => true,
         _ => false,
     }
 }
 
+/// Reads the value of the environment variable that the provided [`VariableConfig`] is sourced
+/// from. Empty values are treated as if the environment variable isn't set.
+fn source_environment_value(config: &VariableConfig) -> Option<String> {
+    let env_name = config.source_environment_variable()?;
+    return env::var(env_name).ok().filter(|value| !value.is_empty());
+}
+
+/// Returns the parts of the provided [`VariableConfig`] which can reference other variables.
+fn variable_templates(config: &VariableConfig) -> Vec<String> {
+    let mut templates = match config {
+        VariableConfig::ShorthandLiteral(value) => vec![value.clone()],
+        VariableConfig::Literal(literal_conf) => vec![literal_conf.value.clone()],
+        VariableConfig::Execution(execution_conf) => {
+            vec![execution_conf.execution.command_template()]
+        }
+        VariableConfig::ExitCode(exit_code_conf) => {
+            vec![exit_code_conf.execution.command_template()]
+        }
+        VariableConfig::Http(http_conf) => {
+            let mut http_templates = vec![http_conf.request.url.clone()];
+            http_templates.extend(http_conf.request.headers.values().cloned());
+            http_templates
+        }
+        VariableConfig::File(file_conf) => vec![file_conf.file.clone()],
+        VariableConfig::Keyring(keyring_conf) => vec![
+            keyring_conf.keyring.service.clone(),
+            keyring_conf.keyring.account.clone(),
+        ],
+        _ => vec![],
+    };
+
+    templates.extend(config.condition());
+    return templates;
+}
+
+/// Sorts the keys of the provided [`VariableConfigMap`] so that each variable comes after the
+/// variables it references, including those used in `required_when` conditions.
+/// Otherwise, variables keep the order they were declared in.
+fn resolution_order(
+    variable_configs: &VariableConfigMap,
+) -> Result<Vec<String>, VariableResolutionError> {
+    let dependencies: Vec<(String, Vec<String>)> = variable_configs
+        .iter()
+        .map(|(key, config)| {
+            let referenced_names: Vec<String> = variable_templates(config)
+                .iter()
+                .flat_map(|template| find_variable_references(template))
+                .collect();
+
+            // Variables referencing themselves are referencing an existing environment variable
+            let mut dependencies: Vec<String> = variable_configs
+                .iter()
+                .filter(|(other_key, other_config)| {
+                    *other_key != key
+                        && referenced_names
+                            .contains(&other_config.environment_variable_name(other_key))
+                })
+                .map(|(other_key, _)| other_key.clone())
+                .collect();
+
+            if let VariableConfig::Argument(argument_conf) = config {
+                if let Some(conditions) = &argument_conf.required_when {
+                    dependencies.extend(
+                        conditions
+                            .keys()
+                            .filter(|other_key| {
+                                *other_key != key && variable_configs.contains_key(*other_key)
+                            })
+                            .cloned(),
+                    );
+                }
+            }
+
+            (key.clone(), dependencies)
+        })
+        .collect();
+
+    let mut order: Vec<String> = Vec::new();
+    while order.len() < dependencies.len() {
+        let next = dependencies.iter().find(|(key, dependencies)| {
+            !order.contains(key)
+                && dependencies
+                    .iter()
+                    .all(|dependency| order.contains(dependency))
+        });
+
+        match next {
+            Some((key, _)) => order.push(key.clone()),
+
+            // If nothing is ready, then the remaining variables must reference each other
+            None => {
+                let keys = dependencies
+                    .iter()
+                    .map(|(key, _)| key)
+                    .filter(|key| !order.contains(key))
+                    .cloned()
+                    .collect();
+                return Err(VariableResolutionError::CircularReference { keys });
+            }
+        }
+    }
+
+    return Ok(order);
+}
+
+/// Returns the provided key, along with the keys of every variable which references it, either
+/// directly or through the other variables they reference.
+fn dependent_keys(variable_configs: &VariableConfigMap, key: &str) -> Vec<String> {
+    let mut dependent_keys = vec![key.to_string()];
+
+    // Variables can reference each other in any order, so keep following the references until
+    // no more variables are found.
+    loop {
+        let dependent_names: Vec<String> = variable_configs
+            .iter()
+            .filter(|(other_key, _)| dependent_keys.contains(other_key))
+            .map(|(other_key, config)| config.environment_variable_name(other_key))
+            .collect();
+
+        let newly_dependent: Vec<String> = variable_configs
+            .iter()
+            .filter(|(other_key, config)| {
+                if dependent_keys.contains(other_key) {
+                    return false;
+                }
+
+                let references_dependent = variable_templates(config)
+                    .iter()
+                    .flat_map(|template| find_variable_references(template))
+                    .any(|name| dependent_names.contains(&name));
+
+                // Arguments can also depend on variables through their `required_when` conditions
+                let required_when_dependent = match config {
+                    VariableConfig::Argument(argument_conf) => argument_conf
+                        .required_when
+                        .iter()
+                        .flat_map(|conditions| conditions.keys())
+                        .any(|condition_key| dependent_keys.contains(condition_key)),
+                    _ => false,
+                };
+
+                return references_dependent || required_when_dependent;
+            })
+            .map(|(other_key, _)| other_key.clone())
+            .collect();
+
+        if newly_dependent.is_empty() {
+            return dependent_keys;
+        }
+
+        dependent_keys.extend(newly_dependent);
+    }
+}
+
+/// Determines whether every variable in `conditions` has been resolved to the corresponding
+/// value.
+fn conditions_met(
+    conditions: &HashMap<String, String>,
+    variable_configs: &VariableConfigMap,
+    resolved_variables: &VariableMap,
+) -> bool {
+    return conditions.iter().all(|(key, expected_value)| {
+        // Resolved variables are keyed by their environment variable name
+        let name = match variable_configs.get(key) {
+            Some(config) => config.environment_variable_name(key),
+            None => key.clone(),
+        };
+
+        return resolved_variables.get(&name) == Some(expected_value);
+    });
+}
+
+/// Returns `true` if the provided `when` condition is truthy once variables have been substituted
+/// into it. Empty values, `false`, `no`, and `0` are falsy, and referenced variables that aren't
+/// set are treated as empty.
+fn condition_met(condition: &str, resolved_variables: &VariableMap) -> bool {
+    let mut variables = resolved_variables.clone();
+    for name in find_variable_references(condition) {
+        if lookup_variable(&name, &variables).is_none() {
+            variables.insert(name, String::new());
+        }
+    }
+
+    let value = substitute_variables(condition, &variables);
+    return match value.trim().to_lowercase().as_str() {
+        "" | "false" | "no" | "0" => false,
+        _ => true,
+    };
+}
+
+/// Substitutes variables into the values of the provided [`VariableMap`], so that variables whose
+/// values reference other variables are expanded.
+/// The first render pass is the substitution into the command itself, so values are expanded
+/// `render_passes - 1` times, until they stop changing, or until they grow too large.
+pub fn expand_variables(variables: &mut VariableMap, render_passes: u32) {
+    for _ in 1..render_passes.min(MAX_RENDER_PASSES) {
+        let expanded_variables: VariableMap = variables
+            .iter()
+            .map(|(name, value)| (name.clone(), substitute_variables(value, variables)))
+            .collect();
+
+        if expanded_variables == *variables {
+            return;
+        }
+
+        let too_large = expanded_variables
+            .values()
+            .any(|value| value.len() > MAX_EXPANDED_VALUE_LENGTH);
+        if too_large {
+            return;
+        }
+
+        *variables = expanded_variables;
+    }
+}
+
+/// Adds the provided environment variables to the [`VariableMap`], so that they're available to
+/// the commands being executed. Variables are substituted into the values first, and any existing
+/// variables with the same name are replaced.
+pub fn apply_environment(environment: &LinkedHashMap<String, String>, variables: &mut VariableMap) {
+    let values: Vec<(String, String)> = environment
+        .iter()
+        .map(|(name, value)| (name.clone(), substitute_variables(value, variables)))
+        .collect();
+
+    variables.extend(values);
+}
+
 /// Uses bash-style variable substitution to replace variable names with their values.
+/// Names which aren't in the provided [`VariableMap`] are looked up from the environment, and
+/// names which aren't set at all are left as is.
 pub fn substitute_variables(template: &str, variables: &VariableMap) -> String {
+    return substitute(template, variables, &mut Vec::new());
+}
+
+/// Returns the names of the variables referenced by the provided template which aren't set, and
+/// would be left as is by [`substitute_variables`].
+/// Variables which are only used in conditional expansions (e.g. `${name:-default}`) can be unset.
+pub fn find_missing_variables(template: &str, variables: &VariableMap) -> Vec<String> {
+    let mut missing: Vec<String> = Vec::new();
+    substitute(template, variables, &mut missing);
+
+    let mut names: Vec<String> = Vec::new();
+    for name in missing {
+        if !names.contains(&name) {
+            names.push(name);
+        }
+    }
+
+    return names;
+}
+
+/// Substitutes variables into the provided template, adding the names of any variables which
+/// aren't set to `missing`.
+fn substitute(template: &str, variables: &VariableMap, missing: &mut Vec<String>) -> String {
     let mut result = String::new();
     let mut chars = template.chars().peekable();
 
@@ -161,6 +954,40 @@ pub fn substitute_variables(template: &str, variables: &VariableMap) -> String {
                 // It's a single backslash at the end of the string
                 result.push(ch);
             }
+        } else if ch == '$' && chars.peek() == Some(&'{') {
+            // Start of a braced expression, collect everything up to the matching brace
+            chars.next();
+            let mut expression = String::new();
+            let mut depth = 1;
+            while let Some(next_ch) = chars.next() {
+                match next_ch {
+                    '{' => depth += 1,
+                    '}' => depth -= 1,
+                    _ => {}
+                }
+
+                if depth == 0 {
+                    break;
+                }
+
+                expression.push(next_ch);
+            }
+
+            if depth != 0 {
+                // Unterminated expression, leave it as is
+                result.push_str("${");
+                result.push_str(&expression);
+                continue;
+            }
+
+            match substitute_expression(&expression, variables, missing) {
+                Some(value) => result.push_str(&value),
+                None => {
+                    result.push_str("${");
+                    result.push_str(&expression);
+                    result.push('}');
+                }
+            }
         } else if ch == '$' {
             // Start of a variable, collect the variable name
             let mut var_name = String::new();
@@ -173,10 +1000,14 @@ pub fn substitute_variables(template: &str, variables: &VariableMap) -> String {
                 }
             }
             // Substitute the variable if it exists
-            if let Some(value) = variables.get(&var_name) {
-                result.push_str(value);
+            if let Some(value) = lookup_variable(&var_name, variables) {
+                result.push_str(&value);
             } else {
                 // If the variable is not found, leave it as is (including the $ sign)
+                if !var_name.is_empty() {
+                    missing.push(var_name.clone());
+                }
+
                 result.push('$');
                 result.push_str(&var_name);
             }
@@ -189,67 +1020,325 @@ pub fn substitute_variables(template: &str, variables: &VariableMap) -> String {
     result
 }
 
-#[derive(Error, Debug)]
-#[error("failed to resolve variable \"{key}\"")]
-pub enum VariableResolutionError {
-    Execution {
-        key: String,
-        source: ExecutionError,
-    },
+/// Substitutes a braced expression (the contents of `${...}`), supporting bash-style conditional
+/// expansion:
+/// - `${name}` is replaced with the value of `name`.
+/// - `${name:-word}` is replaced with the value of `name`, or `word` if `name` is unset or empty.
+/// - `${name:+word}` is replaced with `word` if `name` is set and non-empty, otherwise nothing.
+/// Omitting the colon (`${name-word}` and `${name+word}`) only checks whether `name` is set.
+///
+/// Other expansions are handled by [`transform_value`].
+/// Returns `None` if the expression can't be substituted, so it can be left as is.
+fn substitute_expression(
+    expression: &str,
+    variables: &VariableMap,
+    missing: &mut Vec<String>,
+) -> Option<String> {
+    // `${#name}` is the length of the value
+    if let Some(name) = expression.strip_prefix('#') {
+        let Some(value) = lookup_variable(name, variables) else {
+            missing.push(name.to_string());
+            return None;
+        };
 
-    #[error("failed to resolve variable \"{key}\": {status}")]
-    ExitStatus {
-        key: String,
-        status: ExitStatus,
-    },
+        return Some(value.chars().count().to_string());
+    }
 
-    Parse {
-        key: String,
-        source: FromUtf8Error,
-    },
+    let name_length = expression
+        .find(|ch: char| !(ch.is_alphanumeric() || ch == '_'))
+        .unwrap_or(expression.len());
+    let (name, operation) = expression.split_at(name_length);
+    if name.is_empty() {
+        return None;
+    }
 
-    Prompt {
-        key: String,
-        source: PromptError,
-    },
-}
+    let value = lookup_variable(name, variables);
+    if operation.is_empty() {
+        if value.is_none() {
+            missing.push(name.to_string());
+        }
 
-#[cfg(test)]
-mod tests {
-    use super::*;
-    use crate::args::MockArgumentResolver;
-    use crate::config::VariableConfig::Prompt;
-    use crate::config::{
-        BashCommandConfig, ExecutionConfigVariant, ExecutionVariableConfig, LiteralVariableConfig,
-        PromptConfig, PromptOptionsVariant, PromptVariableConfig, SelectOptionsConfig,
-        SelectPromptOptions, ShellCommandConfigVariant, VariableConfig,
+        return value;
+    }
+
+    let (check_empty, conditional_operation) = match operation.strip_prefix(':') {
+        Some(operation) => (true, operation),
+        None => (false, operation),
     };
-    use crate::exec::{ExitStatus, MockCommandExecutor, Output};
-    use crate::prompt::MockPromptExecutor;
 
-    #[test]
-    fn variable_resolver_resolves_shorthand_literal() {
-        // Arrange
-        let command_executor = MockCommandExecutor::new();
-        let mut argument_resolver = MockArgumentResolver::new();
-        argument_resolver
-            .expect_get()
-            .times(0..)
-            .returning(|_| None);
+    let is_set = match &value {
+        Some(value) => !(check_empty && value.is_empty()),
+        None => false,
+    };
 
-        let prompt_executor = MockPromptExecutor::new();
+    if let Some(word) = conditional_operation.strip_prefix('-') {
+        return match is_set {
+            true => value,
+            false => Some(substitute(word, variables, missing)),
+        };
+    }
 
-        let variable_resolver = RealVariableResolver {
-            command_executor: Box::new(command_executor),
-            prompt_executor: Box::new(prompt_executor),
-            argument_resolver: Box::new(argument_resolver),
-            dingus_options: Default::default(),
+    if let Some(word) = conditional_operation.strip_prefix('+') {
+        return match is_set {
+            true => Some(substitute(word, variables, missing)),
+            false => Some(String::new()),
         };
+    }
 
-        let name = "name";
-        let value = "Dingus";
-        let mut variable_configs = VariableConfigMap::new();
-        variable_configs.insert(
+    let Some(value) = value else {
+        missing.push(name.to_string());
+        return None;
+    };
+
+    return transform_value(&value, operation, variables, missing);
+}
+
+/// Returns the value of the variable with the provided name, falling back to the environment
+/// variable with the same name.
+fn lookup_variable(name: &str, variables: &VariableMap) -> Option<String> {
+    if name.is_empty() {
+        return None;
+    }
+
+    return variables.get(name).cloned().or_else(|| env::var(name).ok());
+}
+
+/// Transforms the provided value using bash-style parameter expansion:
+/// - `^^` and `,,` convert the value to upper or lower case, `^` and `,` only convert the first
+///   character.
+/// - `#prefix` and `%suffix` remove the prefix or suffix from the value, if it has one.
+/// - `/find/replace` replaces the first occurrence of `find` with `replace`, and `//find/replace`
+///   replaces all of them.
+///
+/// Patterns are matched literally, and can reference other variables.
+/// Returns `None` if the operation isn't supported.
+fn transform_value(
+    value: &str,
+    operation: &str,
+    variables: &VariableMap,
+    missing: &mut Vec<String>,
+) -> Option<String> {
+    if operation == "^^" {
+        return Some(value.to_uppercase());
+    }
+
+    if operation == ",," {
+        return Some(value.to_lowercase());
+    }
+
+    if operation == "^" || operation == "," {
+        let mut chars = value.chars();
+        return Some(match chars.next() {
+            Some(first) if operation == "^" => first.to_uppercase().chain(chars).collect(),
+            Some(first) => first.to_lowercase().chain(chars).collect(),
+            None => String::new(),
+        });
+    }
+
+    // Patterns are matched literally, so the longest and shortest matches are the same
+    if let Some(prefix) = operation
+        .strip_prefix("##")
+        .or_else(|| operation.strip_prefix('#'))
+    {
+        let prefix = substitute(prefix, variables, missing);
+        return Some(value.strip_prefix(&prefix).unwrap_or(value).to_string());
+    }
+
+    if let Some(suffix) = operation
+        .strip_prefix("%%")
+        .or_else(|| operation.strip_prefix('%'))
+    {
+        let suffix = substitute(suffix, variables, missing);
+        return Some(value.strip_suffix(&suffix).unwrap_or(value).to_string());
+    }
+
+    if let Some(replacement) = operation.strip_prefix('/') {
+        let (replacement, replace_all) = match replacement.strip_prefix('/') {
+            Some(replacement) => (replacement, true),
+            None => (replacement, false),
+        };
+
+        let (find, replace) = replacement.split_once('/').unwrap_or((replacement, ""));
+        let find = substitute(find, variables, missing);
+        let replace = substitute(replace, variables, missing);
+        if find.is_empty() {
+            return Some(value.to_string());
+        }
+
+        return Some(match replace_all {
+            true => value.replace(&find, &replace),
+            false => value.replacen(&find, &replace, 1),
+        });
+    }
+
+    return None;
+}
+
+/// Finds the names of all variables referenced in the provided template using bash-style syntax
+/// (`$name` or `${name}`).
+/// Escaped references (`\$name`) are ignored.
+pub fn find_variable_references(template: &str) -> Vec<String> {
+    let mut references: Vec<String> = vec![];
+    let mut chars = template.chars().peekable();
+
+    while let Some(ch) = chars.next() {
+        if ch == '\\' {
+            // Skip over escaped '$'
+            if let Some('$') = chars.peek() {
+                chars.next();
+            }
+        } else if ch == '$' {
+            let braced = chars.peek() == Some(&'{');
+            if braced {
+                chars.next();
+
+                // Skip over the `#` in `${#name}`
+                if chars.peek() == Some(&'#') {
+                    chars.next();
+                }
+            }
+
+            let mut var_name = String::new();
+            while let Some(&next_ch) = chars.peek() {
+                if next_ch.is_alphanumeric() || next_ch == '_' {
+                    var_name.push(next_ch);
+                    chars.next();
+                } else {
+                    break;
+                }
+            }
+
+            if braced && chars.peek() == Some(&'}') {
+                chars.next();
+            }
+
+            if !var_name.is_empty() && !references.contains(&var_name) {
+                references.push(var_name);
+            }
+        }
+    }
+
+    references
+}
+
+#[derive(Error, Debug)]
+#[error("failed to resolve variable \"{key}\"")]
+pub enum VariableResolutionError {
+    Execution {
+        key: String,
+        source: ExecutionError,
+    },
+
+    #[error("failed to resolve variable \"{key}\": {status}")]
+    ExitStatus {
+        key: String,
+        status: ExitStatus,
+    },
+
+    Parse {
+        key: String,
+        source: FromUtf8Error,
+    },
+
+    Prompt {
+        key: String,
+        source: PromptError,
+    },
+
+    Http {
+        key: String,
+        source: RemoteError,
+    },
+
+    Keyring {
+        key: String,
+        source: KeyringError,
+    },
+
+    #[error(
+        "failed to resolve variable \"{key}\": an argument is required{}",
+        format_suggestions(suggestions)
+    )]
+    MissingArgument {
+        key: String,
+
+        /// The ways that the argument could be provided.
+        suggestions: Vec<String>,
+    },
+
+    #[error("invalid validation pattern for variable \"{key}\"")]
+    InvalidPattern {
+        key: String,
+        source: regex::Error,
+    },
+
+    #[error("invalid value for variable \"{key}\": {message}")]
+    Invalid {
+        key: String,
+        message: String,
+    },
+
+    #[error("failed to resolve variable \"{key}\": failed to read {}", path.display())]
+    ReadFile {
+        key: String,
+        path: PathBuf,
+        source: io::Error,
+    },
+
+    #[error("circular reference detected between variables: {}", keys.join(", "))]
+    CircularReference {
+        keys: Vec<String>,
+    },
+
+    #[error("failed to review answers")]
+    Review {
+        source: PromptError,
+    },
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::args::{ClapArgumentResolver, MockArgumentResolver};
+    use crate::config::VariableConfig::Prompt;
+    use crate::config::{parse_config, Platform};
+    use crate::config::{
+        ArgumentConfigVariant, ArgumentVariableConfig, BashCommandConfig, ExecutionConfigVariant,
+        ExecutionVariableConfig, ExitCodeVariableConfig, LiteralVariableConfig,
+        NamedArgumentConfig, PositionalArgumentConfig, PromptConfig, PromptConfigVariant,
+        PromptOptionsVariant, PromptVariableConfig, RawCommandConfigVariant, SelectOptionsConfig,
+        SelectPromptOptions, ShellCommandConfigVariant, TextPromptOptions, VariableConfig,
+    };
+    use crate::exec::{ExitStatus, MockCommandExecutor, Output};
+    use crate::keyring::MockKeyring;
+    use crate::prompt::MockPromptExecutor;
+    use mockall::Sequence;
+    use tempfile::TempDir;
+
+    #[test]
+    fn variable_resolver_resolves_shorthand_literal() {
+        // Arrange
+        let command_executor = MockCommandExecutor::new();
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+
+        let prompt_executor = MockPromptExecutor::new();
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(command_executor),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        let name = "name";
+        let value = "Dingus";
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
             name.to_string(),
             VariableConfig::ShorthandLiteral(value.to_string()),
         );
@@ -280,6 +1369,7 @@ mod tests {
             command_executor: Box::new(command_executor),
             prompt_executor: Box::new(prompt_executor),
             argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
             dingus_options: Default::default(),
         };
 
@@ -292,6 +1382,10 @@ mod tests {
                 value: value.to_string(),
                 argument: None,
                 environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -330,6 +1424,7 @@ mod tests {
             command_executor: Box::new(command_executor),
             prompt_executor: Box::new(prompt_executor),
             argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
             dingus_options: Default::default(),
         };
 
@@ -344,8 +1439,14 @@ mod tests {
                     BashCommandConfig {
                         working_directory: None,
                         command: format!("echo \"{value}\""),
+                        script_file: false,
                     },
                 )),
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
             }),
         );
 
@@ -361,9 +1462,50 @@ mod tests {
     }
 
     #[test]
-    fn variable_resolver_resolves_text_prompt_variable() {
+    fn variable_resolver_reuses_output_of_identical_commands() {
         // Arrange
-        let command_executor = MockCommandExecutor::new();
+        let yaml = "variables:
+    source_region:
+        execute: aws ec2 describe-regions
+    target_region:
+        execute: aws ec2 describe-regions
+    has_regions:
+        exit_code: aws ec2 describe-regions
+    timestamp:
+        execute: date +%s%N
+    other_timestamp:
+        execute: date +%s%N
+        no_cache: true
+commands:
+    demo:
+        action: echo $source_region";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .withf(|execution_config, _| {
+                execution_config.command_template() == "aws ec2 describe-regions"
+            })
+            .once()
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Success,
+                    stdout: "ap-southeast-2".as_bytes().to_vec(),
+                    stderr: vec![],
+                })
+            });
+        command_executor
+            .expect_get_output()
+            .withf(|execution_config, _| execution_config.command_template() == "date +%s%N")
+            .times(2)
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Success,
+                    stdout: "1700000000".as_bytes().to_vec(),
+                    stderr: vec![],
+                })
+            });
 
         let mut argument_resolver = MockArgumentResolver::new();
         argument_resolver
@@ -371,49 +1513,51 @@ mod tests {
             .times(0..)
             .returning(|_| None);
 
-        let value = "Dingus";
-        let mut prompt_executor = MockPromptExecutor::new();
-        prompt_executor
-            .expect_execute()
-            .once()
-            .returning(|_| Ok(value.to_string()));
-
         let variable_resolver = RealVariableResolver {
             command_executor: Box::new(command_executor),
-            prompt_executor: Box::new(prompt_executor),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
             argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
             dingus_options: Default::default(),
         };
 
-        let name = "name";
-        let mut variable_configs = VariableConfigMap::new();
-        variable_configs.insert(
-            name.to_string(),
-            Prompt(PromptVariableConfig {
-                argument: None,
-                environment_variable_name: None,
-                prompt: PromptConfig {
-                    message: "Enter your name".to_string(),
-                    options: Default::default(),
-                },
-            }),
-        );
-
         // Act
-        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
 
         // Assert
-        assert!(!resolved_variables.is_err());
-
-        let binding = resolved_variables.unwrap().clone();
-        let resolved_value = binding.get(name).unwrap().as_str();
-        assert_eq!(resolved_value, value);
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables["source_region"], "ap-southeast-2");
+        assert_eq!(resolved_variables["target_region"], "ap-southeast-2");
+        assert_eq!(resolved_variables["has_regions"], "0");
+        assert_eq!(resolved_variables["other_timestamp"], "1700000000");
     }
 
     #[test]
-    fn variable_resolver_resolves_select_prompt_variable() {
+    fn variable_resolver_resolves_referenced_variables_first() {
         // Arrange
-        let command_executor = MockCommandExecutor::new();
+        let yaml = "variables:
+    uri:
+        value: s3://$bucket/reports
+    objects:
+        execute: aws s3 ls $uri
+    bucket: my-bucket
+commands:
+    demo:
+        action: echo $objects";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor
+            .expect_get_output()
+            .withf(|_, variables| variables.get("uri").unwrap() == "s3://my-bucket/reports")
+            .once()
+            .returning(|_, _| {
+                Ok(Output {
+                    status: ExitStatus::Success,
+                    stdout: "report.csv".as_bytes().to_vec(),
+                    stderr: vec![],
+                })
+            });
 
         let mut argument_resolver = MockArgumentResolver::new();
         argument_resolver
@@ -421,113 +1565,1462 @@ mod tests {
             .times(0..)
             .returning(|_| None);
 
-        let value = "Dingus";
-        let mut prompt_executor = MockPromptExecutor::new();
-        prompt_executor
-            .expect_execute()
-            .once()
-            .returning(|_| Ok(value.to_string()));
-
         let variable_resolver = RealVariableResolver {
             command_executor: Box::new(command_executor),
-            prompt_executor: Box::new(prompt_executor),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
             argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
             dingus_options: Default::default(),
         };
 
-        let name = "name";
-        let mut variable_configs = VariableConfigMap::new();
-        variable_configs.insert(
-            name.to_string(),
-            Prompt(PromptVariableConfig {
-                argument: None,
-                environment_variable_name: None,
-                prompt: PromptConfig {
-                    message: "Select your name".to_string(),
-                    options: PromptOptionsVariant::Select(SelectPromptOptions {
-                        options: SelectOptionsConfig::Literal(vec![
-                            "Alice".to_string(),
-                            "Bob".to_string(),
-                            "Charlie".to_string(),
-                            "Dingus".to_string(),
-                        ]),
-                    }),
-                },
-            }),
-        );
-
         // Act
-        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
 
         // Assert
-        assert!(!resolved_variables.is_err());
-
-        let binding = resolved_variables.unwrap().clone();
-        let resolved_value = binding.get(name).unwrap().as_str();
-        assert_eq!(resolved_value, value);
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables["uri"], "s3://my-bucket/reports");
+        assert_eq!(resolved_variables["objects"], "report.csv");
     }
 
     #[test]
-    fn variable_resolver_uses_custom_env_var() {
+    fn variable_resolver_skips_variables_when_condition_is_falsy() {
         // Arrange
-        let command_executor = MockCommandExecutor::new();
+        let yaml = "variables:
+    branch:
+        when: $deploy
+        prompt: Which branch do you want to deploy?
+    deploy: \"false\"
+commands:
+    demo:
+        action: echo $branch";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor.expect_execute().times(0);
+
         let mut argument_resolver = MockArgumentResolver::new();
         argument_resolver
             .expect_get()
             .times(0..)
             .returning(|_| None);
-        let prompt_executor = MockPromptExecutor::new();
 
         let variable_resolver = RealVariableResolver {
-            command_executor: Box::new(command_executor),
+            command_executor: Box::new(MockCommandExecutor::new()),
             prompt_executor: Box::new(prompt_executor),
             argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
             dingus_options: Default::default(),
         };
 
-        let name = "name";
-        let value = "Dingus";
-        let env_var_name = "USER_NAME";
-        let mut variable_configs = VariableConfigMap::new();
-        variable_configs.insert(
-            name.to_string(),
-            VariableConfig::Literal(LiteralVariableConfig {
-                value: value.to_string(),
-                argument: None,
-                environment_variable_name: Some(env_var_name.to_string()),
-            }),
-        );
-
         // Act
-        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
 
         // Assert
-        assert!(!resolved_variables.is_err());
-
-        let binding = resolved_variables.unwrap().clone();
-        let resolved_value = binding.get(env_var_name).unwrap().as_str();
-        assert_eq!(resolved_value, value);
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables.get("branch"), None);
+        assert_eq!(resolved_variables["deploy"], "false");
     }
 
     #[test]
-    fn substitute_variables_substitutes_variables() {
+    fn variable_resolver_asks_again_for_changed_answers_and_their_dependents() {
         // Arrange
-        let template = "Hello, $name! You are $age years old.";
-        let mut variables = VariableMap::new();
-        variables.insert("name".to_string(), "Dingus".to_string());
-        variables.insert("age".to_string(), "100".to_string());
+        let yaml = "variables:
+    name:
+        prompt: What's your name?
+    environment:
+        prompt: Which environment?
+    region:
+        when: $environment
+        prompt: Which region?
+    greeting: Hello, $name
+commands:
+    demo:
+        action: echo $greeting";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let answers = vec![
+            ("What's your name?", "Dingus"),
+            ("Which environment?", "staging"),
+            ("Which region?", "us-east-1"),
+            (
+                "Do you want to change any of your answers?",
+                "environment: staging",
+            ),
+            ("Which environment?", "production"),
+            ("Which region?", "eu-west-1"),
+            ("Do you want to change any of your answers?", "Continue"),
+        ];
+
+        let mut seq = Sequence::new();
+        let mut prompt_executor = MockPromptExecutor::new();
+        for (message, answer) in answers {
+            prompt_executor
+                .expect_execute()
+                .once()
+                .in_sequence(&mut seq)
+                .withf(move |prompt_config| prompt_config.message == message)
+                .returning(move |_| Ok(answer.to_string()));
+        }
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: DingusOptions {
+                review_answers: true,
+                ..Default::default()
+            },
+        };
 
         // Act
-        let result = substitute_variables(template, &variables);
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
 
         // Assert
-        assert_eq!(result, "Hello, Dingus! You are 100 years old.")
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables["name"], "Dingus");
+        assert_eq!(resolved_variables["environment"], "production");
+        assert_eq!(resolved_variables["region"], "eu-west-1");
+        assert_eq!(resolved_variables["greeting"], "Hello, Dingus");
     }
 
     #[test]
-    fn substitute_variables_ignores_escaped() {
+    fn dependent_keys_follows_references() {
         // Arrange
-        let template = "Hello, $name! You are \\$age years old.";
+        let yaml = "variables:
+    environment:
+        prompt: Which environment?
+    url: https://$environment.example.com
+    health:
+        when: $url
+        prompt: Check the health of the service?
+    name:
+        prompt: What's your name?
+commands:
+    demo:
+        action: echo $name";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        // Act
+        let keys = dependent_keys(&config.variables, "environment");
+
+        // Assert
+        assert_eq!(keys, vec!["environment", "url", "health"]);
+    }
+
+    #[test]
+    fn variable_resolver_resolves_variables_when_condition_is_truthy() {
+        // Arrange
+        let yaml = "variables:
+    branch:
+        when: $deploy
+        prompt: Which branch do you want to deploy?
+    deploy: \"true\"
+commands:
+    demo:
+        action: echo $branch";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .once()
+            .returning(|_| Ok("main".to_string()));
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables["branch"], "main");
+    }
+
+    #[test]
+    fn condition_met_uses_truthiness_rules() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("yes".to_string(), "true".to_string());
+        variables.insert("no".to_string(), "False".to_string());
+        variables.insert("zero".to_string(), "0".to_string());
+        variables.insert("empty".to_string(), "".to_string());
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        let conditions = vec![
+            "$yes",
+            "$name",
+            "$no",
+            "$zero",
+            "$empty",
+            "$unset",
+            "${empty:-true}",
+        ];
+
+        // Act
+        let results: Vec<bool> = conditions
+            .iter()
+            .map(|condition| condition_met(condition, &variables))
+            .collect();
+
+        // Assert
+        assert_eq!(results, vec![true, true, false, false, false, false, true]);
+    }
+
+    #[test]
+    fn variable_resolver_prefers_environment_variables_over_values() {
+        // Arrange
+        env::set_var("DINGUS_TEST_REGION", "ap-southeast-2");
+        env::set_var("DINGUS_TEST_ACCOUNT", "123456789");
+        env::remove_var("DINGUS_TEST_UNSET_PROFILE");
+        let yaml = "variables:
+    region:
+        value: us-east-1
+        arg: region
+        from_env: DINGUS_TEST_REGION
+    account:
+        execute: aws sts get-caller-identity
+        from_env: DINGUS_TEST_ACCOUNT
+    profile:
+        value: default
+        from_env: DINGUS_TEST_UNSET_PROFILE
+    user:
+        value: dingus
+        from_env: DINGUS_TEST_REGION
+commands:
+    demo:
+        action: echo $region";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        // Arguments still take precedence over the environment
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .returning(|key| match key.as_str() {
+                "user" => Some("godzilla".to_string()),
+                _ => None,
+            });
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables["region"], "ap-southeast-2");
+        assert_eq!(resolved_variables["account"], "123456789");
+        assert_eq!(resolved_variables["profile"], "default");
+        assert_eq!(resolved_variables["user"], "godzilla");
+    }
+
+    #[test]
+    fn missing_argument_error_suggests_environment_variable() {
+        // Arrange
+        env::remove_var("DINGUS_TEST_UNSET_TOKEN");
+        let yaml = "variables:
+    token:
+        arg: token
+        from_env: DINGUS_TEST_UNSET_TOKEN
+        required_when: {}
+commands:
+    demo:
+        action: echo $token";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver.expect_get().returning(|_| None);
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        // Act
+        let result = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(VariableResolutionError::MissingArgument { key, suggestions })
+                if key == "token"
+                    && suggestions.contains(&"set the DINGUS_TEST_UNSET_TOKEN environment variable".to_string())
+        ));
+    }
+
+    #[test]
+    fn variable_resolver_reads_file_variables() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(temp_dir.path().join("token"), "  s3cr3t\n").unwrap();
+        let yaml = format!(
+            "variables:
+    directory: {}
+    token:
+        file: $directory/token
+    profile:
+        file: $directory/profile
+        required: false
+commands:
+    demo:
+        action: echo $token",
+            temp_dir.path().to_str().unwrap()
+        );
+        let config = parse_config(&yaml, Platform::Linux).unwrap();
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver.expect_get().returning(|_| None);
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables["token"], "s3cr3t");
+        assert_eq!(resolved_variables.get("profile"), None);
+    }
+
+    #[test]
+    fn variable_resolver_reads_keyring_variables() {
+        // Arrange
+        let yaml = "variables:
+    environment: staging
+    api_key:
+        keyring:
+            service: my-app-$environment
+            account: api-key
+commands:
+    demo:
+        action: echo $api_key";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver.expect_get().returning(|_| None);
+
+        let mut keyring = MockKeyring::new();
+        keyring
+            .expect_get_secret()
+            .withf(|service, account| service == "my-app-staging" && account == "api-key")
+            .once()
+            .returning(|_, _| Ok("hunter2".to_string()));
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(keyring),
+            dingus_options: Default::default(),
+        };
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        assert_eq!(resolved_variables.unwrap()["api_key"], "hunter2");
+        assert_eq!(
+            sensitive_variable_names(&config.variables),
+            vec!["api_key".to_string()]
+        );
+    }
+
+    #[test]
+    fn variable_resolver_fails_for_missing_keyring_entry() {
+        // Arrange
+        let yaml = "variables:
+    api_key:
+        keyring:
+            service: my-app
+            account: api-key
+commands:
+    demo:
+        action: echo $api_key";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver.expect_get().returning(|_| None);
+
+        let mut keyring = MockKeyring::new();
+        keyring.expect_get_secret().returning(|service, account| {
+            Err(KeyringError::NotFound {
+                service: service.to_string(),
+                account: account.to_string(),
+            })
+        });
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(keyring),
+            dingus_options: Default::default(),
+        };
+
+        // Act
+        let result = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(VariableResolutionError::Keyring {
+                key,
+                source: KeyringError::NotFound { .. }
+            }) if key == "api_key"
+        ));
+    }
+
+    #[test]
+    fn variable_resolver_fails_for_missing_required_file() {
+        // Arrange
+        let temp_dir = TempDir::new().unwrap();
+        let yaml = format!(
+            "variables:
+    token:
+        file: {}/token
+commands:
+    demo:
+        action: echo $token",
+            temp_dir.path().to_str().unwrap()
+        );
+        let config = parse_config(&yaml, Platform::Linux).unwrap();
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver.expect_get().returning(|_| None);
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        // Act
+        let result = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(VariableResolutionError::ReadFile { key, path, .. })
+                if key == "token" && path == temp_dir.path().join("token")
+        ));
+    }
+
+    #[test]
+    fn variable_resolver_fails_for_circular_references() {
+        // Arrange
+        let yaml = "variables:
+    name: Dingus
+    first: $second
+    second:
+        execute: echo $first
+commands:
+    demo:
+        action: echo $first";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        // Act
+        let result = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(VariableResolutionError::CircularReference { keys })
+                if keys == vec!["first".to_string(), "second".to_string()]
+        ));
+    }
+
+    #[test]
+    fn resolution_order_keeps_declaration_order_for_independent_variables() {
+        // Arrange
+        let yaml = "variables:
+    PATH: $PATH:./bin
+    greeting: Hello, $name!
+    name: Dingus
+    farewell: Goodbye!
+commands:
+    demo:
+        action: echo $greeting";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        // Act
+        let order = resolution_order(&config.variables).unwrap();
+
+        // Assert
+        assert_eq!(order, vec!["PATH", "name", "greeting", "farewell"]);
+    }
+
+    #[test]
+    fn variable_resolver_strips_ansi_escapes_from_execution_variable() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor.expect_get_output().returning(|_, _| {
+            Ok(Output {
+                status: ExitStatus::Success,
+                stdout: "\x1b[1;32mDingus\x1b[0m\n".as_bytes().to_vec(),
+                stderr: vec![],
+            })
+        });
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+        let prompt_executor = MockPromptExecutor::new();
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(command_executor),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        let name = "name";
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            name.to_string(),
+            VariableConfig::Execution(ExecutionVariableConfig {
+                argument: None,
+                environment_variable_name: None,
+                execution: ExecutionConfigVariant::RawCommand(RawCommandConfigVariant::Shorthand(
+                    "ls --color=always".to_string(),
+                )),
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        let binding = resolved_variables.unwrap().clone();
+        let resolved_value = binding.get(name).unwrap().as_str();
+        assert_eq!(resolved_value, "Dingus");
+    }
+
+    #[test]
+    fn variable_resolver_resolves_exit_code_variable() {
+        // Arrange
+        let mut command_executor = MockCommandExecutor::new();
+        command_executor.expect_get_output().returning(|_, _| {
+            Ok(Output {
+                status: ExitStatus::Fail(42),
+                stdout: vec![],
+                stderr: "Something went wrong".as_bytes().to_vec(),
+            })
+        });
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+        let prompt_executor = MockPromptExecutor::new();
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(command_executor),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        let name = "status";
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            name.to_string(),
+            VariableConfig::ExitCode(ExitCodeVariableConfig {
+                description: None,
+                argument: None,
+                environment_variable_name: None,
+                execution: ExecutionConfigVariant::ShellCommand(ShellCommandConfigVariant::Bash(
+                    BashCommandConfig {
+                        working_directory: None,
+                        command: "exit 42".to_string(),
+                        script_file: false,
+                    },
+                )),
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        assert!(!resolved_variables.is_err());
+
+        let binding = resolved_variables.unwrap().clone();
+        let resolved_value = binding.get(name).unwrap().as_str();
+        assert_eq!(resolved_value, "42");
+    }
+
+    #[test]
+    fn variable_resolver_resolves_text_prompt_variable() {
+        // Arrange
+        let command_executor = MockCommandExecutor::new();
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+
+        let value = "Dingus";
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .once()
+            .returning(|_| Ok(value.to_string()));
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(command_executor),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        let name = "name";
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            name.to_string(),
+            Prompt(PromptVariableConfig {
+                argument: None,
+                environment_variable_name: None,
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
+                    message: "Enter your name".to_string(),
+                    options: Default::default(),
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        assert!(!resolved_variables.is_err());
+
+        let binding = resolved_variables.unwrap().clone();
+        let resolved_value = binding.get(name).unwrap().as_str();
+        assert_eq!(resolved_value, value);
+    }
+
+    #[test]
+    fn variable_resolver_resolves_select_prompt_variable() {
+        // Arrange
+        let command_executor = MockCommandExecutor::new();
+
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+
+        let value = "Dingus";
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .once()
+            .returning(|_| Ok(value.to_string()));
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(command_executor),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        let name = "name";
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            name.to_string(),
+            Prompt(PromptVariableConfig {
+                argument: None,
+                environment_variable_name: None,
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
+                    message: "Select your name".to_string(),
+                    options: PromptOptionsVariant::Select(SelectPromptOptions {
+                        options: SelectOptionsConfig::Literal(vec![
+                            "Alice".to_string(),
+                            "Bob".to_string(),
+                            "Charlie".to_string(),
+                            "Dingus".to_string(),
+                        ]),
+                        allow_other: false,
+                    }),
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        assert!(!resolved_variables.is_err());
+
+        let binding = resolved_variables.unwrap().clone();
+        let resolved_value = binding.get(name).unwrap().as_str();
+        assert_eq!(resolved_value, value);
+    }
+
+    #[test]
+    fn variable_resolver_uses_custom_env_var() {
+        // Arrange
+        let command_executor = MockCommandExecutor::new();
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+        let prompt_executor = MockPromptExecutor::new();
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(command_executor),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        let name = "name";
+        let value = "Dingus";
+        let env_var_name = "USER_NAME";
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            name.to_string(),
+            VariableConfig::Literal(LiteralVariableConfig {
+                value: value.to_string(),
+                argument: None,
+                environment_variable_name: Some(env_var_name.to_string()),
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        assert!(!resolved_variables.is_err());
+
+        let binding = resolved_variables.unwrap().clone();
+        let resolved_value = binding.get(env_var_name).unwrap().as_str();
+        assert_eq!(resolved_value, value);
+    }
+
+    #[test]
+    fn variable_resolver_fails_when_required_argument_is_missing() {
+        // Arrange
+        let variable_resolver = required_when_variable_resolver("aws");
+        let variable_configs = required_when_variable_configs();
+
+        // Act
+        let result = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        assert!(matches!(
+            result,
+            Err(VariableResolutionError::MissingArgument { key, .. }) if key == "key"
+        ));
+    }
+
+    #[test]
+    fn missing_argument_error_suggests_how_to_provide_it() {
+        // Arrange
+        let variable_resolver = required_when_variable_resolver("aws");
+        let variable_configs = required_when_variable_configs();
+
+        // Act
+        let result = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        assert_eq!(
+            result.unwrap_err().to_string(),
+            "failed to resolve variable \"key\": an argument is required
+  - use the --key argument
+  - use --var key=<value> before the command
+  - set DINGUS_ALLOW_STDIN=true to read it from stdin"
+        );
+    }
+
+    #[test]
+    fn missing_argument_suggestions_describe_named_and_positional_arguments() {
+        // Arrange
+        let named = ArgumentConfigVariant::Named(NamedArgumentConfig {
+            description: None,
+            long: "api-key".to_string(),
+            short: Some('k'),
+        });
+        let positional = ArgumentConfigVariant::Positional(PositionalArgumentConfig {
+            description: None,
+            position: 2,
+        });
+
+        // Act
+        let named_suggestions = missing_argument_suggestions("key", &named, &None);
+        let positional_suggestions = missing_argument_suggestions("key", &positional, &None);
+
+        // Assert
+        assert_eq!(named_suggestions[0], "use the -k or --api-key argument");
+        assert_eq!(
+            positional_suggestions[0],
+            "provide it as positional argument 2"
+        );
+    }
+
+    #[test]
+    fn variable_resolver_reads_missing_argument_from_stdin() {
+        // Arrange
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .returning(|key| match key.as_str() {
+                "provider" => Some("aws".to_string()),
+                _ => None,
+            });
+
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_read_line()
+            .withf(|label| label == "key: ")
+            .once()
+            .returning(|_| Ok("secret".to_string()));
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: DingusOptions {
+                allow_stdin: true,
+                ..Default::default()
+            },
+        };
+        let variable_configs = required_when_variable_configs();
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables.get("key").unwrap(), "secret");
+    }
+
+    fn validated_variable_configs() -> VariableConfigMap {
+        let yaml = "variables:
+    version:
+        arg: version
+        value: 1.0.0
+        validate:
+            - pattern: ^\\d+\\.\\d+\\.\\d+$
+              message: the version must look like 1.2.3
+commands:
+    release:
+        action: ./release.sh $version";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+        return config.variables;
+    }
+
+    fn argument_resolver_with_version(version: &'static str) -> MockArgumentResolver {
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .returning(move |key| match key.as_str() {
+                "version" => Some(version.to_string()),
+                _ => None,
+            });
+
+        return argument_resolver;
+    }
+
+    #[test]
+    fn variable_resolver_fails_for_argument_failing_validation() {
+        // Arrange
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver_with_version("latest")),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: DingusOptions::default(),
+        };
+
+        // Act
+        let result = variable_resolver.resolve_variables(&validated_variable_configs());
+
+        // Assert
+        let err = result.unwrap_err();
+        assert_eq!(
+            err.to_string(),
+            "invalid value for variable \"version\": the version must look like 1.2.3"
+        );
+    }
+
+    #[test]
+    fn variable_resolver_allows_valid_argument() {
+        // Arrange
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver_with_version("2.3.4")),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: DingusOptions::default(),
+        };
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&validated_variable_configs());
+
+        // Assert
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables.get("version").unwrap(), "2.3.4");
+    }
+
+    #[test]
+    fn variable_resolver_prefers_overrides_over_arguments_and_prompts() {
+        // Arrange
+        let yaml = "variables:
+    name:
+        value: Dingus
+        arg: name
+    food:
+        prompt: What's your favourite food?
+commands:
+    greet:
+        action: echo $name likes $food";
+        let config = parse_config(&yaml.to_string(), Platform::Linux).unwrap();
+
+        let matches = clap::Command::new("dingus")
+            .arg(clap::Arg::new("name").long("name"))
+            .get_matches_from(vec!["dingus", "--name", "Mothra"]);
+
+        let mut overrides = HashMap::new();
+        overrides.insert("name".to_string(), "Godzilla".to_string());
+        overrides.insert("food".to_string(), "Pizza".to_string());
+
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor.expect_execute().times(0);
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(
+                ClapArgumentResolver::from_arg_matches(&matches).with_overrides(&overrides),
+            ),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: DingusOptions::default(),
+        };
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&config.variables);
+
+        // Assert
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables.get("name").unwrap(), "Godzilla");
+        assert_eq!(resolved_variables.get("food").unwrap(), "Pizza");
+    }
+
+    #[test]
+    fn variable_resolver_allows_missing_argument_when_not_required() {
+        // Arrange
+        let variable_resolver = required_when_variable_resolver("gcp");
+        let variable_configs = required_when_variable_configs();
+
+        // Act
+        let resolved_variables = variable_resolver.resolve_variables(&variable_configs);
+
+        // Assert
+        let resolved_variables = resolved_variables.unwrap();
+        assert_eq!(resolved_variables.get("provider").unwrap(), "gcp");
+        assert!(!resolved_variables.contains_key("key"));
+    }
+
+    #[test]
+    fn remove_unreferenced_prompts_skips_unreferenced_prompt() {
+        // Arrange
+        let command_executor = MockCommandExecutor::new();
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .times(0..)
+            .returning(|_| None);
+
+        let mut prompt_executor = MockPromptExecutor::new();
+        prompt_executor
+            .expect_execute()
+            .withf(|prompt_config| prompt_config.message == "Enter your name")
+            .once()
+            .returning(|_| Ok("Dingus".to_string()));
+        prompt_executor
+            .expect_execute()
+            .withf(|prompt_config| prompt_config.message == "Enter your password")
+            .times(0);
+
+        let variable_resolver = RealVariableResolver {
+            command_executor: Box::new(command_executor),
+            prompt_executor: Box::new(prompt_executor),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert("name".to_string(), prompt_variable("Enter your name"));
+        variable_configs.insert(
+            "password".to_string(),
+            prompt_variable("Enter your password"),
+        );
+        variable_configs.insert(
+            "greeting".to_string(),
+            VariableConfig::ShorthandLiteral("Hello".to_string()),
+        );
+
+        let templates = vec!["echo $greeting, $name!".to_string()];
+
+        // Act
+        let variable_configs = remove_unreferenced_prompts(&variable_configs, &templates);
+        let resolved_variables = variable_resolver
+            .resolve_variables(&variable_configs)
+            .unwrap();
+
+        // Assert
+        assert_eq!(resolved_variables.get("name").unwrap(), "Dingus");
+        assert_eq!(resolved_variables.get("greeting").unwrap(), "Hello");
+        assert!(!resolved_variables.contains_key("password"));
+    }
+
+    #[test]
+    fn remove_unreferenced_prompts_keeps_indirectly_referenced_prompt() {
+        // Arrange
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert("name".to_string(), prompt_variable("Enter your name"));
+        variable_configs.insert("unused".to_string(), prompt_variable("Enter something"));
+        variable_configs.insert(
+            "greeting".to_string(),
+            VariableConfig::Execution(ExecutionVariableConfig {
+                argument: None,
+                environment_variable_name: None,
+                execution: ExecutionConfigVariant::ShellCommand(ShellCommandConfigVariant::Bash(
+                    BashCommandConfig {
+                        working_directory: None,
+                        command: "echo Hello, ${name}!".to_string(),
+                        script_file: false,
+                    },
+                )),
+                description: None,
+                validate: vec![],
+                no_cache: false,
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        let templates = vec!["echo $greeting".to_string()];
+
+        // Act
+        let variable_configs = remove_unreferenced_prompts(&variable_configs, &templates);
+
+        // Assert
+        let keys: Vec<&String> = variable_configs.keys().collect();
+        assert_eq!(keys, vec!["name", "greeting"]);
+    }
+
+    #[test]
+    fn format_exports_formats_bash_exports() {
+        // Arrange
+        let (variable_configs, variables) = export_variables();
+
+        // Act
+        let exports = format_exports(&variable_configs, &variables, &ExportFormat::Bash, true);
+
+        // Assert
+        assert_eq!(
+            exports,
+            "export name='Dingus'\n\
+            export GREETING='It'\\''s $name \"the\" dingus'\n\
+            export password='hunter2'"
+        );
+    }
+
+    #[test]
+    fn format_exports_formats_fish_exports() {
+        // Arrange
+        let (variable_configs, variables) = export_variables();
+
+        // Act
+        let exports = format_exports(&variable_configs, &variables, &ExportFormat::Fish, true);
+
+        // Assert
+        assert_eq!(
+            exports,
+            "set -gx name 'Dingus'\n\
+            set -gx GREETING 'It\\'s $name \"the\" dingus'\n\
+            set -gx password 'hunter2'"
+        );
+    }
+
+    #[test]
+    fn format_exports_escapes_backslashes_for_fish() {
+        // Arrange
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            "path".to_string(),
+            VariableConfig::ShorthandLiteral("C:\\Users".to_string()),
+        );
+
+        let mut variables = VariableMap::new();
+        variables.insert("path".to_string(), "C:\\Users".to_string());
+
+        // Act
+        let exports = format_exports(&variable_configs, &variables, &ExportFormat::Fish, true);
+
+        // Assert
+        assert_eq!(exports, "set -gx path 'C:\\\\Users'");
+    }
+
+    #[test]
+    fn format_exports_excludes_sensitive_variables() {
+        // Arrange
+        let (variable_configs, variables) = export_variables();
+
+        // Act
+        let exports = format_exports(&variable_configs, &variables, &ExportFormat::Bash, false);
+
+        // Assert
+        assert!(!exports.contains("password"));
+        assert!(exports.contains("export name='Dingus'"));
+    }
+
+    #[test]
+    fn mask_sensitive_variables_obscures_sensitive_values() {
+        // Arrange
+        let (variable_configs, variables) = export_variables();
+        let sensitive_variable_names = sensitive_variable_names(&variable_configs);
+
+        // Act
+        let masked_variables = mask_sensitive_variables(&variables, &sensitive_variable_names);
+
+        // Assert
+        assert_eq!(sensitive_variable_names, vec!["password".to_string()]);
+        assert_eq!(masked_variables["password"], SENSITIVE_VALUE_MASK);
+        assert_eq!(masked_variables["name"], "Dingus");
+    }
+
+    fn export_variables() -> (VariableConfigMap, VariableMap) {
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            "name".to_string(),
+            VariableConfig::ShorthandLiteral("Dingus".to_string()),
+        );
+        variable_configs.insert(
+            "greeting".to_string(),
+            VariableConfig::Literal(LiteralVariableConfig {
+                description: None,
+                argument: None,
+                environment_variable_name: Some("GREETING".to_string()),
+                value: "It's $name \"the\" dingus".to_string(),
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+        variable_configs.insert(
+            "password".to_string(),
+            Prompt(PromptVariableConfig {
+                argument: None,
+                environment_variable_name: None,
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
+                    message: "Enter your password".to_string(),
+                    options: PromptOptionsVariant::Text(TextPromptOptions {
+                        multi_line: false,
+                        sensitive: true,
+                        validate_with: None,
+                        max_attempts: None,
+                        trim: false,
+                        case: None,
+                        confirm: false,
+                        validate: vec![],
+                        prompt_symbol: None,
+                        max_length: None,
+                    }),
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+        variables.insert(
+            "GREETING".to_string(),
+            "It's $name \"the\" dingus".to_string(),
+        );
+        variables.insert("password".to_string(), "hunter2".to_string());
+
+        return (variable_configs, variables);
+    }
+
+    #[test]
+    fn format_answer_includes_value() {
+        // Act
+        let result = format_answer("name", "Dingus", false);
+
+        // Assert
+        assert_eq!(result, "name: Dingus");
+    }
+
+    #[test]
+    fn format_answer_obscures_sensitive_value() {
+        // Act
+        let result = format_answer("password", "hunter2", true);
+
+        // Assert
+        assert_eq!(result, "password: ********");
+    }
+
+    #[test]
+    fn summarise_variables_lists_variables_and_obscures_sensitive_values() {
+        // Arrange
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            "name".to_string(),
+            VariableConfig::ShorthandLiteral("Dingus".to_string()),
+        );
+        variable_configs.insert(
+            "password".to_string(),
+            Prompt(PromptVariableConfig {
+                argument: None,
+                environment_variable_name: None,
+                prompt: PromptConfigVariant::PromptConfig(PromptConfig {
+                    message: "Enter your password".to_string(),
+                    options: PromptOptionsVariant::Text(TextPromptOptions {
+                        multi_line: false,
+                        sensitive: true,
+                        validate_with: None,
+                        max_attempts: None,
+                        trim: false,
+                        case: None,
+                        confirm: false,
+                        validate: vec![],
+                        prompt_symbol: None,
+                        max_length: None,
+                    }),
+                    ..Default::default()
+                }),
+                options: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+        variables.insert("password".to_string(), "hunter2".to_string());
+        variables.insert("PATH".to_string(), "/usr/bin".to_string());
+
+        // Act
+        let summary = summarise_variables(&variable_configs, &variables);
+
+        // Assert
+        assert_eq!(summary, "name      Dingus\npassword  ********");
+    }
+
+    #[test]
+    fn find_variable_references_finds_references_in_expansions() {
+        // Arrange
+        let template = "${#name} ${path#$root/} ${tag:+--tag=$tag}";
+
+        // Act
+        let result = find_variable_references(template);
+
+        // Assert
+        assert_eq!(
+            result,
+            vec![
+                "name".to_string(),
+                "path".to_string(),
+                "root".to_string(),
+                "tag".to_string()
+            ]
+        )
+    }
+
+    #[test]
+    fn find_variable_references_finds_references() {
+        // Arrange
+        let template = "Hello, $first_name ${last_name}! You are \\$age, $first_name.";
+
+        // Act
+        let result = find_variable_references(template);
+
+        // Assert
+        assert_eq!(
+            result,
+            vec!["first_name".to_string(), "last_name".to_string()]
+        )
+    }
+
+    #[test]
+    fn expand_variables_expands_references_on_second_pass() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+        variables.insert("greeting".to_string(), "Hello, $name!".to_string());
+
+        // Act
+        let mut single_pass_variables = variables.clone();
+        expand_variables(&mut single_pass_variables, 1);
+        expand_variables(&mut variables, 2);
+
+        // Assert
+        assert_eq!(
+            single_pass_variables.get("greeting").unwrap(),
+            "Hello, $name!"
+        );
+        assert_eq!(variables.get("greeting").unwrap(), "Hello, Dingus!");
+    }
+
+    #[test]
+    fn expand_variables_expands_nested_references() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("first".to_string(), "Dingus".to_string());
+        variables.insert("second".to_string(), "$first".to_string());
+        variables.insert("third".to_string(), "$second".to_string());
+
+        // Act
+        expand_variables(&mut variables, 3);
+
+        // Assert
+        assert_eq!(variables.get("third").unwrap(), "Dingus");
+    }
+
+    #[test]
+    fn expand_variables_caps_render_passes() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("echo".to_string(), "x$echo".to_string());
+
+        // Act
+        expand_variables(&mut variables, u32::MAX);
+
+        // Assert
+        // The first pass is the command itself, so values are expanded at most 9 times
+        let expected = format!("{}$echo", "x".repeat(2usize.pow(MAX_RENDER_PASSES - 1)));
+        assert_eq!(variables.get("echo").unwrap(), &expected);
+    }
+
+    #[test]
+    fn expand_variables_stops_before_values_grow_too_large() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("echo".to_string(), "$echo$echo".to_string());
+
+        // Act
+        expand_variables(&mut variables, u32::MAX);
+
+        // Assert
+        let value = variables.get("echo").unwrap();
+        assert!(value.len() <= MAX_EXPANDED_VALUE_LENGTH);
+        assert_eq!(value.len(), "$echo".len() * 256);
+    }
+
+    #[test]
+    fn substitute_variables_substitutes_variables() {
+        // Arrange
+        let template = "Hello, $name! You are $age years old.";
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+        variables.insert("age".to_string(), "100".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "Hello, Dingus! You are 100 years old.")
+    }
+
+    #[test]
+    fn substitute_variables_ignores_escaped() {
+        // Arrange
+        let template = "Hello, $name! You are \\$age years old.";
         let mut variables = VariableMap::new();
         variables.insert("name".to_string(), "Dingus".to_string());
         variables.insert("age".to_string(), "100".to_string());
@@ -554,6 +3047,30 @@ mod tests {
         assert_eq!(result, "Hello, Dingus Bingus!")
     }
 
+    #[test]
+    fn apply_environment_substitutes_variables() {
+        // Arrange
+        let mut variables = VariableMap::new();
+        variables.insert("host".to_string(), "example.com".to_string());
+        variables.insert("API_URL".to_string(), "http://localhost".to_string());
+
+        let mut root_environment = LinkedHashMap::new();
+        root_environment.insert("API_URL".to_string(), "https://$host".to_string());
+        root_environment.insert("REGION".to_string(), "us-east-1".to_string());
+
+        let mut command_environment = LinkedHashMap::new();
+        command_environment.insert("REGION".to_string(), "ap-southeast-2".to_string());
+
+        // Act
+        apply_environment(&root_environment, &mut variables);
+        apply_environment(&command_environment, &mut variables);
+
+        // Assert
+        assert_eq!(variables.get("API_URL").unwrap(), "https://example.com");
+        assert_eq!(variables.get("REGION").unwrap(), "ap-southeast-2");
+        assert_eq!(variables.get("host").unwrap(), "example.com");
+    }
+
     #[test]
     fn substitute_variables_allows_adjacent() {
         // Arrange
@@ -583,4 +3100,253 @@ mod tests {
         // Assert
         assert_eq!(result, "Hello, Dingus-the-Bingus!")
     }
+
+    #[test]
+    fn substitute_variables_substitutes_braced_variables() {
+        // Arrange
+        let template = "${name}_backup ${unknown}";
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "Dingus_backup ${unknown}")
+    }
+
+    #[test]
+    fn find_missing_variables_finds_unset_variables() {
+        // Arrange
+        env::set_var("DINGUS_TEST_MISSING_SET", "set");
+        let template = "echo $name $unknown ${other} ${unknown^^} ${optional:-default} $DINGUS_TEST_MISSING_SET ${#length}";
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        // Act
+        let missing = find_missing_variables(template, &variables);
+
+        // Assert
+        assert_eq!(missing, vec!["unknown", "other", "length"]);
+    }
+
+    #[test]
+    fn substitute_variables_includes_fragment_when_variable_is_set() {
+        // Arrange
+        let template = "cargo build${release:+ --release}${verbose:+ --verbose}";
+        let mut variables = VariableMap::new();
+        variables.insert("release".to_string(), "true".to_string());
+        variables.insert("verbose".to_string(), "".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "cargo build --release")
+    }
+
+    #[test]
+    fn substitute_variables_excludes_fragment_when_variable_is_unset() {
+        // Arrange
+        let template = "deploy${DINGUS_TEST_UNSET_VARIABLE:+ --force}";
+        let variables = VariableMap::new();
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "deploy")
+    }
+
+    #[test]
+    fn substitute_variables_substitutes_variables_within_fragments() {
+        // Arrange
+        let template = "docker run ${tag:+--tag=$name:$tag}";
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "dingus".to_string());
+        variables.insert("tag".to_string(), "latest".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "docker run --tag=dingus:latest")
+    }
+
+    #[test]
+    fn substitute_variables_uses_default_when_variable_is_empty() {
+        // Arrange
+        let template = "${environment:-development} ${region:-us-east-1} ${profile-default}";
+        let mut variables = VariableMap::new();
+        variables.insert("environment".to_string(), "".to_string());
+        variables.insert("region".to_string(), "ap-southeast-2".to_string());
+        variables.insert("profile".to_string(), "".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "development ap-southeast-2 ")
+    }
+
+    #[test]
+    fn substitute_variables_converts_case() {
+        // Arrange
+        let template = "${name^^} ${name,,} ${environment^} ${shout,}";
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+        variables.insert("environment".to_string(), "production".to_string());
+        variables.insert("shout".to_string(), "HELLO".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "DINGUS dingus Production hELLO")
+    }
+
+    #[test]
+    fn substitute_variables_removes_prefixes_and_suffixes() {
+        // Arrange
+        let template = "${tag#v} ${file%.tar.gz} ${path##$root/} ${tag%-rc}";
+        let mut variables = VariableMap::new();
+        variables.insert("tag".to_string(), "v1.2.3".to_string());
+        variables.insert("file".to_string(), "dingus.tar.gz".to_string());
+        variables.insert("root".to_string(), "/home/dingus".to_string());
+        variables.insert("path".to_string(), "/home/dingus/notes.txt".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "1.2.3 dingus notes.txt v1.2.3")
+    }
+
+    #[test]
+    fn substitute_variables_replaces_text() {
+        // Arrange
+        let template = "${branch/-/_} ${branch//-/_} ${branch//} ${words/ /}";
+        let mut variables = VariableMap::new();
+        variables.insert("branch".to_string(), "feature-a-b".to_string());
+        variables.insert("words".to_string(), "a b c".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "feature_a-b feature_a_b feature-a-b ab c")
+    }
+
+    #[test]
+    fn substitute_variables_substitutes_length() {
+        // Arrange
+        let template = "${#name} ${#unknown}";
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "6 ${#unknown}")
+    }
+
+    #[test]
+    fn substitute_variables_falls_back_to_environment_variables() {
+        // Arrange
+        env::set_var("DINGUS_TEST_HOME", "/home/dingus");
+        env::set_var("DINGUS_TEST_SHADOWED", "environment");
+        env::remove_var("DINGUS_TEST_UNSET_DIRECTORY");
+        let template = "$DINGUS_TEST_HOME/.config ${DINGUS_TEST_SHADOWED} $DINGUS_TEST_UNSET_DIRECTORY \"${DINGUS_TEST_UNSET_DIRECTORY:-}\"";
+        let mut variables = VariableMap::new();
+        variables.insert("DINGUS_TEST_SHADOWED".to_string(), "variable".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(
+            result,
+            "/home/dingus/.config variable $DINGUS_TEST_UNSET_DIRECTORY \"\""
+        )
+    }
+
+    #[test]
+    fn substitute_variables_leaves_unsupported_expressions() {
+        // Arrange
+        let template = "${name@Q} ${unterminated";
+        let mut variables = VariableMap::new();
+        variables.insert("name".to_string(), "Dingus".to_string());
+
+        // Act
+        let result = substitute_variables(template, &variables);
+
+        // Assert
+        assert_eq!(result, "${name@Q} ${unterminated")
+    }
+
+    fn prompt_variable(message: &str) -> VariableConfig {
+        return Prompt(PromptVariableConfig {
+            argument: None,
+            environment_variable_name: None,
+            prompt: PromptConfigVariant::PromptConfig(PromptConfig {
+                message: message.to_string(),
+                options: Default::default(),
+                ..Default::default()
+            }),
+            options: None,
+            description: None,
+            validate: vec![],
+            from_env: None,
+            when: None,
+        });
+    }
+
+    fn required_when_variable_resolver(provider: &'static str) -> RealVariableResolver {
+        let mut argument_resolver = MockArgumentResolver::new();
+        argument_resolver
+            .expect_get()
+            .returning(move |key| match key.as_str() {
+                "provider" => Some(provider.to_string()),
+                _ => None,
+            });
+
+        return RealVariableResolver {
+            command_executor: Box::new(MockCommandExecutor::new()),
+            prompt_executor: Box::new(MockPromptExecutor::new()),
+            argument_resolver: Box::new(argument_resolver),
+            keyring: Box::new(MockKeyring::new()),
+            dingus_options: Default::default(),
+        };
+    }
+
+    fn required_when_variable_configs() -> VariableConfigMap {
+        let mut variable_configs = VariableConfigMap::new();
+        variable_configs.insert(
+            "provider".to_string(),
+            VariableConfig::Literal(LiteralVariableConfig {
+                value: "gcp".to_string(),
+                argument: Some(ArgumentConfigVariant::Shorthand("provider".to_string())),
+                environment_variable_name: None,
+                description: None,
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+        variable_configs.insert(
+            "key".to_string(),
+            VariableConfig::Argument(ArgumentVariableConfig {
+                argument: ArgumentConfigVariant::Shorthand("key".to_string()),
+                environment_variable_name: None,
+                description: None,
+                required_when: Some(HashMap::from([("provider".to_string(), "aws".to_string())])),
+                validate: vec![],
+                from_env: None,
+                when: None,
+            }),
+        );
+
+        return variable_configs;
+    }
 }